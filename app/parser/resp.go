@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -14,35 +17,67 @@ const (
 	Bulk    = '$'
 	Array   = '*'
 	Error   = '-'
+
+	// RESP3 types
+	Map       = '%'
+	Set       = '~'
+	Double    = ','
+	Boolean   = '#'
+	Null      = '_'
+	BigNumber = '('
+	Verbatim  = '='
+	Attribute = '|'
+	Push      = '>'
+)
+
+const (
+	// MaxInlineSize is the longest inline command accepted before the newline.
+	MaxInlineSize = 64 * 1024
+	// MaxMultibulkLen is the largest number of arguments a command may have,
+	// the limit Redis applies before a client authenticates.
+	MaxMultibulkLen = 1024 * 1024
+	// DefaultMaxBulkLen is the default limit on the size of a single argument.
+	DefaultMaxBulkLen = 512 * 1024 * 1024
 )
 
-var ErrInvalidArrayAst = errors.New("invalid array, expected *")
 var ErrInvalidArrayCRLF = errors.New("invalid array, expected \\r\\n")
 var ErrIncomplete = errors.New("incomplete command")
+var ErrUnbalancedQuotes = errors.New("Protocol error: unbalanced quotes in request")
+var ErrInlineTooBig = errors.New("Protocol error: too big inline request")
+var ErrInvalidMultibulkLen = errors.New("Protocol error: invalid multibulk length")
+var ErrInvalidBulkLen = errors.New("Protocol error: invalid bulk length")
 
+// ParseCommand parses the first command in packet, either as a RESP array of
+// bulk strings or, when packet doesn't start with '*', as an inline command.
+// It returns the command arguments and the unconsumed remainder of packet.
+// The arguments alias packet.
 func ParseCommand(packet []byte) ([][]byte, []byte, error) {
+	return ParseCommandLimit(packet, DefaultMaxBulkLen)
+}
+
+// ParseCommandLimit is like ParseCommand but rejects arguments longer than
+// maxBulkLen bytes.
+func ParseCommandLimit(packet []byte, maxBulkLen int64) ([][]byte, []byte, error) {
 	if len(packet) == 0 {
 		return nil, nil, nil
 	}
 	if packet[0] != Array {
-		return nil, packet, ErrInvalidArrayAst
+		return parseInlineCommand(packet)
 	}
-	args := make([][]byte, 0)
+	var args [][]byte
 	for i := 1; i < len(packet); i++ {
 		if packet[i] == '\n' {
 			if packet[i-1] != '\r' {
 				return nil, packet, ErrInvalidArrayCRLF
 			}
 			count, err := strconv.Atoi(string(packet[1 : i-1]))
-			if err != nil {
-				return nil, packet, errors.New("invalid bulk count: '" + string(packet[1:i-1]) + "' - " + err.Error())
-			}
-			if count < 0 {
-				return nil, packet, errors.New("invalid array length: negative number not allowed")
+			if err != nil || count < 0 || count > MaxMultibulkLen {
+				return nil, packet, ErrInvalidMultibulkLen
 			}
 			if count == 0 {
-				return nil, packet[i:], nil
+				return nil, packet[i+1:], nil
 			}
+			args = make([][]byte, 0, min(count, 1024))
 			i++
 		nextArg:
 			for j := 0; j < count; j++ {
@@ -50,7 +85,7 @@ func ParseCommand(packet []byte) ([][]byte, []byte, error) {
 					return nil, packet, ErrIncomplete
 				}
 				if packet[i] != '$' {
-					return nil, packet, errors.New("expected '$', got '" + string(packet[i]) + "'")
+					return nil, packet, fmt.Errorf("Protocol error: expected '$', got '%c'", packet[i])
 				}
 				for s := i + 1; i < len(packet); i++ {
 					if packet[i] == '\n' {
@@ -58,8 +93,8 @@ func ParseCommand(packet []byte) ([][]byte, []byte, error) {
 							return nil, packet, ErrInvalidArrayCRLF
 						}
 						n, err := strconv.Atoi(string(packet[s : i-1]))
-						if err != nil || count < 0 {
-							return nil, packet, errors.New("Invalid bulk count: '" + string(packet[1:i-1]) + "' - " + err.Error())
+						if err != nil || n < 0 || int64(n) > maxBulkLen {
+							return nil, packet, ErrInvalidBulkLen
 						}
 						i++
 						if len(packet)-i >= n+2 {
@@ -83,6 +118,136 @@ func ParseCommand(packet []byte) ([][]byte, []byte, error) {
 	return nil, packet, ErrIncomplete
 }
 
+// parseInlineCommand parses a newline terminated command whose arguments are
+// separated by spaces, as typed into telnet. Arguments may be quoted.
+func parseInlineCommand(packet []byte) ([][]byte, []byte, error) {
+	end := bytes.IndexByte(packet, '\n')
+	if end == -1 {
+		if len(packet) > MaxInlineSize {
+			return nil, packet, ErrInlineTooBig
+		}
+		return nil, packet, ErrIncomplete
+	}
+	line := packet[:end]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	args, err := splitArgs(line)
+	if err != nil {
+		return nil, packet, err
+	}
+	return args, packet[end+1:], nil
+}
+
+// splitArgs splits line into arguments the way redis-cli and the inline
+// protocol do: double quoted arguments support the \n, \r, \t, \b, \a and
+// \xHH escapes, single quoted arguments only support \'.
+func splitArgs(line []byte) ([][]byte, error) {
+	var args [][]byte
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return args, nil
+		}
+
+		current := []byte{}
+		inQuotes, inSingleQuotes, done := false, false, false
+		for !done {
+			switch {
+			case inQuotes:
+				if i >= len(line) {
+					return nil, ErrUnbalancedQuotes
+				}
+				if line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHexDigit(line[i+2]) && isHexDigit(line[i+3]) {
+					current = append(current, hexDigitValue(line[i+2])<<4|hexDigitValue(line[i+3]))
+					i += 3
+				} else if line[i] == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						current = append(current, '\n')
+					case 'r':
+						current = append(current, '\r')
+					case 't':
+						current = append(current, '\t')
+					case 'b':
+						current = append(current, '\b')
+					case 'a':
+						current = append(current, '\a')
+					default:
+						current = append(current, line[i])
+					}
+				} else if line[i] == '"' {
+					// The closing quote must be followed by a space or
+					// nothing at all.
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				} else {
+					current = append(current, line[i])
+				}
+			case inSingleQuotes:
+				if i >= len(line) {
+					return nil, ErrUnbalancedQuotes
+				}
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					current = append(current, '\'')
+				} else if line[i] == '\'' {
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				} else {
+					current = append(current, line[i])
+				}
+			default:
+				if i >= len(line) {
+					done = true
+					break
+				}
+				switch line[i] {
+				case ' ', '\n', '\r', '\t', 0:
+					done = true
+				case '"':
+					inQuotes = true
+				case '\'':
+					inSingleQuotes = true
+				default:
+					current = append(current, line[i])
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		args = append(args, current)
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexDigitValue(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
 // appendPrefix will append a "$3\r\n" style redis prefix for a message.
 func appendPrefix(b []byte, c byte, n int64) []byte {
 	if n >= 0 && n <= 9 {
@@ -117,6 +282,15 @@ func AppendBulk(b []byte, bulk []byte) []byte {
 	return append(b, '\r', '\n')
 }
 
+// AppendBulkArray appends an array of bulk byte slices to the input bytes.
+func AppendBulkArray(b []byte, bulks [][]byte) []byte {
+	b = AppendArray(b, len(bulks))
+	for _, bulk := range bulks {
+		b = AppendBulk(b, bulk)
+	}
+	return b
+}
+
 // AppendBulkString appends a Redis protocol bulk string to the input bytes.
 func AppendBulkString(b []byte, bulk string) []byte {
 	b = appendPrefix(b, '$', int64(len(bulk)))
@@ -138,6 +312,78 @@ func AppendError(b []byte, s string) []byte {
 	return append(b, '\r', '\n')
 }
 
+// AppendMap appends a RESP3 map header for n key-value pairs to the input bytes.
+func AppendMap(b []byte, n int) []byte {
+	return appendPrefix(b, Map, int64(n))
+}
+
+// AppendSet appends a RESP3 set header for n elements to the input bytes.
+func AppendSet(b []byte, n int) []byte {
+	return appendPrefix(b, Set, int64(n))
+}
+
+// AppendAttribute appends a RESP3 attribute header for n key-value pairs to
+// the input bytes. The attributed reply must follow the attribute pairs.
+func AppendAttribute(b []byte, n int) []byte {
+	return appendPrefix(b, Attribute, int64(n))
+}
+
+// AppendPush appends a RESP3 push header for n elements to the input bytes.
+func AppendPush(b []byte, n int) []byte {
+	return appendPrefix(b, Push, int64(n))
+}
+
+// AppendDouble appends a RESP3 double to the input bytes.
+func AppendDouble(b []byte, f float64) []byte {
+	b = append(b, Double)
+	b = append(b, FormatDouble(f)...)
+	return append(b, '\r', '\n')
+}
+
+// AppendBool appends a RESP3 boolean to the input bytes.
+func AppendBool(b []byte, t bool) []byte {
+	if t {
+		return append(b, '#', 't', '\r', '\n')
+	}
+	return append(b, '#', 'f', '\r', '\n')
+}
+
+// AppendNull appends a RESP3 null to the input bytes.
+func AppendNull(b []byte) []byte {
+	return append(b, '_', '\r', '\n')
+}
+
+// AppendBigNumber appends a RESP3 big number, given in its decimal
+// representation, to the input bytes.
+func AppendBigNumber(b []byte, n string) []byte {
+	b = append(b, BigNumber)
+	b = append(b, n...)
+	return append(b, '\r', '\n')
+}
+
+// AppendVerbatim appends a RESP3 verbatim string with the three letter format
+// (such as "txt" or "mkd") to the input bytes.
+func AppendVerbatim(b []byte, format string, s []byte) []byte {
+	b = appendPrefix(b, Verbatim, int64(len(s)+4))
+	b = append(b, format...)
+	b = append(b, ':')
+	b = append(b, s...)
+	return append(b, '\r', '\n')
+}
+
+// FormatDouble formats f the way Redis replies with doubles.
+func FormatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
 // AppendOK appends a Redis protocol OK to the input bytes.
 func OK() []byte {
 	return []byte("+OK\r\n")
@@ -3,6 +3,7 @@ package parser
 import (
 	"bytes"
 	"errors"
+	"math"
 	"testing"
 )
 
@@ -20,10 +21,49 @@ func TestParseCommand(t *testing.T) {
 			wantErr: nil,
 		},
 		{
-			name:    "invalid array start",
-			input:   []byte("+invalid\r\n"),
-			want:    nil,
-			wantErr: ErrInvalidArrayAst,
+			name:  "inline command",
+			input: []byte("+invalid\r\n"),
+			want:  [][]byte{[]byte("+invalid")},
+		},
+		{
+			name:  "inline command with arguments",
+			input: []byte("SET  foo\tbar\n"),
+			want:  [][]byte{[]byte("SET"), []byte("foo"), []byte("bar")},
+		},
+		{
+			name:  "inline command with quoted arguments",
+			input: []byte("SET foo \"bar baz\" 'it\\'s'\r\n"),
+			want:  [][]byte{[]byte("SET"), []byte("foo"), []byte("bar baz"), []byte("it's")},
+		},
+		{
+			name:  "inline command with escapes",
+			input: []byte("ECHO \"a\\tb\\x41\\\"\"\r\n"),
+			want:  [][]byte{[]byte("ECHO"), []byte("a\tbA\"")},
+		},
+		{
+			name:  "empty inline command",
+			input: []byte("\r\n"),
+			want:  nil,
+		},
+		{
+			name:    "inline command with unbalanced quotes",
+			input:   []byte("SET foo \"bar\r\n"),
+			wantErr: ErrUnbalancedQuotes,
+		},
+		{
+			name:    "inline command with text after closing quote",
+			input:   []byte("SET foo \"bar\"baz\r\n"),
+			wantErr: ErrUnbalancedQuotes,
+		},
+		{
+			name:    "incomplete inline command",
+			input:   []byte("PIN"),
+			wantErr: ErrIncomplete,
+		},
+		{
+			name:    "inline command too big",
+			input:   bytes.Repeat([]byte("a"), MaxInlineSize+1),
+			wantErr: ErrInlineTooBig,
 		},
 		{
 			name:    "zero length array",
@@ -55,13 +95,28 @@ func TestParseCommand(t *testing.T) {
 			name:    "invalid bulk string marker",
 			input:   []byte("*1\r\n#4\r\nPING\r\n"),
 			want:    nil,
-			wantErr: errors.New("expected '$', got '#'"),
+			wantErr: errors.New("Protocol error: expected '$', got '#'"),
 		},
 		{
 			name:    "invalid array length",
 			input:   []byte("*-1\r\n"),
 			want:    nil,
-			wantErr: errors.New("invalid array length: negative number not allowed"),
+			wantErr: ErrInvalidMultibulkLen,
+		},
+		{
+			name:    "non-numeric array length",
+			input:   []byte("*x\r\n"),
+			wantErr: ErrInvalidMultibulkLen,
+		},
+		{
+			name:    "array length over limit",
+			input:   []byte("*1048577\r\n"),
+			wantErr: ErrInvalidMultibulkLen,
+		},
+		{
+			name:    "non-numeric bulk length",
+			input:   []byte("*1\r\n$x\r\n"),
+			wantErr: ErrInvalidBulkLen,
 		},
 		{
 			name:    "incomplete array length",
@@ -81,6 +136,16 @@ func TestParseCommand(t *testing.T) {
 			want:    nil,
 			wantErr: ErrIncomplete,
 		},
+		{
+			name:    "negative bulk length",
+			input:   []byte("*1\r\n$-1\r\n"),
+			wantErr: ErrInvalidBulkLen,
+		},
+		{
+			name:    "bulk length over limit",
+			input:   []byte("*1\r\n$17\r\n"),
+			wantErr: ErrInvalidBulkLen,
+		},
 		{
 			name:    "partial second argument",
 			input:   []byte("*2\r\n$4\r\nPING\r\n$3\r\nfo"),
@@ -91,7 +156,7 @@ func TestParseCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, _, err := ParseCommand(tt.input)
+			got, _, err := ParseCommandLimit(tt.input, 16)
 
 			// Check error
 			if tt.wantErr != nil {
@@ -116,6 +181,28 @@ func TestParseCommand(t *testing.T) {
 	}
 }
 
+func TestParseCommandPipeline(t *testing.T) {
+	packet := []byte("*0\r\n*1\r\n$4\r\nPING\r\nECHO hi\r\n*2\r\n$3\r\nGET\r\n$1\r\nk")
+	want := [][][]byte{
+		nil,
+		{[]byte("PING")},
+		{[]byte("ECHO"), []byte("hi")},
+	}
+	for i, w := range want {
+		got, remainder, err := ParseCommand(packet)
+		if err != nil {
+			t.Fatalf("command %d: unexpected error %v", i, err)
+		}
+		if !compareByteSlices(got, w) {
+			t.Errorf("command %d: got %q, want %q", i, got, w)
+		}
+		packet = remainder
+	}
+	if _, remainder, err := ParseCommand(packet); err != ErrIncomplete || len(remainder) != len(packet) {
+		t.Errorf("expected incomplete trailing command, got err=%v remainder=%q", err, remainder)
+	}
+}
+
 // Helper function to compare two [][]byte
 func compareByteSlices(a, b [][]byte) bool {
 	if len(a) != len(b) {
@@ -128,3 +215,33 @@ func compareByteSlices(a, b [][]byte) bool {
 	}
 	return true
 }
+
+func TestAppendRESP3(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"map", AppendBulkString(AppendBulkString(AppendMap(nil, 1), "k"), "v"), "%1\r\n$1\r\nk\r\n$1\r\nv\r\n"},
+		{"set", AppendInt(AppendSet(nil, 1), 7), "~1\r\n:7\r\n"},
+		{"double", AppendDouble(nil, 3.5), ",3.5\r\n"},
+		{"double integral", AppendDouble(nil, 10), ",10\r\n"},
+		{"double inf", AppendDouble(nil, math.Inf(-1)), ",-inf\r\n"},
+		{"double nan", AppendDouble(nil, math.NaN()), ",nan\r\n"},
+		{"true", AppendBool(nil, true), "#t\r\n"},
+		{"false", AppendBool(nil, false), "#f\r\n"},
+		{"null", AppendNull(nil), "_\r\n"},
+		{"big number", AppendBigNumber(nil, "3492890328409238509324850943850943825024385"), "(3492890328409238509324850943850943825024385\r\n"},
+		{"verbatim", AppendVerbatim(nil, "txt", []byte("Some string")), "=15\r\ntxt:Some string\r\n"},
+		{"attribute", AppendInt(AppendBulkString(AppendAttribute(nil, 1), "ttl"), 3), "|1\r\n$3\r\nttl\r\n:3\r\n"},
+		{"push", AppendBulkString(AppendPush(nil, 1), "message"), ">1\r\n$7\r\nmessage\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if string(tt.got) != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -6,12 +6,55 @@ import (
 	"io"
 )
 
+// ValueType is the RDB type flag that precedes a key-value pair.
+type ValueType byte
+
+const (
+	StringType ValueType = 0x00
+	ListType   ValueType = 0x01
+	SetType    ValueType = 0x02
+	HashType   ValueType = 0x04
+	// ZSetType is a sorted set with binary scores, ZSET_2 in Redis.
+	ZSetType ValueType = 0x05
+	// HashMetadataType is a hash in which some fields have an expiry.
+	HashMetadataType ValueType = 0x18
+	// StreamType is a stream and its consumer groups, STREAM_LISTPACKS_3 in
+	// Redis. Streams saved by older versions in StreamListpacksType or
+	// StreamListpacks2Type can be read as well.
+	StreamType           ValueType = 0x15
+	StreamListpacksType  ValueType = 0x0F
+	StreamListpacks2Type ValueType = 0x13
+)
+
+// Entry is a key-value pair of a database. Value holds the payload of
+// strings, List the elements of lists or the members of sets, Hash the
+// fields of hashes, ZSet the members of sorted sets and Stream the content of
+// streams.
 type Entry struct {
 	Key     string
+	Type    ValueType
 	Value   string
+	List    []string
+	Hash    []HashField
+	ZSet    []ZSetMember
+	Stream  *Stream
 	Expires *int64
 }
 
+// ZSetMember is a member of a sorted set and its score.
+type ZSetMember struct {
+	Member string
+	Score  float64
+}
+
+// HashField is a field of a hash. Expires is the unix time in milliseconds
+// at which the field expires, or 0 if it has no expiry.
+type HashField struct {
+	Field   string
+	Value   string
+	Expires int64
+}
+
 func ReadKeyValue(r io.Reader) (Entry, error) {
 	entry := Entry{}
 
@@ -33,8 +76,11 @@ func ReadKeyValue(r io.Reader) (Entry, error) {
 		}
 	}
 
-	// Read the value type (only handling 0x00 for strings here)
-	if b != 0x00 {
+	entry.Type = ValueType(b)
+	switch entry.Type {
+	case StringType, ListType, SetType, HashType, HashMetadataType, ZSetType:
+	case StreamType, StreamListpacksType, StreamListpacks2Type:
+	default:
 		return entry, fmt.Errorf("unsupported value type: %x", b)
 	}
 
@@ -46,15 +92,113 @@ func ReadKeyValue(r io.Reader) (Entry, error) {
 	entry.Key = key
 
 	// Read the value
-	value, err := ReadString(r)
-	if err != nil {
-		return entry, err
+	switch entry.Type {
+	case StringType:
+		value, err := ReadString(r)
+		if err != nil {
+			return entry, err
+		}
+		entry.Value = value
+	case ListType, SetType:
+		list, err := readStringList(r)
+		if err != nil {
+			return entry, err
+		}
+		entry.List = list
+	case HashType, HashMetadataType:
+		hash, err := readHash(r, entry.Type == HashMetadataType)
+		if err != nil {
+			return entry, err
+		}
+		entry.Hash = hash
+	case ZSetType:
+		zset, err := readZSet(r)
+		if err != nil {
+			return entry, err
+		}
+		entry.ZSet = zset
+	case StreamType, StreamListpacksType, StreamListpacks2Type:
+		stream, err := readStream(r, entry.Type)
+		if err != nil {
+			return entry, err
+		}
+		// Streams are always saved in the latest format.
+		entry.Type = StreamType
+		entry.Stream = stream
 	}
-	entry.Value = value
 
 	return entry, nil
 }
 
+// readZSet reads the members of a sorted set, each followed by its score as
+// a little-endian float64.
+func readZSet(r io.Reader) ([]ZSetMember, error) {
+	size, err := ReadSize(r)
+	if err != nil {
+		return nil, err
+	}
+	zset := make([]ZSetMember, size)
+	for i := range zset {
+		if zset[i].Member, err = ReadString(r); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &zset[i].Score); err != nil {
+			return nil, err
+		}
+	}
+	return zset, nil
+}
+
+// readHash reads the fields of a hash. With metadata, the fields are preceded
+// by the earliest field expiry and each field by its expiry relative to it,
+// plus one, or 0 if it has none.
+func readHash(r io.Reader, metadata bool) ([]HashField, error) {
+	var minExpiry int64
+	if metadata {
+		if err := binary.Read(r, binary.LittleEndian, &minExpiry); err != nil {
+			return nil, err
+		}
+	}
+	size, err := ReadSize(r)
+	if err != nil {
+		return nil, err
+	}
+	hash := make([]HashField, size)
+	for i := range hash {
+		if metadata {
+			ttl, err := ReadSize(r)
+			if err != nil {
+				return nil, err
+			}
+			if ttl != 0 {
+				hash[i].Expires = minExpiry + int64(ttl) - 1
+			}
+		}
+		if hash[i].Field, err = ReadString(r); err != nil {
+			return nil, err
+		}
+		if hash[i].Value, err = ReadString(r); err != nil {
+			return nil, err
+		}
+	}
+	return hash, nil
+}
+
+// readStringList reads a size followed by that many strings.
+func readStringList(r io.Reader) ([]string, error) {
+	size, err := ReadSize(r)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]string, size)
+	for i := range list {
+		if list[i], err = ReadString(r); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
 func WriteKeyValue(w io.Writer, entry Entry) error {
 	// Write expiry if it exists
 	if entry.Expires != nil {
@@ -66,8 +210,8 @@ func WriteKeyValue(w io.Writer, entry Entry) error {
 		}
 	}
 
-	// Write value type (0x00 for string)
-	if err := binary.Write(w, binary.LittleEndian, byte(0x00)); err != nil {
+	// Write value type
+	if err := binary.Write(w, binary.LittleEndian, byte(entry.Type)); err != nil {
 		return err
 	}
 
@@ -75,10 +219,97 @@ func WriteKeyValue(w io.Writer, entry Entry) error {
 	if err := WriteString(w, entry.Key); err != nil {
 		return err
 	}
-	if err := WriteString(w, entry.Value); err != nil {
+	switch entry.Type {
+	case StringType:
+		if err := WriteString(w, entry.Value); err != nil {
+			return err
+		}
+	case ListType, SetType:
+		if err := writeStringList(w, entry.List); err != nil {
+			return err
+		}
+	case HashType, HashMetadataType:
+		if err := writeHash(w, entry.Hash, entry.Type == HashMetadataType); err != nil {
+			return err
+		}
+	case ZSetType:
+		if err := writeZSet(w, entry.ZSet); err != nil {
+			return err
+		}
+	case StreamType:
+		if err := writeStream(w, entry.Stream); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported value type: %x", entry.Type)
+	}
+
+	return nil
+}
+
+// writeStringList writes the size of list followed by its strings.
+func writeStringList(w io.Writer, list []string) error {
+	if err := WriteSize(w, len(list)); err != nil {
 		return err
 	}
+	for _, s := range list {
+		if err := WriteString(w, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// writeHash writes the fields of a hash in the format read by readHash.
+func writeHash(w io.Writer, hash []HashField, metadata bool) error {
+	var minExpiry int64
+	if metadata {
+		for _, field := range hash {
+			if field.Expires != 0 && (minExpiry == 0 || field.Expires < minExpiry) {
+				minExpiry = field.Expires
+			}
+		}
+		if err := binary.Write(w, binary.LittleEndian, minExpiry); err != nil {
+			return err
+		}
+	}
+	if err := WriteSize(w, len(hash)); err != nil {
+		return err
+	}
+	for _, field := range hash {
+		if metadata {
+			var ttl int
+			if field.Expires != 0 {
+				ttl = int(field.Expires-minExpiry) + 1
+			}
+			if err := WriteSize(w, ttl); err != nil {
+				return err
+			}
+		}
+		if err := WriteString(w, field.Field); err != nil {
+			return err
+		}
+		if err := WriteString(w, field.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeZSet writes the members of a sorted set in the format read by
+// readZSet.
+func writeZSet(w io.Writer, zset []ZSetMember) error {
+	if err := WriteSize(w, len(zset)); err != nil {
+		return err
+	}
+	for _, member := range zset {
+		if err := WriteString(w, member.Member); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, member.Score); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
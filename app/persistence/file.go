@@ -20,46 +20,57 @@ const (
 	expireMilliSec   = 0xFC
 	expireSec        = 0xFD
 	databaseStart    = 0xFE
+	functionStart    = 0xF5
 	endOfFileSection = 0xFF
 )
 
-// LoadRDB reads an entire RDB file and returns its entries.
-func LoadRDB(r io.Reader) ([]*Database, error) {
+// LoadRDB reads an entire RDB file and returns its entries and the code of
+// its function libraries.
+func LoadRDB(r io.Reader) ([]*Database, []string, error) {
 	// Verify the header
 	if err := ReadHeader(r); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// Read the metadata section
 	_, r, err := ReadMetadata(r)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Read the database section(s)
+	// Read the function libraries and the database section(s)
 	databases := []*Database{}
+	libraries := []string{}
 	for {
 		startByte := make([]byte, 1)
 		if _, err := r.Read(startByte); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		if startByte[0] == endOfFileSection {
 			break
+		} else if startByte[0] == functionStart {
+			code, err := ReadString(r)
+			if err != nil {
+				return nil, nil, err
+			}
+			libraries = append(libraries, code)
 		} else if startByte[0] == databaseStart {
 			database, err := ReadDatabaseSection(r)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			databases = append(databases, database)
 		} else {
-			return nil, fmt.Errorf("unexpected byte: %x", startByte[0])
+			return nil, nil, fmt.Errorf("unexpected byte: %x", startByte[0])
 		}
 	}
 
-	return databases, nil
+	return databases, libraries, nil
 }
 
-func SaveRDB(dir, dbFilename string, databases []*Database) error {
+// SaveRDB writes the code of the function libraries and then the databases
+// to an RDB file.
+func SaveRDB(dir, dbFilename string, libraries []string, databases []*Database) error {
 	if err := os.MkdirAll(dir, 0750); err != nil {
 		return err
 	}
@@ -72,6 +83,9 @@ func SaveRDB(dir, dbFilename string, databases []*Database) error {
 	if err := WriteHeader(file); err != nil {
 		return err
 	}
+	if err := writeFunctions(file, libraries); err != nil {
+		return err
+	}
 	file.Write([]byte{databaseStart})
 	for _, value := range databases {
 		err := SaveDatabaseSection(file, value)
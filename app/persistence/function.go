@@ -0,0 +1,68 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/crc64"
+)
+
+// dumpVersion is the RDB version written in the footer of FUNCTION DUMP
+// payloads.
+const dumpVersion = 11
+
+var (
+	ErrInvalidDump = errors.New("payload version or checksum are wrong")
+	ErrNotFunction = errors.New("given type is not a function")
+)
+
+// writeFunctions writes the code of each function library after its opcode.
+func writeFunctions(w io.Writer, libraries []string) error {
+	for _, code := range libraries {
+		if _, err := w.Write([]byte{functionStart}); err != nil {
+			return err
+		}
+		if err := WriteString(w, code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DumpFunctions serializes the code of function libraries into a FUNCTION
+// DUMP payload, which ends with the RDB version and a CRC64 checksum.
+func DumpFunctions(libraries []string) []byte {
+	var buf bytes.Buffer
+	writeFunctions(&buf, libraries)
+	binary.Write(&buf, binary.LittleEndian, uint16(dumpVersion))
+	binary.Write(&buf, binary.LittleEndian, crc64.Digest(buf.Bytes()))
+	return buf.Bytes()
+}
+
+// LoadFunctionsDump returns the code of the function libraries serialized in
+// a FUNCTION DUMP payload.
+func LoadFunctionsDump(payload []byte) ([]string, error) {
+	if len(payload) < 10 {
+		return nil, ErrInvalidDump
+	}
+	body, footer := payload[:len(payload)-10], payload[len(payload)-10:]
+	if binary.LittleEndian.Uint16(footer) > dumpVersion ||
+		binary.LittleEndian.Uint64(footer[2:]) != crc64.Digest(payload[:len(payload)-8]) {
+		return nil, ErrInvalidDump
+	}
+	r := bytes.NewReader(body)
+	libraries := []string{}
+	for r.Len() > 0 {
+		if opcode, _ := r.ReadByte(); opcode != functionStart {
+			return nil, ErrNotFunction
+		}
+		code, err := ReadString(r)
+		if err != nil {
+			return nil, ErrInvalidDump
+		}
+		libraries = append(libraries, code)
+	}
+	return libraries, nil
+}
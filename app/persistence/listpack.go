@@ -0,0 +1,192 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// A listpack is a serialized list of strings and integers: a header with its
+// total size in bytes and number of elements, the elements, and an end byte.
+// Each element is an encoding byte that tells its type and size, its data,
+// and the size of the two, which allows walking the list backwards.
+const (
+	listpackHeaderSize = 6
+	listpackEnd        = 0xFF
+)
+
+var errInvalidListpack = errors.New("invalid listpack")
+
+// readListpack returns the elements of a listpack, with integers converted to
+// their decimal representation.
+func readListpack(lp []byte) ([]string, error) {
+	if len(lp) < listpackHeaderSize+1 || int(binary.LittleEndian.Uint32(lp)) != len(lp) {
+		return nil, errInvalidListpack
+	}
+	var elements []string
+	p := lp[listpackHeaderSize:]
+	for len(p) > 0 && p[0] != listpackEnd {
+		element, size, err := readListpackElement(p)
+		if err != nil {
+			return nil, err
+		}
+		size += backlenSize(size)
+		if size > len(p) {
+			return nil, errInvalidListpack
+		}
+		elements = append(elements, element)
+		p = p[size:]
+	}
+	if len(p) != 1 {
+		return nil, errInvalidListpack
+	}
+	return elements, nil
+}
+
+// readListpackElement decodes the element at the start of p and returns it
+// with the size of its encoding and data.
+func readListpackElement(p []byte) (string, int, error) {
+	b := p[0]
+	var value int64
+	var size int
+	switch {
+	case b&0x80 == 0:
+		// 7-bit unsigned integer.
+		return strconv.Itoa(int(b)), 1, nil
+	case b&0xC0 == 0x80:
+		// String of up to 63 bytes.
+		return listpackString(p, 1, int(b&0x3F))
+	case b&0xE0 == 0xC0:
+		// 13-bit signed integer.
+		if len(p) < 2 {
+			return "", 0, errInvalidListpack
+		}
+		value = int64(b&0x1F)<<8 | int64(p[1])
+		if value >= 1<<12 {
+			value -= 1 << 13
+		}
+		return strconv.FormatInt(value, 10), 2, nil
+	case b&0xF0 == 0xE0:
+		// String of up to 4095 bytes.
+		if len(p) < 2 {
+			return "", 0, errInvalidListpack
+		}
+		return listpackString(p, 2, int(b&0x0F)<<8|int(p[1]))
+	case b == 0xF0:
+		if len(p) < 5 {
+			return "", 0, errInvalidListpack
+		}
+		return listpackString(p, 5, int(binary.LittleEndian.Uint32(p[1:])))
+	case b >= 0xF1 && b <= 0xF4:
+		// 16, 24, 32 or 64-bit signed integer.
+		size = map[byte]int{0xF1: 2, 0xF2: 3, 0xF3: 4, 0xF4: 8}[b]
+		if len(p) < 1+size {
+			return "", 0, errInvalidListpack
+		}
+		var u uint64
+		for i := size; i > 0; i-- {
+			u = u<<8 | uint64(p[i])
+		}
+		// Sign-extend the value to 64 bits.
+		shift := 64 - 8*size
+		value = int64(u<<shift) >> shift
+		return strconv.FormatInt(value, 10), 1 + size, nil
+	}
+	return "", 0, errInvalidListpack
+}
+
+func listpackString(p []byte, header, length int) (string, int, error) {
+	if len(p) < header+length {
+		return "", 0, errInvalidListpack
+	}
+	return string(p[header : header+length]), header + length, nil
+}
+
+// backlenSize returns the size of the back length of an element whose
+// encoding and data take size bytes: 7 bits of size per byte.
+func backlenSize(size int) int {
+	switch {
+	case size <= 127:
+		return 1
+	case size < 16383:
+		return 2
+	case size < 2097151:
+		return 3
+	case size < 268435455:
+		return 4
+	}
+	return 5
+}
+
+// appendBacklen appends the back length of an element whose encoding and
+// data take size bytes. It is read from right to left, so the most
+// significant bits come first and every byte but the last read has its high
+// bit set.
+func appendBacklen(b []byte, size int) []byte {
+	n := backlenSize(size)
+	for i := n - 1; i >= 0; i-- {
+		v := byte(size>>(7*i)) & 0x7F
+		if i != n-1 {
+			v |= 0x80
+		}
+		b = append(b, v)
+	}
+	return b
+}
+
+// writeListpack encodes elements as a listpack. Elements that are the
+// canonical representation of an integer are stored as integers.
+func writeListpack(elements []string) []byte {
+	lp := make([]byte, listpackHeaderSize)
+	for _, element := range elements {
+		start := len(lp)
+		if value, err := strconv.ParseInt(element, 10, 64); err == nil && strconv.FormatInt(value, 10) == element {
+			lp = appendListpackInt(lp, value)
+		} else {
+			lp = appendListpackString(lp, element)
+		}
+		lp = appendBacklen(lp, len(lp)-start)
+	}
+	lp = append(lp, listpackEnd)
+	binary.LittleEndian.PutUint32(lp, uint32(len(lp)))
+	// The number of elements saturates at 65535, past which it is counted
+	// when needed.
+	binary.LittleEndian.PutUint16(lp[4:], uint16(min(len(elements), 65535)))
+	return lp
+}
+
+func appendListpackInt(b []byte, value int64) []byte {
+	switch {
+	case value >= 0 && value <= 127:
+		return append(b, byte(value))
+	case value >= -4096 && value <= 4095:
+		return append(b, 0xC0|byte(value>>8)&0x1F, byte(value))
+	}
+	encoding, size := byte(0xF4), 8
+	switch {
+	case value >= -1<<15 && value < 1<<15:
+		encoding, size = 0xF1, 2
+	case value >= -1<<23 && value < 1<<23:
+		encoding, size = 0xF2, 3
+	case value >= -1<<31 && value < 1<<31:
+		encoding, size = 0xF3, 4
+	}
+	b = append(b, encoding)
+	for i := 0; i < size; i++ {
+		b = append(b, byte(value>>(8*i)))
+	}
+	return b
+}
+
+func appendListpackString(b []byte, s string) []byte {
+	switch {
+	case len(s) < 64:
+		b = append(b, 0x80|byte(len(s)))
+	case len(s) < 4096:
+		b = append(b, 0xE0|byte(len(s)>>8), byte(len(s)))
+	default:
+		b = append(b, 0xF0)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+	}
+	return append(b, s...)
+}
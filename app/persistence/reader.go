@@ -24,6 +24,13 @@ func ReadSize(r io.Reader) (int, error) {
 		}
 		return int(b&0x3F)<<8 | int(nextByte), nil
 	case 0b10:
+		if b == 0x81 {
+			var size uint64
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return 0, err
+			}
+			return int(size), nil
+		}
 		var size uint32
 		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
 			return 0, err
@@ -47,6 +54,13 @@ func ReadSize(r io.Reader) (int, error) {
 	}
 }
 
+// readLength decodes a size-encoded integer that may not fit in an int, as
+// the parts of stream IDs.
+func readLength(r io.Reader) (uint64, error) {
+	size, err := ReadSize(r)
+	return uint64(size), err
+}
+
 func ReadHeader(r io.Reader) error {
 	buf := make([]byte, len(header))
 	if _, err := io.ReadFull(r, buf); err != nil {
@@ -65,7 +79,7 @@ func ReadMetadata(r io.Reader) (map[string]string, io.Reader, error) {
 		if err := binary.Read(r, binary.LittleEndian, &startByte); err != nil {
 			return nil, r, err
 		}
-		if startByte == databaseStart || startByte == endOfFileSection {
+		if startByte == databaseStart || startByte == functionStart || startByte == endOfFileSection {
 			r = io.MultiReader(bytes.NewReader([]byte{startByte}), r) // reinsert start byte
 			break
 		} else if startByte == metadataStart {
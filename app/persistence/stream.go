@@ -0,0 +1,492 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// Stream is the content of a stream: its entries in ID order and its consumer
+// groups.
+type Stream struct {
+	Entries []StreamEntry
+	// LastID is the ID of the last entry added, which may have been deleted.
+	LastID StreamID
+	// FirstID is the ID of the first entry, or 0-0 in an empty stream.
+	FirstID StreamID
+	// MaxDeletedID is the largest ID deleted from the stream.
+	MaxDeletedID StreamID
+	// EntriesAdded counts the entries added over the life of the stream.
+	EntriesAdded uint64
+	Groups       []StreamGroup
+}
+
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+// StreamEntry is an entry of a stream, whose fields hold field and value
+// pairs.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// StreamGroup is a consumer group of a stream. EntriesRead is the number of
+// entries the group has read, or -1 if it is not known.
+type StreamGroup struct {
+	Name        string
+	LastID      StreamID
+	EntriesRead int64
+	Pending     []StreamPendingEntry
+	Consumers   []StreamConsumer
+}
+
+// StreamPendingEntry is an entry delivered to a consumer of a group and not
+// acknowledged yet. DeliveryTime is the unix time in milliseconds of its last
+// delivery.
+type StreamPendingEntry struct {
+	ID            StreamID
+	Consumer      string
+	DeliveryTime  int64
+	DeliveryCount uint64
+}
+
+// StreamConsumer is a consumer of a group. SeenTime and ActiveTime are the
+// unix times in milliseconds of its last attempted and successful reads.
+type StreamConsumer struct {
+	Name       string
+	SeenTime   int64
+	ActiveTime int64
+}
+
+// streamNodeMaxEntries is the number of entries stored in each listpack, as
+// set by stream-node-max-entries in Redis.
+const streamNodeMaxEntries = 100
+
+// Flags of the entries of a stream listpack.
+const (
+	streamItemDeleted    = 1
+	streamItemSameFields = 2
+)
+
+func (id StreamID) key() []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, id.Ms)
+	binary.BigEndian.PutUint64(key[8:], id.Seq)
+	return key
+}
+
+func streamIDFromKey(key []byte) StreamID {
+	return StreamID{binary.BigEndian.Uint64(key), binary.BigEndian.Uint64(key[8:])}
+}
+
+// readStream reads a stream. The entries are stored in listpacks, each keyed
+// by the ID of its first entry, the master entry, which holds the fields
+// shared by the entries that follow. The IDs of the entries are relative to
+// it. Older versions of the format lack the fields marked in Stream, the
+// read counters of the groups and the active times of the consumers.
+func readStream(r io.Reader, t ValueType) (*Stream, error) {
+	stream := &Stream{}
+	nodes, err := ReadSize(r)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < nodes; i++ {
+		key, err := ReadString(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != 16 {
+			return nil, fmt.Errorf("invalid stream node key of %d bytes", len(key))
+		}
+		lp, err := ReadString(r)
+		if err != nil {
+			return nil, err
+		}
+		elements, err := readListpack([]byte(lp))
+		if err != nil {
+			return nil, err
+		}
+		entries, err := readStreamNode(streamIDFromKey([]byte(key)), elements)
+		if err != nil {
+			return nil, err
+		}
+		stream.Entries = append(stream.Entries, entries...)
+	}
+
+	// The length is implied by the entries.
+	if _, err := ReadSize(r); err != nil {
+		return nil, err
+	}
+	if stream.LastID, err = readStreamID(r); err != nil {
+		return nil, err
+	}
+	if t == StreamListpacks2Type || t == StreamType {
+		if stream.FirstID, err = readStreamID(r); err != nil {
+			return nil, err
+		}
+		if stream.MaxDeletedID, err = readStreamID(r); err != nil {
+			return nil, err
+		}
+		if stream.EntriesAdded, err = readLength(r); err != nil {
+			return nil, err
+		}
+	} else {
+		if len(stream.Entries) > 0 {
+			stream.FirstID = stream.Entries[0].ID
+		}
+		stream.EntriesAdded = uint64(len(stream.Entries))
+	}
+
+	groups, err := ReadSize(r)
+	if err != nil {
+		return nil, err
+	}
+	stream.Groups = make([]StreamGroup, groups)
+	for i := range stream.Groups {
+		if err := readStreamGroup(r, t, &stream.Groups[i]); err != nil {
+			return nil, err
+		}
+	}
+	return stream, nil
+}
+
+// readStreamNode decodes the entries of a listpack whose master entry has
+// the ID master. Deleted entries are skipped.
+func readStreamNode(master StreamID, elements []string) ([]StreamEntry, error) {
+	next := func() (int64, error) {
+		if len(elements) == 0 {
+			return 0, errInvalidListpack
+		}
+		n, err := strconv.ParseInt(elements[0], 10, 64)
+		elements = elements[1:]
+		return n, err
+	}
+	take := func(n int64) ([]string, error) {
+		if n < 0 || n > int64(len(elements)) {
+			return nil, errInvalidListpack
+		}
+		taken := elements[:n]
+		elements = elements[n:]
+		return taken, nil
+	}
+
+	// The master entry: the counts of valid and deleted entries, the
+	// master fields and a terminator.
+	if _, err := take(2); err != nil {
+		return nil, err
+	}
+	numFields, err := next()
+	if err != nil {
+		return nil, err
+	}
+	masterFields, err := take(numFields)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := take(1); err != nil {
+		return nil, err
+	}
+
+	var entries []StreamEntry
+	for len(elements) > 0 {
+		var header [3]int64
+		for i := range header {
+			if header[i], err = next(); err != nil {
+				return nil, err
+			}
+		}
+		flags := header[0]
+		id := StreamID{master.Ms + uint64(header[1]), master.Seq + uint64(header[2])}
+		var fields []string
+		if flags&streamItemSameFields != 0 {
+			values, err := take(int64(len(masterFields)))
+			if err != nil {
+				return nil, err
+			}
+			fields = make([]string, 0, 2*len(values))
+			for i, value := range values {
+				fields = append(fields, masterFields[i], value)
+			}
+		} else {
+			n, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if fields, err = take(2 * n); err != nil {
+				return nil, err
+			}
+		}
+		// The number of elements of the entry, for walking backwards.
+		if _, err := take(1); err != nil {
+			return nil, err
+		}
+		if flags&streamItemDeleted == 0 {
+			entries = append(entries, StreamEntry{ID: id, Fields: fields})
+		}
+	}
+	return entries, nil
+}
+
+func readStreamGroup(r io.Reader, t ValueType, group *StreamGroup) error {
+	var err error
+	if group.Name, err = ReadString(r); err != nil {
+		return err
+	}
+	if group.LastID, err = readStreamID(r); err != nil {
+		return err
+	}
+	group.EntriesRead = -1
+	if t == StreamListpacks2Type || t == StreamType {
+		entriesRead, err := readLength(r)
+		if err != nil {
+			return err
+		}
+		group.EntriesRead = int64(entriesRead)
+	}
+
+	// The pending entries of the group, whose consumers are given by the
+	// pending IDs of each consumer.
+	size, err := ReadSize(r)
+	if err != nil {
+		return err
+	}
+	group.Pending = make([]StreamPendingEntry, size)
+	pending := make(map[StreamID]*StreamPendingEntry, size)
+	for i := range group.Pending {
+		entry := &group.Pending[i]
+		if entry.ID, err = readRawStreamID(r); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &entry.DeliveryTime); err != nil {
+			return err
+		}
+		if entry.DeliveryCount, err = readLength(r); err != nil {
+			return err
+		}
+		pending[entry.ID] = entry
+	}
+
+	size, err = ReadSize(r)
+	if err != nil {
+		return err
+	}
+	group.Consumers = make([]StreamConsumer, size)
+	for i := range group.Consumers {
+		consumer := &group.Consumers[i]
+		if consumer.Name, err = ReadString(r); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &consumer.SeenTime); err != nil {
+			return err
+		}
+		consumer.ActiveTime = consumer.SeenTime
+		if t == StreamType {
+			if err := binary.Read(r, binary.LittleEndian, &consumer.ActiveTime); err != nil {
+				return err
+			}
+		}
+		ids, err := ReadSize(r)
+		if err != nil {
+			return err
+		}
+		for j := 0; j < ids; j++ {
+			id, err := readRawStreamID(r)
+			if err != nil {
+				return err
+			}
+			entry, ok := pending[id]
+			if !ok {
+				return fmt.Errorf("consumer %s owns entry %d-%d, which is not pending", consumer.Name, id.Ms, id.Seq)
+			}
+			entry.Consumer = consumer.Name
+		}
+	}
+	return nil
+}
+
+// writeStream writes a stream in the format read by readStream.
+func writeStream(w io.Writer, stream *Stream) error {
+	nodes := (len(stream.Entries) + streamNodeMaxEntries - 1) / streamNodeMaxEntries
+	if err := WriteSize(w, nodes); err != nil {
+		return err
+	}
+	for entries := stream.Entries; len(entries) > 0; {
+		n := min(len(entries), streamNodeMaxEntries)
+		master := entries[0].ID
+		if err := WriteString(w, string(master.key())); err != nil {
+			return err
+		}
+		if err := WriteString(w, string(writeListpack(streamNodeElements(entries[:n])))); err != nil {
+			return err
+		}
+		entries = entries[n:]
+	}
+
+	if err := writeLength(w, uint64(len(stream.Entries))); err != nil {
+		return err
+	}
+	for _, id := range []StreamID{stream.LastID, stream.FirstID, stream.MaxDeletedID} {
+		if err := writeStreamID(w, id); err != nil {
+			return err
+		}
+	}
+	if err := writeLength(w, stream.EntriesAdded); err != nil {
+		return err
+	}
+
+	if err := WriteSize(w, len(stream.Groups)); err != nil {
+		return err
+	}
+	for _, group := range stream.Groups {
+		if err := writeStreamGroup(w, &group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamNodeElements returns the listpack elements of entries. The fields of
+// the first entry become the master fields, which later entries with the
+// same fields omit.
+func streamNodeElements(entries []StreamEntry) []string {
+	master := entries[0].ID
+	var masterFields []string
+	for i := 0; i < len(entries[0].Fields); i += 2 {
+		masterFields = append(masterFields, entries[0].Fields[i])
+	}
+	elements := []string{strconv.Itoa(len(entries)), "0", strconv.Itoa(len(masterFields))}
+	elements = append(elements, masterFields...)
+	elements = append(elements, "0")
+
+	for _, entry := range entries {
+		sameFields := len(entry.Fields) == 2*len(masterFields)
+		for i := 0; sameFields && i < len(masterFields); i++ {
+			sameFields = entry.Fields[2*i] == masterFields[i]
+		}
+		flags := 0
+		if sameFields {
+			flags = streamItemSameFields
+		}
+		elements = append(elements,
+			strconv.Itoa(flags),
+			strconv.FormatInt(int64(entry.ID.Ms-master.Ms), 10),
+			strconv.FormatInt(int64(entry.ID.Seq-master.Seq), 10))
+		if sameFields {
+			for i := 1; i < len(entry.Fields); i += 2 {
+				elements = append(elements, entry.Fields[i])
+			}
+			elements = append(elements, strconv.Itoa(len(masterFields)+3))
+		} else {
+			elements = append(elements, strconv.Itoa(len(entry.Fields)/2))
+			elements = append(elements, entry.Fields...)
+			elements = append(elements, strconv.Itoa(len(entry.Fields)+4))
+		}
+	}
+	return elements
+}
+
+func writeStreamGroup(w io.Writer, group *StreamGroup) error {
+	if err := WriteString(w, group.Name); err != nil {
+		return err
+	}
+	if err := writeStreamID(w, group.LastID); err != nil {
+		return err
+	}
+	if err := writeLength(w, uint64(group.EntriesRead)); err != nil {
+		return err
+	}
+
+	pending := slices.Clone(group.Pending)
+	slices.SortFunc(pending, func(a, b StreamPendingEntry) int {
+		return compareStreamIDs(a.ID, b.ID)
+	})
+	if err := WriteSize(w, len(pending)); err != nil {
+		return err
+	}
+	for _, entry := range pending {
+		if _, err := w.Write(entry.ID.key()); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, entry.DeliveryTime); err != nil {
+			return err
+		}
+		if err := writeLength(w, entry.DeliveryCount); err != nil {
+			return err
+		}
+	}
+
+	if err := WriteSize(w, len(group.Consumers)); err != nil {
+		return err
+	}
+	for _, consumer := range group.Consumers {
+		if err := WriteString(w, consumer.Name); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, consumer.SeenTime); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, consumer.ActiveTime); err != nil {
+			return err
+		}
+		var owned []StreamID
+		for _, entry := range pending {
+			if entry.Consumer == consumer.Name {
+				owned = append(owned, entry.ID)
+			}
+		}
+		if err := WriteSize(w, len(owned)); err != nil {
+			return err
+		}
+		for _, id := range owned {
+			if _, err := w.Write(id.key()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func compareStreamIDs(a, b StreamID) int {
+	switch {
+	case a.Ms != b.Ms:
+		if a.Ms < b.Ms {
+			return -1
+		}
+		return 1
+	case a.Seq != b.Seq:
+		if a.Seq < b.Seq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func readStreamID(r io.Reader) (StreamID, error) {
+	ms, err := readLength(r)
+	if err != nil {
+		return StreamID{}, err
+	}
+	seq, err := readLength(r)
+	return StreamID{ms, seq}, err
+}
+
+func writeStreamID(w io.Writer, id StreamID) error {
+	if err := writeLength(w, id.Ms); err != nil {
+		return err
+	}
+	return writeLength(w, id.Seq)
+}
+
+// readRawStreamID reads an ID stored as 16 big-endian bytes.
+func readRawStreamID(r io.Reader) (StreamID, error) {
+	key := make([]byte, 16)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return StreamID{}, err
+	}
+	return streamIDFromKey(key), nil
+}
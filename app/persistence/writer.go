@@ -3,6 +3,7 @@ package persistence
 import (
 	"encoding/binary"
 	"io"
+	"math"
 )
 
 func WriteHeader(w io.Writer) error {
@@ -11,6 +12,12 @@ func WriteHeader(w io.Writer) error {
 }
 
 func WriteSize(w io.Writer, size int) error {
+	return writeLength(w, uint64(size))
+}
+
+// writeLength encodes size, which may not fit in an int, as the parts of
+// stream IDs.
+func writeLength(w io.Writer, size uint64) error {
 	// For values 0-63 (6 bits)
 	if size < 64 {
 		return binary.Write(w, binary.LittleEndian, uint8(size))
@@ -27,6 +34,14 @@ func WriteSize(w io.Writer, size int) error {
 		return binary.Write(w, binary.BigEndian, secondByte)
 	}
 
+	// For values >= 2^32 (64 bits)
+	if size > math.MaxUint32 {
+		if err := binary.Write(w, binary.LittleEndian, uint8(0x81)); err != nil {
+			return err
+		}
+		return binary.Write(w, binary.BigEndian, uint64(size))
+	}
+
 	// For values >= 16384 (32 bits)
 	// Use 0b10 prefix
 	firstByte := uint8(0x80) // 0x80 is 0b10000000
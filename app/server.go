@@ -16,6 +16,11 @@ func main() {
 	dbFilename := flag.String("dbfilename", "rdbfile", "the name of the RDB file")
 	port := flag.Uint("port", 6379, "the port for the server to listen on")
 	replicaOf := flag.String("replicaof", "", "the host and port of the master server to replicate from")
+	queryBufferLimit := flag.Int64("client-query-buffer-limit", server.DefaultClientQueryBufferLimit, "the maximum size of a client's query buffer in bytes")
+	protoMaxBulkLen := flag.Int64("proto-max-bulk-len", server.DefaultProtoMaxBulkLen, "the maximum size of a single request argument in bytes")
+	hz := flag.Int("hz", server.DefaultHz, "how many times per second background tasks such as active expiry run")
+	luaTimeLimit := flag.Int64("lua-time-limit", server.DefaultLuaTimeLimit, "the time in milliseconds after which a running script makes the server reply BUSY")
+	serveStaleData := flag.Bool("replica-serve-stale-data", true, "whether a replica keeps serving commands after losing the link with its master")
 	flag.Parse()
 	if *port > 65535 {
 		log.Fatalf("Invalid port %d", *port)
@@ -27,10 +32,15 @@ func main() {
 	}
 
 	config := server.Config{
-		Dir:        *dir,
-		DBFilename: *dbFilename,
-		Port:       uint16(*port),
-		ReplicaOf:  replica,
+		Dir:                    *dir,
+		DBFilename:             *dbFilename,
+		Port:                   uint16(*port),
+		ReplicaOf:              replica,
+		ClientQueryBufferLimit: *queryBufferLimit,
+		ProtoMaxBulkLen:        *protoMaxBulkLen,
+		Hz:                     *hz,
+		LuaTimeLimit:           *luaTimeLimit,
+		ReplicaServeStaleData:  *serveStaleData,
 	}
 	err := os.MkdirAll(config.Dir, 0750)
 	if err != nil {
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+// blockedClient is a client waiting in a blocking command for one of its keys
+// to become ready.
+type blockedClient struct {
+	client *Client
+	keys   []string
+	// serve tries to complete the command with key. It returns a nil reply
+	// when key cannot serve the client, and otherwise the commands to
+	// propagate in place of the blocking one.
+	serve func(key string) (reply []byte, propagate [][][]byte)
+	// target is the key that receives the element popped for the client, as
+	// in BLMOVE, which becomes ready once the client is served.
+	target string
+	// unblockOnError unblocks the client with the errors serve replies, as
+	// XREADGROUP does once its stream or group is deleted, where other
+	// clients keep waiting.
+	unblockOnError bool
+	// done receives the reply once the client is served or unblocked.
+	done chan []byte
+}
+
+// blockForKeys tries serve against keys in order and returns the first reply.
+// When none of the keys can serve the client, the client blocks until a write
+// makes one of them ready, the timeout expires, it is unblocked with CLIENT
+// UNBLOCK or it disconnects. A zero timeout blocks forever. With
+// unblockOnError, the client is also unblocked when serve replies with an
+// error.
+func (s *Server) blockForKeys(c *Client, keys []string, timeout time.Duration, target string, unblockOnError bool, serve func(key string) ([]byte, [][][]byte)) []byte {
+	s.blockMutex.Lock()
+	for _, key := range keys {
+		if reply, propagate := serve(key); reply != nil {
+			s.blockMutex.Unlock()
+			if propagate != nil {
+				c.dirty++
+				c.alsoPropagate(propagate...)
+			}
+			return reply
+		}
+	}
+	if c.inExec {
+		// Transactions behave as if the timeout expired right away.
+		s.blockMutex.Unlock()
+		return c.appendNullArray(nil)
+	}
+
+	// A client waits at most once on each key.
+	keys = slices.Clone(keys)
+	slices.Sort(keys)
+	bc := &blockedClient{
+		client:         c,
+		keys:           slices.Compact(keys),
+		serve:          serve,
+		target:         target,
+		unblockOnError: unblockOnError,
+		done:           make(chan []byte, 1),
+	}
+	for _, key := range bc.keys {
+		s.blockedKeys[key] = append(s.blockedKeys[key], bc)
+	}
+	c.blocked = bc
+	s.numBlocked.Add(1)
+	s.blockMutex.Unlock()
+
+	// Other clients may run transactions while this one waits.
+	s.keyspaceMutex.RUnlock()
+	defer s.keyspaceMutex.RLock()
+
+	// Replies to earlier pipelined commands must not wait for this one.
+	c.flushReply()
+	queryBufferLimit, _ := s.clientLimits()
+	disconnected, stopWatching := c.watchConnection(queryBufferLimit)
+	defer stopWatching()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case reply := <-bc.done:
+		return reply
+	case <-expired:
+	case <-disconnected:
+	}
+	s.blockMutex.Lock()
+	removed := s.unblockClient(bc)
+	s.blockMutex.Unlock()
+	if !removed {
+		// The client was served or unblocked concurrently.
+		return <-bc.done
+	}
+	return c.appendNullArray(nil)
+}
+
+// unblockClient removes bc from the blocked clients and reports whether it
+// was still blocked. The caller must hold blockMutex.
+func (s *Server) unblockClient(bc *blockedClient) bool {
+	if bc.client.blocked != bc {
+		return false
+	}
+	for _, key := range bc.keys {
+		waiting := slices.DeleteFunc(s.blockedKeys[key], func(other *blockedClient) bool {
+			return other == bc
+		})
+		if len(waiting) == 0 {
+			delete(s.blockedKeys, key)
+		} else {
+			s.blockedKeys[key] = waiting
+		}
+	}
+	bc.client.blocked = nil
+	s.numBlocked.Add(-1)
+	return true
+}
+
+// signalKeysAsReady serves the clients blocked on the keys of a write command
+// that changed the keyspace. Clients blocked on the same key are served in
+// the order they blocked, for as long as the key can serve them.
+func (s *Server) signalKeysAsReady(cmd *Command, req [][]byte) {
+	if s.numBlocked.Load() == 0 {
+		return
+	}
+	s.serveReadyKeys(cmd.keys(req))
+}
+
+// serveReadyKeys serves the clients blocked on the ready keys.
+func (s *Server) serveReadyKeys(ready []string) {
+	if s.numBlocked.Load() == 0 {
+		return
+	}
+	s.blockMutex.Lock()
+	defer s.blockMutex.Unlock()
+	for len(ready) > 0 {
+		key := ready[0]
+		ready = ready[1:]
+		for len(s.blockedKeys[key]) > 0 {
+			bc := s.blockedKeys[key][0]
+			reply, propagate := bc.serve(key)
+			if reply == nil || isError(reply) && !bc.unblockOnError {
+				// Blocked clients keep waiting on keys of the wrong type.
+				break
+			}
+			if isError(reply) {
+				s.unblockClient(bc)
+				bc.done <- reply
+				continue
+			}
+			s.unblockClient(bc)
+			s.touchKeys(key)
+			if s.info.role == MasterRole {
+				for _, args := range propagate {
+					s.PropagateCommand(args)
+				}
+			}
+			if bc.target != "" {
+				s.touchKeys(bc.target)
+				ready = append(ready, bc.target)
+			}
+			bc.done <- reply
+		}
+	}
+}
+
+func (s *Server) handleClientUnblock(c *Client, req [][]byte) []byte {
+	id, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	withError := false
+	if len(req) == 4 {
+		switch strings.ToLower(string(req[3])) {
+		case "timeout":
+		case "error":
+			withError = true
+		default:
+			return parser.AppendError(nil, "ERR CLIENT UNBLOCK reason should be TIMEOUT or ERROR")
+		}
+	} else if len(req) > 4 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+
+	target := s.clientByID(id)
+	if target == nil {
+		return parser.AppendInt(nil, 0)
+	}
+	s.blockMutex.Lock()
+	defer s.blockMutex.Unlock()
+	bc := target.blocked
+	if bc == nil || !s.unblockClient(bc) {
+		return parser.AppendInt(nil, 0)
+	}
+	if withError {
+		bc.done <- parser.AppendError(nil, "UNBLOCKED client unblocked via CLIENT UNBLOCK")
+	} else {
+		bc.done <- target.appendNullArray(nil)
+	}
+	return parser.AppendInt(nil, 1)
+}
+
+// parseTimeout parses the timeout of a blocking command in seconds, which may
+// be fractional. A positive timeout is at least a millisecond, so it is never
+// mistaken for the zero that blocks forever.
+func parseTimeout(arg []byte) (time.Duration, []byte) {
+	seconds, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, parser.AppendError(nil, "ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, parser.AppendError(nil, "ERR timeout is negative")
+	}
+	if seconds > float64(math.MaxInt64/int64(time.Second)) {
+		return 0, parser.AppendError(nil, "ERR timeout is out of range")
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if seconds > 0 && timeout < time.Millisecond {
+		timeout = time.Millisecond
+	}
+	return timeout, nil
+}
@@ -0,0 +1,352 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+// Client holds the per-connection state of a connected client.
+type Client struct {
+	id         int64
+	conn       net.Conn
+	protocol   int
+	name       string
+	libName    string
+	libVersion string
+	// master is set on the client applying the replication stream, whose
+	// commands wait for busy scripts instead of failing with BUSY.
+	master bool
+	// dirty counts the keyspace changes made by the command being executed;
+	// write commands are only propagated when they changed something.
+	dirty int
+	// propagate, when set, holds the commands that replace the one being
+	// executed in the replication stream.
+	propagate [][][]byte
+	// reply holds the replies that have not been written yet.
+	reply []byte
+	// pendingQuery holds the data received while the client was blocked.
+	pendingQuery []byte
+	// watched holds the keys watched for the next transaction, and
+	// watchDirty is set once one of them was modified. Both are guarded by
+	// the server's watchMutex.
+	watched    []watchedKey
+	watchDirty bool
+	// inExec is set while the client runs the commands of a transaction or
+	// a script, which must not block.
+	inExec bool
+	// execPropagate and execReady collect the commands to propagate and the
+	// keys to signal as ready once the transaction being executed is done.
+	execPropagate [][][]byte
+	execReady     []string
+	// blocked is the blocking command the client is waiting in, guarded by
+	// the server's blockMutex.
+	blocked *blockedClient
+	// channels, patterns and shardChannels are the subscriptions of the
+	// client. They are only used by the client's own goroutine.
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
+	// output, once the client subscribed, queues everything written to the
+	// connection so that messages published by other clients keep their
+	// order with the replies.
+	output *clientOutput
+}
+
+// clientOutput is the output queue of a subscribed client, which a goroutine
+// writes to the connection.
+type clientOutput struct {
+	mutex sync.Mutex
+	conn  net.Conn
+	buf   []byte
+	// writing is the size of the output being written.
+	writing int
+	// softLimitSince is when the pending output went over the soft limit.
+	softLimitSince time.Time
+	closed         bool
+	wake           chan struct{}
+}
+
+func newClientOutput(conn net.Conn) *clientOutput {
+	o := &clientOutput{conn: conn, wake: make(chan struct{}, 1)}
+	go o.writeLoop()
+	return o
+}
+
+// writeLoop writes the queued output until the queue is closed and drained,
+// then closes the connection.
+func (o *clientOutput) writeLoop() {
+	var buf []byte
+	for range o.wake {
+		for {
+			o.mutex.Lock()
+			if len(o.buf) == 0 {
+				o.writing = 0
+				closed := o.closed
+				o.mutex.Unlock()
+				if closed {
+					o.conn.Close()
+					return
+				}
+				break
+			}
+			buf, o.buf = o.buf, buf[:0]
+			o.writing = len(buf)
+			o.mutex.Unlock()
+			if _, err := o.conn.Write(buf); err != nil {
+				o.mutex.Lock()
+				o.closed, o.buf = true, nil
+				o.mutex.Unlock()
+				o.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// enqueue adds b to the output and reports whether it was queued. limit,
+// when set, disconnects the client once its pending output exceeds it.
+func (o *clientOutput) enqueue(b []byte, limit *OutputBufferLimit) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.closed {
+		return false
+	}
+	o.buf = append(o.buf, b...)
+	if limit != nil && o.overLimit(*limit) {
+		log.Printf("Closing client %s that overcame its output buffer limits (%d bytes)", o.conn.RemoteAddr(), len(o.buf)+o.writing)
+		o.closed, o.buf = true, nil
+		o.conn.Close()
+		return false
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// overLimit reports whether the pending output exceeds the hard limit, or
+// the soft one for too long. The caller holds the mutex.
+func (o *clientOutput) overLimit(limit OutputBufferLimit) bool {
+	pending := int64(len(o.buf) + o.writing)
+	if limit.Hard > 0 && pending > limit.Hard {
+		return true
+	}
+	if limit.Soft == 0 || pending <= limit.Soft {
+		o.softLimitSince = time.Time{}
+		return false
+	}
+	if o.softLimitSince.IsZero() {
+		o.softLimitSince = time.Now()
+	}
+	return time.Since(o.softLimitSince) > time.Duration(limit.SoftSeconds)*time.Second
+}
+
+// close stops accepting output. The connection is closed once the pending
+// output was written.
+func (o *clientOutput) close() {
+	o.mutex.Lock()
+	o.closed = true
+	o.mutex.Unlock()
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Server) newClient(conn net.Conn) *Client {
+	c := &Client{
+		id:       s.nextClientID.Add(1),
+		conn:     conn,
+		protocol: 2,
+		reply:    make([]byte, 0, readChunkSize),
+	}
+	s.clientsMutex.Lock()
+	s.clients[c.id] = c
+	s.clientsMutex.Unlock()
+	return c
+}
+
+func (s *Server) removeClient(c *Client) {
+	s.clientsMutex.Lock()
+	delete(s.clients, c.id)
+	s.clientsMutex.Unlock()
+	s.unwatchAllKeys(c)
+	s.unsubscribeAll(c)
+	if c.output != nil {
+		c.output.close()
+	}
+	s.txMutex.Lock()
+	delete(s.transactions, c)
+	s.txMutex.Unlock()
+}
+
+func (s *Server) clientByID(id int64) *Client {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	return s.clients[id]
+}
+
+// flushReply writes the pending replies to the connection, or queues them
+// once the client subscribed.
+func (c *Client) flushReply() error {
+	if len(c.reply) == 0 {
+		return nil
+	}
+	var err error
+	if c.output != nil {
+		if !c.output.enqueue(c.reply, nil) {
+			err = net.ErrClosed
+		}
+	} else {
+		_, err = c.conn.Write(c.reply)
+	}
+	c.reply = c.reply[:0]
+	return err
+}
+
+// close closes the connection once the pending replies were written.
+func (c *Client) close() {
+	c.flushReply()
+	if c.output != nil {
+		c.output.close()
+		return
+	}
+	c.conn.Close()
+}
+
+// watchConnection keeps reading from the connection while the client is
+// blocked so that a disconnect is noticed. Data received meanwhile is kept in
+// pendingQuery, up to limit bytes. The returned function stops watching.
+func (c *Client) watchConnection(limit int64) (disconnected <-chan struct{}, stop func()) {
+	closed := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, readChunkSize)
+		for int64(len(c.pendingQuery)) <= limit {
+			n, err := c.conn.Read(buf)
+			c.pendingQuery = append(c.pendingQuery, buf[:n]...)
+			if err != nil {
+				if !errors.Is(err, os.ErrDeadlineExceeded) {
+					close(closed)
+				}
+				return
+			}
+		}
+	}()
+	return closed, func() {
+		c.conn.SetReadDeadline(time.Now())
+		<-done
+		c.conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// rewriteCommand replaces the current command with args when it is
+// propagated to replicas.
+func (c *Client) rewriteCommand(args ...string) {
+	c.propagate = [][][]byte{byteArgs(args...)}
+}
+
+// alsoPropagate adds commands to the ones that replace the current command
+// when it is propagated to replicas, for commands whose effect takes several.
+func (c *Client) alsoPropagate(commands ...[][]byte) {
+	c.propagate = append(c.propagate, commands...)
+}
+
+func byteArgs(args ...string) [][]byte {
+	b := make([][]byte, len(args))
+	for i, arg := range args {
+		b[i] = []byte(arg)
+	}
+	return b
+}
+
+func (c *Client) resp3() bool {
+	return c.protocol >= 3
+}
+
+// appendMap appends a map header for n key-value pairs, falling back to a flat
+// array of 2*n elements for RESP2 clients.
+func (c *Client) appendMap(b []byte, n int) []byte {
+	if c.resp3() {
+		return parser.AppendMap(b, n)
+	}
+	return parser.AppendArray(b, n*2)
+}
+
+// appendSet appends a set header, falling back to an array for RESP2 clients.
+func (c *Client) appendSet(b []byte, n int) []byte {
+	if c.resp3() {
+		return parser.AppendSet(b, n)
+	}
+	return parser.AppendArray(b, n)
+}
+
+// appendBulkSet appends a set of bulk strings, which RESP2 clients receive as
+// an array.
+func (c *Client) appendBulkSet(b []byte, members [][]byte) []byte {
+	b = c.appendSet(b, len(members))
+	for _, member := range members {
+		b = parser.AppendBulk(b, member)
+	}
+	return b
+}
+
+// appendPush appends a push header, falling back to an array for RESP2 clients.
+func (c *Client) appendPush(b []byte, n int) []byte {
+	if c.resp3() {
+		return parser.AppendPush(b, n)
+	}
+	return parser.AppendArray(b, n)
+}
+
+// appendNull appends a null, which RESP2 clients receive as a null bulk string.
+func (c *Client) appendNull(b []byte) []byte {
+	if c.resp3() {
+		return parser.AppendNull(b)
+	}
+	return append(b, parser.NullBulkString()...)
+}
+
+// appendNullArray appends a null, which RESP2 clients receive as a null array.
+func (c *Client) appendNullArray(b []byte) []byte {
+	if c.resp3() {
+		return parser.AppendNull(b)
+	}
+	return append(b, parser.NullArray()...)
+}
+
+// appendDouble appends a double, which RESP2 clients receive as a bulk string.
+func (c *Client) appendDouble(b []byte, f float64) []byte {
+	if c.resp3() {
+		return parser.AppendDouble(b, f)
+	}
+	return parser.AppendBulkString(b, parser.FormatDouble(f))
+}
+
+// appendBool appends a boolean, which RESP2 clients receive as 1 or 0.
+func (c *Client) appendBool(b []byte, t bool) []byte {
+	if c.resp3() {
+		return parser.AppendBool(b, t)
+	}
+	if t {
+		return parser.AppendInt(b, 1)
+	}
+	return parser.AppendInt(b, 0)
+}
+
+// appendVerbatim appends a plain text verbatim string, which RESP2 clients
+// receive as a bulk string.
+func (c *Client) appendVerbatim(b []byte, s []byte) []byte {
+	if c.resp3() {
+		return parser.AppendVerbatim(b, "txt", s)
+	}
+	return parser.AppendBulk(b, s)
+}
@@ -0,0 +1,1314 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+type commandHandler func(s *Server, c *Client, req [][]byte) []byte
+
+type commandFlag uint32
+
+const (
+	flagWrite commandFlag = 1 << iota
+	flagReadonly
+	flagDenyOOM
+	flagAdmin
+	flagPubSub
+	flagNoScript
+	flagBlocking
+	flagLoading
+	flagStale
+	flagFast
+	flagNoMulti
+	flagMovableKeys
+	flagAllowBusy
+	flagMayReplicate
+	// flagExclusive is not reported by COMMAND: the command runs with the
+	// keyspace held for writing, as scripts do.
+	flagExclusive
+)
+
+var flagNames = []struct {
+	flag commandFlag
+	name string
+}{
+	{flagWrite, "write"},
+	{flagReadonly, "readonly"},
+	{flagDenyOOM, "denyoom"},
+	{flagAdmin, "admin"},
+	{flagPubSub, "pubsub"},
+	{flagNoScript, "noscript"},
+	{flagBlocking, "blocking"},
+	{flagLoading, "loading"},
+	{flagStale, "stale"},
+	{flagFast, "fast"},
+	{flagNoMulti, "no_multi"},
+	{flagMovableKeys, "movablekeys"},
+	{flagAllowBusy, "allow_busy"},
+	{flagMayReplicate, "may_replicate"},
+}
+
+// Command describes a command the server understands. Arity follows the Redis
+// convention: a positive value is the exact number of arguments (including the
+// command name) and a negative value is the minimum.
+type Command struct {
+	name        string
+	handler     commandHandler
+	arity       int
+	flags       commandFlag
+	firstKey    int
+	lastKey     int
+	keyStep     int
+	getKeys     func(args [][]byte) []int
+	group       string
+	since       string
+	summary     string
+	complexity  string
+	subcommands map[string]*Command
+}
+
+func (cmd *Command) hasFlag(flag commandFlag) bool {
+	return cmd.flags&flag != 0
+}
+
+func (cmd *Command) flagNames() []string {
+	names := make([]string, 0, len(flagNames))
+	for _, f := range flagNames {
+		if cmd.hasFlag(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+func (cmd *Command) aclCategories() []string {
+	var categories []string
+	if cmd.hasFlag(flagWrite) {
+		categories = append(categories, "@write")
+	}
+	if cmd.hasFlag(flagReadonly) {
+		categories = append(categories, "@read")
+	}
+	if cmd.hasFlag(flagAdmin) {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	if cmd.hasFlag(flagPubSub) {
+		categories = append(categories, "@pubsub")
+	}
+	if cmd.hasFlag(flagBlocking) {
+		categories = append(categories, "@blocking")
+	}
+	if cmd.hasFlag(flagFast) {
+		categories = append(categories, "@fast")
+	} else {
+		categories = append(categories, "@slow")
+	}
+	switch cmd.group {
+	case "string":
+		categories = append(categories, "@string")
+	case "list":
+		categories = append(categories, "@list")
+	case "hash":
+		categories = append(categories, "@hash")
+	case "set":
+		categories = append(categories, "@set")
+	case "sorted-set":
+		categories = append(categories, "@sortedset")
+	case "stream":
+		categories = append(categories, "@stream")
+	case "generic":
+		categories = append(categories, "@keyspace")
+	case "connection":
+		categories = append(categories, "@connection")
+	case "transactions":
+		categories = append(categories, "@transaction")
+	case "scripting":
+		categories = append(categories, "@scripting")
+	}
+	return categories
+}
+
+// keyIndexes returns the positions of the key arguments in args.
+func (cmd *Command) keyIndexes(args [][]byte) []int {
+	if cmd.getKeys != nil {
+		return cmd.getKeys(args)
+	}
+	if cmd.firstKey <= 0 {
+		return nil
+	}
+	last := cmd.lastKey
+	if last < 0 {
+		last = len(args) + last
+	}
+	indexes := make([]int, 0, 1)
+	for i := cmd.firstKey; i <= last && i < len(args); i += cmd.keyStep {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// keys returns the key arguments in args.
+func (cmd *Command) keys(args [][]byte) []string {
+	indexes := cmd.keyIndexes(args)
+	keys := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		keys = append(keys, string(args[i]))
+	}
+	return keys
+}
+
+func (cmd *Command) checkArity(argc int) bool {
+	return (cmd.arity > 0 && argc == cmd.arity) || (cmd.arity < 0 && argc >= -cmd.arity)
+}
+
+func newCommandTable() map[string]*Command {
+	commands := []*Command{
+		{
+			name: "ping", handler: (*Server).handlePing, arity: -1, flags: flagFast | flagStale | flagLoading,
+			group: "connection", since: "1.0.0", summary: "Returns the server's liveliness response.", complexity: "O(1)",
+		},
+		{
+			name: "echo", handler: (*Server).handleEcho, arity: 2, flags: flagFast | flagStale | flagLoading,
+			group: "connection", since: "1.0.0", summary: "Returns the given string.", complexity: "O(1)",
+		},
+		{
+			name: "hello", handler: (*Server).handleHello, arity: -1, flags: flagNoScript | flagLoading | flagStale | flagFast,
+			group: "connection", since: "6.0.0", summary: "Handshakes with the Redis server.", complexity: "O(1)",
+		},
+		{
+			name: "quit", handler: (*Server).handleQuit, arity: -1, flags: flagAllowBusy | flagNoScript | flagLoading | flagStale | flagFast,
+			group: "connection", since: "1.0.0", summary: "Closes the connection.", complexity: "O(1)",
+		},
+		{
+			name: "reset", handler: (*Server).handleReset, arity: 1, flags: flagNoScript | flagLoading | flagStale | flagFast,
+			group: "connection", since: "6.2.0", summary: "Resets the connection.", complexity: "O(1)",
+		},
+		{
+			name: "client", arity: -2,
+			group: "connection", since: "2.4.0", summary: "A container for client connection commands.", complexity: "Depends on subcommand.",
+			subcommands: subcommandTable("client",
+				&Command{
+					name: "id", handler: (*Server).handleClientID, arity: 2, flags: flagNoScript | flagLoading | flagStale,
+					group: "connection", since: "5.0.0", summary: "Returns the unique client ID of the connection.", complexity: "O(1)",
+				},
+				&Command{
+					name: "getname", handler: (*Server).handleClientGetName, arity: 2, flags: flagNoScript | flagLoading | flagStale,
+					group: "connection", since: "2.6.9", summary: "Returns the name of the connection.", complexity: "O(1)",
+				},
+				&Command{
+					name: "setname", handler: (*Server).handleClientSetName, arity: 3, flags: flagNoScript | flagLoading | flagStale,
+					group: "connection", since: "2.6.9", summary: "Sets the connection name.", complexity: "O(1)",
+				},
+				&Command{
+					name: "unblock", handler: (*Server).handleClientUnblock, arity: -3, flags: flagNoScript | flagLoading | flagStale,
+					group: "connection", since: "5.0.0", summary: "Unblocks a client blocked by a blocking command from a different connection.", complexity: "O(log N) where N is the number of client connections",
+				},
+				&Command{
+					name: "setinfo", handler: (*Server).handleClientSetInfo, arity: 4, flags: flagNoScript | flagLoading | flagStale,
+					group: "connection", since: "7.2.0", summary: "Sets information specific to the client or connection.", complexity: "O(1)",
+				},
+			),
+		},
+		{
+			name: "object", arity: -2,
+			group: "generic", since: "2.2.3", summary: "A container for object introspection commands.", complexity: "Depends on subcommand.",
+			subcommands: subcommandTable("object",
+				&Command{
+					name: "encoding", handler: (*Server).handleObjectEncoding, arity: 3, flags: flagReadonly,
+					firstKey: 2, lastKey: 2, keyStep: 1,
+					group: "generic", since: "2.2.3", summary: "Returns the internal encoding of a Redis object.", complexity: "O(1)",
+				},
+			),
+		},
+		{
+			name: "command", handler: (*Server).handleCommandList, arity: -1, flags: flagLoading | flagStale,
+			group: "server", since: "2.8.13", summary: "Returns detailed information about all commands.", complexity: "O(N) where N is the total number of Redis commands",
+			subcommands: subcommandTable("command",
+				&Command{
+					name: "count", handler: (*Server).handleCommandCount, arity: 2, flags: flagLoading | flagStale,
+					group: "server", since: "2.8.13", summary: "Returns a count of commands.", complexity: "O(1)",
+				},
+				&Command{
+					name: "info", handler: (*Server).handleCommandInfo, arity: -2, flags: flagLoading | flagStale,
+					group: "server", since: "2.8.13", summary: "Returns information about one, multiple or all commands.", complexity: "O(N) where N is the number of commands to look up",
+				},
+				&Command{
+					name: "docs", handler: (*Server).handleCommandDocs, arity: -2, flags: flagLoading | flagStale,
+					group: "server", since: "7.0.0", summary: "Returns documentary information about one, multiple or all commands.", complexity: "O(N) where N is the number of commands to look up",
+				},
+				&Command{
+					name: "getkeys", handler: (*Server).handleCommandGetKeys, arity: -3, flags: flagLoading | flagStale,
+					group: "server", since: "2.8.13", summary: "Extracts the key names from an arbitrary command.", complexity: "O(N) where N is the number of arguments to the command",
+				},
+				&Command{
+					name: "list", handler: (*Server).handleCommandNames, arity: -2, flags: flagLoading | flagStale,
+					group: "server", since: "7.0.0", summary: "Returns a list of command names.", complexity: "O(N) where N is the total number of Redis commands",
+				},
+			),
+		},
+		{
+			name: "config", arity: -2,
+			group: "server", since: "2.0.0", summary: "A container for server configuration commands.", complexity: "Depends on subcommand.",
+			subcommands: subcommandTable("config",
+				&Command{
+					name: "get", handler: (*Server).handleConfigGet, arity: -3, flags: flagAdmin | flagNoScript | flagLoading | flagStale,
+					group: "server", since: "2.0.0", summary: "Returns the effective values of configuration parameters.", complexity: "O(N) when N is the number of configuration parameters provided",
+				},
+				&Command{
+					name: "set", handler: (*Server).handleConfigSet, arity: -4, flags: flagAdmin | flagNoScript | flagLoading | flagStale,
+					group: "server", since: "2.0.0", summary: "Sets configuration parameters in-flight.", complexity: "O(N) when N is the number of configuration parameters provided",
+				},
+			),
+		},
+		{
+			name: "info", handler: (*Server).handleInfo, arity: -1, flags: flagLoading | flagStale,
+			group: "server", since: "1.0.0", summary: "Returns information and statistics about the server.", complexity: "O(1)",
+		},
+		{
+			name: "get", handler: (*Server).handleGet, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "1.0.0", summary: "Returns the string value of a key.", complexity: "O(1)",
+		},
+		{
+			name: "set", handler: (*Server).handleSet, arity: -3, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "1.0.0", summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "incr", handler: (*Server).handleIncr, arity: 2, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "1.0.0", summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "incrby", handler: (*Server).handleIncrBy, arity: 3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "2.0.0", summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "decr", handler: (*Server).handleDecr, arity: 2, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "1.0.0", summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "decrby", handler: (*Server).handleDecrBy, arity: 3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "1.0.0", summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "incrbyfloat", handler: (*Server).handleIncrByFloat, arity: 3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "2.6.0", summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "setnx", handler: (*Server).handleSetNX, arity: 3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "1.0.0", summary: "Set the string value of a key only when the key doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "mget", handler: (*Server).handleMGet, arity: -2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "string", since: "1.0.0", summary: "Atomically returns the string values of one or more keys.", complexity: "O(N) where N is the number of keys to retrieve.",
+		},
+		{
+			name: "mset", handler: (*Server).handleMSet, arity: -3, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: -1, keyStep: 2,
+			group: "string", since: "1.0.1", summary: "Atomically creates or modifies the string values of one or more keys.", complexity: "O(N) where N is the number of keys to set.",
+		},
+		{
+			name: "msetnx", handler: (*Server).handleMSetNX, arity: -3, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: -1, keyStep: 2,
+			group: "string", since: "1.0.1", summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.", complexity: "O(N) where N is the number of keys to set.",
+		},
+		{
+			name: "append", handler: (*Server).handleAppend, arity: 3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "2.0.0", summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.", complexity: "O(1). The amortized time complexity is O(1) assuming the appended value is small and the already present value is of any size, since the dynamic string library used by Redis will double the free space available on every reallocation.",
+		},
+		{
+			name: "strlen", handler: (*Server).handleStrLen, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "2.2.0", summary: "Returns the length of a string value.", complexity: "O(1)",
+		},
+		{
+			name: "getrange", handler: (*Server).handleGetRange, arity: 4, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "2.4.0", summary: "Returns a substring of the string stored at a key.", complexity: "O(N) where N is the length of the returned string. The complexity is ultimately determined by the returned length, but because creating a substring from an existing string is very cheap, it can be considered O(1) for small strings.",
+		},
+		{
+			name: "setrange", handler: (*Server).handleSetRange, arity: 4, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "2.2.0", summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.", complexity: "O(1), not counting the time taken to copy the new string in place. Usually, this string is very small so the amortized complexity is O(1). Otherwise, complexity is O(M) with M being the length of the value argument.",
+		},
+		{
+			name: "getdel", handler: (*Server).handleGetDel, arity: 2, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "6.2.0", summary: "Returns the string value of a key after deleting the key.", complexity: "O(1)",
+		},
+		{
+			name: "getex", handler: (*Server).handleGetEx, arity: -2, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "string", since: "6.2.0", summary: "Returns the string value of a key after setting its expiration time.", complexity: "O(1)",
+		},
+		{
+			name: "lpush", handler: (*Server).handleLPush, arity: -3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "1.0.0", summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+		},
+		{
+			name: "rpush", handler: (*Server).handleRPush, arity: -3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "1.0.0", summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+		},
+		{
+			name: "lpushx", handler: (*Server).handleLPushX, arity: -3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "2.2.0", summary: "Prepends one or more elements to a list only when the list exists.", complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+		},
+		{
+			name: "rpushx", handler: (*Server).handleRPushX, arity: -3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "2.2.0", summary: "Appends an element to a list only when the list exists.", complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+		},
+		{
+			name: "lpop", handler: (*Server).handleLPop, arity: -2, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "1.0.0", summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.", complexity: "O(N) where N is the number of elements returned",
+		},
+		{
+			name: "rpop", handler: (*Server).handleRPop, arity: -2, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "1.0.0", summary: "Returns and removes the last elements of a list. Deletes the list if the last element was popped.", complexity: "O(N) where N is the number of elements returned",
+		},
+		{
+			name: "lmpop", handler: (*Server).handleLMPop, arity: -4, flags: flagWrite | flagMovableKeys,
+			getKeys: lmpopKeys,
+			group:   "list", since: "7.0.0", summary: "Returns multiple elements from a list after removing them. Deletes the list if the last element was popped.", complexity: "O(N+M) where N is the number of provided keys and M is the number of elements returned.",
+		},
+		{
+			name: "lmove", handler: (*Server).handleLMove, arity: 5, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: 2, keyStep: 1,
+			group: "list", since: "6.2.0", summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.", complexity: "O(1)",
+		},
+		{
+			name: "rpoplpush", handler: (*Server).handleRPopLPush, arity: 3, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: 2, keyStep: 1,
+			group: "list", since: "1.2.0", summary: "Returns the last element of a list after removing and pushing it to another list. Deletes the list if the last element was popped.", complexity: "O(1)",
+		},
+		{
+			name: "blpop", handler: (*Server).handleBLPop, arity: -3, flags: flagWrite | flagBlocking,
+			firstKey: 1, lastKey: -2, keyStep: 1,
+			group: "list", since: "2.0.0", summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", complexity: "O(N) where N is the number of provided keys.",
+		},
+		{
+			name: "brpop", handler: (*Server).handleBRPop, arity: -3, flags: flagWrite | flagBlocking,
+			firstKey: 1, lastKey: -2, keyStep: 1,
+			group: "list", since: "2.0.0", summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", complexity: "O(N) where N is the number of provided keys.",
+		},
+		{
+			name: "blmpop", handler: (*Server).handleBLMPop, arity: -5, flags: flagWrite | flagBlocking | flagMovableKeys,
+			getKeys: blmpopKeys,
+			group:   "list", since: "7.0.0", summary: "Pops the first element from one of multiple lists. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", complexity: "O(N+M) where N is the number of provided keys and M is the number of elements returned.",
+		},
+		{
+			name: "blmove", handler: (*Server).handleBLMove, arity: 6, flags: flagWrite | flagDenyOOM | flagBlocking,
+			firstKey: 1, lastKey: 2, keyStep: 1,
+			group: "list", since: "6.2.0", summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved.", complexity: "O(1)",
+		},
+		{
+			name: "brpoplpush", handler: (*Server).handleBRPopLPush, arity: 4, flags: flagWrite | flagDenyOOM | flagBlocking,
+			firstKey: 1, lastKey: 2, keyStep: 1,
+			group: "list", since: "2.2.0", summary: "Pops an element from a list, pushes it to another list and returns it. Block until an element is available otherwise. Deletes the list if the last element was popped.", complexity: "O(1)",
+		},
+		{
+			name: "llen", handler: (*Server).handleLLen, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "1.0.0", summary: "Returns the length of a list.", complexity: "O(1)",
+		},
+		{
+			name: "lrange", handler: (*Server).handleLRange, arity: 4, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "1.0.0", summary: "Returns a range of elements from a list.", complexity: "O(S+N) where S is the distance of start offset from HEAD for small lists, from nearest end (HEAD or TAIL) for large lists; and N is the number of elements in the specified range.",
+		},
+		{
+			name: "lindex", handler: (*Server).handleLIndex, arity: 3, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "1.0.0", summary: "Returns an element from a list by its index.", complexity: "O(N) where N is the number of elements to traverse to get to the element at index. This makes asking for the first or the last element of the list O(1).",
+		},
+		{
+			name: "lset", handler: (*Server).handleLSet, arity: 4, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "1.0.0", summary: "Sets the value of an element in a list by its index.", complexity: "O(N) where N is the length of the list. Setting either the first or the last element of the list is O(1).",
+		},
+		{
+			name: "lrem", handler: (*Server).handleLRem, arity: 4, flags: flagWrite,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "1.0.0", summary: "Removes elements from a list. Deletes the list if the last element was removed.", complexity: "O(N+M) where N is the length of the list and M is the number of elements removed.",
+		},
+		{
+			name: "ltrim", handler: (*Server).handleLTrim, arity: 4, flags: flagWrite,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "1.0.0", summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed.", complexity: "O(N) where N is the number of elements to be removed by the operation.",
+		},
+		{
+			name: "linsert", handler: (*Server).handleLInsert, arity: 5, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "2.2.0", summary: "Inserts an element before or after another element in a list.", complexity: "O(N) where N is the number of elements to traverse before seeing the value pivot. This means that inserting somewhere on the left end on the list (head) can be considered O(1) and inserting somewhere on the right end (tail) is O(N).",
+		},
+		{
+			name: "lpos", handler: (*Server).handleLPos, arity: -3, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "list", since: "6.0.6", summary: "Returns the index of matching elements in a list.", complexity: "O(N) where N is the number of elements in the list, for the average case. When searching for elements near the head or the tail of the list, or when the MAXLEN option is provided, the command may run in constant time.",
+		},
+		{
+			name: "hset", handler: (*Server).handleHSet, arity: -4, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Creates or modifies the value of a field in a hash.", complexity: "O(1) for each field/value pair added, so O(N) to add N field/value pairs when the command is called with multiple field/value pairs.",
+		},
+		{
+			name: "hmset", handler: (*Server).handleHSet, arity: -4, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Sets the values of multiple fields.", complexity: "O(N) where N is the number of fields being set.",
+		},
+		{
+			name: "hsetnx", handler: (*Server).handleHSetNX, arity: 4, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Sets the value of a field in a hash only when the field doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "hget", handler: (*Server).handleHGet, arity: 3, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Returns the value of a field in a hash.", complexity: "O(1)",
+		},
+		{
+			name: "hmget", handler: (*Server).handleHMGet, arity: -3, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Returns the values of all fields in a hash.", complexity: "O(N) where N is the number of fields being requested.",
+		},
+		{
+			name: "hdel", handler: (*Server).handleHDel, arity: -3, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.", complexity: "O(N) where N is the number of fields to be removed.",
+		},
+		{
+			name: "hlen", handler: (*Server).handleHLen, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Returns the number of fields in a hash.", complexity: "O(1)",
+		},
+		{
+			name: "hexists", handler: (*Server).handleHExists, arity: 3, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Determines whether a field exists in a hash.", complexity: "O(1)",
+		},
+		{
+			name: "hstrlen", handler: (*Server).handleHStrLen, arity: 3, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "3.2.0", summary: "Returns the length of the value of a field.", complexity: "O(1)",
+		},
+		{
+			name: "hgetall", handler: (*Server).handleHGetAll, arity: 2, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Returns all fields and values in a hash.", complexity: "O(N) where N is the size of the hash.",
+		},
+		{
+			name: "hkeys", handler: (*Server).handleHKeys, arity: 2, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Returns all fields in a hash.", complexity: "O(N) where N is the size of the hash.",
+		},
+		{
+			name: "hvals", handler: (*Server).handleHVals, arity: 2, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Returns all values in a hash.", complexity: "O(N) where N is the size of the hash.",
+		},
+		{
+			name: "hincrby", handler: (*Server).handleHIncrBy, arity: 4, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.0.0", summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "hincrbyfloat", handler: (*Server).handleHIncrByFloat, arity: 4, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.6.0", summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "hrandfield", handler: (*Server).handleHRandField, arity: -2, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "6.2.0", summary: "Returns one or more random fields from a hash.", complexity: "O(N) where N is the number of fields returned",
+		},
+		{
+			name: "hscan", handler: (*Server).handleHScan, arity: -3, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "2.8.0", summary: "Iterates over fields and values of a hash.", complexity: "O(1) for every call. O(N) for a complete iteration, including enough command calls for the cursor to return back to 0. N is the number of elements inside the collection.",
+		},
+		{
+			name: "hexpire", handler: (*Server).handleHExpire, arity: -5, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "7.4.0", summary: "Set expiry for hash field using relative time to expire (seconds)", complexity: "O(N) where N is the number of specified fields",
+		},
+		{
+			name: "hpexpire", handler: (*Server).handleHPExpire, arity: -5, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "7.4.0", summary: "Set expiry for hash field using relative time to expire (milliseconds)", complexity: "O(N) where N is the number of specified fields",
+		},
+		{
+			name: "hexpireat", handler: (*Server).handleHExpireAt, arity: -5, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "7.4.0", summary: "Set expiry for hash field using an absolute Unix timestamp (seconds)", complexity: "O(N) where N is the number of specified fields",
+		},
+		{
+			name: "hpexpireat", handler: (*Server).handleHPExpireAt, arity: -5, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "7.4.0", summary: "Set expiry for hash field using an absolute Unix timestamp (milliseconds)", complexity: "O(N) where N is the number of specified fields",
+		},
+		{
+			name: "httl", handler: (*Server).handleHTTL, arity: -4, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "7.4.0", summary: "Returns the TTL in seconds of a hash field.", complexity: "O(N) where N is the number of specified fields",
+		},
+		{
+			name: "hpttl", handler: (*Server).handleHPTTL, arity: -4, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "7.4.0", summary: "Returns the TTL in milliseconds of a hash field.", complexity: "O(N) where N is the number of specified fields",
+		},
+		{
+			name: "hexpiretime", handler: (*Server).handleHExpireTime, arity: -4, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "7.4.0", summary: "Returns the expiration time of a hash field as a Unix timestamp, in seconds.", complexity: "O(N) where N is the number of specified fields",
+		},
+		{
+			name: "hpexpiretime", handler: (*Server).handleHPExpireTime, arity: -4, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "7.4.0", summary: "Returns the expiration time of a hash field as a Unix timestamp, in msec.", complexity: "O(N) where N is the number of specified fields",
+		},
+		{
+			name: "hpersist", handler: (*Server).handleHPersist, arity: -4, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "hash", since: "7.4.0", summary: "Removes the expiration time for each specified field", complexity: "O(N) where N is the number of specified fields",
+		},
+		{
+			name: "sadd", handler: (*Server).handleSAdd, arity: -3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Adds one or more members to a set. Creates the key if it doesn't exist.", complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+		},
+		{
+			name: "srem", handler: (*Server).handleSRem, arity: -3, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Removes one or more members from a set. Deletes the set if the last member was removed.", complexity: "O(N) where N is the number of members to be removed.",
+		},
+		{
+			name: "smembers", handler: (*Server).handleSMembers, arity: 2, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Returns all members of a set.", complexity: "O(N) where N is the set cardinality.",
+		},
+		{
+			name: "sismember", handler: (*Server).handleSIsMember, arity: 3, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Determines whether a member belongs to a set.", complexity: "O(1)",
+		},
+		{
+			name: "smismember", handler: (*Server).handleSMIsMember, arity: -3, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "set", since: "6.2.0", summary: "Determines whether multiple members belong to a set.", complexity: "O(N) where N is the number of elements being checked for membership",
+		},
+		{
+			name: "scard", handler: (*Server).handleSCard, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Returns the number of members in a set.", complexity: "O(1)",
+		},
+		{
+			name: "spop", handler: (*Server).handleSPop, arity: -2, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped.", complexity: "Without the count argument O(1), otherwise O(N) where N is the value of the passed count.",
+		},
+		{
+			name: "srandmember", handler: (*Server).handleSRandMember, arity: -2, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Get one or multiple random members from a set", complexity: "Without the count argument O(1), otherwise O(N) where N is the absolute value of the passed count.",
+		},
+		{
+			name: "smove", handler: (*Server).handleSMove, arity: 4, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 2, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Moves a member from one set to another.", complexity: "O(1)",
+		},
+		{
+			name: "sscan", handler: (*Server).handleSScan, arity: -3, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "set", since: "2.8.0", summary: "Iterates over members of a set.", complexity: "O(1) for every call. O(N) for a complete iteration, including enough command calls for the cursor to return back to 0. N is the number of elements inside the collection.",
+		},
+		{
+			name: "sinter", handler: (*Server).handleSInter, arity: -2, flags: flagReadonly,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Returns the intersect of multiple sets.", complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+		},
+		{
+			name: "sinterstore", handler: (*Server).handleSInterStore, arity: -3, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Stores the intersect of multiple sets in a key.", complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+		},
+		{
+			name: "sunion", handler: (*Server).handleSUnion, arity: -2, flags: flagReadonly,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Returns the union of multiple sets.", complexity: "O(N) where N is the total number of elements in all given sets.",
+		},
+		{
+			name: "sunionstore", handler: (*Server).handleSUnionStore, arity: -3, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Stores the union of multiple sets in a key.", complexity: "O(N) where N is the total number of elements in all given sets.",
+		},
+		{
+			name: "sdiff", handler: (*Server).handleSDiff, arity: -2, flags: flagReadonly,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Returns the difference of multiple sets.", complexity: "O(N) where N is the total number of elements in all given sets.",
+		},
+		{
+			name: "sdiffstore", handler: (*Server).handleSDiffStore, arity: -3, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "set", since: "1.0.0", summary: "Stores the difference of multiple sets in a key.", complexity: "O(N) where N is the total number of elements in all given sets.",
+		},
+		{
+			name: "sintercard", handler: (*Server).handleSInterCard, arity: -3, flags: flagReadonly | flagMovableKeys,
+			getKeys: sintercardKeys,
+			group:   "set", since: "7.0.0", summary: "Returns the number of members of the intersect of multiple sets.", complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+		},
+		{
+			name: "zadd", handler: (*Server).handleZAdd, arity: -4, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "1.2.0", summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.", complexity: "O(log(N)) for each item added, where N is the number of elements in the sorted set.",
+		},
+		{
+			name: "zrem", handler: (*Server).handleZRem, arity: -3, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "1.2.0", summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.", complexity: "O(M*log(N)) with N being the number of elements in the sorted set and M the number of elements to be removed.",
+		},
+		{
+			name: "zscore", handler: (*Server).handleZScore, arity: 3, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "1.2.0", summary: "Returns the score of a member in a sorted set.", complexity: "O(1)",
+		},
+		{
+			name: "zmscore", handler: (*Server).handleZMScore, arity: -3, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "6.2.0", summary: "Returns the score of one or more members in a sorted set.", complexity: "O(N) where N is the number of members being requested.",
+		},
+		{
+			name: "zincrby", handler: (*Server).handleZIncrBy, arity: 4, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "1.2.0", summary: "Increments the score of a member in a sorted set.", complexity: "O(log(N)) where N is the number of elements in the sorted set.",
+		},
+		{
+			name: "zcard", handler: (*Server).handleZCard, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "1.2.0", summary: "Returns the number of members in a sorted set.", complexity: "O(1)",
+		},
+		{
+			name: "zcount", handler: (*Server).handleZCount, arity: 4, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "2.0.0", summary: "Returns the count of members in a sorted set that have scores within a range.", complexity: "O(log(N)) with N being the number of elements in the sorted set.",
+		},
+		{
+			name: "zlexcount", handler: (*Server).handleZLexCount, arity: 4, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "2.8.9", summary: "Returns the number of members in a sorted set within a lexicographical range.", complexity: "O(log(N)) with N being the number of elements in the sorted set.",
+		},
+		{
+			name: "zrank", handler: (*Server).handleZRank, arity: -3, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "2.0.0", summary: "Returns the index of a member in a sorted set ordered by ascending scores.", complexity: "O(log(N))",
+		},
+		{
+			name: "zrevrank", handler: (*Server).handleZRevRank, arity: -3, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "2.0.0", summary: "Returns the index of a member in a sorted set ordered by descending scores.", complexity: "O(log(N))",
+		},
+		{
+			name: "zrange", handler: (*Server).handleZRange, arity: -4, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "1.2.0", summary: "Returns members in a sorted set within a range of indexes, scores or members.", complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements returned.",
+		},
+		{
+			name: "zrevrange", handler: (*Server).handleZRevRange, arity: -4, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "1.2.0", summary: "Returns members in a sorted set within a range of indexes in reverse order.", complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements returned.",
+		},
+		{
+			name: "zrangebyscore", handler: (*Server).handleZRangeByScore, arity: -4, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "1.0.5", summary: "Returns members in a sorted set within a range of scores.", complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements being returned.",
+		},
+		{
+			name: "zrevrangebyscore", handler: (*Server).handleZRevRangeByScore, arity: -4, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "2.2.0", summary: "Returns members in a sorted set within a range of scores in reverse order.", complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements being returned.",
+		},
+		{
+			name: "zrangebylex", handler: (*Server).handleZRangeByLex, arity: -4, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "2.8.9", summary: "Returns members in a sorted set within a lexicographical range.", complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements being returned.",
+		},
+		{
+			name: "zrevrangebylex", handler: (*Server).handleZRevRangeByLex, arity: -4, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "2.8.9", summary: "Returns members in a sorted set within a lexicographical range in reverse order.", complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements being returned.",
+		},
+		{
+			name: "zrangestore", handler: (*Server).handleZRangeStore, arity: -5, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: 2, keyStep: 1,
+			group: "sorted-set", since: "6.2.0", summary: "Stores a range of members from sorted set in a key.", complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements stored into the destination key.",
+		},
+		{
+			name: "zpopmin", handler: (*Server).handleZPopMin, arity: -2, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "5.0.0", summary: "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", complexity: "O(log(N)*M) with N being the number of elements in the sorted set, and M being the number of elements popped.",
+		},
+		{
+			name: "zpopmax", handler: (*Server).handleZPopMax, arity: -2, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "5.0.0", summary: "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", complexity: "O(log(N)*M) with N being the number of elements in the sorted set, and M being the number of elements popped.",
+		},
+		{
+			name: "bzpopmin", handler: (*Server).handleBZPopMin, arity: -3, flags: flagWrite | flagBlocking | flagFast,
+			firstKey: 1, lastKey: -2, keyStep: 1,
+			group: "sorted-set", since: "5.0.0", summary: "Removes and returns the member with the lowest score from one or more sorted sets. Blocks until a member is available otherwise. Deletes the sorted set if the last element was popped.", complexity: "O(log(N)) with N being the number of elements in the sorted set.",
+		},
+		{
+			name: "bzpopmax", handler: (*Server).handleBZPopMax, arity: -3, flags: flagWrite | flagBlocking | flagFast,
+			firstKey: 1, lastKey: -2, keyStep: 1,
+			group: "sorted-set", since: "5.0.0", summary: "Removes and returns the member with the highest score from one or more sorted sets. Blocks until a member is available otherwise. Deletes the sorted set if the last element was popped.", complexity: "O(log(N)) with N being the number of elements in the sorted set.",
+		},
+		{
+			name: "zunionstore", handler: (*Server).handleZUnionStore, arity: -4, flags: flagWrite | flagDenyOOM | flagMovableKeys,
+			getKeys: zstoreKeys,
+			group:   "sorted-set", since: "2.0.0", summary: "Stores the union of multiple sorted sets in a key.", complexity: "O(N)+O(M log(M)) with N being the sum of the sizes of the input sorted sets, and M being the number of elements in the resulting sorted set.",
+		},
+		{
+			name: "zinterstore", handler: (*Server).handleZInterStore, arity: -4, flags: flagWrite | flagDenyOOM | flagMovableKeys,
+			getKeys: zstoreKeys,
+			group:   "sorted-set", since: "2.0.0", summary: "Stores the intersect of multiple sorted sets in a key.", complexity: "O(N*K)+O(M*log(M)) worst case with N being the smallest input sorted set, K being the number of input sorted sets and M being the number of elements in the resulting sorted set.",
+		},
+		{
+			name: "zdiffstore", handler: (*Server).handleZDiffStore, arity: -4, flags: flagWrite | flagDenyOOM | flagMovableKeys,
+			getKeys: zstoreKeys,
+			group:   "sorted-set", since: "6.2.0", summary: "Stores the difference of multiple sorted sets in a key.", complexity: "O(L + (N-K)log(N)) worst case where L is the total number of elements in all the sets, N is the size of the first set, and K is the size of the result set.",
+		},
+		{
+			name: "zscan", handler: (*Server).handleZScan, arity: -3, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "sorted-set", since: "2.8.0", summary: "Iterates over members and scores of a sorted set.", complexity: "O(1) for every call. O(N) for a complete iteration, including enough command calls for the cursor to return back to 0. N is the number of elements inside the collection.",
+		},
+		{
+			name: "xadd", handler: (*Server).handleXAdd, arity: -5, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "5.0.0", summary: "Appends a new message to a stream. Creates the key if it doesn't exist.", complexity: "O(1) when adding a new entry",
+		},
+		{
+			name: "xrange", handler: (*Server).handleXRange, arity: -4, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "5.0.0", summary: "Returns the messages from a stream within a range of IDs.", complexity: "O(N) with N being the number of elements being returned",
+		},
+		{
+			name: "xrevrange", handler: (*Server).handleXRevRange, arity: -4, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "5.0.0", summary: "Returns the messages from a stream within a range of IDs in reverse order.", complexity: "O(N) with N being the number of elements returned. If N is constant (e.g. always asking for the first 10 elements with COUNT), you can consider it O(1).",
+		},
+		{
+			name: "xlen", handler: (*Server).handleXLen, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "5.0.0", summary: "Return the number of messages in a stream.", complexity: "O(1)",
+		},
+		{
+			name: "xdel", handler: (*Server).handleXDel, arity: -3, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "5.0.0", summary: "Returns the number of messages after removing them from a stream.", complexity: "O(1) for each single item to delete in the stream, regardless of the stream size.",
+		},
+		{
+			name: "xtrim", handler: (*Server).handleXTrim, arity: -4, flags: flagWrite,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "5.0.0", summary: "Deletes messages from the beginning of a stream.", complexity: "O(N), with N being the number of evicted entries. Constant times are very small however, since entries are organized in macro nodes containing multiple entries that can be released with a single deallocation.",
+		},
+		{
+			name: "xsetid", handler: (*Server).handleXSetID, arity: -3, flags: flagWrite | flagDenyOOM | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "5.0.0", summary: "An internal command for replicating stream values.", complexity: "O(1)",
+		},
+		{
+			name: "xread", handler: (*Server).handleXRead, arity: -4, flags: flagReadonly | flagBlocking | flagMovableKeys,
+			getKeys: xreadKeys,
+			group:   "stream", since: "5.0.0", summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.", complexity: "O(N) with N being the number of elements being returned",
+		},
+		{
+			name: "xgroup", arity: -2,
+			group: "stream", since: "5.0.0", summary: "A container for consumer groups commands.", complexity: "Depends on subcommand.",
+			subcommands: subcommandTable("xgroup",
+				&Command{
+					name: "create", handler: (*Server).handleXGroupCreate, arity: -5, flags: flagWrite | flagDenyOOM,
+					firstKey: 2, lastKey: 2, keyStep: 1,
+					group: "stream", since: "5.0.0", summary: "Creates a consumer group.", complexity: "O(1)",
+				},
+				&Command{
+					name: "setid", handler: (*Server).handleXGroupSetID, arity: -5, flags: flagWrite,
+					firstKey: 2, lastKey: 2, keyStep: 1,
+					group: "stream", since: "5.0.0", summary: "Sets the last-delivered ID of a consumer group.", complexity: "O(1)",
+				},
+				&Command{
+					name: "destroy", handler: (*Server).handleXGroupDestroy, arity: 4, flags: flagWrite,
+					firstKey: 2, lastKey: 2, keyStep: 1,
+					group: "stream", since: "5.0.0", summary: "Destroys a consumer group.", complexity: "O(N) where N is the number of entries in the group's pending entries list (PEL).",
+				},
+				&Command{
+					name: "createconsumer", handler: (*Server).handleXGroupCreateConsumer, arity: 5, flags: flagWrite | flagDenyOOM,
+					firstKey: 2, lastKey: 2, keyStep: 1,
+					group: "stream", since: "6.2.0", summary: "Creates a consumer in a consumer group.", complexity: "O(1)",
+				},
+				&Command{
+					name: "delconsumer", handler: (*Server).handleXGroupDelConsumer, arity: 5, flags: flagWrite,
+					firstKey: 2, lastKey: 2, keyStep: 1,
+					group: "stream", since: "5.0.0", summary: "Deletes a consumer from a consumer group.", complexity: "O(1)",
+				},
+			),
+		},
+		{
+			name: "xreadgroup", handler: (*Server).handleXReadGroup, arity: -7, flags: flagWrite | flagBlocking | flagMovableKeys,
+			getKeys: xreadKeys,
+			group:   "stream", since: "5.0.0", summary: "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise.", complexity: "For each stream mentioned: O(M) with M being the number of elements returned. If M is constant (e.g. always asking for the first 10 elements with COUNT), you can consider it O(1). On the other side when XREADGROUP blocks, XADD will pay the O(N) time in order to serve the N clients blocked on the stream getting new data.",
+		},
+		{
+			name: "xack", handler: (*Server).handleXAck, arity: -4, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "5.0.0", summary: "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream.", complexity: "O(1) for each message ID processed.",
+		},
+		{
+			name: "xpending", handler: (*Server).handleXPending, arity: -3, flags: flagReadonly,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "5.0.0", summary: "Returns the information and entries from a stream consumer group's pending entries list.", complexity: "O(N) with N being the number of elements returned, so asking for a small fixed number of entries per call is O(1). O(M), where M is the total number of entries scanned when used with the IDLE filter. When the command returns just the summary and the list of consumers is small, it runs in O(1) time; otherwise, an additional O(N) time for iterating every consumer.",
+		},
+		{
+			name: "xclaim", handler: (*Server).handleXClaim, arity: -6, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "5.0.0", summary: "Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member.", complexity: "O(log N) with N being the number of messages in the PEL of the consumer group.",
+		},
+		{
+			name: "xautoclaim", handler: (*Server).handleXAutoClaim, arity: -6, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "stream", since: "6.2.0", summary: "Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member.", complexity: "O(1) if COUNT is small.",
+		},
+		{
+			name: "xinfo", arity: -2,
+			group: "stream", since: "5.0.0", summary: "A container for stream introspection commands.", complexity: "Depends on subcommand.",
+			subcommands: subcommandTable("xinfo",
+				&Command{
+					name: "stream", handler: (*Server).handleXInfoStream, arity: -3, flags: flagReadonly,
+					firstKey: 2, lastKey: 2, keyStep: 1,
+					group: "stream", since: "5.0.0", summary: "Returns information about a stream.", complexity: "O(1)",
+				},
+				&Command{
+					name: "groups", handler: (*Server).handleXInfoGroups, arity: 3, flags: flagReadonly,
+					firstKey: 2, lastKey: 2, keyStep: 1,
+					group: "stream", since: "5.0.0", summary: "Returns a list of the consumer groups of a stream.", complexity: "O(1)",
+				},
+				&Command{
+					name: "consumers", handler: (*Server).handleXInfoConsumers, arity: 4, flags: flagReadonly,
+					firstKey: 2, lastKey: 2, keyStep: 1,
+					group: "stream", since: "5.0.0", summary: "Returns a list of the consumers in a consumer group.", complexity: "O(1)",
+				},
+			),
+		},
+		{
+			name: "del", handler: (*Server).handleDel, arity: -2, flags: flagWrite,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "generic", since: "1.0.0", summary: "Deletes one or more keys.", complexity: "O(N) where N is the number of keys that will be removed",
+		},
+		{
+			name: "unlink", handler: (*Server).handleDel, arity: -2, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "generic", since: "4.0.0", summary: "Asynchronously deletes one or more keys.", complexity: "O(1) for each key removed regardless of its size",
+		},
+		{
+			name: "exists", handler: (*Server).handleExists, arity: -2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "generic", since: "1.0.0", summary: "Determines whether one or more keys exist.", complexity: "O(N) where N is the number of keys to check",
+		},
+		{
+			name: "rename", handler: (*Server).handleRename, arity: 3, flags: flagWrite,
+			firstKey: 1, lastKey: 2, keyStep: 1,
+			group: "generic", since: "1.0.0", summary: "Renames a key and overwrites the destination.", complexity: "O(1)",
+		},
+		{
+			name: "renamenx", handler: (*Server).handleRenameNX, arity: 3, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 2, keyStep: 1,
+			group: "generic", since: "1.0.0", summary: "Renames a key only when the target key name doesn't exist.", complexity: "O(1)",
+		},
+		{
+			name: "copy", handler: (*Server).handleCopy, arity: -3, flags: flagWrite | flagDenyOOM,
+			firstKey: 1, lastKey: 2, keyStep: 1,
+			group: "generic", since: "6.2.0", summary: "Copies the value of a key to a new key.", complexity: "O(N) worst case for collections, where N is the number of nested items",
+		},
+		{
+			name: "touch", handler: (*Server).handleTouch, arity: -2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "generic", since: "3.2.1", summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.", complexity: "O(N) where N is the number of keys that will be touched",
+		},
+		{
+			name: "expire", handler: (*Server).handleExpire, arity: -3, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "generic", since: "1.0.0", summary: "Sets the expiration time of a key in seconds.", complexity: "O(1)",
+		},
+		{
+			name: "pexpire", handler: (*Server).handlePExpire, arity: -3, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "generic", since: "2.6.0", summary: "Sets the expiration time of a key in milliseconds.", complexity: "O(1)",
+		},
+		{
+			name: "expireat", handler: (*Server).handleExpireAt, arity: -3, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "generic", since: "1.2.0", summary: "Sets the expiration time of a key to a Unix timestamp.", complexity: "O(1)",
+		},
+		{
+			name: "pexpireat", handler: (*Server).handlePExpireAt, arity: -3, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "generic", since: "2.6.0", summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", complexity: "O(1)",
+		},
+		{
+			name: "ttl", handler: (*Server).handleTTL, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "generic", since: "1.0.0", summary: "Returns the expiration time in seconds of a key.", complexity: "O(1)",
+		},
+		{
+			name: "pttl", handler: (*Server).handlePTTL, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "generic", since: "2.6.0", summary: "Returns the expiration time in milliseconds of a key.", complexity: "O(1)",
+		},
+		{
+			name: "expiretime", handler: (*Server).handleExpireTime, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "generic", since: "7.0.0", summary: "Returns the expiration time of a key as a Unix timestamp.", complexity: "O(1)",
+		},
+		{
+			name: "pexpiretime", handler: (*Server).handlePExpireTime, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "generic", since: "7.0.0", summary: "Returns the expiration time of a key as a Unix milliseconds timestamp.", complexity: "O(1)",
+		},
+		{
+			name: "persist", handler: (*Server).handlePersist, arity: 2, flags: flagWrite | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "generic", since: "2.2.0", summary: "Removes the expiration time of a key.", complexity: "O(1)",
+		},
+		{
+			name: "type", handler: (*Server).handleType, arity: 2, flags: flagReadonly | flagFast,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "generic", since: "1.0.0", summary: "Determines the type of value stored at a key.", complexity: "O(1)",
+		},
+		{
+			name: "keys", handler: (*Server).handleKeys, arity: 2, flags: flagReadonly,
+			group: "generic", since: "1.0.0", summary: "Returns all key names that match a pattern.", complexity: "O(N) with N being the number of keys in the database",
+		},
+		{
+			name: "flushdb", handler: (*Server).handleFlush, arity: -1, flags: flagWrite,
+			group: "server", since: "1.0.0", summary: "Remove all keys from the current database.", complexity: "O(N) where N is the number of keys in the selected database",
+		},
+		{
+			name: "flushall", handler: (*Server).handleFlush, arity: -1, flags: flagWrite,
+			group: "server", since: "1.0.0", summary: "Removes all keys from all databases.", complexity: "O(N) where N is the total number of keys in all databases",
+		},
+		{
+			name: "save", handler: (*Server).handleSave, arity: 1, flags: flagAdmin | flagNoScript | flagNoMulti,
+			group: "server", since: "1.0.0", summary: "Synchronously saves the database(s) to disk.", complexity: "O(N) where N is the total number of keys in all databases",
+		},
+		{
+			name: "replconf", handler: (*Server).handleREPLConf, arity: -1, flags: flagAdmin | flagNoScript | flagLoading | flagStale,
+			group: "server", since: "3.0.0", summary: "An internal command for configuring the replication stream.", complexity: "O(1)",
+		},
+		{
+			name: "psync", handler: (*Server).handlePSync, arity: -3, flags: flagAdmin | flagNoScript | flagNoMulti,
+			group: "server", since: "2.8.0", summary: "An internal command used in replication.", complexity: "",
+		},
+		{
+			name: "wait", handler: (*Server).handleWait, arity: 3, flags: flagNoScript,
+			group: "generic", since: "3.0.0", summary: "Blocks until the asynchronous replication of all preceding write commands sent by the connection is completed.", complexity: "O(1)",
+		},
+		{
+			name: "eval", handler: (*Server).handleEval, arity: -3, flags: flagNoScript | flagStale | flagMovableKeys | flagExclusive,
+			getKeys: evalKeys,
+			group:   "scripting", since: "2.6.0", summary: "Executes a server-side Lua script.", complexity: "Depends on the script that is executed.",
+		},
+		{
+			name: "evalsha", handler: (*Server).handleEvalSha, arity: -3, flags: flagNoScript | flagStale | flagMovableKeys | flagExclusive,
+			getKeys: evalKeys,
+			group:   "scripting", since: "2.6.0", summary: "Executes a server-side Lua script by SHA1 digest.", complexity: "Depends on the script that is executed.",
+		},
+		{
+			name: "eval_ro", handler: (*Server).handleEvalRo, arity: -3, flags: flagReadonly | flagNoScript | flagStale | flagMovableKeys | flagExclusive,
+			getKeys: evalKeys,
+			group:   "scripting", since: "7.0.0", summary: "Executes a read-only server-side Lua script.", complexity: "Depends on the script that is executed.",
+		},
+		{
+			name: "evalsha_ro", handler: (*Server).handleEvalShaRo, arity: -3, flags: flagReadonly | flagNoScript | flagStale | flagMovableKeys | flagExclusive,
+			getKeys: evalKeys,
+			group:   "scripting", since: "7.0.0", summary: "Executes a read-only server-side Lua script by SHA1 digest.", complexity: "Depends on the script that is executed.",
+		},
+		{
+			name: "script", arity: -2,
+			group: "scripting", since: "2.6.0", summary: "A container for Lua scripts management commands.", complexity: "Depends on subcommand.",
+			subcommands: subcommandTable("script",
+				&Command{
+					name: "load", handler: (*Server).handleScriptLoad, arity: 3, flags: flagNoScript | flagStale,
+					group: "scripting", since: "2.6.0", summary: "Loads a server-side Lua script to the script cache.", complexity: "O(N) with N being the length in bytes of the script body.",
+				},
+				&Command{
+					name: "exists", handler: (*Server).handleScriptExists, arity: -3, flags: flagNoScript,
+					group: "scripting", since: "2.6.0", summary: "Determines whether server-side Lua scripts exist in the script cache.", complexity: "O(N) with N being the number of scripts to check (so checking a single script is an O(1) operation).",
+				},
+				&Command{
+					name: "flush", handler: (*Server).handleScriptFlush, arity: -2, flags: flagNoScript,
+					group: "scripting", since: "2.6.0", summary: "Removes all server-side Lua scripts from the script cache.", complexity: "O(N) with N being the number of scripts in cache",
+				},
+				&Command{
+					name: "kill", handler: (*Server).handleScriptKill, arity: 2, flags: flagNoScript | flagAllowBusy,
+					group: "scripting", since: "2.6.0", summary: "Terminates a server-side Lua script during execution.", complexity: "O(1)",
+				},
+			),
+		},
+		{
+			name: "fcall", handler: (*Server).handleFcall, arity: -3, flags: flagNoScript | flagStale | flagMovableKeys | flagExclusive,
+			getKeys: evalKeys,
+			group:   "scripting", since: "7.0.0", summary: "Invokes a function.", complexity: "Depends on the function that is executed.",
+		},
+		{
+			name: "fcall_ro", handler: (*Server).handleFcallRo, arity: -3, flags: flagReadonly | flagNoScript | flagStale | flagMovableKeys | flagExclusive,
+			getKeys: evalKeys,
+			group:   "scripting", since: "7.0.0", summary: "Invokes a read-only function.", complexity: "Depends on the function that is executed.",
+		},
+		{
+			name: "function", arity: -2,
+			group: "scripting", since: "7.0.0", summary: "A container for function commands.", complexity: "Depends on subcommand.",
+			subcommands: subcommandTable("function",
+				&Command{
+					name: "load", handler: (*Server).handleFunctionLoad, arity: -3, flags: flagWrite | flagDenyOOM | flagNoScript | flagExclusive,
+					group: "scripting", since: "7.0.0", summary: "Creates a library.", complexity: "O(1) (considering compilation time is redundant)",
+				},
+				&Command{
+					name: "delete", handler: (*Server).handleFunctionDelete, arity: 3, flags: flagWrite | flagNoScript | flagExclusive,
+					group: "scripting", since: "7.0.0", summary: "Deletes a library and its functions.", complexity: "O(1)",
+				},
+				&Command{
+					name: "flush", handler: (*Server).handleFunctionFlush, arity: -2, flags: flagWrite | flagNoScript | flagExclusive,
+					group: "scripting", since: "7.0.0", summary: "Deletes all libraries and functions.", complexity: "O(N) where N is the number of functions deleted",
+				},
+				&Command{
+					name: "list", handler: (*Server).handleFunctionList, arity: -2, flags: flagNoScript,
+					group: "scripting", since: "7.0.0", summary: "Returns information about all libraries.", complexity: "O(N) where N is the number of functions",
+				},
+				&Command{
+					name: "dump", handler: (*Server).handleFunctionDump, arity: 2, flags: flagNoScript,
+					group: "scripting", since: "7.0.0", summary: "Dumps all libraries into a serialized binary payload.", complexity: "O(N) where N is the number of functions",
+				},
+				&Command{
+					name: "restore", handler: (*Server).handleFunctionRestore, arity: -3, flags: flagWrite | flagDenyOOM | flagNoScript | flagExclusive,
+					group: "scripting", since: "7.0.0", summary: "Restores all libraries from a payload.", complexity: "O(N) where N is the number of functions on the payload",
+				},
+				&Command{
+					name: "kill", handler: (*Server).handleFunctionKill, arity: 2, flags: flagNoScript | flagAllowBusy,
+					group: "scripting", since: "7.0.0", summary: "Terminates a function during execution.", complexity: "O(1)",
+				},
+				&Command{
+					name: "stats", handler: (*Server).handleFunctionStats, arity: 2, flags: flagNoScript | flagAllowBusy,
+					group: "scripting", since: "7.0.0", summary: "Returns information about a function during execution.", complexity: "O(1)",
+				},
+			),
+		},
+		{
+			name: "watch", handler: (*Server).handleWatch, arity: -2, flags: flagNoScript | flagLoading | flagStale | flagFast | flagAllowBusy,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "transactions", since: "2.2.0", summary: "Monitors changes to keys to determine the execution of a transaction.", complexity: "O(1) for every key.",
+		},
+		{
+			name: "unwatch", handler: (*Server).handleUnwatch, arity: 1, flags: flagNoScript | flagLoading | flagStale | flagFast | flagAllowBusy,
+			group: "transactions", since: "2.2.0", summary: "Forgets about watched keys of a transaction.", complexity: "O(1)",
+		},
+		{
+			name: "multi", handler: (*Server).handleMulti, arity: 1, flags: flagNoScript | flagLoading | flagStale | flagFast | flagAllowBusy,
+			group: "transactions", since: "1.2.0", summary: "Starts a transaction.", complexity: "O(1)",
+		},
+		{
+			name: "exec", handler: (*Server).handleExec, arity: 1, flags: flagNoScript | flagLoading | flagStale,
+			group: "transactions", since: "1.2.0", summary: "Executes all commands in a transaction.", complexity: "Depends on commands in the transaction",
+		},
+		{
+			name: "discard", handler: (*Server).handleDiscard, arity: 1, flags: flagNoScript | flagLoading | flagStale | flagFast | flagAllowBusy,
+			group: "transactions", since: "2.0.0", summary: "Discards a transaction.", complexity: "O(N), when N is the number of queued commands",
+		},
+		{
+			name: "subscribe", handler: (*Server).handleSubscribe, arity: -2, flags: flagPubSub | flagNoScript | flagLoading | flagStale | flagNoMulti,
+			group: "pubsub", since: "2.0.0", summary: "Listens for messages published to channels.", complexity: "O(N) where N is the number of channels to subscribe to.",
+		},
+		{
+			name: "unsubscribe", handler: (*Server).handleUnsubscribe, arity: -1, flags: flagPubSub | flagNoScript | flagLoading | flagStale | flagNoMulti,
+			group: "pubsub", since: "2.0.0", summary: "Stops listening to messages posted to channels.", complexity: "O(N) where N is the number of channels to unsubscribe.",
+		},
+		{
+			name: "psubscribe", handler: (*Server).handlePSubscribe, arity: -2, flags: flagPubSub | flagNoScript | flagLoading | flagStale | flagNoMulti,
+			group: "pubsub", since: "2.0.0", summary: "Listens for messages published to channels that match one or more patterns.", complexity: "O(N) where N is the number of patterns to subscribe to.",
+		},
+		{
+			name: "punsubscribe", handler: (*Server).handlePUnsubscribe, arity: -1, flags: flagPubSub | flagNoScript | flagLoading | flagStale | flagNoMulti,
+			group: "pubsub", since: "2.0.0", summary: "Stops listening to messages published to channels that match one or more patterns.", complexity: "O(N) where N is the number of patterns to unsubscribe.",
+		},
+		{
+			name: "ssubscribe", handler: (*Server).handleSSubscribe, arity: -2, flags: flagPubSub | flagNoScript | flagLoading | flagStale | flagNoMulti,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "pubsub", since: "7.0.0", summary: "Listens for messages published to shard channels.", complexity: "O(N) where N is the number of shard channels to subscribe to.",
+		},
+		{
+			name: "sunsubscribe", handler: (*Server).handleSUnsubscribe, arity: -1, flags: flagPubSub | flagNoScript | flagLoading | flagStale | flagNoMulti,
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			group: "pubsub", since: "7.0.0", summary: "Stops listening to messages posted to shard channels.", complexity: "O(N) where N is the number of shard channels to unsubscribe.",
+		},
+		{
+			name: "publish", handler: (*Server).handlePublish, arity: 3, flags: flagPubSub | flagLoading | flagStale | flagFast | flagMayReplicate,
+			group: "pubsub", since: "2.0.0", summary: "Posts a message to a channel.", complexity: "O(N+M) where N is the number of clients subscribed to the receiving channel and M is the total number of subscribed patterns (by any client).",
+		},
+		{
+			name: "spublish", handler: (*Server).handleSPublish, arity: 3, flags: flagPubSub | flagLoading | flagStale | flagFast | flagMayReplicate,
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			group: "pubsub", since: "7.0.0", summary: "Post a message to a shard channel", complexity: "O(N) where N is the number of clients subscribed to the receiving shard channel.",
+		},
+		{
+			name: "pubsub", arity: -2,
+			group: "pubsub", since: "2.8.0", summary: "A container for Pub/Sub commands.", complexity: "Depends on subcommand.",
+			subcommands: subcommandTable("pubsub",
+				&Command{
+					name: "channels", handler: (*Server).handlePubSubChannels, arity: -2, flags: flagPubSub | flagLoading | flagStale,
+					group: "pubsub", since: "2.8.0", summary: "Returns the active channels.", complexity: "O(N) where N is the number of active channels, and assuming constant time pattern matching (relatively short channels and patterns)",
+				},
+				&Command{
+					name: "numsub", handler: (*Server).handlePubSubNumSub, arity: -2, flags: flagPubSub | flagLoading | flagStale,
+					group: "pubsub", since: "2.8.0", summary: "Returns a count of subscribers to channels.", complexity: "O(N) for the NUMSUB subcommand, where N is the number of requested channels",
+				},
+				&Command{
+					name: "numpat", handler: (*Server).handlePubSubNumPat, arity: 2, flags: flagPubSub | flagLoading | flagStale,
+					group: "pubsub", since: "2.8.0", summary: "Returns a count of unique pattern subscriptions.", complexity: "O(1)",
+				},
+				&Command{
+					name: "shardchannels", handler: (*Server).handlePubSubShardChannels, arity: -2, flags: flagPubSub | flagLoading | flagStale,
+					group: "pubsub", since: "7.0.0", summary: "Returns the active shard channels.", complexity: "O(N) where N is the number of active shard channels, and assuming constant time pattern matching (relatively short shard channels).",
+				},
+				&Command{
+					name: "shardnumsub", handler: (*Server).handlePubSubShardNumSub, arity: -2, flags: flagPubSub | flagLoading | flagStale,
+					group: "pubsub", since: "7.0.0", summary: "Returns the count of subscribers of shard channels.", complexity: "O(N) for the SHARDNUMSUB subcommand, where N is the number of requested shard channels",
+				},
+			),
+		},
+	}
+
+	table := make(map[string]*Command, len(commands))
+	for _, cmd := range commands {
+		table[cmd.name] = cmd
+	}
+	return table
+}
+
+func subcommandTable(parent string, subcommands ...*Command) map[string]*Command {
+	table := make(map[string]*Command, len(subcommands))
+	for _, sub := range subcommands {
+		table[sub.name] = sub
+		sub.name = parent + "|" + sub.name
+	}
+	return table
+}
+
+// lookupCommand resolves req to a command (or subcommand) from the command
+// table and validates its arity. When the request cannot be dispatched, the
+// error reply to send back is returned instead.
+func (s *Server) lookupCommand(req [][]byte) (*Command, []byte) {
+	cmd, ok := s.commands[strings.ToLower(string(req[0]))]
+	if !ok {
+		return nil, unknownCommandError(req)
+	}
+	if cmd.subcommands != nil && len(req) >= 2 {
+		sub, ok := cmd.subcommands[strings.ToLower(string(req[1]))]
+		if !ok {
+			return nil, parser.AppendError(nil, fmt.Sprintf("ERR unknown subcommand '%.128s'. Try %s HELP.",
+				req[1], strings.ToUpper(cmd.name)))
+		}
+		cmd = sub
+	}
+	if !cmd.checkArity(len(req)) {
+		return nil, parser.AppendError(nil, fmt.Sprintf("ERR wrong number of arguments for '%s' command", cmd.name))
+	}
+	return cmd, nil
+}
+
+func unknownCommandError(req [][]byte) []byte {
+	var args strings.Builder
+	for _, arg := range req[1:] {
+		if args.Len() >= 128 {
+			break
+		}
+		fmt.Fprintf(&args, "'%.*s' ", 128-args.Len(), arg)
+	}
+	return parser.AppendError(nil, fmt.Sprintf("ERR unknown command '%.128s', with args beginning with: %s",
+		req[0], args.String()))
+}
+
+func xreadKeys(args [][]byte) []int {
+	for i, arg := range args {
+		if strings.EqualFold(string(arg), "streams") {
+			numKeys := (len(args) - i - 1) / 2
+			keys := make([]int, 0, numKeys)
+			for k := i + 1; k <= i+numKeys; k++ {
+				keys = append(keys, k)
+			}
+			return keys
+		}
+	}
+	return nil
+}
+
+// lmpopKeys returns the key positions of LMPOP numkeys key [key ...] ...
+func lmpopKeys(args [][]byte) []int {
+	return numKeysKeys(args, 1)
+}
+
+// sintercardKeys returns the key positions of SINTERCARD numkeys key [key ...] ...
+func sintercardKeys(args [][]byte) []int {
+	return numKeysKeys(args, 1)
+}
+
+// blmpopKeys returns the key positions of BLMPOP timeout numkeys key [key ...] ...
+func blmpopKeys(args [][]byte) []int {
+	return numKeysKeys(args, 2)
+}
+
+// evalKeys returns the key positions of EVAL script numkeys key [key ...] ...
+func evalKeys(args [][]byte) []int {
+	return numKeysKeys(args, 2)
+}
+
+// zstoreKeys returns the key positions of ZUNIONSTORE destination numkeys key
+// [key ...] ...
+func zstoreKeys(args [][]byte) []int {
+	keys := numKeysKeys(args, 2)
+	if keys == nil {
+		return nil
+	}
+	return append([]int{1}, keys...)
+}
+
+// numKeysKeys returns the positions of the keys that follow the numkeys
+// argument at index i.
+func numKeysKeys(args [][]byte, i int) []int {
+	if i >= len(args) {
+		return nil
+	}
+	numKeys, err := strconv.Atoi(string(args[i]))
+	if err != nil || numKeys <= 0 || numKeys > len(args)-i-1 {
+		return nil
+	}
+	keys := make([]int, numKeys)
+	for k := range keys {
+		keys[k] = i + 1 + k
+	}
+	return keys
+}
@@ -1,8 +1,215 @@
 package server
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultClientQueryBufferLimit = 1024 * 1024 * 1024
+	DefaultProtoMaxBulkLen        = 512 * 1024 * 1024
+	DefaultHz                     = 10
+	DefaultLuaTimeLimit           = 5000
+	// MaxHz bounds how often the server runs its background tasks.
+	MaxHz = 500
+)
+
 type Config struct {
-	Dir        string
-	DBFilename string
-	Port       uint16
-	ReplicaOf  string
+	Dir                    string
+	DBFilename             string
+	Port                   uint16
+	ReplicaOf              string
+	ClientQueryBufferLimit int64
+	ProtoMaxBulkLen        int64
+	Hz                     int
+	// LuaTimeLimit is the time in milliseconds after which a running script
+	// makes the server reply BUSY to other clients.
+	LuaTimeLimit int64
+	// ReplicaServeStaleData lets a replica that lost the link with its
+	// master keep serving commands with the data it has.
+	ReplicaServeStaleData bool
+	// PubSubOutputBufferLimit bounds the output pending for the clients
+	// subscribed to channels or patterns.
+	PubSubOutputBufferLimit OutputBufferLimit
+}
+
+// OutputBufferLimit bounds the output pending for a client, which is
+// disconnected once it exceeds Hard bytes, or Soft bytes for SoftSeconds in a
+// row. Zero disables a limit.
+type OutputBufferLimit struct {
+	Hard        int64
+	Soft        int64
+	SoftSeconds int64
+}
+
+// DefaultPubSubOutputBufferLimit is the pubsub class of the default
+// client-output-buffer-limit.
+var DefaultPubSubOutputBufferLimit = OutputBufferLimit{Hard: 32 * 1024 * 1024, Soft: 8 * 1024 * 1024, SoftSeconds: 60}
+
+// configParam exposes a Config field through CONFIG GET and CONFIG SET.
+// Parameters without a setter are immutable at runtime.
+type configParam struct {
+	name string
+	get  func(c *Config) string
+	set  func(c *Config, value string) error
+}
+
+var configParams = []configParam{
+	{
+		name: "dir",
+		get:  func(c *Config) string { return c.Dir },
+	},
+	{
+		name: "dbfilename",
+		get:  func(c *Config) string { return c.DBFilename },
+	},
+	{
+		name: "port",
+		get:  func(c *Config) string { return strconv.Itoa(int(c.Port)) },
+	},
+	{
+		name: "client-query-buffer-limit",
+		get:  func(c *Config) string { return strconv.FormatInt(c.ClientQueryBufferLimit, 10) },
+		set: func(c *Config, value string) error {
+			limit, err := parseMemory(value)
+			if err != nil {
+				return err
+			}
+			if limit < 1024*1024 {
+				return errors.New("argument must be between 1048576 and 9223372036854775807 inclusive")
+			}
+			c.ClientQueryBufferLimit = limit
+			return nil
+		},
+	},
+	{
+		name: "proto-max-bulk-len",
+		get:  func(c *Config) string { return strconv.FormatInt(c.ProtoMaxBulkLen, 10) },
+		set: func(c *Config, value string) error {
+			limit, err := parseMemory(value)
+			if err != nil {
+				return err
+			}
+			if limit < 1024*1024 {
+				return errors.New("argument must be between 1048576 and 9223372036854775807 inclusive")
+			}
+			c.ProtoMaxBulkLen = limit
+			return nil
+		},
+	},
+	{
+		name: "hz",
+		get:  func(c *Config) string { return strconv.Itoa(c.Hz) },
+		set: func(c *Config, value string) error {
+			hz, err := strconv.Atoi(value)
+			if err != nil {
+				return errors.New("argument couldn't be parsed into an integer")
+			}
+			// Out of range values are clamped rather than rejected.
+			c.Hz = min(max(hz, 1), MaxHz)
+			return nil
+		},
+	},
+	{
+		name: "lua-time-limit",
+		get:  func(c *Config) string { return strconv.FormatInt(c.LuaTimeLimit, 10) },
+		set: func(c *Config, value string) error {
+			limit, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || limit < 0 {
+				return errors.New("argument must be between 0 and 9223372036854775807 inclusive")
+			}
+			c.LuaTimeLimit = limit
+			return nil
+		},
+	},
+	{
+		name: "replica-serve-stale-data",
+		get: func(c *Config) string {
+			if c.ReplicaServeStaleData {
+				return "yes"
+			}
+			return "no"
+		},
+		set: func(c *Config, value string) error {
+			switch strings.ToLower(value) {
+			case "yes":
+				c.ReplicaServeStaleData = true
+			case "no":
+				c.ReplicaServeStaleData = false
+			default:
+				return errors.New("argument must be 'yes' or 'no'")
+			}
+			return nil
+		},
+	},
+	{
+		// Only the pubsub class of client-output-buffer-limit is supported.
+		name: "client-output-buffer-limit",
+		get: func(c *Config) string {
+			limit := c.PubSubOutputBufferLimit
+			return fmt.Sprintf("pubsub %d %d %d", limit.Hard, limit.Soft, limit.SoftSeconds)
+		},
+		set: func(c *Config, value string) error {
+			args := strings.Fields(value)
+			if len(args) == 0 || len(args)%4 != 0 {
+				return errors.New("Wrong number of arguments in buffer limit configuration.")
+			}
+			limit := c.PubSubOutputBufferLimit
+			for i := 0; i < len(args); i += 4 {
+				if !strings.EqualFold(args[i], "pubsub") {
+					return errors.New("Invalid client class specified in buffer limit configuration.")
+				}
+				hard, err := parseMemory(args[i+1])
+				if err != nil {
+					return errors.New("Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+				}
+				soft, err := parseMemory(args[i+2])
+				if err != nil {
+					return errors.New("Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+				}
+				seconds, err := strconv.ParseInt(args[i+3], 10, 64)
+				if err != nil || seconds < 0 {
+					return errors.New("Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+				}
+				limit = OutputBufferLimit{Hard: hard, Soft: soft, SoftSeconds: seconds}
+			}
+			c.PubSubOutputBufferLimit = limit
+			return nil
+		},
+	},
+}
+
+func findConfigParam(name string) *configParam {
+	for i := range configParams {
+		if configParams[i].name == name {
+			return &configParams[i]
+		}
+	}
+	return nil
+}
+
+// parseMemory parses a memory amount such as "512mb" or "1gb" into bytes.
+func parseMemory(value string) (int64, error) {
+	value = strings.ToLower(value)
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"kb", 1024}, {"mb", 1024 * 1024}, {"gb", 1024 * 1024 * 1024},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000}, {"b", 1},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSuffix(value, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("argument must be a memory value")
+	}
+	return n * multiplier, nil
 }
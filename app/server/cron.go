@@ -0,0 +1,72 @@
+package server
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// expireCycleCPUPercent is the share of each cron period that the active
+// expire cycle may use.
+const expireCycleCPUPercent = 25
+
+// Stats holds the counters reported in the stats section of INFO.
+type Stats struct {
+	expiredKeys                atomic.Int64
+	expiredSubkeys             atomic.Int64
+	expiredStalePerc           atomic.Uint64
+	expireCycleCPUMilliseconds atomic.Int64
+}
+
+// serverCron runs the periodic background tasks hz times per second.
+func (s *Server) serverCron() {
+	for {
+		period := time.Second / time.Duration(s.hz())
+		time.Sleep(period)
+		s.activeExpireCycle(period * expireCycleCPUPercent / 100)
+	}
+}
+
+func (s *Server) hz() int {
+	s.configMutex.RLock()
+	defer s.configMutex.RUnlock()
+	return s.config.Hz
+}
+
+// activeExpireCycle removes expired keys, and then expired hash fields, from
+// every database, spending at most timeLimit, and updates the expire
+// statistics.
+func (s *Server) activeExpireCycle(timeLimit time.Duration) {
+	start := time.Now()
+	deadline := start.Add(timeLimit)
+	var sampled, expired int
+	for _, store := range s.stores {
+		if time.Now().After(deadline) {
+			break
+		}
+		n, e := store.ActiveExpireCycle(deadline)
+		sampled += n
+		expired += e
+	}
+	var expiredFields int
+	for _, store := range s.stores {
+		if time.Now().After(deadline) {
+			break
+		}
+		expiredFields += store.ActiveExpireFields(deadline)
+	}
+	elapsed := time.Since(start)
+
+	s.stats.expiredKeys.Add(int64(expired))
+	s.stats.expiredSubkeys.Add(int64(expiredFields))
+	s.stats.expireCycleCPUMilliseconds.Add(elapsed.Milliseconds())
+	// Like Redis, keep a running average of the share of sampled keys that
+	// were already expired.
+	var currentPerc float64
+	if sampled > 0 {
+		currentPerc = float64(expired) / float64(sampled)
+	}
+	stalePerc := math.Float64frombits(s.stats.expiredStalePerc.Load())
+	stalePerc = currentPerc*0.05 + stalePerc*0.95
+	s.stats.expiredStalePerc.Store(math.Float64bits(stalePerc))
+}
@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// functionChunkName is the name function libraries are compiled under, which
+// shows in the errors they raise.
+const functionChunkName = "user_function"
+
+// libraryLoadTimeout bounds the time the code of a library may run for while
+// it registers its functions.
+const libraryLoadTimeout = 500 * time.Millisecond
+
+type functionFlag uint8
+
+const (
+	functionNoWrites functionFlag = 1 << iota
+	functionAllowOOM
+	functionAllowStale
+	functionNoCluster
+	functionAllowCrossSlotKeys
+)
+
+var functionFlagNames = []struct {
+	flag functionFlag
+	name string
+}{
+	{functionNoWrites, "no-writes"},
+	{functionAllowOOM, "allow-oom"},
+	{functionAllowStale, "allow-stale"},
+	{functionNoCluster, "no-cluster"},
+	{functionAllowCrossSlotKeys, "allow-cross-slot-keys"},
+}
+
+// functionLibrary is a library loaded with FUNCTION LOAD, along with the
+// functions its code registered.
+type functionLibrary struct {
+	name      string
+	code      string
+	functions map[string]*luaFunction
+}
+
+type luaFunction struct {
+	name        string
+	library     *functionLibrary
+	description string
+	flags       functionFlag
+	callback    *lua.LFunction
+}
+
+func (f *luaFunction) hasFlag(flag functionFlag) bool {
+	return f.flags&flag != 0
+}
+
+func (f *luaFunction) flagNames() []string {
+	names := make([]string, 0, len(functionFlagNames))
+	for _, fl := range functionFlagNames {
+		if f.hasFlag(fl.flag) {
+			names = append(names, fl.name)
+		}
+	}
+	return names
+}
+
+// validFunctionName reports whether name is made of letters, digits and
+// underscores only.
+func validFunctionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLibraryMetadata parses the "#!lua name=<library>" line code starts
+// with, and returns the name of the library and its code with that line
+// blanked so that line numbers are kept.
+func parseLibraryMetadata(code string) (string, string, error) {
+	if !strings.HasPrefix(code, "#!") {
+		return "", "", errors.New("ERR Missing library metadata")
+	}
+	shebang, body := code, ""
+	if i := strings.IndexByte(code, '\n'); i >= 0 {
+		shebang, body = code[:i], code[i:]
+	}
+	parts := strings.Fields(shebang[2:])
+	if len(parts) == 0 || !strings.EqualFold(parts[0], "lua") {
+		engine := ""
+		if len(parts) > 0 {
+			engine = parts[0]
+		}
+		return "", "", fmt.Errorf("ERR Engine '%s' not found", engine)
+	}
+	name := ""
+	for _, part := range parts[1:] {
+		value, ok := strings.CutPrefix(part, "name=")
+		if !ok {
+			return "", "", fmt.Errorf("ERR Invalid metadata value given: %s", part)
+		}
+		name = value
+	}
+	if name == "" {
+		return "", "", errors.New("ERR Library name was not given")
+	}
+	if !validFunctionName(name) {
+		return "", "", errors.New("ERR Library names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	}
+	return name, body, nil
+}
+
+// newFunctionState creates the interpreter functions run in, whose redis
+// table also allows registering functions while a library is loaded.
+func (s *Server) newFunctionState() *lua.LState {
+	L := s.newLuaState()
+	redis := L.G.Global.RawGetString("redis").(*lua.LTable)
+	redis.RawSetString("register_function", L.NewFunction(s.luaRegisterFunction))
+	return L
+}
+
+// luaRegisterFunction implements redis.register_function, in both its
+// (name, callback) and named arguments forms.
+func (s *Server) luaRegisterFunction(L *lua.LState) int {
+	lib := s.loadingLibrary
+	if lib == nil {
+		L.RaiseError("redis.register_function can only be called on FUNCTION LOAD command")
+	}
+	f := &luaFunction{library: lib}
+	switch L.GetTop() {
+	case 1:
+		args, ok := L.Get(1).(*lua.LTable)
+		if !ok {
+			L.RaiseError("calling redis.register_function with a single argument is only applicable to Lua table (representing named arguments).")
+		}
+		args.ForEach(func(key, value lua.LValue) {
+			k, ok := key.(lua.LString)
+			if !ok {
+				L.RaiseError("named argument key given to redis.register_function is not a string")
+			}
+			switch k {
+			case "function_name":
+				name, ok := value.(lua.LString)
+				if !ok {
+					L.RaiseError("function_name argument given to redis.register_function must be a string")
+				}
+				f.name = string(name)
+			case "description":
+				description, ok := value.(lua.LString)
+				if !ok {
+					L.RaiseError("description argument given to redis.register_function must be a string")
+				}
+				f.description = string(description)
+			case "callback":
+				callback, ok := value.(*lua.LFunction)
+				if !ok {
+					L.RaiseError("callback argument given to redis.register_function must be a function")
+				}
+				f.callback = callback
+			case "flags":
+				flags, ok := value.(*lua.LTable)
+				if !ok {
+					L.RaiseError("flags argument to redis.register_function must be a table representing function flags")
+				}
+				f.flags = luaFunctionFlags(L, flags)
+			default:
+				L.RaiseError("unknown argument given to redis.register_function")
+			}
+		})
+	case 2:
+		name, ok := L.Get(1).(lua.LString)
+		if !ok {
+			L.RaiseError("first argument to redis.register_function must be a string")
+		}
+		callback, ok := L.Get(2).(*lua.LFunction)
+		if !ok {
+			L.RaiseError("second argument to redis.register_function must be a function")
+		}
+		f.name, f.callback = string(name), callback
+	default:
+		L.RaiseError("wrong number of arguments to redis.register_function")
+	}
+	switch {
+	case f.name == "":
+		L.RaiseError("redis.register_function must get a function name argument")
+	case f.callback == nil:
+		L.RaiseError("redis.register_function must get a callback argument")
+	case !validFunctionName(f.name):
+		L.RaiseError("Function names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	case lib.functions[f.name] != nil:
+		L.RaiseError("Function already exists in the library")
+	}
+	lib.functions[f.name] = f
+	return 0
+}
+
+func luaFunctionFlags(L *lua.LState, flags *lua.LTable) functionFlag {
+	var parsed functionFlag
+	flags.ForEach(func(_, value lua.LValue) {
+		name, ok := value.(lua.LString)
+		if !ok {
+			L.RaiseError("unknown flag given")
+		}
+		known := false
+		for _, fl := range functionFlagNames {
+			if fl.name == string(name) {
+				parsed |= fl.flag
+				known = true
+			}
+		}
+		if !known {
+			L.RaiseError("unknown flag given")
+		}
+	})
+	return parsed
+}
+
+// loadLibrary runs code to register the functions of its library, and
+// returns the name of the library. replace allows replacing a library of the
+// same name. The caller holds scriptMutex.
+func (s *Server) loadLibrary(code string, replace bool) (string, error) {
+	name, body, err := parseLibraryMetadata(code)
+	if err != nil {
+		return "", err
+	}
+	old := s.libraries[name]
+	if old != nil && !replace {
+		return "", fmt.Errorf("ERR Library '%s' already exists", name)
+	}
+	chunk, err := parse.Parse(strings.NewReader(body), functionChunkName)
+	if err != nil {
+		return "", fmt.Errorf("ERR Error compiling function: %s", strings.TrimSpace(err.Error()))
+	}
+	proto, err := lua.Compile(chunk, functionChunkName)
+	if err != nil {
+		return "", fmt.Errorf("ERR Error compiling function: %s", strings.TrimSpace(err.Error()))
+	}
+
+	if s.functionState == nil {
+		s.functionState = s.newFunctionState()
+	}
+	L := s.functionState
+	lib := &functionLibrary{name: name, code: code, functions: make(map[string]*luaFunction)}
+	ctx, cancel := context.WithTimeout(context.Background(), libraryLoadTimeout)
+	defer cancel()
+	s.loadingLibrary = lib
+	L.SetContext(ctx)
+	L.Push(L.NewFunctionFromProto(proto))
+	err = L.PCall(0, 0, nil)
+	L.RemoveContext()
+	L.SetTop(0)
+	s.loadingLibrary = nil
+	if err != nil {
+		msg := err.Error()
+		if ctx.Err() != nil {
+			msg = "FUNCTION LOAD timeout"
+		} else if apiErr, ok := err.(*lua.ApiError); ok {
+			msg = apiErr.Object.String()
+			if table, ok := apiErr.Object.(*lua.LTable); ok {
+				msg = strings.TrimPrefix(table.RawGetString("err").String(), "ERR ")
+			}
+		}
+		return "", fmt.Errorf("ERR Error registering functions: %s", msg)
+	}
+	if len(lib.functions) == 0 {
+		return "", errors.New("ERR No functions registered")
+	}
+	for fname := range lib.functions {
+		if f := s.functions[fname]; f != nil && f.library != old {
+			return "", fmt.Errorf("ERR Function %s already exists", fname)
+		}
+	}
+
+	if old != nil {
+		s.unloadLibrary(old)
+	}
+	s.libraries[name] = lib
+	for fname, f := range lib.functions {
+		s.functions[fname] = f
+	}
+	return name, nil
+}
+
+// unloadLibrary removes lib and its functions. The caller holds scriptMutex.
+func (s *Server) unloadLibrary(lib *functionLibrary) {
+	delete(s.libraries, lib.name)
+	for fname := range lib.functions {
+		delete(s.functions, fname)
+	}
+}
+
+// flushLibraries removes all the libraries along with the interpreter they
+// were loaded in. The caller holds scriptMutex.
+func (s *Server) flushLibraries() {
+	s.libraries = make(map[string]*functionLibrary)
+	s.functions = make(map[string]*luaFunction)
+	if s.functionState != nil {
+		s.functionState.Close()
+		s.functionState = nil
+	}
+}
+
+// restoreLibraries loads the libraries of codes, either all of them or none.
+// flush removes the loaded libraries first, and replace lets codes replace
+// the libraries of the same name.
+func (s *Server) restoreLibraries(codes []string, flush, replace bool) error {
+	s.scriptMutex.Lock()
+	defer s.scriptMutex.Unlock()
+	libraries, functions := s.libraries, s.functions
+	s.libraries = make(map[string]*functionLibrary, len(libraries))
+	s.functions = make(map[string]*luaFunction, len(functions))
+	if !flush {
+		for name, lib := range libraries {
+			s.libraries[name] = lib
+		}
+		for name, f := range functions {
+			s.functions[name] = f
+		}
+	}
+	for _, code := range codes {
+		if _, err := s.loadLibrary(code, replace); err != nil {
+			s.libraries, s.functions = libraries, functions
+			return err
+		}
+	}
+	return nil
+}
+
+// libraryCodes returns the code of the loaded libraries, sorted by library
+// name, as they are saved in RDB files and FUNCTION DUMP payloads.
+func (s *Server) libraryCodes() []string {
+	s.scriptMutex.Lock()
+	defer s.scriptMutex.Unlock()
+	names := make([]string, 0, len(s.libraries))
+	for name := range s.libraries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	codes := make([]string, len(names))
+	for i, name := range names {
+		codes[i] = s.libraries[name].code
+	}
+	return codes
+}
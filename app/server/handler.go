@@ -1,219 +1,339 @@
 package server
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"path"
-	"strconv"
+	"slices"
 	"strings"
 
 	"github.com/codecrafters-io/redis-starter-go/app/parser"
 	"github.com/codecrafters-io/redis-starter-go/app/persistence"
 )
 
+const (
+	readChunkSize = 16 * 1024
+	// replyFlushSize is the amount of pending output after which replies to
+	// a pipeline are written before the whole pipeline has been processed.
+	replyFlushSize = 64 * 1024
+	// Buffers that grew larger than maxReusableBufferSize are released
+	// instead of being kept around for the next request.
+	maxReusableBufferSize = 1024 * 1024
+)
+
+// handleClient serves a client connection. Every command available in the
+// query buffer is executed before the replies are written back in a single
+// write, so pipelined commands cost one round trip.
 func (s *Server) handleClient(conn net.Conn) {
-	buf := make([]byte, 0, 1024)
-	tmp := make([]byte, 1024)
+	client := s.newClient(conn)
+	defer s.removeClient(client)
+	query := make([]byte, 0, readChunkSize)
 
-outerLoop:
 	for {
-		n, err := conn.Read(tmp)
-		if err != nil {
-			if err != io.EOF {
-				log.Println("Error reading from client:", err)
+		if len(client.pendingQuery) > 0 {
+			// Data received while the client was blocked.
+			query = append(query, client.pendingQuery...)
+			client.pendingQuery = nil
+		} else {
+			query = slices.Grow(query, readChunkSize)
+			n, err := conn.Read(query[len(query):cap(query)])
+			if err != nil {
+				if err != io.EOF {
+					log.Println("Error reading from client:", err)
+				}
+				conn.Close()
+				return
 			}
+			query = query[:len(query)+n]
+		}
+
+		queryBufferLimit, maxBulkLen := s.clientLimits()
+		if int64(len(query)) > queryBufferLimit {
+			log.Printf("Closing client %s that reached max query buffer length (%d bytes)", conn.RemoteAddr(), len(query))
 			conn.Close()
 			return
 		}
-		buf = append(buf, tmp[:n]...)
-		for len(buf) > 0 {
-			req, remainder, err := parser.ParseCommand(buf[:n])
+
+		consumed := 0
+		for consumed < len(query) {
+			req, remainder, err := parser.ParseCommandLimit(query[consumed:], maxBulkLen)
 			if err != nil {
 				if err == parser.ErrIncomplete {
 					// Command is incomplete, wait for more data
 					break
 				}
 				log.Printf("Error parsing command: %v", err)
-				conn.Write(parser.AppendError(nil, err.Error()))
+				client.reply = parser.AppendError(client.reply, "ERR "+err.Error())
+				client.flushReply()
 				conn.Close()
 				return
 			}
-			buf = remainder
+			consumed = len(query) - len(remainder)
 			if len(req) == 0 {
-				continue outerLoop
+				continue
 			}
-			response, keepListening := s.handleCommand(req, conn)
+			response, keepListening := s.handleCommand(req, client)
 			if !keepListening {
+				// QUIT is answered before the connection is closed, while
+				// the connection of PSYNC now carries the replication
+				// stream.
+				if response != nil {
+					client.reply = append(client.reply, response...)
+					client.close()
+				}
 				return
 			}
-
-			if len(response) > 0 {
-				conn.Write(response)
+			client.reply = append(client.reply, response...)
+			if len(client.reply) >= replyFlushSize {
+				if err := client.flushReply(); err != nil {
+					conn.Close()
+					return
+				}
 			}
 		}
+		// Keep the unparsed tail at the start of the query buffer.
+		query = append(query[:0], query[consumed:]...)
+
+		if err := client.flushReply(); err != nil {
+			conn.Close()
+			return
+		}
+		if cap(client.reply) > maxReusableBufferSize {
+			client.reply = make([]byte, 0, readChunkSize)
+		}
+		if cap(query) > maxReusableBufferSize && len(query) < readChunkSize {
+			query = append(make([]byte, 0, readChunkSize), query...)
+		}
 	}
 }
 
-func (s *Server) handleCommand(req [][]byte, conn net.Conn) (response []byte, keepListening bool) {
-	cmd := strings.ToLower(string(req[0]))
+// handleCommand dispatches req through the command table. The arguments alias
+// the connection's query buffer and are only valid during the call, so
+// anything retained afterwards must be copied.
+func (s *Server) handleCommand(req [][]byte, c *Client) (response []byte, keepListening bool) {
+	s.txMutex.RLock()
+	tx, exists := s.transactions[c]
+	s.txMutex.RUnlock()
+	inMulti := exists && tx.inMulti
 
-	switch cmd {
-	case "multi":
-		return s.handleMulti(conn), true
-	case "exec":
-		return s.handleExec(conn), true
-	case "discard":
-		return s.handleDiscard(conn), true
+	cmd, errReply := s.lookupCommand(req)
+	if errReply != nil {
+		if inMulti {
+			tx.failed = true
+		}
+		return errReply, true
 	}
-	s.txMutex.Lock()
-	tx, exists := s.transactions[conn]
-	s.txMutex.Unlock()
 
-	if exists && tx.inMulti {
-		tx.commands = append(tx.commands, req)
-		return parser.AppendString(nil, "QUEUED"), true
+	if !cmd.hasFlag(flagAllowBusy) && !c.master {
+		if errReply := s.busyScriptError(); errReply != nil {
+			if inMulti {
+				tx.failed = true
+			}
+			return errReply, true
+		}
 	}
-	switch cmd {
-	case "ping":
-		response = parser.AppendString(nil, "PONG")
-	case "echo":
-		response = parser.AppendString(nil, string(req[1]))
-	case "config":
-		response = s.handleConfig(req)
-	case "info":
-		response = s.handleInfo(req)
-	case "get":
-		response = s.handleGet(req)
-	case "set":
-		response = s.handleSet(req)
-		s.PropagateCommand(req)
-	case "incr":
-		response = s.handleIncr(req)
-		s.PropagateCommand(req)
-	case "xadd":
-		response = s.handleXAdd(req)
-		s.PropagateCommand(req)
-	case "xrange":
-		response = s.handleXRange(req)
-	case "xread":
-		response = s.handleXRead(req)
-	case "type":
-		response = s.handleType(req)
-	case "keys":
-		response = s.handleKeys(req)
-	case "save":
-		response = s.handleSave()
-	case "replconf":
-		response = s.handleREPLConf(req)
-	case "psync":
-		if s.info.role != "master" {
-			response = parser.AppendError(nil, "-1")
-		} else {
-			s.handlePSync(req, conn)
-			return nil, false
+
+	if !cmd.hasFlag(flagStale) {
+		if errReply := s.staleReplicaError(); errReply != nil {
+			if inMulti {
+				tx.failed = true
+			}
+			return errReply, true
 		}
-	case "wait":
-		if s.info.role == "master" {
-			response = s.handleWait(req)
-		} else {
-			response = parser.AppendError(nil, "-1")
+	}
+
+	if c.subscribed() && !c.resp3() {
+		switch cmd.name {
+		case "subscribe", "unsubscribe", "psubscribe", "punsubscribe", "ssubscribe", "sunsubscribe", "ping", "quit", "reset":
+		default:
+			return parser.AppendError(nil, fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context",
+				strings.ToLower(string(req[0])))), true
 		}
-	default:
-		response = parser.AppendError(nil, "-1")
+	}
+
+	switch cmd.name {
+	case "multi", "exec", "discard", "watch", "reset":
+		return cmd.handler(s, c, req), true
+	case "quit":
+		return cmd.handler(s, c, req), false
+	}
+	if inMulti {
+		if cmd.hasFlag(flagNoMulti) {
+			tx.failed = true
+			return parser.AppendError(nil, "ERR Command not allowed inside a transaction"), true
+		}
+		tx.commands = append(tx.commands, cloneArgs(req))
+		return parser.AppendString(nil, "QUEUED"), true
+	}
+
+	// Commands allowed while a script is busy do not wait for it.
+	if !c.inExec && !cmd.hasFlag(flagAllowBusy) {
+		unlock, errReply := s.lockKeyspace(c, cmd.hasFlag(flagExclusive))
+		if errReply != nil {
+			return errReply, true
+		}
+		defer unlock()
+	}
+	response = s.call(c, cmd, req)
+	if cmd.name == "psync" && response == nil {
+		// The connection now carries the replication stream.
+		return nil, false
 	}
 	return response, true
 }
 
-func (s *Server) handleConfig(req [][]byte) []byte {
-	if len(req) < 3 {
-		return parser.AppendError(nil, "1")
+// call runs cmd for c. The changes made by write commands are propagated,
+// or collected while running a transaction or a script, and wake up the
+// clients waiting for the keys written.
+func (s *Server) call(c *Client, cmd *Command, req [][]byte) []byte {
+	c.dirty = 0
+	c.propagate = nil
+	response := cmd.handler(s, c, req)
+	if c.dirty == 0 {
+		return response
 	}
-	switch strings.ToLower(string(req[1])) {
-	case "get":
-		response := parser.AppendArray(nil, len(req[2:])*2)
-		for _, arg := range req[2:] {
-			switch strings.ToLower(string(arg)) {
-			case "dir":
-				response = parser.AppendBulkString(response, "dir")
-				response = parser.AppendBulkString(response, s.config.Dir)
-			case "dbfilename":
-				response = parser.AppendBulkString(response, "dbfilename")
-				response = parser.AppendBulkString(response, s.config.DBFilename)
-			}
+	propagate := c.propagate
+	if propagate == nil {
+		propagate = [][][]byte{req}
+	}
+	switch {
+	case cmd.hasFlag(flagWrite):
+		s.touchWatchedKeys(cmd, req)
+		if c.inExec {
+			// The transaction is propagated and serves blocked clients
+			// once it is done.
+			c.execPropagate = append(c.execPropagate, propagate...)
+			c.execReady = append(c.execReady, cmd.keys(req)...)
+			return response
 		}
-		return response
+		s.propagateCommands(propagate)
+		s.signalKeysAsReady(cmd, req)
+	case cmd.hasFlag(flagMayReplicate):
+		// Commands such as PUBLISH leave the keyspace untouched but are
+		// propagated all the same.
+		if c.inExec {
+			c.execPropagate = append(c.execPropagate, propagate...)
+			return response
+		}
+		s.propagateCommands(propagate)
 	}
-	return parser.AppendError(nil, "-1")
+	return response
 }
 
-func (s *Server) handleInfo(req [][]byte) []byte {
-	for _, section := range req[1:] {
-		switch strings.ToLower(string(section)) {
-		case "replication":
-			return parser.AppendBulk(nil, s.getInfoReplication())
-		}
+// propagateCommands propagates commands to the replicas, if any.
+func (s *Server) propagateCommands(commands [][][]byte) {
+	if s.info.role != MasterRole {
+		return
+	}
+	for _, args := range commands {
+		s.PropagateCommand(args)
 	}
-	return parser.AppendBulk(nil, s.getInfoReplication())
 }
 
-func (s *Server) handleGet(req [][]byte) []byte {
-	value, ok := s.stores[0].Get(string(req[1]))
-	if !ok {
-		return parser.NullBulkString()
+func cloneArgs(req [][]byte) [][]byte {
+	size := 0
+	for _, arg := range req {
+		size += len(arg)
+	}
+	buf := make([]byte, 0, size)
+	args := make([][]byte, len(req))
+	for i, arg := range req {
+		buf = append(buf, arg...)
+		args[i] = buf[len(buf)-len(arg):]
 	}
-	return parser.AppendBulk(nil, value)
+	return args
 }
 
-func (s *Server) handleSet(req [][]byte) []byte {
-	var expiry int64
-	if len(req) == 5 && strings.ToLower(string(req[3])) == "px" {
-		var err error
-		expiry, err = strconv.ParseInt(string(req[4]), 10, 64)
-		if err != nil {
-			return parser.AppendError(nil, "1")
+func isError(response []byte) bool {
+	return len(response) > 0 && response[0] == parser.Error
+}
+
+func (s *Server) handlePing(c *Client, req [][]byte) []byte {
+	if len(req) > 2 {
+		return parser.AppendError(nil, "ERR wrong number of arguments for 'ping' command")
+	}
+	if c.subscribed() && !c.resp3() {
+		// Subscribed RESP2 clients expect every reply to be an array.
+		response := parser.AppendArray(nil, 2)
+		response = parser.AppendBulkString(response, "pong")
+		if len(req) == 2 {
+			return parser.AppendBulk(response, req[1])
 		}
+		return parser.AppendBulkString(response, "")
 	}
-	err := s.stores[0].Set(string(req[1]), req[2], expiry)
-	if err != nil {
-		return parser.AppendError(nil, "1")
+	if len(req) == 2 {
+		return parser.AppendBulk(nil, req[1])
 	}
-	return parser.AppendString(nil, "OK")
+	return parser.AppendString(nil, "PONG")
 }
 
-func (s *Server) handleIncr(req [][]byte) []byte {
-	if len(req) < 2 {
-		log.Println("Not enough arguments for INCR")
-		return parser.AppendError(nil, "-1")
-	}
-	key := string(req[1])
-	value, ok := s.stores[0].Get(key)
-	var valInt int64
-	if ok {
-		var err error
-		valInt, err = strconv.ParseInt(string(value), 10, 64)
-		if err != nil {
-			return parser.AppendError(nil, "ERR value is not an integer or out of range")
+func (s *Server) handleEcho(c *Client, req [][]byte) []byte {
+	return parser.AppendBulk(nil, req[1])
+}
+
+func (s *Server) handleConfigGet(c *Client, req [][]byte) []byte {
+	s.configMutex.RLock()
+	defer s.configMutex.RUnlock()
+
+	var params []string
+	for _, param := range configParams {
+		for _, arg := range req[2:] {
+			if stringMatch(string(arg), param.name, true) {
+				params = append(params, param.name, param.get(&s.config))
+				break
+			}
 		}
 	}
-	valInt++
-	s.stores[0].Set(key, []byte(strconv.FormatInt(valInt, 10)), 0)
-	return parser.AppendInt(nil, int64(valInt))
+	response := c.appendMap(nil, len(params)/2)
+	for _, param := range params {
+		response = parser.AppendBulkString(response, param)
+	}
+	return response
 }
 
-func (s *Server) handleType(req [][]byte) []byte {
-	if len(req) < 2 {
-		log.Println("Not enough arguments for TYPE")
-		return parser.AppendError(nil, "-1")
+func (s *Server) handleConfigSet(c *Client, req [][]byte) []byte {
+	if len(req)%2 != 0 {
+		return parser.AppendError(nil, "ERR wrong number of arguments for 'config|set' command")
 	}
+	s.configMutex.Lock()
+	defer s.configMutex.Unlock()
+
+	// Apply all parameters to a copy so a failure leaves the config intact.
+	config := s.config
+	for i := 2; i < len(req); i += 2 {
+		name := strings.ToLower(string(req[i]))
+		param := findConfigParam(name)
+		if param == nil {
+			return parser.AppendError(nil, fmt.Sprintf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", req[i]))
+		}
+		if param.set == nil {
+			return parser.AppendError(nil, fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - can't set immutable config", name))
+		}
+		if err := param.set(&config, string(req[i+1])); err != nil {
+			return parser.AppendError(nil, fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - %s", name, err))
+		}
+	}
+	s.config = config
+	return parser.OK()
+}
+
+func (s *Server) clientLimits() (queryBufferLimit, maxBulkLen int64) {
+	s.configMutex.RLock()
+	defer s.configMutex.RUnlock()
+	return s.config.ClientQueryBufferLimit, s.config.ProtoMaxBulkLen
+}
+
+func (s *Server) handleInfo(c *Client, req [][]byte) []byte {
+	return c.appendVerbatim(nil, s.getInfo(argStrings(req[1:])...))
+}
+
+func (s *Server) handleType(c *Client, req [][]byte) []byte {
 	return parser.AppendString(nil, s.stores[0].Type(string(req[1])))
 }
 
-func (s *Server) handleKeys(req [][]byte) []byte {
-	if len(req) < 2 {
-		log.Println("Not enough arguments for KEYS")
-		return parser.AppendError(nil, "-1")
-	}
+func (s *Server) handleKeys(c *Client, req [][]byte) []byte {
 	keys, err := s.stores[0].Keys(string(req[1]))
 	if err != nil {
 		log.Println(err)
@@ -226,7 +346,29 @@ func (s *Server) handleKeys(req [][]byte) []byte {
 	return response
 }
 
-func (s *Server) handleSave() []byte {
+// handleFlush implements FLUSHDB and FLUSHALL, which empty the database and
+// all the databases. The keys are always freed synchronously.
+func (s *Server) handleFlush(c *Client, req [][]byte) []byte {
+	if len(req) > 2 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	if len(req) == 2 && !strings.EqualFold(string(req[1]), "async") && !strings.EqualFold(string(req[1]), "sync") {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	stores := s.stores[:1]
+	if strings.EqualFold(string(req[0]), "flushall") {
+		stores = s.stores
+	}
+	for _, store := range stores {
+		s.touchAllWatchedKeys(store)
+		c.dirty += store.Flush()
+	}
+	// Flushes are propagated even when there was nothing to remove.
+	c.dirty = max(c.dirty, 1)
+	return parser.OK()
+}
+
+func (s *Server) handleSave(c *Client, req [][]byte) []byte {
 	databases := make([]*persistence.Database, 0, len(s.stores))
 	for i, store := range s.stores {
 		databases = append(databases, &persistence.Database{
@@ -234,7 +376,7 @@ func (s *Server) handleSave() []byte {
 			Entries: store.Export(),
 		})
 	}
-	if err := persistence.SaveRDB(s.config.Dir, s.config.DBFilename, databases); err != nil {
+	if err := persistence.SaveRDB(s.config.Dir, s.config.DBFilename, s.libraryCodes(), databases); err != nil {
 		log.Println(err)
 		return parser.AppendError(nil, "-1")
 	}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+func (s *Server) sortedCommands() []*Command {
+	commands := make([]*Command, 0, len(s.commands))
+	for _, cmd := range s.commands {
+		commands = append(commands, cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].name < commands[j].name
+	})
+	return commands
+}
+
+func sortedSubcommands(cmd *Command) []*Command {
+	subcommands := make([]*Command, 0, len(cmd.subcommands))
+	for _, sub := range cmd.subcommands {
+		subcommands = append(subcommands, sub)
+	}
+	sort.Slice(subcommands, func(i, j int) bool {
+		return subcommands[i].name < subcommands[j].name
+	})
+	return subcommands
+}
+
+func (s *Server) handleCommandList(c *Client, req [][]byte) []byte {
+	commands := s.sortedCommands()
+	response := parser.AppendArray(nil, len(commands))
+	for _, cmd := range commands {
+		response = appendCommandInfo(c, response, cmd)
+	}
+	return response
+}
+
+func (s *Server) handleCommandCount(c *Client, req [][]byte) []byte {
+	return parser.AppendInt(nil, int64(len(s.commands)))
+}
+
+func (s *Server) handleCommandNames(c *Client, req [][]byte) []byte {
+	commands := s.sortedCommands()
+	response := parser.AppendArray(nil, len(commands))
+	for _, cmd := range commands {
+		response = parser.AppendBulkString(response, cmd.name)
+	}
+	return response
+}
+
+func (s *Server) handleCommandInfo(c *Client, req [][]byte) []byte {
+	if len(req) == 2 {
+		return s.handleCommandList(c, req)
+	}
+	response := parser.AppendArray(nil, len(req)-2)
+	for _, name := range req[2:] {
+		cmd := s.findCommand(string(name))
+		if cmd == nil {
+			response = c.appendNullArray(response)
+			continue
+		}
+		response = appendCommandInfo(c, response, cmd)
+	}
+	return response
+}
+
+func (s *Server) handleCommandDocs(c *Client, req [][]byte) []byte {
+	var commands []*Command
+	if len(req) == 2 {
+		commands = s.sortedCommands()
+	} else {
+		for _, name := range req[2:] {
+			if cmd := s.findCommand(string(name)); cmd != nil {
+				commands = append(commands, cmd)
+			}
+		}
+	}
+	response := c.appendMap(nil, len(commands))
+	for _, cmd := range commands {
+		response = parser.AppendBulkString(response, cmd.name)
+		response = appendCommandDocs(c, response, cmd)
+	}
+	return response
+}
+
+func (s *Server) handleCommandGetKeys(c *Client, req [][]byte) []byte {
+	args := req[2:]
+	cmd, _ := s.lookupCommand(args)
+	if cmd == nil {
+		if s.findCommand(string(args[0])) == nil {
+			return parser.AppendError(nil, "ERR Invalid command specified")
+		}
+		return parser.AppendError(nil, "ERR Invalid number of arguments specified for command")
+	}
+	keys := cmd.keyIndexes(args)
+	if len(keys) == 0 {
+		return parser.AppendError(nil, "ERR The command has no key arguments")
+	}
+	response := parser.AppendArray(nil, len(keys))
+	for _, i := range keys {
+		response = parser.AppendBulk(response, args[i])
+	}
+	return response
+}
+
+// findCommand looks up a command by name, accepting the "parent|sub" form for
+// subcommands.
+func (s *Server) findCommand(name string) *Command {
+	parent, sub, isSub := strings.Cut(strings.ToLower(name), "|")
+	cmd, ok := s.commands[parent]
+	if !ok {
+		return nil
+	}
+	if isSub {
+		return cmd.subcommands[sub]
+	}
+	return cmd
+}
+
+func appendCommandInfo(c *Client, b []byte, cmd *Command) []byte {
+	b = parser.AppendArray(b, 10)
+	b = parser.AppendBulkString(b, cmd.name)
+	b = parser.AppendInt(b, int64(cmd.arity))
+	flags := cmd.flagNames()
+	b = c.appendSet(b, len(flags))
+	for _, flag := range flags {
+		b = parser.AppendString(b, flag)
+	}
+	b = parser.AppendInt(b, int64(cmd.firstKey))
+	b = parser.AppendInt(b, int64(cmd.lastKey))
+	b = parser.AppendInt(b, int64(cmd.keyStep))
+	categories := cmd.aclCategories()
+	b = c.appendSet(b, len(categories))
+	for _, category := range categories {
+		b = parser.AppendString(b, category)
+	}
+	// Command tips and key specifications are not tracked.
+	b = parser.AppendArray(b, 0)
+	b = parser.AppendArray(b, 0)
+	subcommands := sortedSubcommands(cmd)
+	b = parser.AppendArray(b, len(subcommands))
+	for _, sub := range subcommands {
+		b = appendCommandInfo(c, b, sub)
+	}
+	return b
+}
+
+func appendCommandDocs(c *Client, b []byte, cmd *Command) []byte {
+	fields := 4
+	if cmd.complexity != "" {
+		fields++
+	}
+	if len(cmd.subcommands) > 0 {
+		fields++
+	}
+	b = c.appendMap(b, fields)
+	b = parser.AppendBulkString(b, "summary")
+	b = parser.AppendBulkString(b, cmd.summary)
+	b = parser.AppendBulkString(b, "since")
+	b = parser.AppendBulkString(b, cmd.since)
+	b = parser.AppendBulkString(b, "group")
+	b = parser.AppendBulkString(b, cmd.group)
+	if cmd.complexity != "" {
+		b = parser.AppendBulkString(b, "complexity")
+		b = parser.AppendBulkString(b, cmd.complexity)
+	}
+	b = parser.AppendBulkString(b, "doc_flags")
+	b = c.appendSet(b, 0)
+	if len(cmd.subcommands) > 0 {
+		subcommands := sortedSubcommands(cmd)
+		b = parser.AppendBulkString(b, "subcommands")
+		b = c.appendMap(b, len(subcommands))
+		for _, sub := range subcommands {
+			b = parser.AppendBulkString(b, sub.name)
+			b = appendCommandDocs(c, b, sub)
+		}
+	}
+	return b
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+const (
+	serverName    = "redis"
+	serverVersion = "7.4.0"
+)
+
+func (s *Server) handleHello(c *Client, req [][]byte) []byte {
+	protocol := c.protocol
+	if len(req) > 1 {
+		version, err := strconv.ParseInt(string(req[1]), 10, 64)
+		if err != nil {
+			return parser.AppendError(nil, "ERR Protocol version is not an integer or out of range")
+		}
+		if version < 2 || version > 3 {
+			return parser.AppendError(nil, "NOPROTO unsupported protocol version")
+		}
+		protocol = int(version)
+	}
+
+	var name []byte
+	setName := false
+	for i := 2; i < len(req); i++ {
+		more := len(req) - i - 1
+		switch strings.ToLower(string(req[i])) {
+		case "auth":
+			if more < 2 {
+				return parser.AppendError(nil, "ERR Syntax error in HELLO option 'auth'")
+			}
+			if errReply := checkAuth(req[i+1], req[i+2]); errReply != nil {
+				return errReply
+			}
+			i += 2
+		case "setname":
+			if more < 1 {
+				return parser.AppendError(nil, "ERR Syntax error in HELLO option 'setname'")
+			}
+			if !validClientName(req[i+1]) {
+				return parser.AppendError(nil, "ERR Client names cannot contain spaces, newlines or special characters.")
+			}
+			name = req[i+1]
+			setName = true
+			i++
+		default:
+			return parser.AppendError(nil, "ERR Syntax error in HELLO option '"+string(req[i])+"'")
+		}
+	}
+
+	s.setProtocol(c, protocol)
+	if setName {
+		c.name = string(name)
+	}
+
+	role := "master"
+	if s.info.role != MasterRole {
+		role = "replica"
+	}
+	response := c.appendMap(nil, 7)
+	response = parser.AppendBulkString(response, "server")
+	response = parser.AppendBulkString(response, serverName)
+	response = parser.AppendBulkString(response, "version")
+	response = parser.AppendBulkString(response, serverVersion)
+	response = parser.AppendBulkString(response, "proto")
+	response = parser.AppendInt(response, int64(c.protocol))
+	response = parser.AppendBulkString(response, "id")
+	response = parser.AppendInt(response, c.id)
+	response = parser.AppendBulkString(response, "mode")
+	response = parser.AppendBulkString(response, "standalone")
+	response = parser.AppendBulkString(response, "role")
+	response = parser.AppendBulkString(response, role)
+	response = parser.AppendBulkString(response, "modules")
+	response = parser.AppendArray(response, 0)
+	return response
+}
+
+// checkAuth validates credentials against the only configured user, the
+// passwordless "default" user.
+func checkAuth(username, password []byte) []byte {
+	if string(username) != "default" {
+		return parser.AppendError(nil, "WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	return nil
+}
+
+func validClientName(name []byte) bool {
+	for _, ch := range name {
+		if ch < '!' || ch > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) handleClientID(c *Client, req [][]byte) []byte {
+	return parser.AppendInt(nil, c.id)
+}
+
+func (s *Server) handleClientGetName(c *Client, req [][]byte) []byte {
+	if c.name == "" {
+		return c.appendNull(nil)
+	}
+	return parser.AppendBulkString(nil, c.name)
+}
+
+func (s *Server) handleClientSetName(c *Client, req [][]byte) []byte {
+	if !validClientName(req[2]) {
+		return parser.AppendError(nil, "ERR Client names cannot contain spaces, newlines or special characters.")
+	}
+	c.name = string(req[2])
+	return parser.OK()
+}
+
+func (s *Server) handleClientSetInfo(c *Client, req [][]byte) []byte {
+	if !validClientName(req[3]) {
+		return parser.AppendError(nil, "ERR lib-name and lib-ver cannot contain spaces, newlines or special characters.")
+	}
+	switch strings.ToLower(string(req[2])) {
+	case "lib-name":
+		c.libName = string(req[3])
+	case "lib-ver":
+		c.libVersion = string(req[3])
+	default:
+		return parser.AppendError(nil, "ERR Unrecognized option '"+string(req[2])+"'")
+	}
+	return parser.OK()
+}
+
+// handleQuit replies OK, after which the connection is closed.
+func (s *Server) handleQuit(c *Client, req [][]byte) []byte {
+	return parser.OK()
+}
+
+// handleReset discards the transaction, the watched keys and the
+// subscriptions of the client, and brings it back to RESP2 with no name.
+func (s *Server) handleReset(c *Client, req [][]byte) []byte {
+	s.txMutex.Lock()
+	delete(s.transactions, c)
+	s.txMutex.Unlock()
+	s.unwatchAllKeys(c)
+	s.unsubscribeAll(c)
+	s.setProtocol(c, 2)
+	c.name = ""
+	return parser.AppendString(nil, "RESET")
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+func (s *Server) handleExpire(c *Client, req [][]byte) []byte {
+	return s.expireGeneric(c, req, time.Now().UnixMilli(), time.Second)
+}
+
+func (s *Server) handlePExpire(c *Client, req [][]byte) []byte {
+	return s.expireGeneric(c, req, time.Now().UnixMilli(), time.Millisecond)
+}
+
+func (s *Server) handleExpireAt(c *Client, req [][]byte) []byte {
+	return s.expireGeneric(c, req, 0, time.Second)
+}
+
+func (s *Server) handlePExpireAt(c *Client, req [][]byte) []byte {
+	return s.expireGeneric(c, req, 0, time.Millisecond)
+}
+
+// expireGeneric implements the EXPIRE family. The expiry argument is
+// interpreted in the given unit and offset by basetime, both in milliseconds.
+// The command is propagated as an absolute PEXPIREAT, or as a DEL when the
+// expiry is already in the past.
+func (s *Server) expireGeneric(c *Client, req [][]byte, basetime int64, unit time.Duration) []byte {
+	key := string(req[1])
+	when, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	cond, err := parseExpireCondition(req[3:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+
+	multiplier := int64(unit / time.Millisecond)
+	if when > math.MaxInt64/multiplier || when < math.MinInt64/multiplier {
+		return expireTimeError(string(req[0]))
+	}
+	when *= multiplier
+	if (when > 0 && basetime > math.MaxInt64-when) || (when < 0 && basetime < math.MinInt64-when) {
+		return expireTimeError(string(req[0]))
+	}
+	when += basetime
+
+	set, deleted := s.stores[0].Expire(key, when, cond)
+	if !set {
+		return parser.AppendInt(nil, 0)
+	}
+	c.dirty++
+	if deleted {
+		c.rewriteCommand("DEL", key)
+	} else {
+		c.rewriteCommand("PEXPIREAT", key, strconv.FormatInt(when, 10))
+	}
+	return parser.AppendInt(nil, 1)
+}
+
+func expireTimeError(cmd string) []byte {
+	return parser.AppendError(nil, "ERR invalid expire time in '"+strings.ToLower(cmd)+"' command")
+}
+
+func parseExpireCondition(args [][]byte) (store.ExpireCondition, error) {
+	var cond store.ExpireCondition
+	for _, arg := range args {
+		switch strings.ToLower(string(arg)) {
+		case "nx":
+			cond |= store.ExpireNX
+		case "xx":
+			cond |= store.ExpireXX
+		case "gt":
+			cond |= store.ExpireGT
+		case "lt":
+			cond |= store.ExpireLT
+		default:
+			return 0, fmt.Errorf("ERR Unsupported option %s", arg)
+		}
+	}
+	if cond&store.ExpireNX != 0 && cond != store.ExpireNX {
+		return 0, errors.New("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if cond&store.ExpireGT != 0 && cond&store.ExpireLT != 0 {
+		return 0, errors.New("ERR GT and LT options at the same time are not compatible")
+	}
+	return cond, nil
+}
+
+func (s *Server) handleTTL(c *Client, req [][]byte) []byte {
+	return s.ttlGeneric(req, false, false)
+}
+
+func (s *Server) handlePTTL(c *Client, req [][]byte) []byte {
+	return s.ttlGeneric(req, true, false)
+}
+
+func (s *Server) handleExpireTime(c *Client, req [][]byte) []byte {
+	return s.ttlGeneric(req, false, true)
+}
+
+func (s *Server) handlePExpireTime(c *Client, req [][]byte) []byte {
+	return s.ttlGeneric(req, true, true)
+}
+
+// ttlGeneric implements TTL, PTTL, EXPIRETIME and PEXPIRETIME. It replies -2
+// for missing keys and -1 for keys without an expiry.
+func (s *Server) ttlGeneric(req [][]byte, millis bool, absolute bool) []byte {
+	expiry, ok := s.stores[0].ExpireTime(string(req[1]))
+	if !ok {
+		return parser.AppendInt(nil, -2)
+	}
+	if expiry == 0 {
+		return parser.AppendInt(nil, -1)
+	}
+	if !absolute {
+		expiry = max(expiry-time.Now().UnixMilli(), 0)
+		if !millis {
+			return parser.AppendInt(nil, (expiry+500)/1000)
+		}
+	} else if !millis {
+		return parser.AppendInt(nil, expiry/1000)
+	}
+	return parser.AppendInt(nil, expiry)
+}
+
+func (s *Server) handlePersist(c *Client, req [][]byte) []byte {
+	if !s.stores[0].Persist(string(req[1])) {
+		return parser.AppendInt(nil, 0)
+	}
+	c.dirty++
+	return parser.AppendInt(nil, 1)
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence"
+)
+
+func (s *Server) handleFcall(c *Client, req [][]byte) []byte {
+	return s.fcall(c, req, false)
+}
+
+func (s *Server) handleFcallRo(c *Client, req [][]byte) []byte {
+	return s.fcall(c, req, true)
+}
+
+// fcall implements FCALL function numkeys [key ...] [arg ...] and FCALL_RO,
+// which only calls functions flagged no-writes.
+func (s *Server) fcall(c *Client, req [][]byte, readonly bool) []byte {
+	keys, args, errReply := parseNumKeys(req[2], req[3:])
+	if errReply != nil {
+		return errReply
+	}
+	s.scriptMutex.Lock()
+	f := s.functions[string(req[1])]
+	L := s.functionState
+	s.scriptMutex.Unlock()
+	if f == nil {
+		return parser.AppendError(nil, "ERR Function not found")
+	}
+	if readonly && !f.hasFlag(functionNoWrites) {
+		return parser.AppendError(nil, "ERR Can not execute a script with write flag using *_ro command.")
+	}
+	if !f.hasFlag(functionAllowStale) {
+		if errReply := s.staleReplicaError(); errReply != nil {
+			return errReply
+		}
+	}
+	run := &scriptRun{name: f.name, command: req, function: true, readonly: f.hasFlag(functionNoWrites)}
+	return s.runLua(c, L, f.callback, run, luaStringTable(L, keys), luaStringTable(L, args))
+}
+
+// handleFunctionLoad implements FUNCTION LOAD [REPLACE] code.
+func (s *Server) handleFunctionLoad(c *Client, req [][]byte) []byte {
+	replace := false
+	for _, arg := range req[2 : len(req)-1] {
+		if !strings.EqualFold(string(arg), "replace") {
+			return parser.AppendError(nil, "ERR Unknown option given: "+string(arg))
+		}
+		replace = true
+	}
+	s.scriptMutex.Lock()
+	name, err := s.loadLibrary(string(req[len(req)-1]), replace)
+	s.scriptMutex.Unlock()
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.AppendBulkString(nil, name)
+}
+
+func (s *Server) handleFunctionDelete(c *Client, req [][]byte) []byte {
+	s.scriptMutex.Lock()
+	defer s.scriptMutex.Unlock()
+	lib := s.libraries[string(req[2])]
+	if lib == nil {
+		return parser.AppendError(nil, "ERR Library not found")
+	}
+	s.unloadLibrary(lib)
+	c.dirty++
+	return parser.OK()
+}
+
+// handleFunctionFlush implements FUNCTION FLUSH [ASYNC | SYNC]. The libraries
+// are always flushed synchronously.
+func (s *Server) handleFunctionFlush(c *Client, req [][]byte) []byte {
+	if len(req) > 3 || len(req) == 3 && !strings.EqualFold(string(req[2]), "async") && !strings.EqualFold(string(req[2]), "sync") {
+		return parser.AppendError(nil, "ERR FUNCTION FLUSH only supports SYNC|ASYNC option")
+	}
+	s.scriptMutex.Lock()
+	s.flushLibraries()
+	s.scriptMutex.Unlock()
+	c.dirty++
+	return parser.OK()
+}
+
+// handleFunctionList implements FUNCTION LIST [LIBRARYNAME pattern]
+// [WITHCODE]. Libraries are listed by name.
+func (s *Server) handleFunctionList(c *Client, req [][]byte) []byte {
+	withCode := false
+	pattern := ""
+	for i := 2; i < len(req); i++ {
+		switch arg := strings.ToLower(string(req[i])); {
+		case arg == "withcode" && !withCode:
+			withCode = true
+		case arg == "libraryname" && pattern == "":
+			if i+1 == len(req) {
+				return parser.AppendError(nil, "ERR library name argument was not given")
+			}
+			i++
+			pattern = string(req[i])
+		default:
+			return parser.AppendError(nil, "ERR Unknown argument "+string(req[i]))
+		}
+	}
+
+	s.scriptMutex.Lock()
+	defer s.scriptMutex.Unlock()
+	libraries := make([]*functionLibrary, 0, len(s.libraries))
+	for name, lib := range s.libraries {
+		if pattern == "" || stringMatch(pattern, name, false) {
+			libraries = append(libraries, lib)
+		}
+	}
+	sort.Slice(libraries, func(i, j int) bool { return libraries[i].name < libraries[j].name })
+
+	response := parser.AppendArray(nil, len(libraries))
+	for _, lib := range libraries {
+		fields := 3
+		if withCode {
+			fields++
+		}
+		response = c.appendMap(response, fields)
+		response = parser.AppendBulkString(response, "library_name")
+		response = parser.AppendBulkString(response, lib.name)
+		response = parser.AppendBulkString(response, "engine")
+		response = parser.AppendBulkString(response, "LUA")
+		response = parser.AppendBulkString(response, "functions")
+		names := make([]string, 0, len(lib.functions))
+		for name := range lib.functions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		response = parser.AppendArray(response, len(names))
+		for _, name := range names {
+			f := lib.functions[name]
+			response = c.appendMap(response, 3)
+			response = parser.AppendBulkString(response, "name")
+			response = parser.AppendBulkString(response, f.name)
+			response = parser.AppendBulkString(response, "description")
+			if f.description == "" {
+				response = c.appendNull(response)
+			} else {
+				response = parser.AppendBulkString(response, f.description)
+			}
+			response = parser.AppendBulkString(response, "flags")
+			flags := f.flagNames()
+			response = c.appendSet(response, len(flags))
+			for _, flag := range flags {
+				response = parser.AppendBulkString(response, flag)
+			}
+		}
+		if withCode {
+			response = parser.AppendBulkString(response, "library_code")
+			response = parser.AppendBulkString(response, lib.code)
+		}
+	}
+	return response
+}
+
+func (s *Server) handleFunctionDump(c *Client, req [][]byte) []byte {
+	payload := persistence.DumpFunctions(s.libraryCodes())
+	return parser.AppendBulkString(nil, string(payload))
+}
+
+// handleFunctionRestore implements FUNCTION RESTORE payload [FLUSH | APPEND |
+// REPLACE]. APPEND, the default, fails when a library already exists,
+// REPLACE replaces it and FLUSH removes all the libraries first.
+func (s *Server) handleFunctionRestore(c *Client, req [][]byte) []byte {
+	flush, replace := false, false
+	if len(req) > 4 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	if len(req) == 4 {
+		switch strings.ToLower(string(req[3])) {
+		case "flush":
+			flush = true
+		case "replace":
+			replace = true
+		case "append":
+		default:
+			return parser.AppendError(nil, "ERR Wrong restore policy given, value should be either FLUSH, APPEND or REPLACE.")
+		}
+	}
+	libraries, err := persistence.LoadFunctionsDump(req[2])
+	if err != nil {
+		return parser.AppendError(nil, "ERR "+err.Error())
+	}
+	if err := s.restoreLibraries(libraries, flush, replace); err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.OK()
+}
+
+// handleFunctionKill stops the running function, unless it already wrote to
+// the keyspace.
+func (s *Server) handleFunctionKill(c *Client, req [][]byte) []byte {
+	return s.killScript(true)
+}
+
+// handleFunctionStats reports the function being run, if any, and how many
+// libraries and functions are loaded.
+func (s *Server) handleFunctionStats(c *Client, req [][]byte) []byte {
+	response := c.appendMap(nil, 2)
+	response = parser.AppendBulkString(response, "running_script")
+	if run := s.runningScript.Load(); run == nil || !run.function {
+		response = c.appendNull(response)
+	} else {
+		response = c.appendMap(response, 3)
+		response = parser.AppendBulkString(response, "name")
+		response = parser.AppendBulkString(response, run.name)
+		response = parser.AppendBulkString(response, "command")
+		response = parser.AppendBulkArray(response, run.command)
+		response = parser.AppendBulkString(response, "duration_ms")
+		response = parser.AppendInt(response, time.Since(run.start).Milliseconds())
+	}
+
+	s.scriptMutex.Lock()
+	libraries, functions := len(s.libraries), len(s.functions)
+	s.scriptMutex.Unlock()
+	response = parser.AppendBulkString(response, "engines")
+	response = c.appendMap(response, 1)
+	response = parser.AppendBulkString(response, "LUA")
+	response = c.appendMap(response, 2)
+	response = parser.AppendBulkString(response, "libraries_count")
+	response = parser.AppendInt(response, int64(libraries))
+	response = parser.AppendBulkString(response, "functions_count")
+	return parser.AppendInt(response, int64(functions))
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+func (s *Server) handleDel(c *Client, req [][]byte) []byte {
+	deleted := s.stores[0].Delete(argStrings(req[1:])...)
+	c.dirty += deleted
+	return parser.AppendInt(nil, int64(deleted))
+}
+
+func (s *Server) handleExists(c *Client, req [][]byte) []byte {
+	return parser.AppendInt(nil, int64(s.stores[0].Exists(argStrings(req[1:])...)))
+}
+
+func (s *Server) handleRename(c *Client, req [][]byte) []byte {
+	if _, err := s.stores[0].Rename(string(req[1]), string(req[2]), false); err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.OK()
+}
+
+func (s *Server) handleRenameNX(c *Client, req [][]byte) []byte {
+	renamed, err := s.stores[0].Rename(string(req[1]), string(req[2]), true)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !renamed {
+		return parser.AppendInt(nil, 0)
+	}
+	c.dirty++
+	return parser.AppendInt(nil, 1)
+}
+
+func (s *Server) handleCopy(c *Client, req [][]byte) []byte {
+	src, dst := string(req[1]), string(req[2])
+	db := 0
+	replace := false
+	for i := 3; i < len(req); i++ {
+		switch strings.ToLower(string(req[i])) {
+		case "replace":
+			replace = true
+		case "db":
+			if i+1 >= len(req) {
+				return parser.AppendError(nil, "ERR syntax error")
+			}
+			n, err := strconv.Atoi(string(req[i+1]))
+			if err != nil {
+				return parser.AppendError(nil, "ERR value is not an integer or out of range")
+			}
+			if n < 0 || n >= len(s.stores) {
+				return parser.AppendError(nil, "ERR DB index is out of range")
+			}
+			db = n
+			i++
+		default:
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+	}
+
+	var copied bool
+	if db == 0 {
+		var err error
+		copied, err = s.stores[0].Copy(src, dst, replace)
+		if err != nil {
+			return parser.AppendError(nil, err.Error())
+		}
+	} else if item, ok := s.stores[0].CopyItem(src); ok {
+		copied = s.stores[db].PutItem(dst, item, replace)
+	}
+	if !copied {
+		return parser.AppendInt(nil, 0)
+	}
+	c.dirty++
+	return parser.AppendInt(nil, 1)
+}
+
+func (s *Server) handleTouch(c *Client, req [][]byte) []byte {
+	return parser.AppendInt(nil, int64(s.stores[0].Exists(argStrings(req[1:])...)))
+}
+
+// scanOptions are the options of the SCAN family of commands.
+type scanOptions struct {
+	cursor   uint64
+	pattern  string
+	count    int
+	noValues bool
+}
+
+// parseScanArgs parses the cursor [MATCH pattern] [COUNT count] arguments of
+// the SCAN family of commands that follow the key, and NOVALUES when allowed.
+func parseScanArgs(req [][]byte, allowNoValues bool) (scanOptions, []byte) {
+	opts := scanOptions{count: 10}
+	cursor, err := strconv.ParseUint(string(req[2]), 10, 64)
+	if err != nil {
+		return opts, parser.AppendError(nil, "ERR invalid cursor")
+	}
+	opts.cursor = cursor
+	for i := 3; i < len(req); i++ {
+		switch strings.ToLower(string(req[i])) {
+		case "match":
+			if i+1 == len(req) {
+				return opts, parser.AppendError(nil, "ERR syntax error")
+			}
+			i++
+			opts.pattern = string(req[i])
+		case "count":
+			if i+1 == len(req) {
+				return opts, parser.AppendError(nil, "ERR syntax error")
+			}
+			i++
+			n, err := strconv.ParseInt(string(req[i]), 10, 64)
+			if err != nil {
+				return opts, parser.AppendError(nil, "ERR value is not an integer or out of range")
+			}
+			if n < 1 {
+				return opts, parser.AppendError(nil, "ERR syntax error")
+			}
+			opts.count = int(min(n, math.MaxInt32))
+		case "novalues":
+			if !allowNoValues {
+				return opts, parser.AppendError(nil, "ERR syntax error")
+			}
+			opts.noValues = true
+		default:
+			return opts, parser.AppendError(nil, "ERR syntax error")
+		}
+	}
+	return opts, nil
+}
+
+// match reports whether elem matches the MATCH pattern, if any.
+func (opts scanOptions) match(elem []byte) bool {
+	return opts.pattern == "" || stringMatch(opts.pattern, string(elem), false)
+}
+
+// appendScanReply appends the reply of the SCAN family: the cursor to
+// continue from and the elements returned.
+func appendScanReply(b []byte, cursor uint64, elements [][]byte) []byte {
+	b = parser.AppendArray(b, 2)
+	b = parser.AppendBulkString(b, strconv.FormatUint(cursor, 10))
+	return parser.AppendBulkArray(b, elements)
+}
+
+func (s *Server) handleObjectEncoding(c *Client, req [][]byte) []byte {
+	encoding, ok := s.stores[0].Encoding(string(req[2]))
+	if !ok {
+		return c.appendNull(nil)
+	}
+	return parser.AppendBulkString(nil, encoding)
+}
+
+func argStrings(args [][]byte) []string {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = string(arg)
+	}
+	return strs
+}
@@ -0,0 +1,392 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// maxFieldExpireTime is the largest unix time in milliseconds accepted as a
+// field expiry, as in Redis.
+const maxFieldExpireTime = 1<<48 - 1
+
+func (s *Server) handleHSet(c *Client, req [][]byte) []byte {
+	if len(req)%2 != 0 {
+		return parser.AppendError(nil, "ERR wrong number of arguments for '"+strings.ToLower(string(req[0]))+"' command")
+	}
+	added, err := s.stores[0].HashSet(string(req[1]), req[2:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty += (len(req) - 2) / 2
+	if strings.EqualFold(string(req[0]), "hmset") {
+		return parser.OK()
+	}
+	return parser.AppendInt(nil, int64(added))
+}
+
+func (s *Server) handleHSetNX(c *Client, req [][]byte) []byte {
+	set, err := s.stores[0].HashSetNX(string(req[1]), req[2], req[3])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !set {
+		return parser.AppendInt(nil, 0)
+	}
+	c.dirty++
+	return parser.AppendInt(nil, 1)
+}
+
+func (s *Server) handleHGet(c *Client, req [][]byte) []byte {
+	value, ok, err := s.stores[0].HashGet(string(req[1]), req[2])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !ok {
+		return c.appendNull(nil)
+	}
+	return parser.AppendBulk(nil, value)
+}
+
+func (s *Server) handleHMGet(c *Client, req [][]byte) []byte {
+	values, err := s.stores[0].HashMGet(string(req[1]), req[2:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	response := parser.AppendArray(nil, len(values))
+	for _, value := range values {
+		if value == nil {
+			response = c.appendNull(response)
+		} else {
+			response = parser.AppendBulk(response, value)
+		}
+	}
+	return response
+}
+
+func (s *Server) handleHDel(c *Client, req [][]byte) []byte {
+	deleted, err := s.stores[0].HashDelete(string(req[1]), req[2:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty += deleted
+	return parser.AppendInt(nil, int64(deleted))
+}
+
+func (s *Server) handleHLen(c *Client, req [][]byte) []byte {
+	length, err := s.stores[0].HashLen(string(req[1]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendInt(nil, int64(length))
+}
+
+func (s *Server) handleHExists(c *Client, req [][]byte) []byte {
+	ok, err := s.stores[0].HashExists(string(req[1]), req[2])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !ok {
+		return parser.AppendInt(nil, 0)
+	}
+	return parser.AppendInt(nil, 1)
+}
+
+func (s *Server) handleHStrLen(c *Client, req [][]byte) []byte {
+	length, err := s.stores[0].HashStrLen(string(req[1]), req[2])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendInt(nil, int64(length))
+}
+
+func (s *Server) handleHGetAll(c *Client, req [][]byte) []byte {
+	pairs, err := s.stores[0].HashGetAll(string(req[1]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	response := c.appendMap(nil, len(pairs)/2)
+	for _, b := range pairs {
+		response = parser.AppendBulk(response, b)
+	}
+	return response
+}
+
+func (s *Server) handleHKeys(c *Client, req [][]byte) []byte {
+	return s.hashPairsGeneric(req, 0)
+}
+
+func (s *Server) handleHVals(c *Client, req [][]byte) []byte {
+	return s.hashPairsGeneric(req, 1)
+}
+
+// hashPairsGeneric implements HKEYS and HVALS, replying with either the
+// fields or the values of the hash, selected by offset.
+func (s *Server) hashPairsGeneric(req [][]byte, offset int) []byte {
+	pairs, err := s.stores[0].HashGetAll(string(req[1]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	response := parser.AppendArray(nil, len(pairs)/2)
+	for i := offset; i < len(pairs); i += 2 {
+		response = parser.AppendBulk(response, pairs[i])
+	}
+	return response
+}
+
+func (s *Server) handleHIncrBy(c *Client, req [][]byte) []byte {
+	delta, err := strconv.ParseInt(string(req[3]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	value, err := s.stores[0].HashIncrBy(string(req[1]), req[2], delta)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.AppendInt(nil, value)
+}
+
+func (s *Server) handleHIncrByFloat(c *Client, req [][]byte) []byte {
+	delta, err := strconv.ParseFloat(string(req[3]), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return parser.AppendError(nil, "ERR value is not a valid float")
+	}
+	value, err := s.stores[0].HashIncrByFloat(string(req[1]), req[2], delta)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	// Unlike INCRBYFLOAT, the command is propagated as is: rewriting it as
+	// an HSET would drop the expiry of the field on replicas.
+	c.dirty++
+	return parser.AppendBulk(nil, value)
+}
+
+func (s *Server) handleHRandField(c *Client, req [][]byte) []byte {
+	key := string(req[1])
+	if len(req) == 2 {
+		pairs, err := s.stores[0].HashRandFields(key, 1, false)
+		if err != nil {
+			return parser.AppendError(nil, err.Error())
+		}
+		if len(pairs) == 0 {
+			return c.appendNull(nil)
+		}
+		return parser.AppendBulk(nil, pairs[0])
+	}
+
+	count, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	withValues := false
+	if len(req) == 4 && strings.EqualFold(string(req[3]), "withvalues") {
+		withValues = true
+	} else if len(req) > 3 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	repeat := count < 0
+	if repeat {
+		if count < -math.MaxInt64/2 {
+			return parser.AppendError(nil, "ERR value is out of range")
+		}
+		count = -count
+	}
+	pairs, err := s.stores[0].HashRandFields(key, int(min(count, math.MaxInt32)), repeat)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+
+	n := len(pairs) / 2
+	response := parser.AppendArray(nil, n)
+	if withValues && !c.resp3() {
+		response = parser.AppendArray(nil, 2*n)
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		if withValues && c.resp3() {
+			response = parser.AppendArray(response, 2)
+		}
+		response = parser.AppendBulk(response, pairs[i])
+		if withValues {
+			response = parser.AppendBulk(response, pairs[i+1])
+		}
+	}
+	return response
+}
+
+func (s *Server) handleHScan(c *Client, req [][]byte) []byte {
+	opts, errReply := parseScanArgs(req, true)
+	if errReply != nil {
+		return errReply
+	}
+	next, pairs, err := s.stores[0].HashScan(string(req[1]), opts.cursor, opts.count)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	var elements [][]byte
+	for i := 0; i < len(pairs); i += 2 {
+		if !opts.match(pairs[i]) {
+			continue
+		}
+		elements = append(elements, pairs[i])
+		if !opts.noValues {
+			elements = append(elements, pairs[i+1])
+		}
+	}
+	return appendScanReply(nil, next, elements)
+}
+
+func (s *Server) handleHExpire(c *Client, req [][]byte) []byte {
+	return s.hexpireGeneric(c, req, time.Now().UnixMilli(), time.Second)
+}
+
+func (s *Server) handleHPExpire(c *Client, req [][]byte) []byte {
+	return s.hexpireGeneric(c, req, time.Now().UnixMilli(), time.Millisecond)
+}
+
+func (s *Server) handleHExpireAt(c *Client, req [][]byte) []byte {
+	return s.hexpireGeneric(c, req, 0, time.Second)
+}
+
+func (s *Server) handleHPExpireAt(c *Client, req [][]byte) []byte {
+	return s.hexpireGeneric(c, req, 0, time.Millisecond)
+}
+
+// hexpireGeneric implements the HEXPIRE family, which sets the expiry of
+// hash fields like expireGeneric does for keys. Only the fields that changed
+// are propagated, as an absolute HPEXPIREAT, or as an HDEL when the expiry is
+// already in the past.
+func (s *Server) hexpireGeneric(c *Client, req [][]byte, basetime int64, unit time.Duration) []byte {
+	key := string(req[1])
+	when, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	cond := store.ExpireAlways
+	i := 3
+	if !strings.EqualFold(string(req[i]), "fields") {
+		if cond, err = parseExpireCondition(req[i : i+1]); err != nil {
+			return parser.AppendError(nil, err.Error())
+		}
+		i++
+	}
+	fields, errReply := parseFieldsArg(req, i)
+	if errReply != nil {
+		return errReply
+	}
+
+	multiplier := int64(unit / time.Millisecond)
+	if when < 0 || when > maxFieldExpireTime/multiplier {
+		return parser.AppendError(nil, "ERR invalid expire time, must be >= 0 and <= "+strconv.Itoa(maxFieldExpireTime))
+	}
+	when = when*multiplier + basetime
+	if when > maxFieldExpireTime {
+		return parser.AppendError(nil, "ERR invalid expire time, must be >= 0 and <= "+strconv.Itoa(maxFieldExpireTime))
+	}
+
+	results, err := s.stores[0].HashExpire(key, fields, when, cond)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	var changed []string
+	response := parser.AppendArray(nil, len(results))
+	for i, result := range results {
+		if result > 0 {
+			changed = append(changed, string(fields[i]))
+		}
+		response = parser.AppendInt(response, int64(result))
+	}
+	if len(changed) > 0 {
+		c.dirty += len(changed)
+		if when <= time.Now().UnixMilli() {
+			c.rewriteCommand(append([]string{"HDEL", key}, changed...)...)
+		} else {
+			c.rewriteCommand(append([]string{"HPEXPIREAT", key, strconv.FormatInt(when, 10), "FIELDS", strconv.Itoa(len(changed))}, changed...)...)
+		}
+	}
+	return response
+}
+
+// parseFieldsArg parses the FIELDS numfields field [field ...] arguments that
+// start at index i and end the command.
+func parseFieldsArg(req [][]byte, i int) ([][]byte, []byte) {
+	if i+1 >= len(req) || !strings.EqualFold(string(req[i]), "fields") {
+		return nil, parser.AppendError(nil, "ERR Mandatory argument FIELDS is missing or not at the right position")
+	}
+	numFields, err := strconv.ParseInt(string(req[i+1]), 10, 64)
+	if err != nil || numFields <= 0 {
+		return nil, parser.AppendError(nil, "ERR Parameter `numFields` should be greater than 0")
+	}
+	if numFields != int64(len(req)-i-2) {
+		return nil, parser.AppendError(nil, "ERR The `numfields` parameter must match the number of arguments")
+	}
+	return req[i+2:], nil
+}
+
+func (s *Server) handleHTTL(c *Client, req [][]byte) []byte {
+	return s.httlGeneric(req, false, false)
+}
+
+func (s *Server) handleHPTTL(c *Client, req [][]byte) []byte {
+	return s.httlGeneric(req, true, false)
+}
+
+func (s *Server) handleHExpireTime(c *Client, req [][]byte) []byte {
+	return s.httlGeneric(req, false, true)
+}
+
+func (s *Server) handleHPExpireTime(c *Client, req [][]byte) []byte {
+	return s.httlGeneric(req, true, true)
+}
+
+// httlGeneric implements HTTL, HPTTL, HEXPIRETIME and HPEXPIRETIME, replying
+// for each field like ttlGeneric does for keys.
+func (s *Server) httlGeneric(req [][]byte, millis bool, absolute bool) []byte {
+	fields, errReply := parseFieldsArg(req, 2)
+	if errReply != nil {
+		return errReply
+	}
+	expiries, err := s.stores[0].HashExpireTime(string(req[1]), fields)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	now := time.Now().UnixMilli()
+	response := parser.AppendArray(nil, len(expiries))
+	for _, expiry := range expiries {
+		if expiry > 0 {
+			if !absolute {
+				expiry = max(expiry-now, 0)
+				if !millis {
+					expiry = (expiry + 500) / 1000
+				}
+			} else if !millis {
+				expiry /= 1000
+			}
+		}
+		response = parser.AppendInt(response, expiry)
+	}
+	return response
+}
+
+func (s *Server) handleHPersist(c *Client, req [][]byte) []byte {
+	fields, errReply := parseFieldsArg(req, 2)
+	if errReply != nil {
+		return errReply
+	}
+	results, err := s.stores[0].HashPersist(string(req[1]), fields)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	response := parser.AppendArray(nil, len(results))
+	for _, result := range results {
+		if result == 1 {
+			c.dirty++
+		}
+		response = parser.AppendInt(response, int64(result))
+	}
+	return response
+}
@@ -0,0 +1,430 @@
+package server
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+func (s *Server) handleLPush(c *Client, req [][]byte) []byte {
+	return s.pushGeneric(c, req, true, false)
+}
+
+func (s *Server) handleRPush(c *Client, req [][]byte) []byte {
+	return s.pushGeneric(c, req, false, false)
+}
+
+func (s *Server) handleLPushX(c *Client, req [][]byte) []byte {
+	return s.pushGeneric(c, req, true, true)
+}
+
+func (s *Server) handleRPushX(c *Client, req [][]byte) []byte {
+	return s.pushGeneric(c, req, false, true)
+}
+
+func (s *Server) pushGeneric(c *Client, req [][]byte, left, xx bool) []byte {
+	length, err := s.stores[0].ListPush(string(req[1]), req[2:], left, xx)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if length > 0 {
+		c.dirty += len(req) - 2
+	}
+	return parser.AppendInt(nil, int64(length))
+}
+
+func (s *Server) handleLPop(c *Client, req [][]byte) []byte {
+	return s.popGeneric(c, req, true)
+}
+
+func (s *Server) handleRPop(c *Client, req [][]byte) []byte {
+	return s.popGeneric(c, req, false)
+}
+
+func (s *Server) popGeneric(c *Client, req [][]byte, left bool) []byte {
+	if len(req) > 3 {
+		return parser.AppendError(nil, "ERR wrong number of arguments for '"+strings.ToLower(string(req[0]))+"' command")
+	}
+	count := 1
+	if len(req) == 3 {
+		n, err := strconv.ParseInt(string(req[2]), 10, 64)
+		if err != nil || n < 0 {
+			return parser.AppendError(nil, "ERR value is out of range, must be positive")
+		}
+		count = int(min(n, math.MaxInt32))
+	}
+
+	values, err := s.stores[0].ListPop(string(req[1]), count, left)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if values == nil {
+		if len(req) == 3 {
+			return c.appendNullArray(nil)
+		}
+		return c.appendNull(nil)
+	}
+	c.dirty += len(values)
+	if len(req) == 3 {
+		return parser.AppendBulkArray(nil, values)
+	}
+	return parser.AppendBulk(nil, values[0])
+}
+
+func (s *Server) handleLMPop(c *Client, req [][]byte) []byte {
+	numKeys, err := strconv.Atoi(string(req[1]))
+	if err != nil || numKeys <= 0 {
+		return parser.AppendError(nil, "ERR numkeys should be greater than 0")
+	}
+	if numKeys > len(req)-3 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	left, count, errReply := parseMPopArgs(req[2+numKeys:])
+	if errReply != nil {
+		return errReply
+	}
+
+	key, values, err := s.stores[0].ListMPop(argStrings(req[2:2+numKeys]), count, left)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if values == nil {
+		return c.appendNullArray(nil)
+	}
+	c.dirty += len(values)
+	// Replicas only need to pop from the list that was chosen.
+	if left {
+		c.rewriteCommand("LPOP", key, strconv.Itoa(len(values)))
+	} else {
+		c.rewriteCommand("RPOP", key, strconv.Itoa(len(values)))
+	}
+	response := parser.AppendArray(nil, 2)
+	response = parser.AppendBulkString(response, key)
+	return parser.AppendBulkArray(response, values)
+}
+
+// parseMPopArgs parses the LEFT|RIGHT [COUNT count] arguments of LMPOP.
+func parseMPopArgs(args [][]byte) (left bool, count int, errReply []byte) {
+	switch strings.ToLower(string(args[0])) {
+	case "left":
+		left = true
+	case "right":
+	default:
+		return false, 0, parser.AppendError(nil, "ERR syntax error")
+	}
+	if len(args) == 1 {
+		return left, 1, nil
+	}
+	if len(args) != 3 || !strings.EqualFold(string(args[1]), "count") {
+		return false, 0, parser.AppendError(nil, "ERR syntax error")
+	}
+	n, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil || n <= 0 {
+		return false, 0, parser.AppendError(nil, "ERR count should be greater than 0")
+	}
+	return left, int(min(n, math.MaxInt32)), nil
+}
+
+func (s *Server) handleLMove(c *Client, req [][]byte) []byte {
+	srcLeft, ok := parseListEnd(req[3])
+	if !ok {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	dstLeft, ok := parseListEnd(req[4])
+	if !ok {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	return s.moveGeneric(c, req, srcLeft, dstLeft)
+}
+
+func (s *Server) handleRPopLPush(c *Client, req [][]byte) []byte {
+	return s.moveGeneric(c, req, false, true)
+}
+
+func (s *Server) moveGeneric(c *Client, req [][]byte, srcLeft, dstLeft bool) []byte {
+	value, ok, err := s.stores[0].ListMove(string(req[1]), string(req[2]), srcLeft, dstLeft)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !ok {
+		return c.appendNull(nil)
+	}
+	c.dirty++
+	return parser.AppendBulk(nil, value)
+}
+
+// parseListEnd parses a LEFT or RIGHT argument, reporting true for LEFT.
+func parseListEnd(arg []byte) (left bool, ok bool) {
+	switch strings.ToLower(string(arg)) {
+	case "left":
+		return true, true
+	case "right":
+		return false, true
+	}
+	return false, false
+}
+
+func (s *Server) handleBLPop(c *Client, req [][]byte) []byte {
+	return s.blockingPopGeneric(c, req, true)
+}
+
+func (s *Server) handleBRPop(c *Client, req [][]byte) []byte {
+	return s.blockingPopGeneric(c, req, false)
+}
+
+func (s *Server) blockingPopGeneric(c *Client, req [][]byte, left bool) []byte {
+	timeout, errReply := parseTimeout(req[len(req)-1])
+	if errReply != nil {
+		return errReply
+	}
+	pop := "RPOP"
+	if left {
+		pop = "LPOP"
+	}
+	return s.blockForKeys(c, argStrings(req[1:len(req)-1]), timeout, "", false, func(key string) ([]byte, [][][]byte) {
+		values, err := s.stores[0].ListPop(key, 1, left)
+		if err != nil {
+			return parser.AppendError(nil, err.Error()), nil
+		}
+		if values == nil {
+			return nil, nil
+		}
+		response := parser.AppendArray(nil, 2)
+		response = parser.AppendBulkString(response, key)
+		return parser.AppendBulk(response, values[0]), [][][]byte{{[]byte(pop), []byte(key)}}
+	})
+}
+
+func (s *Server) handleBLMPop(c *Client, req [][]byte) []byte {
+	timeout, errReply := parseTimeout(req[1])
+	if errReply != nil {
+		return errReply
+	}
+	numKeys, err := strconv.Atoi(string(req[2]))
+	if err != nil || numKeys <= 0 {
+		return parser.AppendError(nil, "ERR numkeys should be greater than 0")
+	}
+	if numKeys > len(req)-4 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	left, count, errReply := parseMPopArgs(req[3+numKeys:])
+	if errReply != nil {
+		return errReply
+	}
+	pop := "RPOP"
+	if left {
+		pop = "LPOP"
+	}
+	return s.blockForKeys(c, argStrings(req[3:3+numKeys]), timeout, "", false, func(key string) ([]byte, [][][]byte) {
+		values, err := s.stores[0].ListPop(key, count, left)
+		if err != nil {
+			return parser.AppendError(nil, err.Error()), nil
+		}
+		if values == nil {
+			return nil, nil
+		}
+		response := parser.AppendArray(nil, 2)
+		response = parser.AppendBulkString(response, key)
+		response = parser.AppendBulkArray(response, values)
+		return response, [][][]byte{{[]byte(pop), []byte(key), []byte(strconv.Itoa(len(values)))}}
+	})
+}
+
+func (s *Server) handleBLMove(c *Client, req [][]byte) []byte {
+	srcLeft, ok := parseListEnd(req[3])
+	if !ok {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	dstLeft, ok := parseListEnd(req[4])
+	if !ok {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	return s.blockingMoveGeneric(c, req[1], req[2], req[5], srcLeft, dstLeft)
+}
+
+func (s *Server) handleBRPopLPush(c *Client, req [][]byte) []byte {
+	return s.blockingMoveGeneric(c, req[1], req[2], req[3], false, true)
+}
+
+func (s *Server) blockingMoveGeneric(c *Client, src, dst, timeoutArg []byte, srcLeft, dstLeft bool) []byte {
+	timeout, errReply := parseTimeout(timeoutArg)
+	if errReply != nil {
+		return errReply
+	}
+	// Replicas receive the move as LMOVE, which also covers BRPOPLPUSH.
+	propagate := [][]byte{[]byte("LMOVE"), bytes.Clone(src), bytes.Clone(dst), []byte("RIGHT"), []byte("RIGHT")}
+	if srcLeft {
+		propagate[3] = []byte("LEFT")
+	}
+	if dstLeft {
+		propagate[4] = []byte("LEFT")
+	}
+	return s.blockForKeys(c, []string{string(src)}, timeout, string(dst), false, func(key string) ([]byte, [][][]byte) {
+		value, ok, err := s.stores[0].ListMove(key, string(dst), srcLeft, dstLeft)
+		if err != nil {
+			return parser.AppendError(nil, err.Error()), nil
+		}
+		if !ok {
+			return nil, nil
+		}
+		return parser.AppendBulk(nil, value), [][][]byte{propagate}
+	})
+}
+
+func (s *Server) handleLLen(c *Client, req [][]byte) []byte {
+	length, err := s.stores[0].ListLen(string(req[1]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendInt(nil, int64(length))
+}
+
+func (s *Server) handleLRange(c *Client, req [][]byte) []byte {
+	start, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	stop, err := strconv.ParseInt(string(req[3]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	values, err := s.stores[0].ListRange(string(req[1]), start, stop)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendBulkArray(nil, values)
+}
+
+func (s *Server) handleLIndex(c *Client, req [][]byte) []byte {
+	index, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	value, ok, err := s.stores[0].ListIndex(string(req[1]), index)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !ok {
+		return c.appendNull(nil)
+	}
+	return parser.AppendBulk(nil, value)
+}
+
+func (s *Server) handleLSet(c *Client, req [][]byte) []byte {
+	index, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	if err := s.stores[0].ListSet(string(req[1]), index, req[3]); err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.OK()
+}
+
+func (s *Server) handleLRem(c *Client, req [][]byte) []byte {
+	count, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	removed, err := s.stores[0].ListRemove(string(req[1]), count, req[3])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty += removed
+	return parser.AppendInt(nil, int64(removed))
+}
+
+func (s *Server) handleLTrim(c *Client, req [][]byte) []byte {
+	start, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	stop, err := strconv.ParseInt(string(req[3]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	if err := s.stores[0].ListTrim(string(req[1]), start, stop); err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.OK()
+}
+
+func (s *Server) handleLInsert(c *Client, req [][]byte) []byte {
+	var before bool
+	switch strings.ToLower(string(req[2])) {
+	case "before":
+		before = true
+	case "after":
+	default:
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	length, err := s.stores[0].ListInsert(string(req[1]), before, req[3], req[4])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if length > 0 {
+		c.dirty++
+	}
+	return parser.AppendInt(nil, int64(length))
+}
+
+func (s *Server) handleLPos(c *Client, req [][]byte) []byte {
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 3; i < len(req); i += 2 {
+		if i+1 >= len(req) {
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+		n, err := strconv.ParseInt(string(req[i+1]), 10, 64)
+		if err != nil {
+			return parser.AppendError(nil, "ERR value is not an integer or out of range")
+		}
+		switch strings.ToLower(string(req[i])) {
+		case "rank":
+			if n == 0 {
+				return parser.AppendError(nil, "ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+			}
+			if n == math.MinInt64 {
+				return parser.AppendError(nil, "ERR value is out of range, value must between -9223372036854775807 and 9223372036854775807")
+			}
+			rank = n
+		case "count":
+			if n < 0 {
+				return parser.AppendError(nil, "ERR COUNT can't be negative")
+			}
+			count = n
+		case "maxlen":
+			if n < 0 {
+				return parser.AppendError(nil, "ERR MAXLEN can't be negative")
+			}
+			maxLen = n
+		default:
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+	}
+
+	// Without COUNT only the first match is returned, as a single integer.
+	limit := count
+	if count < 0 {
+		limit = 1
+	}
+	positions, err := s.stores[0].ListPos(string(req[1]), req[2], rank, limit, maxLen)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if count < 0 {
+		if len(positions) == 0 {
+			return c.appendNull(nil)
+		}
+		return parser.AppendInt(nil, positions[0])
+	}
+	response := parser.AppendArray(nil, len(positions))
+	for _, pos := range positions {
+		response = parser.AppendInt(response, pos)
+	}
+	return response
+}
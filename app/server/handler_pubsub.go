@@ -0,0 +1,105 @@
+package server
+
+import (
+	"sort"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+func (s *Server) handleSubscribe(c *Client, req [][]byte) []byte {
+	return s.subscribe(c, channelKind, req[1:])
+}
+
+func (s *Server) handleUnsubscribe(c *Client, req [][]byte) []byte {
+	return s.unsubscribe(c, channelKind, req[1:])
+}
+
+func (s *Server) handlePSubscribe(c *Client, req [][]byte) []byte {
+	return s.subscribe(c, patternKind, req[1:])
+}
+
+func (s *Server) handlePUnsubscribe(c *Client, req [][]byte) []byte {
+	return s.unsubscribe(c, patternKind, req[1:])
+}
+
+func (s *Server) handleSSubscribe(c *Client, req [][]byte) []byte {
+	return s.subscribe(c, shardChannelKind, req[1:])
+}
+
+func (s *Server) handleSUnsubscribe(c *Client, req [][]byte) []byte {
+	return s.unsubscribe(c, shardChannelKind, req[1:])
+}
+
+// handlePublish implements PUBLISH channel message. It is always propagated
+// so that the clients subscribed on replicas receive the message too.
+func (s *Server) handlePublish(c *Client, req [][]byte) []byte {
+	receivers := s.publish(req[1], req[2], false)
+	c.dirty++
+	return parser.AppendInt(nil, int64(receivers))
+}
+
+func (s *Server) handleSPublish(c *Client, req [][]byte) []byte {
+	receivers := s.publish(req[1], req[2], true)
+	c.dirty++
+	return parser.AppendInt(nil, int64(receivers))
+}
+
+// activeChannels returns the channels of kind with subscribers that match
+// the optional pattern, sorted.
+func (s *Server) activeChannels(kind *pubsubKind, req [][]byte) []byte {
+	pattern := ""
+	if len(req) == 3 {
+		pattern = string(req[2])
+	}
+	s.pubsubMutex.RLock()
+	channels := make([]string, 0, len(kind.server(s)))
+	for channel := range kind.server(s) {
+		if pattern == "" || stringMatch(pattern, channel, false) {
+			channels = append(channels, channel)
+		}
+	}
+	s.pubsubMutex.RUnlock()
+	sort.Strings(channels)
+	response := parser.AppendArray(nil, len(channels))
+	for _, channel := range channels {
+		response = parser.AppendBulkString(response, channel)
+	}
+	return response
+}
+
+// subscriberCounts returns the number of subscribers of each channel of
+// kind in req.
+func (s *Server) subscriberCounts(c *Client, kind *pubsubKind, req [][]byte) []byte {
+	s.pubsubMutex.RLock()
+	defer s.pubsubMutex.RUnlock()
+	response := c.appendMap(nil, len(req)-2)
+	for _, channel := range req[2:] {
+		response = parser.AppendBulk(response, channel)
+		response = parser.AppendInt(response, int64(len(kind.server(s)[string(channel)])))
+	}
+	return response
+}
+
+func (s *Server) handlePubSubChannels(c *Client, req [][]byte) []byte {
+	return s.activeChannels(channelKind, req)
+}
+
+func (s *Server) handlePubSubNumSub(c *Client, req [][]byte) []byte {
+	return s.subscriberCounts(c, channelKind, req)
+}
+
+// handlePubSubNumPat returns the number of patterns clients are subscribed
+// to.
+func (s *Server) handlePubSubNumPat(c *Client, req [][]byte) []byte {
+	s.pubsubMutex.RLock()
+	defer s.pubsubMutex.RUnlock()
+	return parser.AppendInt(nil, int64(len(s.patterns)))
+}
+
+func (s *Server) handlePubSubShardChannels(c *Client, req [][]byte) []byte {
+	return s.activeChannels(shardChannelKind, req)
+}
+
+func (s *Server) handlePubSubShardNumSub(c *Client, req [][]byte) []byte {
+	return s.subscriberCounts(c, shardChannelKind, req)
+}
@@ -17,9 +17,9 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/app/parser"
 )
 
-func (s *Server) handleREPLConf(req [][]byte) []byte {
+func (s *Server) handleREPLConf(c *Client, req [][]byte) []byte {
 	if s.info.role != "master" {
-		if string(req[1]) == "GETACK" {
+		if len(req) > 1 && string(req[1]) == "GETACK" {
 			return parser.EncodeStringArray("REPLCONF", "ACK", strconv.FormatInt(s.info.masterReplOffset.Load(), 10))
 		}
 		return parser.AppendError(nil, "-1")
@@ -28,12 +28,15 @@ func (s *Server) handleREPLConf(req [][]byte) []byte {
 
 }
 
-func (s *Server) handlePSync(req [][]byte, conn net.Conn) {
-	if len(req) < 3 {
-		log.Println("Not enough arguments for PSYNC")
-		conn.Write(parser.AppendError(nil, "-1"))
+// handlePSync performs a full resynchronization with the replica. On success
+// it returns a nil reply, as the connection is then owned by the replication
+// stream.
+func (s *Server) handlePSync(c *Client, req [][]byte) []byte {
+	if s.info.role != "master" {
+		return parser.AppendError(nil, "ERR PSYNC is only supported by masters")
 	}
 	if string(req[1]) == "?" {
+		conn := c.conn
 		conn.Write(parser.AppendString(nil, fmt.Sprintf("FULLRESYNC %s %d", s.info.masterReplID, s.info.masterReplOffset.Load())))
 		err := s.FullResync(conn)
 		if err != nil {
@@ -47,11 +50,13 @@ func (s *Server) handlePSync(req [][]byte, conn net.Conn) {
 			offset: &offset,
 		})
 		s.slaveMutex.Unlock()
+		return nil
 	}
+	return parser.AppendError(nil, "ERR partial resynchronization is not supported")
 }
 
 func (s *Server) FullResync(conn net.Conn) error {
-	if ok := s.handleSave(); string(ok) != string(parser.OK()) {
+	if ok := s.handleSave(nil, nil); string(ok) != string(parser.OK()) {
 		return errors.New("error saving RDB")
 	}
 	file, err := os.Open(path.Join(s.config.Dir, s.config.DBFilename))
@@ -138,9 +143,9 @@ func (s *Server) PropagateCommand(req [][]byte) {
 	log.Println("Finished propagation")
 }
 
-func (s *Server) handleWait(req [][]byte) []byte {
-	if len(req) < 3 {
-		return parser.AppendError(nil, "-1")
+func (s *Server) handleWait(c *Client, req [][]byte) []byte {
+	if s.info.role != "master" {
+		return parser.AppendError(nil, "ERR WAIT cannot be used with replica instances")
 	}
 	repAmount, err := strconv.Atoi(string(req[1]))
 	if err != nil {
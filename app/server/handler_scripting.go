@@ -0,0 +1,104 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+func (s *Server) handleEval(c *Client, req [][]byte) []byte {
+	return s.eval(c, req, false, false)
+}
+
+func (s *Server) handleEvalSha(c *Client, req [][]byte) []byte {
+	return s.eval(c, req, true, false)
+}
+
+func (s *Server) handleEvalRo(c *Client, req [][]byte) []byte {
+	return s.eval(c, req, false, true)
+}
+
+func (s *Server) handleEvalShaRo(c *Client, req [][]byte) []byte {
+	return s.eval(c, req, true, true)
+}
+
+// eval implements EVAL script numkeys [key ...] [arg ...] and its variants,
+// which take the SHA1 of a cached script or reject writes.
+func (s *Server) eval(c *Client, req [][]byte, bySHA, readonly bool) []byte {
+	keys, args, errReply := parseNumKeys(req[2], req[3:])
+	if errReply != nil {
+		return errReply
+	}
+	var sha string
+	var script *luaScript
+	if bySHA {
+		sha = strings.ToLower(string(req[1]))
+		if script = s.cachedScript(sha); script == nil {
+			return parser.AppendError(nil, "NOSCRIPT No matching script. Please use EVAL.")
+		}
+	} else {
+		var err error
+		if sha, script, err = s.loadScript(string(req[1])); err != nil {
+			return parser.AppendError(nil, err.Error())
+		}
+	}
+	return s.runScript(c, req, script, sha, keys, args, readonly)
+}
+
+// parseNumKeys splits args into the numkeys keys that lead them and the
+// arguments that follow.
+func parseNumKeys(numKeys []byte, args [][]byte) (keys, rest [][]byte, errReply []byte) {
+	n, err := strconv.ParseInt(string(numKeys), 10, 64)
+	switch {
+	case err != nil:
+		return nil, nil, parser.AppendError(nil, "ERR value is not an integer or out of range")
+	case n < 0:
+		return nil, nil, parser.AppendError(nil, "ERR Number of keys can't be negative")
+	case n > int64(len(args)):
+		return nil, nil, parser.AppendError(nil, "ERR Number of keys can't be greater than number of args")
+	}
+	return args[:n], args[n:], nil
+}
+
+func (s *Server) handleScriptLoad(c *Client, req [][]byte) []byte {
+	sha, _, err := s.loadScript(string(req[2]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendBulkString(nil, sha)
+}
+
+func (s *Server) handleScriptExists(c *Client, req [][]byte) []byte {
+	response := parser.AppendArray(nil, len(req)-2)
+	for _, sha := range req[2:] {
+		exists := int64(0)
+		if s.cachedScript(string(sha)) != nil {
+			exists = 1
+		}
+		response = parser.AppendInt(response, exists)
+	}
+	return response
+}
+
+// handleScriptFlush implements SCRIPT FLUSH [ASYNC | SYNC]. The cache is
+// always flushed synchronously, along with the interpreter.
+func (s *Server) handleScriptFlush(c *Client, req [][]byte) []byte {
+	if len(req) > 3 || len(req) == 3 && !strings.EqualFold(string(req[2]), "async") && !strings.EqualFold(string(req[2]), "sync") {
+		return parser.AppendError(nil, "ERR SCRIPT FLUSH only support SYNC|ASYNC option")
+	}
+	s.scriptMutex.Lock()
+	defer s.scriptMutex.Unlock()
+	s.scripts = make(map[string]*luaScript)
+	if s.luaState != nil {
+		s.luaState.Close()
+		s.luaState = nil
+	}
+	return parser.OK()
+}
+
+// handleScriptKill stops the running script, unless it already wrote to the
+// keyspace.
+func (s *Server) handleScriptKill(c *Client, req [][]byte) []byte {
+	return s.killScript(false)
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+func (s *Server) handleSAdd(c *Client, req [][]byte) []byte {
+	added, err := s.stores[0].SetAdd(string(req[1]), req[2:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty += added
+	return parser.AppendInt(nil, int64(added))
+}
+
+func (s *Server) handleSRem(c *Client, req [][]byte) []byte {
+	removed, err := s.stores[0].SetRemove(string(req[1]), req[2:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty += removed
+	return parser.AppendInt(nil, int64(removed))
+}
+
+func (s *Server) handleSMembers(c *Client, req [][]byte) []byte {
+	members, err := s.stores[0].SetMembers(string(req[1]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return c.appendBulkSet(nil, members)
+}
+
+func (s *Server) handleSIsMember(c *Client, req [][]byte) []byte {
+	results, err := s.stores[0].SetIsMember(string(req[1]), req[2:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if results[0] {
+		return parser.AppendInt(nil, 1)
+	}
+	return parser.AppendInt(nil, 0)
+}
+
+func (s *Server) handleSMIsMember(c *Client, req [][]byte) []byte {
+	results, err := s.stores[0].SetIsMember(string(req[1]), req[2:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	response := parser.AppendArray(nil, len(results))
+	for _, ok := range results {
+		if ok {
+			response = parser.AppendInt(response, 1)
+		} else {
+			response = parser.AppendInt(response, 0)
+		}
+	}
+	return response
+}
+
+func (s *Server) handleSCard(c *Client, req [][]byte) []byte {
+	card, err := s.stores[0].SetCard(string(req[1]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendInt(nil, int64(card))
+}
+
+// handleSPop pops random members, so it is propagated as an SREM of the
+// members that were popped.
+func (s *Server) handleSPop(c *Client, req [][]byte) []byte {
+	if len(req) > 3 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	key := string(req[1])
+	count := 1
+	if len(req) == 3 {
+		n, err := strconv.ParseInt(string(req[2]), 10, 64)
+		if err != nil || n < 0 {
+			return parser.AppendError(nil, "ERR value is out of range, must be positive")
+		}
+		count = int(min(n, math.MaxInt32))
+	}
+
+	members, err := s.stores[0].SetPop(key, count)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if len(members) > 0 {
+		c.dirty += len(members)
+		args := append([]string{"SREM", key}, make([]string, len(members))...)
+		for i, member := range members {
+			args[2+i] = string(member)
+		}
+		c.rewriteCommand(args...)
+	}
+	if len(req) == 3 {
+		return c.appendBulkSet(nil, members)
+	}
+	if len(members) == 0 {
+		return c.appendNull(nil)
+	}
+	return parser.AppendBulk(nil, members[0])
+}
+
+func (s *Server) handleSRandMember(c *Client, req [][]byte) []byte {
+	if len(req) > 3 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	key := string(req[1])
+	if len(req) == 2 {
+		members, err := s.stores[0].SetRandMembers(key, 1, false)
+		if err != nil {
+			return parser.AppendError(nil, err.Error())
+		}
+		if len(members) == 0 {
+			return c.appendNull(nil)
+		}
+		return parser.AppendBulk(nil, members[0])
+	}
+
+	count, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	repeat := count < 0
+	if repeat {
+		if count < -math.MaxInt64/2 {
+			return parser.AppendError(nil, "ERR value is out of range")
+		}
+		count = -count
+	}
+	members, err := s.stores[0].SetRandMembers(key, int(min(count, math.MaxInt32)), repeat)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	// Members may repeat, so the reply is an array even in RESP3.
+	return parser.AppendBulkArray(nil, members)
+}
+
+func (s *Server) handleSMove(c *Client, req [][]byte) []byte {
+	moved, err := s.stores[0].SetMove(string(req[1]), string(req[2]), req[3])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !moved {
+		return parser.AppendInt(nil, 0)
+	}
+	c.dirty++
+	return parser.AppendInt(nil, 1)
+}
+
+func (s *Server) handleSScan(c *Client, req [][]byte) []byte {
+	opts, errReply := parseScanArgs(req, false)
+	if errReply != nil {
+		return errReply
+	}
+	next, members, err := s.stores[0].SetScan(string(req[1]), opts.cursor, opts.count)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	var elements [][]byte
+	for _, member := range members {
+		if opts.match(member) {
+			elements = append(elements, member)
+		}
+	}
+	return appendScanReply(nil, next, elements)
+}
+
+func (s *Server) handleSInter(c *Client, req [][]byte) []byte {
+	return s.setAlgebraGeneric(c, req, store.SetOpInter)
+}
+
+func (s *Server) handleSUnion(c *Client, req [][]byte) []byte {
+	return s.setAlgebraGeneric(c, req, store.SetOpUnion)
+}
+
+func (s *Server) handleSDiff(c *Client, req [][]byte) []byte {
+	return s.setAlgebraGeneric(c, req, store.SetOpDiff)
+}
+
+func (s *Server) setAlgebraGeneric(c *Client, req [][]byte, op store.SetOperation) []byte {
+	members, err := s.stores[0].SetAlgebra(op, argStrings(req[1:]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return c.appendBulkSet(nil, members)
+}
+
+func (s *Server) handleSInterStore(c *Client, req [][]byte) []byte {
+	return s.setAlgebraStoreGeneric(c, req, store.SetOpInter)
+}
+
+func (s *Server) handleSUnionStore(c *Client, req [][]byte) []byte {
+	return s.setAlgebraStoreGeneric(c, req, store.SetOpUnion)
+}
+
+func (s *Server) handleSDiffStore(c *Client, req [][]byte) []byte {
+	return s.setAlgebraStoreGeneric(c, req, store.SetOpDiff)
+}
+
+func (s *Server) setAlgebraStoreGeneric(c *Client, req [][]byte, op store.SetOperation) []byte {
+	card, err := s.stores[0].SetAlgebraStore(op, string(req[1]), argStrings(req[2:]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.AppendInt(nil, int64(card))
+}
+
+func (s *Server) handleSInterCard(c *Client, req [][]byte) []byte {
+	numKeys, err := strconv.ParseInt(string(req[1]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return parser.AppendError(nil, "ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(req)-2) {
+		return parser.AppendError(nil, "ERR Number of keys can't be greater than number of args")
+	}
+	keys := argStrings(req[2 : 2+numKeys])
+	limit := 0
+	for i := 2 + int(numKeys); i < len(req); i += 2 {
+		if !strings.EqualFold(string(req[i]), "limit") || i+1 == len(req) {
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+		n, err := strconv.ParseInt(string(req[i+1]), 10, 64)
+		if err != nil {
+			return parser.AppendError(nil, "ERR value is not an integer or out of range")
+		}
+		if n < 0 {
+			return parser.AppendError(nil, "ERR LIMIT can't be negative")
+		}
+		limit = int(min(n, math.MaxInt32))
+	}
+
+	card, err := s.stores[0].SetInterCard(keys, limit)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendInt(nil, int64(card))
+}
@@ -1,7 +1,8 @@
 package server
 
 import (
-	"log"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -10,189 +11,331 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/app/store"
 )
 
-func (s *Server) handleXAdd(req [][]byte) []byte {
-	if len(req) < 5 {
-		log.Println("Not enough arguments for XADD")
-		return parser.AppendError(nil, "Not enough arguments for XADD")
-	}
-	key := string(req[1])
-	switch s.stores[0].Type(key) {
-	case "string":
-		log.Printf("XADD for key %s - Already exists as string type", key)
-		return parser.AppendError(nil, "1")
-	case "none":
-		err := s.stores[0].SetStream(key)
-		if err != nil {
-			return parser.AppendError(nil, "1")
+// streamNodeMaxEntries is the number of entries Redis groups in a node of a
+// stream, which bounds approximate trimming to 100 nodes by default.
+const streamNodeMaxEntries = 100
+
+// parseStreamTrim parses the options of XADD or XTRIM from req[2:]. For XADD
+// they end at the entry ID, whose index it returns.
+func parseStreamTrim(req [][]byte, xadd bool) (store.StreamAddOptions, int, []byte) {
+	var opts store.StreamAddOptions
+	trim := &opts.Trim
+	approx, limitGiven := false, false
+	i := 2
+	for ; i < len(req); i++ {
+		arg := strings.ToLower(string(req[i]))
+		moreArgs := len(req) - 1 - i
+		switch {
+		case xadd && arg == "*":
+		case (arg == "maxlen" || arg == "minid") && moreArgs > 0:
+			if trim.Strategy != store.TrimNone {
+				return opts, 0, parser.AppendError(nil, "ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+			}
+			approx = false
+			if next := string(req[i+1]); moreArgs >= 2 && (next == "~" || next == "=") {
+				approx = next == "~"
+				i++
+			}
+			i++
+			if arg == "minid" {
+				trim.Strategy, trim.MinID = store.TrimMinID, req[i]
+				continue
+			}
+			n, err := strconv.ParseInt(string(req[i]), 10, 64)
+			if err != nil {
+				return opts, 0, parser.AppendError(nil, "ERR value is not an integer or out of range")
+			}
+			if n < 0 {
+				return opts, 0, parser.AppendError(nil, "ERR The MAXLEN argument must be >= 0.")
+			}
+			trim.Strategy, trim.MaxLen = store.TrimMaxLen, n
+			continue
+		case arg == "limit" && moreArgs > 0:
+			n, err := strconv.ParseInt(string(req[i+1]), 10, 64)
+			if err != nil {
+				return opts, 0, parser.AppendError(nil, "ERR value is not an integer or out of range")
+			}
+			if n < 0 {
+				return opts, 0, parser.AppendError(nil, "ERR The LIMIT argument must be >= 0.")
+			}
+			trim.Limit, limitGiven = n, true
+			i++
+			continue
+		case xadd && arg == "nomkstream":
+			opts.NoMkStream = true
+			continue
+		case !xadd:
+			return opts, 0, parser.AppendError(nil, "ERR syntax error")
 		}
+		// Anything else is the entry ID of XADD.
+		break
 	}
-	entryID := req[2]
-
-	entryValues := make([]string, (len(req)-3)%2)
-	for i := 3; i < len(req); i++ {
-		entryValues = append(entryValues, string(req[i]))
+	switch {
+	case limitGiven && trim.Strategy == store.TrimNone:
+		return opts, 0, parser.AppendError(nil, "ERR syntax error, LIMIT cannot be used without specifying a trimming strategy")
+	case !xadd && trim.Strategy == store.TrimNone:
+		return opts, 0, parser.AppendError(nil, "ERR syntax error, XTRIM must be called with a trimming strategy")
+	case limitGiven && !approx:
+		return opts, 0, parser.AppendError(nil, "ERR syntax error, LIMIT cannot be used without the special ~ option")
+	case approx && !limitGiven:
+		trim.Limit = 100 * streamNodeMaxEntries
 	}
+	return opts, i, nil
+}
 
-	newEntryID, err := s.stores[0].AddStreamEntry(key, entryID, entryValues)
+func (s *Server) handleXAdd(c *Client, req [][]byte) []byte {
+	opts, idIndex, errReply := parseStreamTrim(req, true)
+	if errReply != nil {
+		return errReply
+	}
+	if n := len(req) - idIndex - 1; n < 2 || n%2 != 0 {
+		return parser.AppendError(nil, "ERR wrong number of arguments for 'xadd' command")
+	}
+	fields := make([]string, 0, len(req)-idIndex-1)
+	for _, arg := range req[idIndex+1:] {
+		fields = append(fields, string(arg))
+	}
+	id, err := s.stores[0].StreamAdd(string(req[1]), req[idIndex], fields, opts)
 	if err != nil {
 		return parser.AppendError(nil, err.Error())
 	}
-	return parser.AppendBulkString(nil, string(newEntryID))
+	if id == "" {
+		return c.appendNull(nil)
+	}
+	c.dirty++
+	// Replicas add the entry with the ID generated here.
+	args := make([]string, len(req))
+	for i, arg := range req {
+		args[i] = string(arg)
+	}
+	args[idIndex] = id
+	c.rewriteCommand(args...)
+	return parser.AppendBulkString(nil, id)
 }
 
-func (s *Server) handleXRange(req [][]byte) []byte {
-	if len(req) < 4 {
-		log.Println("Not enough arguments for XRANGE")
-		return parser.AppendError(nil, "ERR Not enough arguments for XRANGE")
+func (s *Server) handleXTrim(c *Client, req [][]byte) []byte {
+	opts, _, errReply := parseStreamTrim(req, false)
+	if errReply != nil {
+		return errReply
 	}
-	key := string(req[1])
-	if s.stores[0].Type(key) != "stream" {
-		return parser.AppendError(nil, "ERR key is not a stream")
+	trimmed, err := s.stores[0].StreamTrim(string(req[1]), opts.Trim)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
 	}
-	entries := s.stores[0].Range(key, req[2], req[3])
+	c.dirty += trimmed
+	return parser.AppendInt(nil, int64(trimmed))
+}
 
-	response := parser.AppendArray(nil, len(entries))
-	for _, entry := range entries {
-		response = parser.AppendArray(response, 2)
-		response = parser.AppendBulkString(response, entry.ID)
-		response = parser.AppendArray(response, len(entry.Value)*2)
-		for _, kv := range entry.Value {
-			response = parser.AppendBulkString(response, kv.Key)
-			response = parser.AppendBulkString(response, kv.Value)
-		}
+func (s *Server) handleXDel(c *Client, req [][]byte) []byte {
+	deleted, err := s.stores[0].StreamDelete(string(req[1]), req[2:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
 	}
-	return response
+	c.dirty += deleted
+	return parser.AppendInt(nil, int64(deleted))
 }
 
-func (s *Server) handleXRead(req [][]byte) []byte {
-	if len(req) < 4 {
-		log.Println("Not enough arguments for XREAD")
-		return parser.AppendError(nil, "ERR Not enough arguments for XREAD")
+func (s *Server) handleXLen(c *Client, req [][]byte) []byte {
+	n, err := s.stores[0].StreamLen(string(req[1]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
 	}
+	return parser.AppendInt(nil, int64(n))
+}
 
-	// Parse BLOCK option if present
-	var blockMillis int64 = -1 // -1 means non-blocking
-	for i := 1; i < len(req); i++ {
-		if strings.ToUpper(string(req[i])) == "BLOCK" {
-			if i+1 >= len(req) {
-				return parser.AppendError(nil, "ERR syntax error")
-			}
-			var err error
-			blockMillis, err = strconv.ParseInt(string(req[i+1]), 10, 64)
+func (s *Server) handleXSetID(c *Client, req [][]byte) []byte {
+	entriesAdded := int64(-1)
+	var maxDeletedID []byte
+	for i := 3; i < len(req); i += 2 {
+		if i+1 == len(req) {
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+		switch strings.ToLower(string(req[i])) {
+		case "entriesadded":
+			n, err := strconv.ParseInt(string(req[i+1]), 10, 64)
 			if err != nil {
-				return parser.AppendError(nil, "ERR invalid BLOCK timeout")
+				return parser.AppendError(nil, "ERR value is not an integer or out of range")
 			}
-			break
+			if n < 0 {
+				return parser.AppendError(nil, "ERR entries_added must be positive")
+			}
+			entriesAdded = n
+		case "maxdeletedid":
+			maxDeletedID = req[i+1]
+		default:
+			return parser.AppendError(nil, "ERR syntax error")
 		}
 	}
+	if err := s.stores[0].StreamSetID(string(req[1]), req[2], entriesAdded, maxDeletedID); err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.OK()
+}
 
-	// Find STREAMS keyword position
-	streamsPos := -1
-	for i, arg := range req {
-		if strings.ToUpper(string(arg)) == "STREAMS" {
-			streamsPos = i
-			break
+func (s *Server) handleXRange(c *Client, req [][]byte) []byte {
+	return s.xrange(c, req, false)
+}
+
+func (s *Server) handleXRevRange(c *Client, req [][]byte) []byte {
+	return s.xrange(c, req, true)
+}
+
+// xrange implements XRANGE, and XREVRANGE whose bounds come in reverse order.
+func (s *Server) xrange(c *Client, req [][]byte, rev bool) []byte {
+	count := -1
+	for i := 4; i < len(req); i += 2 {
+		if !strings.EqualFold(string(req[i]), "count") || i+1 == len(req) {
+			return parser.AppendError(nil, "ERR syntax error")
 		}
+		n, err := strconv.ParseInt(string(req[i+1]), 10, 64)
+		if err != nil {
+			return parser.AppendError(nil, "ERR value is not an integer or out of range")
+		}
+		count = int(max(min(n, math.MaxInt32), 0))
 	}
-	if streamsPos == -1 {
-		return parser.AppendError(nil, "ERR syntax error")
+	if count == 0 {
+		return c.appendNullArray(nil)
 	}
-
-	streamKeys := req[streamsPos+1 : (len(req)+streamsPos+1)/2]
-	streamIDs := req[(len(req)+streamsPos+1)/2:]
-
-	if len(streamKeys) != len(streamIDs) {
-		return parser.AppendError(nil, "ERR Unbalanced STREAMS list")
+	var entries []store.StreamEntry
+	var err error
+	if rev {
+		entries, err = s.stores[0].ReverseRange(string(req[1]), req[2], req[3], max(count, 0))
+	} else {
+		entries, err = s.stores[0].Range(string(req[1]), req[2], req[3], max(count, 0))
 	}
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return c.appendStreamEntries(nil, entries)
+}
 
-	// Set up deadline for blocking
-	var deadline time.Time
-	if blockMillis > 0 {
-		deadline = time.Now().Add(time.Duration(blockMillis) * time.Millisecond)
+// appendStreamEntries appends entries as an array of ID and fields pairs.
+func (c *Client) appendStreamEntries(b []byte, entries []store.StreamEntry) []byte {
+	b = parser.AppendArray(b, len(entries))
+	for _, entry := range entries {
+		b = c.appendStreamEntry(b, entry)
 	}
+	return b
+}
 
-	// Check for $ IDs
-	for i, keyBytes := range streamKeys {
-		key := string(keyBytes)
-		startID := streamIDs[i]
-		if s.stores[0].Type(key) != "stream" {
-			return parser.AppendError(nil, "ERR key is not a stream")
-		}
+// appendStreamEntry appends an entry as its ID and fields. Deleted entries
+// read from a pending entries list have null fields.
+func (c *Client) appendStreamEntry(b []byte, entry store.StreamEntry) []byte {
+	b = parser.AppendArray(b, 2)
+	b = parser.AppendBulkString(b, entry.ID)
+	if entry.Value == nil {
+		return c.appendNullArray(b)
+	}
+	b = parser.AppendArray(b, len(entry.Value)*2)
+	for _, kv := range entry.Value {
+		b = parser.AppendBulkString(b, kv.Key)
+		b = parser.AppendBulkString(b, kv.Value)
+	}
+	return b
+}
 
-		if string(startID) == "$" {
-			var err error
-			startID, err = s.stores[0].GetStreamLastEntryID(key)
-			if err != nil {
-				return parser.AppendError(nil, err.Error())
-			}
+// appendStreams appends the entries read from several streams: a map of
+// stream key to entries for RESP3 clients, and an array of key and entries
+// pairs for RESP2 ones.
+func (c *Client) appendStreams(b []byte, keys []string, entries [][]store.StreamEntry) []byte {
+	if c.resp3() {
+		b = parser.AppendMap(b, len(keys))
+	} else {
+		b = parser.AppendArray(b, len(keys))
+	}
+	for i, key := range keys {
+		if !c.resp3() {
+			b = parser.AppendArray(b, 2)
 		}
-		streamIDs[i] = startID
+		b = parser.AppendBulkString(b, key)
+		b = c.appendStreamEntries(b, entries[i])
 	}
+	return b
+}
 
-	for {
-		// Collect entries from all streams
-		var results []struct {
-			key     string
-			entries []store.StreamEntry
+// handleXRead implements XREAD [COUNT count] [BLOCK milliseconds] STREAMS
+// key [key ...] id [id ...]. An ID of $ reads the entries added after the
+// command is called. With BLOCK, a read that finds no entries waits for an
+// entry to be added to one of the streams, forever with a timeout of 0.
+func (s *Server) handleXRead(c *Client, req [][]byte) []byte {
+	count := 0
+	var timeout time.Duration
+	block := false
+	i := 1
+	for ; i < len(req); i++ {
+		arg := strings.ToLower(string(req[i]))
+		if arg == "streams" {
+			break
 		}
-
-		for i, keyBytes := range streamKeys {
-			key := string(keyBytes)
-			startID := streamIDs[i]
-			if s.stores[0].Type(key) != "stream" {
-				return parser.AppendError(nil, "ERR key is not a stream")
-			}
-
-			if string(startID) == "$" {
-				var err error
-				startID, err = s.stores[0].GetStreamLastEntryID(key)
-				if err != nil {
-					return parser.AppendError(nil, err.Error())
-				}
+		switch {
+		case arg == "count" && i+1 < len(req):
+			i++
+			n, err := strconv.ParseInt(string(req[i]), 10, 64)
+			if err != nil {
+				return parser.AppendError(nil, "ERR value is not an integer or out of range")
 			}
-			entries := s.stores[0].Range(key, startID, []byte("+"))
-
-			// Filter entries to make range exclusive
-			var validEntries []store.StreamEntry
-			for _, entry := range entries {
-				if entry.ID > string(startID) {
-					validEntries = append(validEntries, entry)
-				}
+			count = int(max(min(n, math.MaxInt32), 0))
+		case arg == "block" && i+1 < len(req):
+			i++
+			ms, err := strconv.ParseInt(string(req[i]), 10, 64)
+			if err != nil {
+				return parser.AppendError(nil, "ERR timeout is not an integer or out of range")
 			}
-
-			if len(validEntries) > 0 {
-				results = append(results, struct {
-					key     string
-					entries []store.StreamEntry
-				}{key, validEntries})
+			if ms < 0 {
+				return parser.AppendError(nil, "ERR timeout is negative")
 			}
+			block, timeout = true, time.Duration(ms)*time.Millisecond
+		default:
+			return parser.AppendError(nil, "ERR syntax error")
 		}
-
-		if len(results) > 0 || blockMillis == -1 {
-			if len(results) == 0 {
-				return parser.NullArray()
-			}
-			response := parser.AppendArray(nil, len(results))
-			for _, result := range results {
-				response = parser.AppendArray(response, 2)
-				response = parser.AppendBulkString(response, result.key)
-				response = parser.AppendArray(response, len(result.entries))
-				for _, entry := range result.entries {
-					response = parser.AppendArray(response, 2)
-					response = parser.AppendBulkString(response, entry.ID)
-					response = parser.AppendArray(response, len(entry.Value)*2)
-					for _, kv := range entry.Value {
-						response = parser.AppendBulkString(response, kv.Key)
-						response = parser.AppendBulkString(response, kv.Value)
-					}
-				}
+	}
+	streams := req[min(i+1, len(req)):]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		return parser.AppendError(nil, "ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.")
+	}
+	keys := argStrings(streams[:len(streams)/2])
+	ids := make([][]byte, len(keys))
+	for i, id := range streams[len(streams)/2:] {
+		switch string(id) {
+		case ">":
+			return parser.AppendError(nil, "ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+		case "$":
+			// Blocked reads resume from the last ID at the time of the call.
+			last, err := s.stores[0].GetStreamLastEntryID(keys[i])
+			if err != nil {
+				return parser.AppendError(nil, err.Error())
 			}
-			return response
-		}
-		if blockMillis > 0 && time.Now().After(deadline) {
-			return parser.NullArray()
+			ids[i] = last
+		default:
+			ids[i] = id
 		}
-		time.Sleep(10 * time.Millisecond)
 	}
 
+	entries, err := s.stores[0].StreamRead(keys, ids, count)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	var replyKeys []string
+	var replyEntries [][]store.StreamEntry
+	for i, key := range keys {
+		if len(entries[i]) > 0 {
+			replyKeys = append(replyKeys, key)
+			replyEntries = append(replyEntries, entries[i])
+		}
+	}
+	if len(replyKeys) > 0 {
+		return c.appendStreams(nil, replyKeys, replyEntries)
+	}
+	if !block {
+		return c.appendNullArray(nil)
+	}
+	return s.blockForKeys(c, keys, timeout, "", false, func(key string) ([]byte, [][][]byte) {
+		id := ids[slices.Index(keys, key)]
+		entries, err := s.stores[0].StreamRead([]string{key}, [][]byte{id}, count)
+		if err != nil || len(entries[0]) == 0 {
+			return nil, nil
+		}
+		return c.appendStreams(nil, []string{key}, entries), nil
+	})
 }
@@ -0,0 +1,433 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+func (s *Server) handleXGroupCreate(c *Client, req [][]byte) []byte {
+	mkstream := false
+	entriesRead := int64(-1)
+	for i := 5; i < len(req); i++ {
+		switch {
+		case strings.EqualFold(string(req[i]), "mkstream"):
+			mkstream = true
+		case strings.EqualFold(string(req[i]), "entriesread") && i+1 < len(req):
+			var errReply []byte
+			if entriesRead, errReply = parseEntriesRead(req[i+1]); errReply != nil {
+				return errReply
+			}
+			i++
+		default:
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+	}
+	if err := s.stores[0].StreamGroupCreate(string(req[2]), string(req[3]), req[4], entriesRead, mkstream); err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.OK()
+}
+
+func (s *Server) handleXGroupSetID(c *Client, req [][]byte) []byte {
+	entriesRead := int64(-1)
+	if len(req) > 5 {
+		if len(req) != 7 || !strings.EqualFold(string(req[5]), "entriesread") {
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+		var errReply []byte
+		if entriesRead, errReply = parseEntriesRead(req[6]); errReply != nil {
+			return errReply
+		}
+	}
+	if err := s.stores[0].StreamGroupSetID(string(req[2]), string(req[3]), req[4], entriesRead); err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.OK()
+}
+
+// parseEntriesRead parses the ENTRIESREAD option of XGROUP CREATE and SETID,
+// where -1 stands for an unknown count.
+func parseEntriesRead(arg []byte) (int64, []byte) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	if n < -1 {
+		return 0, parser.AppendError(nil, "ERR value for ENTRIESREAD must be positive or -1")
+	}
+	return n, nil
+}
+
+func (s *Server) handleXGroupDestroy(c *Client, req [][]byte) []byte {
+	destroyed, err := s.stores[0].StreamGroupDestroy(string(req[2]), string(req[3]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !destroyed {
+		return parser.AppendInt(nil, 0)
+	}
+	c.dirty++
+	return parser.AppendInt(nil, 1)
+}
+
+func (s *Server) handleXGroupCreateConsumer(c *Client, req [][]byte) []byte {
+	created, err := s.stores[0].StreamCreateConsumer(string(req[2]), string(req[3]), string(req[4]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !created {
+		return parser.AppendInt(nil, 0)
+	}
+	c.dirty++
+	return parser.AppendInt(nil, 1)
+}
+
+func (s *Server) handleXGroupDelConsumer(c *Client, req [][]byte) []byte {
+	pending, err := s.stores[0].StreamDeleteConsumer(string(req[2]), string(req[3]), string(req[4]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.AppendInt(nil, int64(pending))
+}
+
+// groupUpdateCommands returns the commands that replay on replicas the
+// changes made to a consumer group, as Redis does: the creation of the
+// consumer, an XCLAIM that forces the state of every delivered or claimed
+// entry, an XACK of the entries that left the pending entries list and an
+// XGROUP SETID with the count of entries read when the last delivered ID
+// moved.
+func groupUpdateCommands(key, group, consumer string, update store.GroupUpdate) [][][]byte {
+	var commands [][][]byte
+	if update.ConsumerCreated {
+		commands = append(commands, byteArgs("XGROUP", "CREATECONSUMER", key, group, consumer))
+	}
+	for _, p := range update.Claimed {
+		commands = append(commands, byteArgs("XCLAIM", key, group, p.Consumer, "0", p.ID,
+			"TIME", strconv.FormatInt(p.DeliveryTime, 10),
+			"RETRYCOUNT", strconv.FormatInt(p.DeliveryCount, 10),
+			"FORCE", "JUSTID", "LASTID", update.LastID))
+	}
+	if len(update.Removed) > 0 {
+		commands = append(commands, byteArgs(append([]string{"XACK", key, group}, update.Removed...)...))
+	}
+	if update.LastIDChanged {
+		commands = append(commands, byteArgs("XGROUP", "SETID", key, group, update.LastID,
+			"ENTRIESREAD", strconv.FormatInt(update.EntriesRead, 10)))
+	}
+	return commands
+}
+
+// propagateGroupUpdate propagates the changes made to a consumer group in
+// place of the current command.
+func (c *Client) propagateGroupUpdate(key, group, consumer string, update store.GroupUpdate) {
+	if commands := groupUpdateCommands(key, group, consumer, update); len(commands) > 0 {
+		c.dirty++
+		c.alsoPropagate(commands...)
+	}
+}
+
+// handleXReadGroup implements XREADGROUP GROUP group consumer [COUNT count]
+// [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]. It is
+// propagated as the changes it made to the groups, as the entries read
+// depend on when it runs.
+func (s *Server) handleXReadGroup(c *Client, req [][]byte) []byte {
+	if !strings.EqualFold(string(req[1]), "group") {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	group, consumer := string(req[2]), string(req[3])
+	count := 0
+	var timeout time.Duration
+	block, noack := false, false
+	i := 4
+	for ; i < len(req); i++ {
+		arg := strings.ToLower(string(req[i]))
+		if arg == "streams" {
+			break
+		}
+		switch {
+		case arg == "noack":
+			noack = true
+		case arg == "count" && i+1 < len(req):
+			i++
+			n, err := strconv.ParseInt(string(req[i]), 10, 64)
+			if err != nil {
+				return parser.AppendError(nil, "ERR value is not an integer or out of range")
+			}
+			count = int(max(min(n, math.MaxInt32), 0))
+		case arg == "block" && i+1 < len(req):
+			i++
+			ms, err := strconv.ParseInt(string(req[i]), 10, 64)
+			if err != nil {
+				return parser.AppendError(nil, "ERR timeout is not an integer or out of range")
+			}
+			if ms < 0 {
+				return parser.AppendError(nil, "ERR timeout is negative")
+			}
+			block, timeout = true, time.Duration(ms)*time.Millisecond
+		default:
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+	}
+	streams := req[min(i+1, len(req)):]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		return parser.AppendError(nil, "ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.")
+	}
+	keys := argStrings(streams[:len(streams)/2])
+	ids := streams[len(streams)/2:]
+
+	entries, updates, err := s.stores[0].StreamReadGroup(group, consumer, keys, ids, count, noack)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	var replyKeys []string
+	var replyEntries [][]store.StreamEntry
+	history := false
+	for i, key := range keys {
+		c.propagateGroupUpdate(key, group, consumer, updates[i])
+		// Reads of pending entries always reply, even with no entries.
+		if string(ids[i]) != ">" {
+			history = true
+		} else if len(entries[i]) == 0 {
+			continue
+		}
+		replyKeys = append(replyKeys, key)
+		replyEntries = append(replyEntries, entries[i])
+	}
+	if len(replyKeys) > 0 || history {
+		return c.appendStreams(nil, replyKeys, replyEntries)
+	}
+	if !block {
+		return c.appendNullArray(nil)
+	}
+	// The client is unblocked with an error once the stream or the group is
+	// deleted.
+	return s.blockForKeys(c, keys, timeout, "", true, func(key string) ([]byte, [][][]byte) {
+		entries, updates, err := s.stores[0].StreamReadGroup(group, consumer, []string{key}, [][]byte{[]byte(">")}, count, noack)
+		if err != nil && s.stores[0].Type(key) != string(store.StreamType) {
+			return parser.AppendError(nil, "UNBLOCKED the stream key no longer exists"), nil
+		}
+		if err != nil {
+			return parser.AppendError(nil, err.Error()), nil
+		}
+		if len(entries[0]) == 0 {
+			return nil, nil
+		}
+		reply := c.appendStreams(nil, []string{key}, entries)
+		return reply, groupUpdateCommands(key, group, consumer, updates[0])
+	})
+}
+
+func (s *Server) handleXAck(c *Client, req [][]byte) []byte {
+	acked, err := s.stores[0].StreamAck(string(req[1]), string(req[2]), req[3:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty += acked
+	return parser.AppendInt(nil, int64(acked))
+}
+
+// handleXPending implements XPENDING key group [[IDLE min-idle-time] start
+// end count [consumer]]. Without a range it replies with a summary of the
+// pending entries list.
+func (s *Server) handleXPending(c *Client, req [][]byte) []byte {
+	key, group := string(req[1]), string(req[2])
+	if len(req) == 3 {
+		summary, err := s.stores[0].StreamPendingSummary(key, group)
+		if err != nil {
+			return parser.AppendError(nil, err.Error())
+		}
+		response := parser.AppendArray(nil, 4)
+		response = parser.AppendInt(response, int64(summary.Count))
+		if summary.Count == 0 {
+			response = c.appendNull(response)
+			response = c.appendNull(response)
+			return c.appendNullArray(response)
+		}
+		response = parser.AppendBulkString(response, summary.First)
+		response = parser.AppendBulkString(response, summary.Last)
+		response = parser.AppendArray(response, len(summary.Consumers))
+		for _, consumer := range summary.Consumers {
+			response = parser.AppendArray(response, 2)
+			response = parser.AppendBulkString(response, consumer.Name)
+			response = parser.AppendBulkString(response, strconv.Itoa(consumer.Count))
+		}
+		return response
+	}
+
+	args := req[3:]
+	var minIdle int64
+	if strings.EqualFold(string(args[0]), "idle") && len(args) > 1 {
+		var err error
+		if minIdle, err = strconv.ParseInt(string(args[1]), 10, 64); err != nil {
+			return parser.AppendError(nil, "ERR value is not an integer or out of range")
+		}
+		args = args[2:]
+	}
+	if len(args) < 3 || len(args) > 4 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	n, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	count := int(max(min(n, math.MaxInt32), 0))
+	consumer := ""
+	if len(args) == 4 {
+		consumer = string(args[3])
+	}
+	entries, err := s.stores[0].StreamPendingRange(key, group, args[0], args[1], count, consumer, minIdle)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	response := parser.AppendArray(nil, len(entries))
+	for _, entry := range entries {
+		response = parser.AppendArray(response, 4)
+		response = parser.AppendBulkString(response, entry.ID)
+		response = parser.AppendBulkString(response, entry.Consumer)
+		response = parser.AppendInt(response, entry.Idle)
+		response = parser.AppendInt(response, entry.DeliveryCount)
+	}
+	return response
+}
+
+// parseMinIdle parses the min-idle-time argument of XCLAIM and XAUTOCLAIM,
+// where negative values mean 0.
+func parseMinIdle(arg []byte, command string) (int64, []byte) {
+	minIdle, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, parser.AppendError(nil, "ERR Invalid min-idle-time argument for "+command)
+	}
+	return max(minIdle, 0), nil
+}
+
+// handleXClaim implements XCLAIM key group consumer min-idle-time id [id ...]
+// [IDLE ms] [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE]
+// [JUSTID] [LASTID lastid].
+func (s *Server) handleXClaim(c *Client, req [][]byte) []byte {
+	key, group, consumer := string(req[1]), string(req[2]), string(req[3])
+	minIdle, errReply := parseMinIdle(req[4], "XCLAIM")
+	if errReply != nil {
+		return errReply
+	}
+	// The IDs end at the first argument that is not one.
+	i := 5
+	for i < len(req) && store.IsStreamID(req[i]) {
+		i++
+	}
+	ids := req[5:i]
+
+	now := time.Now().UnixMilli()
+	opts := store.StreamClaimOptions{DeliveryTime: now, RetryCount: -1}
+	for ; i < len(req); i++ {
+		option := strings.ToUpper(string(req[i]))
+		switch option {
+		case "FORCE":
+			opts.Force = true
+		case "JUSTID":
+			opts.JustID = true
+		case "IDLE", "TIME", "RETRYCOUNT", "LASTID":
+			if i+1 == len(req) {
+				return parser.AppendError(nil, "ERR syntax error")
+			}
+			i++
+			if option == "LASTID" {
+				opts.LastID = req[i]
+				continue
+			}
+			n, err := strconv.ParseInt(string(req[i]), 10, 64)
+			if err != nil {
+				return parser.AppendError(nil, "ERR Invalid "+option+" option argument for XCLAIM")
+			}
+			switch option {
+			case "IDLE":
+				opts.DeliveryTime = now - n
+			case "TIME":
+				opts.DeliveryTime = n
+			case "RETRYCOUNT":
+				opts.RetryCount = n
+			}
+		default:
+			return parser.AppendError(nil, "ERR Unrecognized XCLAIM option '"+string(req[i])+"'")
+		}
+	}
+	// Delivery times in the future, or before the epoch, are bogus and taken
+	// as now, rather than failing clients whose clock is a bit off.
+	if opts.DeliveryTime < 0 || opts.DeliveryTime > now {
+		opts.DeliveryTime = now
+	}
+
+	entries, update, err := s.stores[0].StreamClaim(key, group, consumer, minIdle, ids, opts)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.propagateGroupUpdate(key, group, consumer, update)
+	if opts.JustID {
+		return appendEntryIDs(nil, entries)
+	}
+	return c.appendStreamEntries(nil, entries)
+}
+
+// handleXAutoClaim implements XAUTOCLAIM key group consumer min-idle-time
+// start [COUNT count] [JUSTID].
+func (s *Server) handleXAutoClaim(c *Client, req [][]byte) []byte {
+	key, group, consumer := string(req[1]), string(req[2]), string(req[3])
+	minIdle, errReply := parseMinIdle(req[4], "XAUTOCLAIM")
+	if errReply != nil {
+		return errReply
+	}
+	count, justID := 100, false
+	for i := 6; i < len(req); i++ {
+		switch {
+		case strings.EqualFold(string(req[i]), "justid"):
+			justID = true
+		case strings.EqualFold(string(req[i]), "count") && i+1 < len(req):
+			i++
+			n, err := strconv.ParseInt(string(req[i]), 10, 64)
+			if err != nil {
+				return parser.AppendError(nil, "ERR value is not an integer or out of range")
+			}
+			// Up to ten times COUNT entries are scanned.
+			if n < 1 || n > math.MaxInt64/10 {
+				return parser.AppendError(nil, "ERR COUNT must be > 0")
+			}
+			count = int(n)
+		default:
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+	}
+
+	next, entries, update, err := s.stores[0].StreamAutoClaim(key, group, consumer, minIdle, req[5], count, justID)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.propagateGroupUpdate(key, group, consumer, update)
+	response := parser.AppendArray(nil, 3)
+	response = parser.AppendBulkString(response, next)
+	if justID {
+		response = appendEntryIDs(response, entries)
+	} else {
+		response = c.appendStreamEntries(response, entries)
+	}
+	response = parser.AppendArray(response, len(update.Removed))
+	for _, id := range update.Removed {
+		response = parser.AppendBulkString(response, id)
+	}
+	return response
+}
+
+// appendEntryIDs appends the IDs of entries as an array.
+func appendEntryIDs(b []byte, entries []store.StreamEntry) []byte {
+	b = parser.AppendArray(b, len(entries))
+	for _, entry := range entries {
+		b = parser.AppendBulkString(b, entry.ID)
+	}
+	return b
+}
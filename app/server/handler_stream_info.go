@@ -0,0 +1,181 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// handleXInfoStream implements XINFO STREAM key [FULL [COUNT count]]. The
+// full form lists the first count entries, 10 by default or all of them for
+// 0, and details the groups and consumers.
+func (s *Server) handleXInfoStream(c *Client, req [][]byte) []byte {
+	full := false
+	count := 10
+	switch len(req) {
+	case 3:
+	case 4, 6:
+		full = strings.EqualFold(string(req[3]), "full")
+		if !full || len(req) == 6 && !strings.EqualFold(string(req[4]), "count") {
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+		if len(req) == 6 {
+			n, err := strconv.ParseInt(string(req[5]), 10, 64)
+			if err != nil {
+				return parser.AppendError(nil, "ERR value is not an integer or out of range")
+			}
+			if n >= 0 {
+				count = int(min(n, math.MaxInt32))
+			}
+		}
+	default:
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	info, err := s.stores[0].StreamInfo(string(req[2]), full, count)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+
+	pairs := 10
+	if full {
+		pairs = 9
+	}
+	response := c.appendMap(nil, pairs)
+	response = parser.AppendBulkString(response, "length")
+	response = parser.AppendInt(response, int64(info.Length))
+	response = parser.AppendBulkString(response, "radix-tree-keys")
+	response = parser.AppendInt(response, int64(info.RadixTreeKeys))
+	response = parser.AppendBulkString(response, "radix-tree-nodes")
+	response = parser.AppendInt(response, int64(info.RadixTreeNodes))
+	response = parser.AppendBulkString(response, "last-generated-id")
+	response = parser.AppendBulkString(response, info.LastGeneratedID)
+	response = parser.AppendBulkString(response, "max-deleted-entry-id")
+	response = parser.AppendBulkString(response, info.MaxDeletedID)
+	response = parser.AppendBulkString(response, "entries-added")
+	response = parser.AppendInt(response, int64(info.EntriesAdded))
+	response = parser.AppendBulkString(response, "recorded-first-entry-id")
+	response = parser.AppendBulkString(response, info.RecordedFirstID)
+	if !full {
+		response = parser.AppendBulkString(response, "groups")
+		response = parser.AppendInt(response, int64(len(info.Groups)))
+		response = parser.AppendBulkString(response, "first-entry")
+		response = c.appendOptionalEntry(response, info.FirstEntry)
+		response = parser.AppendBulkString(response, "last-entry")
+		return c.appendOptionalEntry(response, info.LastEntry)
+	}
+
+	response = parser.AppendBulkString(response, "entries")
+	response = c.appendStreamEntries(response, info.Entries)
+	response = parser.AppendBulkString(response, "groups")
+	response = parser.AppendArray(response, len(info.Groups))
+	for _, group := range info.Groups {
+		response = c.appendMap(response, 7)
+		response = parser.AppendBulkString(response, "name")
+		response = parser.AppendBulkString(response, group.Name)
+		response = parser.AppendBulkString(response, "last-delivered-id")
+		response = parser.AppendBulkString(response, group.LastDeliveredID)
+		response = c.appendGroupCounters(response, group)
+		response = parser.AppendBulkString(response, "pel-count")
+		response = parser.AppendInt(response, int64(group.Pending))
+		response = parser.AppendBulkString(response, "pending")
+		response = parser.AppendArray(response, len(group.PendingEntries))
+		for _, entry := range group.PendingEntries {
+			response = parser.AppendArray(response, 4)
+			response = parser.AppendBulkString(response, entry.ID)
+			response = parser.AppendBulkString(response, entry.Consumer)
+			response = parser.AppendInt(response, entry.DeliveryTime)
+			response = parser.AppendInt(response, entry.DeliveryCount)
+		}
+		response = parser.AppendBulkString(response, "consumers")
+		response = parser.AppendArray(response, len(group.Consumers))
+		for _, consumer := range group.Consumers {
+			response = c.appendMap(response, 5)
+			response = parser.AppendBulkString(response, "name")
+			response = parser.AppendBulkString(response, consumer.Name)
+			response = parser.AppendBulkString(response, "seen-time")
+			response = parser.AppendInt(response, consumer.SeenTime)
+			response = parser.AppendBulkString(response, "active-time")
+			response = parser.AppendInt(response, consumer.ActiveTime)
+			response = parser.AppendBulkString(response, "pel-count")
+			response = parser.AppendInt(response, int64(consumer.Pending))
+			response = parser.AppendBulkString(response, "pending")
+			response = parser.AppendArray(response, len(consumer.PendingEntries))
+			for _, entry := range consumer.PendingEntries {
+				response = parser.AppendArray(response, 3)
+				response = parser.AppendBulkString(response, entry.ID)
+				response = parser.AppendInt(response, entry.DeliveryTime)
+				response = parser.AppendInt(response, entry.DeliveryCount)
+			}
+		}
+	}
+	return response
+}
+
+// appendOptionalEntry appends entry, or a null for the first and last entries
+// of an empty stream.
+func (c *Client) appendOptionalEntry(b []byte, entry *store.StreamEntry) []byte {
+	if entry == nil {
+		return c.appendNull(b)
+	}
+	return c.appendStreamEntry(b, *entry)
+}
+
+// appendGroupCounters appends the entries-read and lag fields of a group,
+// which are null when unknown.
+func (c *Client) appendGroupCounters(b []byte, group store.GroupInfo) []byte {
+	b = parser.AppendBulkString(b, "entries-read")
+	if group.EntriesRead < 0 {
+		b = c.appendNull(b)
+	} else {
+		b = parser.AppendInt(b, group.EntriesRead)
+	}
+	b = parser.AppendBulkString(b, "lag")
+	if group.Lag < 0 {
+		return c.appendNull(b)
+	}
+	return parser.AppendInt(b, group.Lag)
+}
+
+func (s *Server) handleXInfoGroups(c *Client, req [][]byte) []byte {
+	groups, err := s.stores[0].StreamGroupsInfo(string(req[2]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	response := parser.AppendArray(nil, len(groups))
+	for _, group := range groups {
+		response = c.appendMap(response, 6)
+		response = parser.AppendBulkString(response, "name")
+		response = parser.AppendBulkString(response, group.Name)
+		response = parser.AppendBulkString(response, "consumers")
+		response = parser.AppendInt(response, int64(len(group.Consumers)))
+		response = parser.AppendBulkString(response, "pending")
+		response = parser.AppendInt(response, int64(group.Pending))
+		response = parser.AppendBulkString(response, "last-delivered-id")
+		response = parser.AppendBulkString(response, group.LastDeliveredID)
+		response = c.appendGroupCounters(response, group)
+	}
+	return response
+}
+
+func (s *Server) handleXInfoConsumers(c *Client, req [][]byte) []byte {
+	consumers, err := s.stores[0].StreamConsumersInfo(string(req[2]), string(req[3]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	response := parser.AppendArray(nil, len(consumers))
+	for _, consumer := range consumers {
+		response = c.appendMap(response, 4)
+		response = parser.AppendBulkString(response, "name")
+		response = parser.AppendBulkString(response, consumer.Name)
+		response = parser.AppendBulkString(response, "pending")
+		response = parser.AppendInt(response, int64(consumer.Pending))
+		response = parser.AppendBulkString(response, "idle")
+		response = parser.AppendInt(response, consumer.Idle)
+		response = parser.AppendBulkString(response, "inactive")
+		response = parser.AppendInt(response, consumer.Inactive)
+	}
+	return response
+}
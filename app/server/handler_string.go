@@ -0,0 +1,345 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+func (s *Server) handleGet(c *Client, req [][]byte) []byte {
+	value, ok, err := s.stores[0].Get(string(req[1]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !ok {
+		return c.appendNull(nil)
+	}
+	return parser.AppendBulk(nil, value)
+}
+
+func (s *Server) handleSet(c *Client, req [][]byte) []byte {
+	var opts store.SetOptions
+	var expireOpt string
+	for i := 3; i < len(req); i++ {
+		opt := strings.ToLower(string(req[i]))
+		switch opt {
+		case "nx", "xx":
+			if opts.NX || opts.XX {
+				return parser.AppendError(nil, "ERR syntax error")
+			}
+			opts.NX, opts.XX = opt == "nx", opt == "xx"
+		case "get":
+			opts.Get = true
+		case "keepttl":
+			if expireOpt != "" {
+				return parser.AppendError(nil, "ERR syntax error")
+			}
+			opts.KeepTTL = true
+		case "ex", "px", "exat", "pxat":
+			if opts.KeepTTL || expireOpt != "" || i+1 >= len(req) {
+				return parser.AppendError(nil, "ERR syntax error")
+			}
+			expireOpt = opt
+			i++
+			at, errReply := parseExpiryOption("set", opt, req[i])
+			if errReply != nil {
+				return errReply
+			}
+			opts.ExpireAt = at
+		default:
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+	}
+
+	key := string(req[1])
+	result, err := s.stores[0].SetWithOptions(key, req[2], opts)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if result.Set {
+		c.dirty++
+		// Replicas receive an absolute expiry so they don't drift, and none of
+		// the conditions since the outcome is already known.
+		switch {
+		case expireOpt != "":
+			c.rewriteCommand("SET", key, string(req[2]), "PXAT", strconv.FormatInt(opts.ExpireAt, 10))
+		case opts.KeepTTL:
+			c.rewriteCommand("SET", key, string(req[2]), "KEEPTTL")
+		case len(req) > 3:
+			c.rewriteCommand("SET", key, string(req[2]))
+		}
+	}
+
+	if opts.Get {
+		if !result.Exists {
+			return c.appendNull(nil)
+		}
+		return parser.AppendBulk(nil, result.Old)
+	}
+	if !result.Set {
+		return c.appendNull(nil)
+	}
+	return parser.OK()
+}
+
+// parseExpiryOption converts the argument of an EX, PX, EXAT or PXAT option of
+// cmd to a unix time in milliseconds, or returns the error reply.
+func parseExpiryOption(cmd string, opt string, arg []byte) (int64, []byte) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	if n <= 0 {
+		return 0, expireTimeError(cmd)
+	}
+	if opt == "ex" || opt == "exat" {
+		if n > math.MaxInt64/1000 {
+			return 0, expireTimeError(cmd)
+		}
+		n *= 1000
+	}
+	if opt == "ex" || opt == "px" {
+		now := time.Now().UnixMilli()
+		if n > math.MaxInt64-now {
+			return 0, expireTimeError(cmd)
+		}
+		n += now
+	}
+	return n, nil
+}
+
+func (s *Server) handleIncr(c *Client, req [][]byte) []byte {
+	return s.incrDecr(c, string(req[1]), 1)
+}
+
+func (s *Server) handleDecr(c *Client, req [][]byte) []byte {
+	return s.incrDecr(c, string(req[1]), -1)
+}
+
+func (s *Server) handleIncrBy(c *Client, req [][]byte) []byte {
+	delta, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	return s.incrDecr(c, string(req[1]), delta)
+}
+
+func (s *Server) handleDecrBy(c *Client, req [][]byte) []byte {
+	delta, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	if delta == math.MinInt64 {
+		return parser.AppendError(nil, "ERR decrement would overflow")
+	}
+	return s.incrDecr(c, string(req[1]), -delta)
+}
+
+func (s *Server) incrDecr(c *Client, key string, delta int64) []byte {
+	value, err := s.stores[0].IncrBy(key, delta)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.AppendInt(nil, value)
+}
+
+func (s *Server) handleIncrByFloat(c *Client, req [][]byte) []byte {
+	delta, err := strconv.ParseFloat(string(req[2]), 64)
+	if err != nil || math.IsNaN(delta) {
+		return parser.AppendError(nil, "ERR value is not a valid float")
+	}
+	key := string(req[1])
+	value, err := s.stores[0].IncrByFloat(key, delta)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	// Replicas may round differently, so they receive the exact result.
+	c.rewriteCommand("SET", key, string(value), "KEEPTTL")
+	return parser.AppendBulk(nil, value)
+}
+
+func (s *Server) handleSetNX(c *Client, req [][]byte) []byte {
+	result, _ := s.stores[0].SetWithOptions(string(req[1]), req[2], store.SetOptions{NX: true})
+	if !result.Set {
+		return parser.AppendInt(nil, 0)
+	}
+	c.dirty++
+	return parser.AppendInt(nil, 1)
+}
+
+func (s *Server) handleMGet(c *Client, req [][]byte) []byte {
+	values := s.stores[0].MGet(argStrings(req[1:])...)
+	response := parser.AppendArray(nil, len(values))
+	for _, value := range values {
+		if value == nil {
+			response = c.appendNull(response)
+		} else {
+			response = parser.AppendBulk(response, value)
+		}
+	}
+	return response
+}
+
+func (s *Server) handleMSet(c *Client, req [][]byte) []byte {
+	if _, errReply := s.msetGeneric(c, req, false); errReply != nil {
+		return errReply
+	}
+	return parser.OK()
+}
+
+func (s *Server) handleMSetNX(c *Client, req [][]byte) []byte {
+	set, errReply := s.msetGeneric(c, req, true)
+	if errReply != nil {
+		return errReply
+	}
+	if !set {
+		return parser.AppendInt(nil, 0)
+	}
+	return parser.AppendInt(nil, 1)
+}
+
+// msetGeneric sets all the key-value pairs of MSET and MSETNX in one step so
+// that no client observes a partial update.
+func (s *Server) msetGeneric(c *Client, req [][]byte, nx bool) (bool, []byte) {
+	if len(req)%2 == 0 {
+		return false, parser.AppendError(nil, "ERR wrong number of arguments for '"+strings.ToLower(string(req[0]))+"' command")
+	}
+	n := (len(req) - 1) / 2
+	keys := make([]string, n)
+	values := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		keys[i] = string(req[1+2*i])
+		values[i] = req[2+2*i]
+	}
+	if !s.stores[0].MSet(keys, values, nx) {
+		return false, nil
+	}
+	c.dirty += n
+	return true, nil
+}
+
+func (s *Server) handleAppend(c *Client, req [][]byte) []byte {
+	length, err := s.stores[0].Append(string(req[1]), req[2])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.AppendInt(nil, int64(length))
+}
+
+func (s *Server) handleStrLen(c *Client, req [][]byte) []byte {
+	length, err := s.stores[0].StrLen(string(req[1]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendInt(nil, int64(length))
+}
+
+func (s *Server) handleGetRange(c *Client, req [][]byte) []byte {
+	start, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	end, err := strconv.ParseInt(string(req[3]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	value, err := s.stores[0].GetRange(string(req[1]), start, end)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendBulk(nil, value)
+}
+
+func (s *Server) handleSetRange(c *Client, req [][]byte) []byte {
+	offset, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	if offset < 0 {
+		return parser.AppendError(nil, "ERR offset is out of range")
+	}
+	_, maxBulkLen := s.clientLimits()
+	if len(req[3]) > 0 && offset > maxBulkLen-int64(len(req[3])) {
+		return parser.AppendError(nil, "ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	}
+	length, err := s.stores[0].SetRange(string(req[1]), int(offset), req[3])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if len(req[3]) > 0 {
+		c.dirty++
+	}
+	return parser.AppendInt(nil, int64(length))
+}
+
+func (s *Server) handleGetDel(c *Client, req [][]byte) []byte {
+	key := string(req[1])
+	value, ok, err := s.stores[0].GetDel(key)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !ok {
+		return c.appendNull(nil)
+	}
+	c.dirty++
+	c.rewriteCommand("DEL", key)
+	return parser.AppendBulk(nil, value)
+}
+
+func (s *Server) handleGetEx(c *Client, req [][]byte) []byte {
+	var expireAt int64
+	var expireOpt string
+	persist := false
+	for i := 2; i < len(req); i++ {
+		opt := strings.ToLower(string(req[i]))
+		switch opt {
+		case "persist":
+			if expireOpt != "" {
+				return parser.AppendError(nil, "ERR syntax error")
+			}
+			persist = true
+		case "ex", "px", "exat", "pxat":
+			if persist || expireOpt != "" || i+1 >= len(req) {
+				return parser.AppendError(nil, "ERR syntax error")
+			}
+			expireOpt = opt
+			i++
+			at, errReply := parseExpiryOption("getex", opt, req[i])
+			if errReply != nil {
+				return errReply
+			}
+			expireAt = at
+		default:
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+	}
+
+	key := string(req[1])
+	value, ok, err := s.stores[0].GetEx(key, expireAt, persist)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !ok {
+		return c.appendNull(nil)
+	}
+	// Like EXPIRE, the change reaches replicas as an absolute expiry.
+	switch {
+	case expireAt > 0 && expireAt <= time.Now().UnixMilli():
+		c.dirty++
+		c.rewriteCommand("DEL", key)
+	case expireAt > 0:
+		c.dirty++
+		c.rewriteCommand("PEXPIREAT", key, strconv.FormatInt(expireAt, 10))
+	case persist:
+		c.dirty++
+		c.rewriteCommand("PERSIST", key)
+	}
+	return parser.AppendBulk(nil, value)
+}
@@ -1,21 +1,26 @@
 package server
 
 import (
-	"log"
-	"net"
+	"slices"
 
 	"github.com/codecrafters-io/redis-starter-go/app/parser"
 )
 
-func (s *Server) handleMulti(conn net.Conn) []byte {
+// handleMulti starts a transaction. Subscribed clients cannot start one, as
+// the messages they receive would interleave with the replies of EXEC.
+func (s *Server) handleMulti(c *Client, req [][]byte) []byte {
+	if c.subscribed() {
+		return parser.AppendError(nil, "ERR MULTI is not allowed in the subscribed context")
+	}
+
 	s.txMutex.Lock()
 	defer s.txMutex.Unlock()
 
-	if tx, exists := s.transactions[conn]; exists && tx.inMulti {
+	if tx, exists := s.transactions[c]; exists && tx.inMulti {
 		return parser.AppendError(nil, "ERR MULTI calls can not be nested")
 	}
 
-	s.transactions[conn] = &Transaction{
+	s.transactions[c] = &Transaction{
 		commands: make([][][]byte, 0),
 		inMulti:  true,
 	}
@@ -23,27 +28,48 @@ func (s *Server) handleMulti(conn net.Conn) []byte {
 	return parser.OK()
 }
 
-func (s *Server) handleExec(conn net.Conn) []byte {
+// handleExec runs the queued commands of a transaction while holding the
+// keyspace for writing, so that no other client sees or changes it halfway.
+// The transaction is discarded when a command could not be queued, and
+// aborted when a watched key was modified.
+func (s *Server) handleExec(c *Client, req [][]byte) []byte {
 	s.txMutex.Lock()
-
-	tx, exists := s.transactions[conn]
+	tx, exists := s.transactions[c]
 	if !exists || !tx.inMulti {
 		s.txMutex.Unlock()
 		return parser.AppendError(nil, "ERR EXEC without MULTI")
 	}
-
-	tx.inMulti = false
+	delete(s.transactions, c)
 	s.txMutex.Unlock()
 
+	if tx.failed {
+		s.unwatchAllKeys(c)
+		return parser.AppendError(nil, "EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	unlock, errReply := s.lockKeyspace(c, true)
+	if errReply != nil {
+		s.unwatchAllKeys(c)
+		return errReply
+	}
+	defer unlock()
+	aborted := s.watchedKeysChanged(c)
+	s.unwatchAllKeys(c)
+	if aborted {
+		return c.appendNullArray(nil)
+	}
+
 	responses := make([][]byte, 0, len(tx.commands))
+	c.inExec = true
 	for _, cmd := range tx.commands {
-		log.Printf("Launching cmd %s", cmd)
-		response, _ := s.handleCommand(cmd, conn)
-		log.Printf("Response -> %s", response)
+		response, _ := s.handleCommand(cmd, c)
 		responses = append(responses, response)
 	}
+	c.inExec = false
 
-	delete(s.transactions, conn)
+	s.propagateTransaction(c.execPropagate)
+	s.serveReadyKeys(c.execReady)
+	c.execPropagate, c.execReady = nil, nil
 
 	result := parser.AppendArray(nil, len(responses))
 	for _, resp := range responses {
@@ -52,14 +78,140 @@ func (s *Server) handleExec(conn net.Conn) []byte {
 	return result
 }
 
-func (s *Server) handleDiscard(conn net.Conn) []byte {
+// propagateTransaction propagates the commands of a transaction, wrapped in
+// MULTI and EXEC when there are several of them.
+func (s *Server) propagateTransaction(commands [][][]byte) {
+	if s.info.role != MasterRole || len(commands) == 0 {
+		return
+	}
+	if len(commands) == 1 {
+		s.PropagateCommand(commands[0])
+		return
+	}
+	s.PropagateCommand(byteArgs("MULTI"))
+	for _, args := range commands {
+		s.PropagateCommand(args)
+	}
+	s.PropagateCommand(byteArgs("EXEC"))
+}
+
+func (s *Server) handleDiscard(c *Client, req [][]byte) []byte {
 	s.txMutex.Lock()
 	defer s.txMutex.Unlock()
 
-	if tx, exists := s.transactions[conn]; !exists || !tx.inMulti {
+	if tx, exists := s.transactions[c]; !exists || !tx.inMulti {
 		return parser.AppendError(nil, "ERR DISCARD without MULTI")
 	}
 
-	delete(s.transactions, conn)
+	delete(s.transactions, c)
+	s.unwatchAllKeys(c)
+	return parser.OK()
+}
+
+// watchedKey is a key watched by a client. existed records whether the key
+// existed when it was watched, so that its expiry aborts the transaction.
+type watchedKey struct {
+	key     string
+	existed bool
+}
+
+func (s *Server) handleWatch(c *Client, req [][]byte) []byte {
+	s.txMutex.RLock()
+	tx, exists := s.transactions[c]
+	s.txMutex.RUnlock()
+	if exists && tx.inMulti {
+		return parser.AppendError(nil, "ERR WATCH inside MULTI is not allowed")
+	}
+
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	for _, arg := range req[1:] {
+		key := string(arg)
+		if slices.ContainsFunc(c.watched, func(wk watchedKey) bool { return wk.key == key }) {
+			continue
+		}
+		c.watched = append(c.watched, watchedKey{key: key, existed: s.stores[0].Exists(key) > 0})
+		s.watchedKeys[key] = append(s.watchedKeys[key], c)
+		s.numWatched.Add(1)
+	}
 	return parser.OK()
 }
+
+func (s *Server) handleUnwatch(c *Client, req [][]byte) []byte {
+	s.unwatchAllKeys(c)
+	return parser.OK()
+}
+
+// touchWatchedKeys flags the clients watching the keys of a write command.
+func (s *Server) touchWatchedKeys(cmd *Command, req [][]byte) {
+	if s.numWatched.Load() == 0 {
+		return
+	}
+	s.touchKeys(cmd.keys(req)...)
+}
+
+// touchKeys flags the clients watching keys, so that their next transaction
+// is aborted.
+func (s *Server) touchKeys(keys ...string) {
+	if s.numWatched.Load() == 0 {
+		return
+	}
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	for _, key := range keys {
+		for _, c := range s.watchedKeys[key] {
+			c.watchDirty = true
+		}
+	}
+}
+
+// touchAllWatchedKeys flags the clients watching a key of store that exists,
+// before the store is flushed.
+func (s *Server) touchAllWatchedKeys(store Store) {
+	if s.numWatched.Load() == 0 {
+		return
+	}
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	for key, clients := range s.watchedKeys {
+		if store.Exists(key) == 0 {
+			continue
+		}
+		for _, c := range clients {
+			c.watchDirty = true
+		}
+	}
+}
+
+// watchedKeysChanged reports whether a key watched by c was modified, or
+// expired, since it was watched.
+func (s *Server) watchedKeysChanged(c *Client) bool {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	if c.watchDirty {
+		return true
+	}
+	for _, wk := range c.watched {
+		if wk.existed && s.stores[0].Exists(wk.key) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// unwatchAllKeys forgets the keys watched by c.
+func (s *Server) unwatchAllKeys(c *Client) {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	for _, wk := range c.watched {
+		clients := slices.DeleteFunc(s.watchedKeys[wk.key], func(other *Client) bool { return other == c })
+		if len(clients) == 0 {
+			delete(s.watchedKeys, wk.key)
+		} else {
+			s.watchedKeys[wk.key] = clients
+		}
+	}
+	s.numWatched.Add(-int64(len(c.watched)))
+	c.watched = nil
+	c.watchDirty = false
+}
@@ -0,0 +1,542 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+// parseScore parses a score, which may be inf or -inf but not NaN.
+func parseScore(arg []byte) (float64, bool) {
+	score, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false
+	}
+	return score, true
+}
+
+// parseScoreRange parses the min and max of a range of scores, each of which
+// is excluded when prefixed with an open parenthesis.
+func parseScoreRange(minArg, maxArg []byte) (store.ScoreRange, bool) {
+	var r store.ScoreRange
+	var okMin, okMax bool
+	r.Min, r.MinExclusive, okMin = parseScoreBound(minArg)
+	r.Max, r.MaxExclusive, okMax = parseScoreBound(maxArg)
+	return r, okMin && okMax
+}
+
+func parseScoreBound(arg []byte) (float64, bool, bool) {
+	exclusive := len(arg) > 0 && arg[0] == '('
+	if exclusive {
+		arg = arg[1:]
+	}
+	score, ok := parseScore(arg)
+	return score, exclusive, ok
+}
+
+// parseLexRange parses the min and max of a range of members: - and + for
+// the ends of the sorted set, or a member prefixed with [ to include it or (
+// to exclude it.
+func parseLexRange(minArg, maxArg []byte) (store.LexRange, bool) {
+	var r store.LexRange
+	var okMin, okMax bool
+	r.Min, okMin = parseLexBound(minArg)
+	r.Max, okMax = parseLexBound(maxArg)
+	return r, okMin && okMax
+}
+
+func parseLexBound(arg []byte) (store.LexBound, bool) {
+	if len(arg) == 0 {
+		return store.LexBound{}, false
+	}
+	switch arg[0] {
+	case '-', '+':
+		if len(arg) != 1 {
+			return store.LexBound{}, false
+		}
+		if arg[0] == '-' {
+			return store.LexBound{Inf: -1}, true
+		}
+		return store.LexBound{Inf: 1}, true
+	case '[':
+		return store.LexBound{Value: string(arg[1:])}, true
+	case '(':
+		return store.LexBound{Value: string(arg[1:]), Exclusive: true}, true
+	}
+	return store.LexBound{}, false
+}
+
+// appendScoreMembers appends members, with their scores if withScores is
+// set. RESP3 clients receive each member and its score as a pair, RESP2
+// clients a flat array.
+func (c *Client) appendScoreMembers(b []byte, members []store.ScoreMember, withScores bool) []byte {
+	if withScores && !c.resp3() {
+		b = parser.AppendArray(b, 2*len(members))
+	} else {
+		b = parser.AppendArray(b, len(members))
+	}
+	for _, m := range members {
+		if withScores && c.resp3() {
+			b = parser.AppendArray(b, 2)
+		}
+		b = parser.AppendBulkString(b, m.Member)
+		if withScores {
+			b = c.appendDouble(b, m.Score)
+		}
+	}
+	return b
+}
+
+func (s *Server) handleZAdd(c *Client, req [][]byte) []byte {
+	var opts store.ZAddOptions
+	ch := false
+	i := 2
+options:
+	for ; i < len(req); i++ {
+		switch strings.ToLower(string(req[i])) {
+		case "nx":
+			opts.NX = true
+		case "xx":
+			opts.XX = true
+		case "gt":
+			opts.GT = true
+		case "lt":
+			opts.LT = true
+		case "ch":
+			ch = true
+		case "incr":
+			opts.Incr = true
+		default:
+			break options
+		}
+	}
+	elements := len(req) - i
+	if elements == 0 || elements%2 != 0 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	if opts.NX && opts.XX {
+		return parser.AppendError(nil, "ERR XX and NX options at the same time are not compatible")
+	}
+	if opts.NX && (opts.GT || opts.LT) || opts.GT && opts.LT {
+		return parser.AppendError(nil, "ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if opts.Incr && elements > 2 {
+		return parser.AppendError(nil, "ERR INCR option supports a single increment-element pair")
+	}
+	members := make([]store.ScoreMember, 0, elements/2)
+	for ; i < len(req); i += 2 {
+		score, ok := parseScore(req[i])
+		if !ok {
+			return parser.AppendError(nil, "ERR value is not a valid float")
+		}
+		members = append(members, store.ScoreMember{Member: string(req[i+1]), Score: score})
+	}
+
+	result, err := s.stores[0].ZAdd(string(req[1]), members, opts)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty += result.Added + result.Updated
+	if opts.Incr {
+		if !result.Applied {
+			return c.appendNull(nil)
+		}
+		return c.appendDouble(nil, result.Score)
+	}
+	if ch {
+		return parser.AppendInt(nil, int64(result.Added+result.Updated))
+	}
+	return parser.AppendInt(nil, int64(result.Added))
+}
+
+func (s *Server) handleZIncrBy(c *Client, req [][]byte) []byte {
+	delta, ok := parseScore(req[2])
+	if !ok {
+		return parser.AppendError(nil, "ERR value is not a valid float")
+	}
+	members := []store.ScoreMember{{Member: string(req[3]), Score: delta}}
+	result, err := s.stores[0].ZAdd(string(req[1]), members, store.ZAddOptions{Incr: true})
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return c.appendDouble(nil, result.Score)
+}
+
+func (s *Server) handleZRem(c *Client, req [][]byte) []byte {
+	removed, err := s.stores[0].ZRem(string(req[1]), req[2:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty += removed
+	return parser.AppendInt(nil, int64(removed))
+}
+
+func (s *Server) handleZScore(c *Client, req [][]byte) []byte {
+	scores, found, err := s.stores[0].ZScores(string(req[1]), req[2:3])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	if !found[0] {
+		return c.appendNull(nil)
+	}
+	return c.appendDouble(nil, scores[0])
+}
+
+func (s *Server) handleZMScore(c *Client, req [][]byte) []byte {
+	scores, found, err := s.stores[0].ZScores(string(req[1]), req[2:])
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	response := parser.AppendArray(nil, len(scores))
+	for i, score := range scores {
+		if found[i] {
+			response = c.appendDouble(response, score)
+		} else {
+			response = c.appendNull(response)
+		}
+	}
+	return response
+}
+
+func (s *Server) handleZCard(c *Client, req [][]byte) []byte {
+	card, err := s.stores[0].ZCard(string(req[1]))
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendInt(nil, int64(card))
+}
+
+func (s *Server) handleZCount(c *Client, req [][]byte) []byte {
+	r, ok := parseScoreRange(req[2], req[3])
+	if !ok {
+		return parser.AppendError(nil, "ERR min or max is not a float")
+	}
+	count, err := s.stores[0].ZCount(string(req[1]), r)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendInt(nil, int64(count))
+}
+
+func (s *Server) handleZLexCount(c *Client, req [][]byte) []byte {
+	r, ok := parseLexRange(req[2], req[3])
+	if !ok {
+		return parser.AppendError(nil, "ERR min or max not valid string range item")
+	}
+	count, err := s.stores[0].ZLexCount(string(req[1]), r)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return parser.AppendInt(nil, int64(count))
+}
+
+func (s *Server) handleZRank(c *Client, req [][]byte) []byte {
+	return s.zrankGeneric(c, req, false)
+}
+
+func (s *Server) handleZRevRank(c *Client, req [][]byte) []byte {
+	return s.zrankGeneric(c, req, true)
+}
+
+func (s *Server) zrankGeneric(c *Client, req [][]byte, rev bool) []byte {
+	withScore := len(req) == 4 && strings.EqualFold(string(req[3]), "withscore")
+	if len(req) > 3 && !withScore {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	rank, score, ok, err := s.stores[0].ZRank(string(req[1]), req[2], rev)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	switch {
+	case !ok && withScore:
+		return c.appendNullArray(nil)
+	case !ok:
+		return c.appendNull(nil)
+	case withScore:
+		response := parser.AppendArray(nil, 2)
+		response = parser.AppendInt(response, int64(rank))
+		return c.appendDouble(response, score)
+	}
+	return parser.AppendInt(nil, int64(rank))
+}
+
+// zrangeMode describes a command of the ZRANGE family.
+type zrangeMode struct {
+	by  store.ZRangeBy
+	rev bool
+	// auto lets BYSCORE, BYLEX and REV select the range, as in ZRANGE.
+	auto bool
+	// store rejects WITHSCORES, as in ZRANGESTORE.
+	store bool
+}
+
+// parseZRangeArgs parses the min max [BYSCORE|BYLEX] [REV] [LIMIT offset
+// count] [WITHSCORES] arguments of the ZRANGE family that start at req[i].
+func parseZRangeArgs(req [][]byte, i int, mode zrangeMode) (store.ZRangeQuery, bool, []byte) {
+	q := store.ZRangeQuery{By: mode.by, Rev: mode.rev, Count: -1}
+	withScores, limit := false, false
+	for j := i + 2; j < len(req); j++ {
+		switch arg := strings.ToLower(string(req[j])); {
+		case arg == "withscores" && !mode.store:
+			withScores = true
+		case arg == "limit" && j+2 < len(req):
+			offset, err := strconv.ParseInt(string(req[j+1]), 10, 64)
+			if err != nil {
+				return q, false, parser.AppendError(nil, "ERR value is not an integer or out of range")
+			}
+			count, err := strconv.ParseInt(string(req[j+2]), 10, 64)
+			if err != nil {
+				return q, false, parser.AppendError(nil, "ERR value is not an integer or out of range")
+			}
+			q.Offset, q.Count = int(min(offset, math.MaxInt32)), int(min(count, math.MaxInt32))
+			limit = true
+			j += 2
+		case arg == "byscore" && mode.auto:
+			q.By = store.ZRangeByScore
+		case arg == "bylex" && mode.auto:
+			q.By = store.ZRangeByLex
+		case arg == "rev" && mode.auto:
+			q.Rev = true
+		default:
+			return q, false, parser.AppendError(nil, "ERR syntax error")
+		}
+	}
+	if limit && q.By == store.ZRangeByRank {
+		return q, false, parser.AppendError(nil, "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if withScores && q.By == store.ZRangeByLex {
+		return q, false, parser.AppendError(nil, "ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	minArg, maxArg := req[i], req[i+1]
+	if q.Rev && q.By != store.ZRangeByRank {
+		// Reversed ranges by score or member take the max first.
+		minArg, maxArg = maxArg, minArg
+	}
+	var ok bool
+	switch q.By {
+	case store.ZRangeByRank:
+		start, err1 := strconv.ParseInt(string(minArg), 10, 64)
+		stop, err2 := strconv.ParseInt(string(maxArg), 10, 64)
+		if err1 != nil || err2 != nil {
+			return q, false, parser.AppendError(nil, "ERR value is not an integer or out of range")
+		}
+		q.Start, q.Stop = start, stop
+	case store.ZRangeByScore:
+		if q.Score, ok = parseScoreRange(minArg, maxArg); !ok {
+			return q, false, parser.AppendError(nil, "ERR min or max is not a float")
+		}
+	case store.ZRangeByLex:
+		if q.Lex, ok = parseLexRange(minArg, maxArg); !ok {
+			return q, false, parser.AppendError(nil, "ERR min or max not valid string range item")
+		}
+	}
+	return q, withScores, nil
+}
+
+func (s *Server) handleZRange(c *Client, req [][]byte) []byte {
+	return s.zrangeGeneric(c, req, zrangeMode{auto: true})
+}
+
+func (s *Server) handleZRevRange(c *Client, req [][]byte) []byte {
+	return s.zrangeGeneric(c, req, zrangeMode{rev: true})
+}
+
+func (s *Server) handleZRangeByScore(c *Client, req [][]byte) []byte {
+	return s.zrangeGeneric(c, req, zrangeMode{by: store.ZRangeByScore})
+}
+
+func (s *Server) handleZRevRangeByScore(c *Client, req [][]byte) []byte {
+	return s.zrangeGeneric(c, req, zrangeMode{by: store.ZRangeByScore, rev: true})
+}
+
+func (s *Server) handleZRangeByLex(c *Client, req [][]byte) []byte {
+	return s.zrangeGeneric(c, req, zrangeMode{by: store.ZRangeByLex})
+}
+
+func (s *Server) handleZRevRangeByLex(c *Client, req [][]byte) []byte {
+	return s.zrangeGeneric(c, req, zrangeMode{by: store.ZRangeByLex, rev: true})
+}
+
+func (s *Server) zrangeGeneric(c *Client, req [][]byte, mode zrangeMode) []byte {
+	q, withScores, errReply := parseZRangeArgs(req, 2, mode)
+	if errReply != nil {
+		return errReply
+	}
+	members, err := s.stores[0].ZRange(string(req[1]), q)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	return c.appendScoreMembers(nil, members, withScores)
+}
+
+func (s *Server) handleZRangeStore(c *Client, req [][]byte) []byte {
+	q, _, errReply := parseZRangeArgs(req, 3, zrangeMode{auto: true, store: true})
+	if errReply != nil {
+		return errReply
+	}
+	card, err := s.stores[0].ZRangeStore(string(req[1]), string(req[2]), q)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.AppendInt(nil, int64(card))
+}
+
+func (s *Server) handleZPopMin(c *Client, req [][]byte) []byte {
+	return s.zpopGeneric(c, req, false)
+}
+
+func (s *Server) handleZPopMax(c *Client, req [][]byte) []byte {
+	return s.zpopGeneric(c, req, true)
+}
+
+func (s *Server) zpopGeneric(c *Client, req [][]byte, max bool) []byte {
+	if len(req) > 3 {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	count := 1
+	if len(req) == 3 {
+		n, err := strconv.ParseInt(string(req[2]), 10, 64)
+		if err != nil || n < 0 {
+			return parser.AppendError(nil, "ERR value is out of range, must be positive")
+		}
+		count = int(min(n, math.MaxInt32))
+	}
+	popped, err := s.stores[0].ZPop(string(req[1]), count, max)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty += len(popped)
+	if len(req) == 3 {
+		return c.appendScoreMembers(nil, popped, true)
+	}
+	// Without a count the member and its score are returned flat.
+	response := parser.AppendArray(nil, 2*len(popped))
+	for _, m := range popped {
+		response = parser.AppendBulkString(response, m.Member)
+		response = c.appendDouble(response, m.Score)
+	}
+	return response
+}
+
+func (s *Server) handleBZPopMin(c *Client, req [][]byte) []byte {
+	return s.blockingZPopGeneric(c, req, false)
+}
+
+func (s *Server) handleBZPopMax(c *Client, req [][]byte) []byte {
+	return s.blockingZPopGeneric(c, req, true)
+}
+
+func (s *Server) blockingZPopGeneric(c *Client, req [][]byte, max bool) []byte {
+	timeout, errReply := parseTimeout(req[len(req)-1])
+	if errReply != nil {
+		return errReply
+	}
+	pop := "ZPOPMIN"
+	if max {
+		pop = "ZPOPMAX"
+	}
+	return s.blockForKeys(c, argStrings(req[1:len(req)-1]), timeout, "", false, func(key string) ([]byte, [][][]byte) {
+		popped, err := s.stores[0].ZPop(key, 1, max)
+		if err != nil {
+			return parser.AppendError(nil, err.Error()), nil
+		}
+		if popped == nil {
+			return nil, nil
+		}
+		response := parser.AppendArray(nil, 3)
+		response = parser.AppendBulkString(response, key)
+		response = parser.AppendBulkString(response, popped[0].Member)
+		return c.appendDouble(response, popped[0].Score), [][][]byte{{[]byte(pop), []byte(key)}}
+	})
+}
+
+func (s *Server) handleZUnionStore(c *Client, req [][]byte) []byte {
+	return s.zsetAlgebraStoreGeneric(c, req, store.SetOpUnion)
+}
+
+func (s *Server) handleZInterStore(c *Client, req [][]byte) []byte {
+	return s.zsetAlgebraStoreGeneric(c, req, store.SetOpInter)
+}
+
+func (s *Server) handleZDiffStore(c *Client, req [][]byte) []byte {
+	return s.zsetAlgebraStoreGeneric(c, req, store.SetOpDiff)
+}
+
+// zsetAlgebraStoreGeneric implements the destination numkeys key [key ...]
+// [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] commands, where
+// ZDIFFSTORE takes neither WEIGHTS nor AGGREGATE.
+func (s *Server) zsetAlgebraStoreGeneric(c *Client, req [][]byte, op store.SetOperation) []byte {
+	numKeys, err := strconv.ParseInt(string(req[2]), 10, 64)
+	if err != nil {
+		return parser.AppendError(nil, "ERR value is not an integer or out of range")
+	}
+	if numKeys < 1 {
+		return parser.AppendError(nil, fmt.Sprintf("ERR at least 1 input key is needed for '%s' command", strings.ToLower(string(req[0]))))
+	}
+	if numKeys > int64(len(req)-3) {
+		return parser.AppendError(nil, "ERR syntax error")
+	}
+	n := int(numKeys)
+	var weights []float64
+	aggregate := store.ZAggregateSum
+	for i := 3 + n; i < len(req); i++ {
+		switch arg := strings.ToLower(string(req[i])); {
+		case arg == "weights" && op != store.SetOpDiff && i+n < len(req):
+			weights = make([]float64, n)
+			for k := range weights {
+				weight, ok := parseScore(req[i+1+k])
+				if !ok {
+					return parser.AppendError(nil, "ERR weight value is not a float")
+				}
+				weights[k] = weight
+			}
+			i += n
+		case arg == "aggregate" && op != store.SetOpDiff && i+1 < len(req):
+			switch strings.ToLower(string(req[i+1])) {
+			case "sum":
+				aggregate = store.ZAggregateSum
+			case "min":
+				aggregate = store.ZAggregateMin
+			case "max":
+				aggregate = store.ZAggregateMax
+			default:
+				return parser.AppendError(nil, "ERR syntax error")
+			}
+			i++
+		default:
+			return parser.AppendError(nil, "ERR syntax error")
+		}
+	}
+
+	card, err := s.stores[0].ZAlgebraStore(op, string(req[1]), argStrings(req[3:3+n]), weights, aggregate)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	c.dirty++
+	return parser.AppendInt(nil, int64(card))
+}
+
+func (s *Server) handleZScan(c *Client, req [][]byte) []byte {
+	opts, errReply := parseScanArgs(req, false)
+	if errReply != nil {
+		return errReply
+	}
+	next, members, err := s.stores[0].ZScan(string(req[1]), opts.cursor, opts.count)
+	if err != nil {
+		return parser.AppendError(nil, err.Error())
+	}
+	var elements [][]byte
+	for _, m := range members {
+		if opts.match([]byte(m.Member)) {
+			elements = append(elements, []byte(m.Member), []byte(parser.FormatDouble(m.Score)))
+		}
+	}
+	return appendScanReply(nil, next, elements)
+}
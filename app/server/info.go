@@ -2,20 +2,50 @@ package server
 
 import (
 	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync/atomic"
-
-	"github.com/codecrafters-io/redis-starter-go/app/parser"
+	"time"
 )
 
 type Info struct {
 	role             string
 	masterReplID     string
 	masterReplOffset *atomic.Int64
+	startTime        time.Time
+}
+
+func (s *Server) getInfoServer() []byte {
+	s.configMutex.RLock()
+	port := s.config.Port
+	s.configMutex.RUnlock()
+	uptime := time.Since(s.info.startTime)
+	return []byte(fmt.Sprintf(`# Server
+redis_version:%s
+redis_mode:standalone
+os:%s %s
+arch_bits:%d
+go_version:%s
+process_id:%d
+tcp_port:%d
+uptime_in_seconds:%d
+uptime_in_days:%d`,
+		serverVersion,
+		runtime.GOOS, runtime.GOARCH,
+		strconv.IntSize,
+		runtime.Version(),
+		os.Getpid(),
+		port,
+		int64(uptime.Seconds()),
+		int64(uptime.Hours()/24),
+	))
 }
 
 func (s *Server) getInfoReplication() []byte {
-	var response []byte
-	response = parser.AppendBulkString(nil, fmt.Sprintf(`# Replication
+	return []byte(fmt.Sprintf(`# Replication
 role:%s
 master_replid:%s
 master_repl_offset:%d`,
@@ -23,5 +53,52 @@ master_repl_offset:%d`,
 		s.info.masterReplID,
 		s.info.masterReplOffset.Load(),
 	))
-	return response
+}
+
+func (s *Server) getInfoStats() []byte {
+	return []byte(fmt.Sprintf(`# Stats
+expired_subkeys:%d
+expired_keys:%d
+expired_stale_perc:%.2f
+expire_cycle_cpu_milliseconds:%d`,
+		s.stats.expiredSubkeys.Load(),
+		s.stats.expiredKeys.Load(),
+		math.Float64frombits(s.stats.expiredStalePerc.Load())*100,
+		s.stats.expireCycleCPUMilliseconds.Load(),
+	))
+}
+
+// infoSections lists the INFO sections in the order they are reported.
+var infoSections = []struct {
+	name string
+	get  func(s *Server) []byte
+}{
+	{"server", (*Server).getInfoServer},
+	{"stats", (*Server).getInfoStats},
+	{"replication", (*Server).getInfoReplication},
+}
+
+// getInfo returns the requested INFO sections, or all of them when none or
+// "all", "default" or "everything" is requested.
+func (s *Server) getInfo(sections ...string) []byte {
+	all := len(sections) == 0
+	requested := make(map[string]bool, len(sections))
+	for _, section := range sections {
+		section = strings.ToLower(section)
+		if section == "all" || section == "default" || section == "everything" {
+			all = true
+		}
+		requested[section] = true
+	}
+	var info []byte
+	for _, section := range infoSections {
+		if !all && !requested[section.name] {
+			continue
+		}
+		if len(info) > 0 {
+			info = append(info, "\n\n"...)
+		}
+		info = append(info, section.get(s)...)
+	}
+	return info
 }
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"sort"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+// pubsubKind is one of the kinds of subscriptions: channels, patterns and
+// shard channels.
+type pubsubKind struct {
+	subscribe   string
+	unsubscribe string
+	message     string
+	// server and client return the subscriptions of the kind held by the
+	// server and by a client.
+	server func(s *Server) map[string]map[*Client]struct{}
+	client func(c *Client) *map[string]struct{}
+}
+
+var (
+	channelKind = &pubsubKind{
+		subscribe: "subscribe", unsubscribe: "unsubscribe", message: "message",
+		server: func(s *Server) map[string]map[*Client]struct{} { return s.channels },
+		client: func(c *Client) *map[string]struct{} { return &c.channels },
+	}
+	patternKind = &pubsubKind{
+		subscribe: "psubscribe", unsubscribe: "punsubscribe", message: "pmessage",
+		server: func(s *Server) map[string]map[*Client]struct{} { return s.patterns },
+		client: func(c *Client) *map[string]struct{} { return &c.patterns },
+	}
+	shardChannelKind = &pubsubKind{
+		subscribe: "ssubscribe", unsubscribe: "sunsubscribe", message: "smessage",
+		server: func(s *Server) map[string]map[*Client]struct{} { return s.shardChannels },
+		client: func(c *Client) *map[string]struct{} { return &c.shardChannels },
+	}
+)
+
+// subscriptionCount returns the number of channels and patterns the client
+// is subscribed to, which SUBSCRIBE and PSUBSCRIBE replies report.
+func (c *Client) subscriptionCount() int {
+	return len(c.channels) + len(c.patterns)
+}
+
+// subscribed reports whether the client has any subscription, which puts
+// RESP2 clients in the subscribed context.
+func (c *Client) subscribed() bool {
+	return c.subscriptionCount()+len(c.shardChannels) > 0
+}
+
+// countFor returns the count reported in the replies to the subscriptions of
+// kind: shard channels are counted apart.
+func (c *Client) countFor(kind *pubsubKind) int {
+	if kind == shardChannelKind {
+		return len(c.shardChannels)
+	}
+	return c.subscriptionCount()
+}
+
+// setProtocol switches the protocol of c, which publishers read to encode
+// the messages they deliver to it.
+func (s *Server) setProtocol(c *Client, protocol int) {
+	if c.output == nil {
+		c.protocol = protocol
+		return
+	}
+	s.pubsubMutex.Lock()
+	c.protocol = protocol
+	s.pubsubMutex.Unlock()
+}
+
+// appendSubscriptionReply appends the confirmation of a subscription change.
+func (c *Client) appendSubscriptionReply(b []byte, action string, name []byte, count int) []byte {
+	b = c.appendPush(b, 3)
+	b = parser.AppendBulkString(b, action)
+	if name == nil {
+		b = c.appendNull(b)
+	} else {
+		b = parser.AppendBulk(b, name)
+	}
+	return parser.AppendInt(b, int64(count))
+}
+
+// subscribe subscribes c to names. The confirmations are queued along with
+// the replies pending for c while the server's subscriptions are locked, so
+// that no message published to names can be delivered before them.
+func (s *Server) subscribe(c *Client, kind *pubsubKind, names [][]byte) []byte {
+	if c.output == nil {
+		c.output = newClientOutput(c.conn)
+	}
+	s.pubsubMutex.Lock()
+	defer s.pubsubMutex.Unlock()
+	subscriptions := kind.client(c)
+	if *subscriptions == nil {
+		*subscriptions = make(map[string]struct{})
+	}
+	for _, name := range names {
+		if _, ok := (*subscriptions)[string(name)]; !ok {
+			(*subscriptions)[string(name)] = struct{}{}
+			clients := kind.server(s)[string(name)]
+			if clients == nil {
+				clients = make(map[*Client]struct{})
+				kind.server(s)[string(name)] = clients
+			}
+			clients[c] = struct{}{}
+		}
+		c.reply = c.appendSubscriptionReply(c.reply, kind.subscribe, name, c.countFor(kind))
+	}
+	c.flushReply()
+	return nil
+}
+
+// unsubscribe unsubscribes c from names, or from all the subscriptions of
+// kind when names is empty.
+func (s *Server) unsubscribe(c *Client, kind *pubsubKind, names [][]byte) []byte {
+	subscriptions := kind.client(c)
+	if len(names) == 0 {
+		for name := range *subscriptions {
+			names = append(names, []byte(name))
+		}
+		sort.Slice(names, func(i, j int) bool { return string(names[i]) < string(names[j]) })
+	}
+	if len(names) == 0 {
+		return c.appendSubscriptionReply(nil, kind.unsubscribe, nil, c.countFor(kind))
+	}
+	s.pubsubMutex.Lock()
+	defer s.pubsubMutex.Unlock()
+	var response []byte
+	for _, name := range names {
+		if _, ok := (*subscriptions)[string(name)]; ok {
+			delete(*subscriptions, string(name))
+			clients := kind.server(s)[string(name)]
+			delete(clients, c)
+			if len(clients) == 0 {
+				delete(kind.server(s), string(name))
+			}
+		}
+		response = c.appendSubscriptionReply(response, kind.unsubscribe, name, c.countFor(kind))
+	}
+	return response
+}
+
+// unsubscribeAll removes all the subscriptions of c without replying.
+func (s *Server) unsubscribeAll(c *Client) {
+	if !c.subscribed() {
+		return
+	}
+	s.pubsubMutex.Lock()
+	defer s.pubsubMutex.Unlock()
+	for _, kind := range []*pubsubKind{channelKind, patternKind, shardChannelKind} {
+		subscriptions := kind.client(c)
+		for name := range *subscriptions {
+			clients := kind.server(s)[name]
+			delete(clients, c)
+			if len(clients) == 0 {
+				delete(kind.server(s), name)
+			}
+		}
+		*subscriptions = nil
+	}
+}
+
+// publish delivers message to the clients subscribed to channel, and to
+// those subscribed to a matching pattern unless shard is set. It returns the
+// number of clients that received it.
+func (s *Server) publish(channel, message []byte, shard bool) int {
+	s.configMutex.RLock()
+	limit := s.config.PubSubOutputBufferLimit
+	s.configMutex.RUnlock()
+
+	s.pubsubMutex.RLock()
+	defer s.pubsubMutex.RUnlock()
+	receivers := 0
+	kind := channelKind
+	if shard {
+		kind = shardChannelKind
+	}
+	for c := range kind.server(s)[string(channel)] {
+		b := c.appendPush(nil, 3)
+		b = parser.AppendBulkString(b, kind.message)
+		b = parser.AppendBulk(b, channel)
+		b = parser.AppendBulk(b, message)
+		if c.output.enqueue(b, &limit) {
+			receivers++
+		}
+	}
+	if shard {
+		return receivers
+	}
+	for pattern, clients := range s.patterns {
+		if !stringMatch(pattern, string(channel), false) {
+			continue
+		}
+		for c := range clients {
+			b := c.appendPush(nil, 4)
+			b = parser.AppendBulkString(b, patternKind.message)
+			b = parser.AppendBulkString(b, pattern)
+			b = parser.AppendBulk(b, channel)
+			b = parser.AppendBulk(b, message)
+			if c.output.enqueue(b, &limit) {
+				receivers++
+			}
+		}
+	}
+	return receivers
+}
@@ -203,13 +203,17 @@ func readFullRDB(conn net.Conn, leftover []byte, rdbPath string) error {
 
 func (s *Server) handleMaster(conn net.Conn) {
 	defer conn.Close()
+	defer s.masterLinkDown.Store(true)
 	buf := make([]byte, 0, 1024)
 	tmp := make([]byte, 1024)
 
-	for !s.ready {
+	for !s.ready.Load() {
 		time.Sleep(10 * time.Millisecond)
 	}
 	log.Println("Listening to master")
+	client := s.newClient(conn)
+	defer s.removeClient(client)
+	client.master = true
 outerLoop:
 	for {
 		n, err := conn.Read(tmp)
@@ -240,11 +244,9 @@ outerLoop:
 				continue outerLoop
 			}
 			log.Printf("Request received: %s", req)
-			switch strings.ToLower(string(req[0])) {
-			case "set":
-				s.handleSet(req)
-			case "replconf":
-				response := s.handleREPLConf(req)
+			response, _ := s.handleCommand(req, client)
+			// Only REPLCONF GETACK is answered on the replication link.
+			if strings.EqualFold(string(req[0]), "replconf") {
 				log.Printf("Sending REPLCONF response: %q", response)
 				_, err := conn.Write(response)
 				if err != nil {
@@ -255,3 +257,19 @@ outerLoop:
 		}
 	}
 }
+
+// staleReplicaError returns the MASTERDOWN error when the server is a replica
+// that lost the link with its master and is not allowed to serve stale data,
+// and nil otherwise.
+func (s *Server) staleReplicaError() []byte {
+	if s.info.role != SlaveRole || !s.masterLinkDown.Load() {
+		return nil
+	}
+	s.configMutex.RLock()
+	serveStale := s.config.ReplicaServeStaleData
+	s.configMutex.RUnlock()
+	if serveStale {
+		return nil
+	}
+	return parser.AppendError(nil, "MASTERDOWN Link with MASTER is down and replica-serve-stale-data is set to 'no'.")
+}
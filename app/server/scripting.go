@@ -0,0 +1,494 @@
+package server
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// luaChunkName is the name scripts are compiled under, which shows in the
+// errors they raise.
+const luaChunkName = "user_script"
+
+// luaScript is a compiled script of the script cache.
+type luaScript struct {
+	body  string
+	proto *lua.FunctionProto
+}
+
+// scriptRun describes the script or function being run, for SCRIPT KILL,
+// FUNCTION KILL and the BUSY replies sent once it runs for longer than
+// lua-time-limit.
+type scriptRun struct {
+	// client runs the commands the script calls.
+	client *Client
+	// name is the SHA1 of the script or the name of the function, and
+	// command the command that runs it.
+	name     string
+	command  [][]byte
+	function bool
+	start    time.Time
+	readonly bool
+	// wrote is set once the script called a write command, after which it
+	// cannot be killed.
+	wrote  atomic.Bool
+	killed atomic.Bool
+	cancel context.CancelFunc
+}
+
+func sha1hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadScript compiles body and adds it to the script cache, unless it is
+// already there. It returns the SHA1 of the script.
+func (s *Server) loadScript(body string) (string, *luaScript, error) {
+	sha := sha1hex(body)
+	s.scriptMutex.Lock()
+	defer s.scriptMutex.Unlock()
+	if script, ok := s.scripts[sha]; ok {
+		return sha, script, nil
+	}
+	chunk, err := parse.Parse(strings.NewReader(body), luaChunkName)
+	if err != nil {
+		return "", nil, fmt.Errorf("ERR Error compiling script (new function): %s", strings.TrimSpace(err.Error()))
+	}
+	proto, err := lua.Compile(chunk, luaChunkName)
+	if err != nil {
+		return "", nil, fmt.Errorf("ERR Error compiling script (new function): %s", strings.TrimSpace(err.Error()))
+	}
+	script := &luaScript{body: body, proto: proto}
+	s.scripts[sha] = script
+	return sha, script, nil
+}
+
+func (s *Server) cachedScript(sha string) *luaScript {
+	s.scriptMutex.Lock()
+	defer s.scriptMutex.Unlock()
+	return s.scripts[strings.ToLower(sha)]
+}
+
+// busyScriptError returns the BUSY error when a script or a function has
+// been running for longer than lua-time-limit, and nil otherwise.
+func (s *Server) busyScriptError() []byte {
+	run := s.runningScript.Load()
+	if run == nil {
+		return nil
+	}
+	s.configMutex.RLock()
+	limit := time.Duration(s.config.LuaTimeLimit) * time.Millisecond
+	s.configMutex.RUnlock()
+	if time.Since(run.start) <= limit {
+		return nil
+	}
+	if run.function {
+		return parser.AppendError(nil, "BUSY Redis is busy running a script. You can only call FUNCTION KILL or SHUTDOWN NOSAVE.")
+	}
+	return parser.AppendError(nil, "BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.")
+}
+
+// busyCheckInterval is how often a command waiting for the keyspace checks
+// whether the script holding it has become busy.
+const busyCheckInterval = 10 * time.Millisecond
+
+// lockKeyspace acquires the keyspace for c, for writing when exclusive is
+// set, and returns the function that releases it. A command waiting for a
+// script to end gives up with the BUSY error once the script runs for longer
+// than lua-time-limit, except for the master's, which must not be dropped.
+func (s *Server) lockKeyspace(c *Client, exclusive bool) (func(), []byte) {
+	lock, unlock, tryLock := s.keyspaceMutex.RLock, s.keyspaceMutex.RUnlock, s.keyspaceMutex.TryRLock
+	if exclusive {
+		lock, unlock, tryLock = s.keyspaceMutex.Lock, s.keyspaceMutex.Unlock, s.keyspaceMutex.TryLock
+	}
+	if tryLock() {
+		return unlock, nil
+	}
+	if c.master {
+		lock()
+		return unlock, nil
+	}
+	acquired, abandoned := make(chan struct{}), make(chan struct{})
+	go func() {
+		lock()
+		select {
+		case acquired <- struct{}{}:
+		case <-abandoned:
+			unlock()
+		}
+	}()
+	ticker := time.NewTicker(busyCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-acquired:
+			return unlock, nil
+		case <-ticker.C:
+			if errReply := s.busyScriptError(); errReply != nil {
+				close(abandoned)
+				return nil, errReply
+			}
+		}
+	}
+}
+
+// killScript stops the running script, or function, unless it already wrote
+// to the keyspace.
+func (s *Server) killScript(function bool) []byte {
+	run := s.runningScript.Load()
+	switch {
+	case run == nil || run.function != function:
+		return parser.AppendError(nil, "NOTBUSY No scripts in execution right now.")
+	case run.wrote.Load():
+		return parser.AppendError(nil, "UNKILLABLE Sorry the script already executed write commands against the dataset. You can either wait the script termination or kill the server in a hard way using the SHUTDOWN NOSAVE command.")
+	}
+	run.killed.Store(true)
+	run.cancel()
+	return parser.OK()
+}
+
+// runScript runs script for c with the KEYS and ARGV tables set to keys and
+// args, and returns its reply. The caller holds the keyspace for writing.
+func (s *Server) runScript(c *Client, req [][]byte, script *luaScript, sha string, keys, args [][]byte, readonly bool) []byte {
+	s.scriptMutex.Lock()
+	if s.luaState == nil {
+		s.luaState = s.newLuaState()
+	}
+	L := s.luaState
+	s.scriptMutex.Unlock()
+
+	L.G.Global.RawSetString("KEYS", luaStringTable(L, keys))
+	L.G.Global.RawSetString("ARGV", luaStringTable(L, args))
+	run := &scriptRun{name: sha, command: req, readonly: readonly}
+	return s.runLua(c, L, L.NewFunctionFromProto(script.proto), run)
+}
+
+// runLua calls fn with args for c as described by run, and returns its
+// reply. The writes of the script are propagated as a transaction once it is
+// done.
+func (s *Server) runLua(c *Client, L *lua.LState, fn *lua.LFunction, run *scriptRun, args ...lua.LValue) []byte {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	run.client = &Client{protocol: 2, inExec: true}
+	run.command = cloneArgs(run.command)
+	run.start = time.Now()
+	run.cancel = cancel
+	s.runningScript.Store(run)
+	L.SetContext(ctx)
+	L.Push(L.NewFunction(luaErrorHandler))
+	L.Push(fn)
+	for _, arg := range args {
+		L.Push(arg)
+	}
+	err := L.PCall(len(args), 1, L.Get(1).(*lua.LFunction))
+	L.RemoveContext()
+	s.runningScript.Store(nil)
+
+	var response []byte
+	if err != nil {
+		response = scriptError(err, run)
+	} else {
+		response = c.appendLuaValue(nil, L.Get(-1))
+	}
+	L.SetTop(0)
+
+	if c.inExec {
+		c.execPropagate = append(c.execPropagate, run.client.execPropagate...)
+		c.execReady = append(c.execReady, run.client.execReady...)
+	} else {
+		s.propagateTransaction(run.client.execPropagate)
+		s.serveReadyKeys(run.client.execReady)
+	}
+	return response
+}
+
+// newLuaState creates the interpreter scripts run in, with the redis library
+// and a read-only global table.
+func (s *Server) newLuaState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	redis := L.NewTable()
+	L.SetFuncs(redis, map[string]lua.LGFunction{
+		"call":  func(L *lua.LState) int { return s.luaRedisCall(L, true) },
+		"pcall": func(L *lua.LState) int { return s.luaRedisCall(L, false) },
+		"error_reply": func(L *lua.LState) int {
+			L.Push(luaStatusTable(L, "err", strings.TrimPrefix(L.CheckString(1), "-")))
+			return 1
+		},
+		"status_reply": func(L *lua.LState) int {
+			L.Push(luaStatusTable(L, "ok", L.CheckString(1)))
+			return 1
+		},
+		"sha1hex": func(L *lua.LState) int {
+			if L.GetTop() != 1 {
+				L.RaiseError("wrong number of arguments")
+			}
+			L.Push(lua.LString(sha1hex(L.ToString(1))))
+			return 1
+		},
+		"log": luaLog,
+	})
+	for level, name := range []string{"LOG_DEBUG", "LOG_VERBOSE", "LOG_NOTICE", "LOG_WARNING"} {
+		redis.RawSetString(name, lua.LNumber(level))
+	}
+	L.SetGlobal("redis", redis)
+
+	// Scripts cannot leak state to each other through globals.
+	globals := L.NewTable()
+	globals.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		L.RaiseError("Attempt to modify a readonly table")
+		return 0
+	}))
+	globals.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+		L.RaiseError("Script attempted to access nonexistent global variable '%s'", L.ToString(2))
+		return 0
+	}))
+	L.SetMetatable(L.G.Global, globals)
+	return L
+}
+
+// luaRedisCall implements redis.call and redis.pcall. Errors are raised by
+// redis.call and returned as error tables by redis.pcall.
+func (s *Server) luaRedisCall(L *lua.LState, raise bool) int {
+	run := s.runningScript.Load()
+	fail := func(msg string) int {
+		err := luaStatusTable(L, "err", msg)
+		if !raise {
+			L.Push(err)
+			return 1
+		}
+		// Where returns the position of the caller as "source:line:".
+		where := strings.TrimSuffix(L.Where(1), ":")
+		if i := strings.LastIndexByte(where, ':'); i > 0 && !strings.HasPrefix(where, "[") {
+			err.RawSetString("source", lua.LString("@"+where[:i]))
+			err.RawSetString("line", lua.LString(where[i+1:]))
+		}
+		L.Error(err, 0)
+		return 0
+	}
+
+	if run == nil {
+		return fail("ERR redis.call/pcall can only be called inside a script invocation")
+	}
+	if L.GetTop() == 0 {
+		return fail("ERR Please specify at least one argument for this redis lib call")
+	}
+	args := make([][]byte, L.GetTop())
+	for i := range args {
+		switch arg := L.Get(i + 1).(type) {
+		case lua.LString:
+			args[i] = []byte(arg)
+		case lua.LNumber:
+			args[i] = []byte(strconv.FormatFloat(float64(arg), 'g', -1, 64))
+		default:
+			return fail("ERR Lua redis lib command arguments must be strings or integers")
+		}
+	}
+	cmd, errReply := s.lookupCommand(args)
+	switch {
+	case errReply != nil && s.commands[strings.ToLower(string(args[0]))] == nil:
+		return fail("ERR Unknown Redis command called from script")
+	case errReply != nil:
+		return fail(replyErrorMessage(errReply))
+	case cmd.hasFlag(flagNoScript):
+		return fail("ERR This Redis command is not allowed from script")
+	case cmd.hasFlag(flagWrite) && run.readonly:
+		return fail("ERR Write commands are not allowed from read-only scripts.")
+	}
+	if cmd.hasFlag(flagWrite) {
+		run.wrote.Store(true)
+	}
+	reply := s.call(run.client, cmd, args)
+	if isError(reply) {
+		return fail(replyErrorMessage(reply))
+	}
+	value, _ := luaValueFromReply(L, reply)
+	L.Push(value)
+	return 1
+}
+
+// luaLog implements redis.log, which writes its arguments to the server log.
+func luaLog(L *lua.LState) int {
+	if L.GetTop() < 2 {
+		L.RaiseError("redis.log() requires two arguments or more.")
+	}
+	level, ok := L.Get(1).(lua.LNumber)
+	if !ok {
+		L.RaiseError("First argument must be a number (log level).")
+	}
+	if level < 0 || level > 3 {
+		L.RaiseError("Invalid debug level.")
+	}
+	parts := make([]string, 0, L.GetTop()-1)
+	for i := 2; i <= L.GetTop(); i++ {
+		parts = append(parts, L.ToString(i))
+	}
+	log.Printf("Script log: %s", strings.Join(parts, " "))
+	return 0
+}
+
+// luaErrorHandler turns the errors raised while running a script into error
+// tables, recording where they were raised.
+func luaErrorHandler(L *lua.LState) int {
+	err, ok := L.Get(1).(*lua.LTable)
+	if !ok || err.RawGetString("err").Type() != lua.LTString {
+		err = luaStatusTable(L, "err", "ERR "+L.ToString(1))
+	}
+	if err.RawGetString("line") == lua.LNil {
+		for level := 1; level <= 2; level++ {
+			dbg, ok := L.GetStack(level)
+			if !ok {
+				break
+			}
+			if _, infoErr := L.GetInfo("Sl", dbg, lua.LNil); infoErr == nil && dbg.CurrentLine >= 0 {
+				err.RawSetString("source", lua.LString("@"+dbg.Source))
+				err.RawSetString("line", lua.LString(strconv.Itoa(dbg.CurrentLine)))
+				break
+			}
+		}
+	}
+	L.Push(err)
+	return 1
+}
+
+// scriptError formats the error the script or function of run failed with.
+func scriptError(err error, run *scriptRun) []byte {
+	msg := "ERR " + err.Error()
+	var source, line string
+	if apiErr, ok := err.(*lua.ApiError); ok {
+		if table, ok := apiErr.Object.(*lua.LTable); ok {
+			msg = table.RawGetString("err").String()
+			source = lua.LVAsString(table.RawGetString("source"))
+			line = lua.LVAsString(table.RawGetString("line"))
+		}
+	}
+	if run.killed.Load() {
+		msg = "ERR Script killed by user with SCRIPT KILL..."
+		if run.function {
+			msg = "ERR Script killed by user with FUNCTION KILL..."
+		}
+	}
+	if source == "" {
+		return parser.AppendError(nil, fmt.Sprintf("%s script: %s", msg, run.name))
+	}
+	return parser.AppendError(nil, fmt.Sprintf("%s script: %s, on %s:%s.", msg, run.name, source, line))
+}
+
+// replyErrorMessage returns the message of an error reply.
+func replyErrorMessage(reply []byte) string {
+	return strings.TrimSuffix(string(reply[1:]), "\r\n")
+}
+
+func luaStringTable(L *lua.LState, values [][]byte) *lua.LTable {
+	table := L.CreateTable(len(values), 0)
+	for _, value := range values {
+		table.Append(lua.LString(value))
+	}
+	return table
+}
+
+// luaStatusTable returns a table with a single field, as the ones standing for
+// status and error replies.
+func luaStatusTable(L *lua.LState, field, msg string) *lua.LTable {
+	table := L.CreateTable(0, 1)
+	table.RawSetString(field, lua.LString(msg))
+	return table
+}
+
+// luaValueFromReply converts the RESP2 reply of a command called by a script
+// into a Lua value, and returns the rest of the reply.
+func luaValueFromReply(L *lua.LState, reply []byte) (lua.LValue, []byte) {
+	end := strings.Index(string(reply), "\r\n")
+	kind, line, rest := reply[0], string(reply[1:end]), reply[end+2:]
+	switch kind {
+	case parser.String:
+		return luaStatusTable(L, "ok", line), rest
+	case parser.Error:
+		return luaStatusTable(L, "err", line), rest
+	case parser.Integer:
+		n, _ := strconv.ParseInt(line, 10, 64)
+		return lua.LNumber(n), rest
+	case parser.Bulk:
+		n, _ := strconv.Atoi(line)
+		if n < 0 {
+			return lua.LFalse, rest
+		}
+		return lua.LString(rest[:n]), rest[n+2:]
+	case parser.Array:
+		n, _ := strconv.Atoi(line)
+		if n < 0 {
+			return lua.LFalse, rest
+		}
+		table := L.CreateTable(n, 0)
+		for i := 0; i < n; i++ {
+			var value lua.LValue
+			value, rest = luaValueFromReply(L, rest)
+			table.Append(value)
+		}
+		return table, rest
+	}
+	return lua.LNil, rest
+}
+
+// appendLuaValue appends the reply for a value returned by a script. Tables
+// are converted to arrays up to their first nil, unless they stand for a
+// status or an error reply.
+func (c *Client) appendLuaValue(b []byte, value lua.LValue) []byte {
+	switch value := value.(type) {
+	case lua.LString:
+		return parser.AppendBulkString(b, string(value))
+	case lua.LNumber:
+		return parser.AppendInt(b, int64(value))
+	case lua.LBool:
+		if c.resp3() {
+			return parser.AppendBool(b, bool(value))
+		}
+		if value {
+			return parser.AppendInt(b, 1)
+		}
+		return append(b, parser.NullBulkString()...)
+	case *lua.LTable:
+		if err, ok := value.RawGetString("err").(lua.LString); ok {
+			return parser.AppendError(b, string(err))
+		}
+		if status, ok := value.RawGetString("ok").(lua.LString); ok {
+			return parser.AppendString(b, string(status))
+		}
+		n := 0
+		for value.RawGetInt(n+1) != lua.LNil {
+			n++
+		}
+		b = parser.AppendArray(b, n)
+		for i := 1; i <= n; i++ {
+			b = c.appendLuaValue(b, value.RawGetInt(i))
+		}
+		return b
+	}
+	return c.appendNull(b)
+}
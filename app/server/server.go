@@ -9,10 +9,12 @@ import (
 	"path"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/parser"
 	"github.com/codecrafters-io/redis-starter-go/app/persistence"
 	"github.com/codecrafters-io/redis-starter-go/app/store"
+	lua "github.com/yuin/gopher-lua"
 )
 
 const (
@@ -23,30 +25,169 @@ const (
 type Store interface {
 	Keys(pattern string) ([]string, error)
 	Load(entries []persistence.Entry)
-	Get(key string) ([]byte, bool)
+	Get(key string) ([]byte, bool, error)
 	Set(key string, value []byte, expiry int64) error
-	SetStream(key string) error
-	AddStreamEntry(key string, entryID []byte, fields []string) (string, error)
+	SetWithOptions(key string, value []byte, opts store.SetOptions) (store.SetResult, error)
+	MGet(keys ...string) [][]byte
+	MSet(keys []string, values [][]byte, nx bool) bool
+	Append(key string, value []byte) (int, error)
+	StrLen(key string) (int, error)
+	GetRange(key string, start, end int64) ([]byte, error)
+	SetRange(key string, offset int, value []byte) (int, error)
+	GetDel(key string) ([]byte, bool, error)
+	GetEx(key string, expireAt int64, persist bool) ([]byte, bool, error)
+	IncrBy(key string, delta int64) (int64, error)
+	IncrByFloat(key string, delta float64) ([]byte, error)
+	ListPush(key string, values [][]byte, left, xx bool) (int, error)
+	ListPop(key string, count int, left bool) ([][]byte, error)
+	ListMPop(keys []string, count int, left bool) (string, [][]byte, error)
+	ListMove(src, dst string, srcLeft, dstLeft bool) ([]byte, bool, error)
+	ListLen(key string) (int, error)
+	ListRange(key string, start, stop int64) ([][]byte, error)
+	ListIndex(key string, index int64) ([]byte, bool, error)
+	ListSet(key string, index int64, value []byte) error
+	ListRemove(key string, count int64, value []byte) (int, error)
+	ListTrim(key string, start, stop int64) error
+	ListInsert(key string, before bool, pivot, value []byte) (int, error)
+	ListPos(key string, value []byte, rank, count, maxLen int64) ([]int64, error)
+	HashSet(key string, pairs [][]byte) (int, error)
+	HashSetNX(key string, field, value []byte) (bool, error)
+	HashGet(key string, field []byte) ([]byte, bool, error)
+	HashMGet(key string, fields [][]byte) ([][]byte, error)
+	HashDelete(key string, fields [][]byte) (int, error)
+	HashLen(key string) (int, error)
+	HashExists(key string, field []byte) (bool, error)
+	HashStrLen(key string, field []byte) (int, error)
+	HashGetAll(key string) ([][]byte, error)
+	HashIncrBy(key string, field []byte, delta int64) (int64, error)
+	HashIncrByFloat(key string, field []byte, delta float64) ([]byte, error)
+	HashRandFields(key string, count int, repeat bool) ([][]byte, error)
+	HashScan(key string, cursor uint64, count int) (uint64, [][]byte, error)
+	HashExpire(key string, fields [][]byte, at int64, cond store.ExpireCondition) ([]int, error)
+	HashExpireTime(key string, fields [][]byte) ([]int64, error)
+	HashPersist(key string, fields [][]byte) ([]int, error)
+	SetAdd(key string, members [][]byte) (int, error)
+	SetRemove(key string, members [][]byte) (int, error)
+	SetMembers(key string) ([][]byte, error)
+	SetIsMember(key string, members [][]byte) ([]bool, error)
+	SetCard(key string) (int, error)
+	SetPop(key string, count int) ([][]byte, error)
+	SetRandMembers(key string, count int, repeat bool) ([][]byte, error)
+	SetMove(src, dst string, member []byte) (bool, error)
+	SetScan(key string, cursor uint64, count int) (uint64, [][]byte, error)
+	SetAlgebra(op store.SetOperation, keys []string) ([][]byte, error)
+	SetAlgebraStore(op store.SetOperation, dst string, keys []string) (int, error)
+	SetInterCard(keys []string, limit int) (int, error)
+	ZAdd(key string, members []store.ScoreMember, opts store.ZAddOptions) (store.ZAddResult, error)
+	ZRem(key string, members [][]byte) (int, error)
+	ZScores(key string, members [][]byte) ([]float64, []bool, error)
+	ZCard(key string) (int, error)
+	ZCount(key string, r store.ScoreRange) (int, error)
+	ZLexCount(key string, r store.LexRange) (int, error)
+	ZRank(key string, member []byte, rev bool) (int, float64, bool, error)
+	ZRange(key string, q store.ZRangeQuery) ([]store.ScoreMember, error)
+	ZRangeStore(dst, src string, q store.ZRangeQuery) (int, error)
+	ZPop(key string, count int, max bool) ([]store.ScoreMember, error)
+	ZScan(key string, cursor uint64, count int) (uint64, []store.ScoreMember, error)
+	ZAlgebraStore(op store.SetOperation, dst string, keys []string, weights []float64, aggregate store.ZAggregate) (int, error)
+	Delete(keys ...string) int
+	Exists(keys ...string) int
+	Flush() int
+	Rename(src, dst string, nx bool) (bool, error)
+	Copy(src, dst string, replace bool) (bool, error)
+	CopyItem(key string) (store.Item, bool)
+	PutItem(key string, item store.Item, replace bool) bool
+	Expire(key string, at int64, cond store.ExpireCondition) (set bool, deleted bool)
+	ExpireTime(key string) (int64, bool)
+	Persist(key string) bool
+	ActiveExpireCycle(deadline time.Time) (sampled, expired int)
+	ActiveExpireFields(deadline time.Time) (expired int)
+	StreamAdd(key string, entryID []byte, fields []string, opts store.StreamAddOptions) (string, error)
+	StreamTrim(key string, opts store.StreamTrimOptions) (int, error)
+	StreamDelete(key string, ids [][]byte) (int, error)
+	StreamLen(key string) (int, error)
+	StreamSetID(key string, id []byte, entriesAdded int64, maxDeletedID []byte) error
 	GetStreamLastEntryID(key string) ([]byte, error)
-	Range(key string, start, end []byte) []store.StreamEntry
+	StreamRead(keys []string, ids [][]byte, count int) ([][]store.StreamEntry, error)
+	Range(key string, start, end []byte, count int) ([]store.StreamEntry, error)
+	ReverseRange(key string, end, start []byte, count int) ([]store.StreamEntry, error)
+	StreamGroupCreate(key, group string, id []byte, entriesRead int64, mkstream bool) error
+	StreamGroupSetID(key, group string, id []byte, entriesRead int64) error
+	StreamGroupDestroy(key, group string) (bool, error)
+	StreamCreateConsumer(key, group, consumer string) (bool, error)
+	StreamDeleteConsumer(key, group, consumer string) (int, error)
+	StreamReadGroup(group, consumer string, keys []string, ids [][]byte, count int, noack bool) ([][]store.StreamEntry, []store.GroupUpdate, error)
+	StreamAck(key, group string, ids [][]byte) (int, error)
+	StreamPendingSummary(key, group string) (store.PendingSummary, error)
+	StreamPendingRange(key, group string, start, end []byte, count int, consumer string, minIdle int64) ([]store.PendingEntry, error)
+	StreamClaim(key, group, consumer string, minIdle int64, ids [][]byte, opts store.StreamClaimOptions) ([]store.StreamEntry, store.GroupUpdate, error)
+	StreamAutoClaim(key, group, consumer string, minIdle int64, start []byte, count int, justID bool) (string, []store.StreamEntry, store.GroupUpdate, error)
+	StreamInfo(key string, full bool, count int) (*store.StreamInfo, error)
+	StreamGroupsInfo(key string) ([]store.GroupInfo, error)
+	StreamConsumersInfo(key, group string) ([]store.ConsumerInfo, error)
 	Type(key string) string
+	Encoding(key string) (string, bool)
 	Export() []persistence.Entry
 }
 
 type Server struct {
 	config       Config
+	configMutex  sync.RWMutex
+	commands     map[string]*Command
+	nextClientID atomic.Int64
+	clientsMutex sync.Mutex
+	clients      map[int64]*Client
 	info         Info
-	ready        bool
+	stats        Stats
+	ready        atomic.Bool
 	slaveMutex   sync.Mutex
 	slaves       []Slave
 	stores       []Store
-	transactions map[net.Conn]*Transaction
+	transactions map[*Client]*Transaction
 	txMutex      sync.RWMutex
+	// keyspaceMutex is held for reading while a command runs, and for
+	// writing while a transaction runs, so that transactions are isolated.
+	keyspaceMutex sync.RWMutex
+	// watchedKeys holds the clients watching each key, guarded by
+	// watchMutex, and numWatched counts the watches.
+	watchMutex  sync.Mutex
+	watchedKeys map[string][]*Client
+	numWatched  atomic.Int64
+	blockMutex  sync.Mutex
+	blockedKeys map[string][]*blockedClient
+	numBlocked  atomic.Int64
+	// scripts caches the scripts by SHA1, and luaState is the interpreter
+	// they share, both guarded by scriptMutex. runningScript is the script
+	// being run, if any.
+	scriptMutex   sync.Mutex
+	scripts       map[string]*luaScript
+	luaState      *lua.LState
+	runningScript atomic.Pointer[scriptRun]
+	// libraries and functions hold the function libraries and the functions
+	// they registered by name, and functionState is the interpreter they are
+	// loaded in, all guarded by scriptMutex. loadingLibrary is the library
+	// whose code is being run by FUNCTION LOAD.
+	libraries      map[string]*functionLibrary
+	functions      map[string]*luaFunction
+	functionState  *lua.LState
+	loadingLibrary *functionLibrary
+	// masterLinkDown is set on replicas once the link with the master is
+	// lost.
+	masterLinkDown atomic.Bool
+	// channels, patterns and shardChannels hold the clients subscribed to
+	// each channel, pattern and shard channel, guarded by pubsubMutex.
+	pubsubMutex   sync.RWMutex
+	channels      map[string]map[*Client]struct{}
+	patterns      map[string]map[*Client]struct{}
+	shardChannels map[string]map[*Client]struct{}
 }
 
 type Transaction struct {
 	commands [][][]byte
 	inMulti  bool
+	// failed is set when a command could not be queued, and makes EXEC
+	// discard the transaction.
+	failed bool
 }
 
 type Slave struct {
@@ -55,6 +196,23 @@ type Slave struct {
 }
 
 func NewServer(config Config, rdbPath string) *Server {
+	if config.ClientQueryBufferLimit == 0 {
+		config.ClientQueryBufferLimit = DefaultClientQueryBufferLimit
+	}
+	if config.ProtoMaxBulkLen == 0 {
+		config.ProtoMaxBulkLen = DefaultProtoMaxBulkLen
+	}
+	if config.Hz == 0 {
+		config.Hz = DefaultHz
+	}
+	config.Hz = min(max(config.Hz, 1), MaxHz)
+	if config.LuaTimeLimit == 0 {
+		config.LuaTimeLimit = DefaultLuaTimeLimit
+	}
+	if config.PubSubOutputBufferLimit == (OutputBufferLimit{}) {
+		config.PubSubOutputBufferLimit = DefaultPubSubOutputBufferLimit
+	}
+
 	var role string
 	if config.ReplicaOf == "" {
 		role = "master"
@@ -62,42 +220,59 @@ func NewServer(config Config, rdbPath string) *Server {
 		role = "slave"
 	}
 
+	stores, libraries := createStores(rdbPath)
 	srv := &Server{
-		config: config,
-		stores: createStores(rdbPath),
+		config:   config,
+		commands: newCommandTable(),
+		stores:   stores,
 		info: Info{
 			role:             role,
 			masterReplID:     "8371b4fb1155b71f4a04d3e1bc3e18c4a990aeeb",
 			masterReplOffset: &atomic.Int64{},
+			startTime:        time.Now(),
 		},
-		transactions: make(map[net.Conn]*Transaction),
+		transactions:  make(map[*Client]*Transaction),
+		watchedKeys:   make(map[string][]*Client),
+		clients:       make(map[int64]*Client),
+		blockedKeys:   make(map[string][]*blockedClient),
+		scripts:       make(map[string]*luaScript),
+		libraries:     make(map[string]*functionLibrary),
+		functions:     make(map[string]*luaFunction),
+		channels:      make(map[string]map[*Client]struct{}),
+		patterns:      make(map[string]map[*Client]struct{}),
+		shardChannels: make(map[string]map[*Client]struct{}),
 	}
+	srv.loadLibraries(libraries)
 
 	if config.ReplicaOf != "" {
 		err := srv.SetupReplica(config.ReplicaOf, path.Join(config.Dir, config.DBFilename))
 		if err != nil {
 			log.Fatal(err)
 		}
-		srv.stores = createStores(rdbPath)
+		srv.stores, libraries = createStores(rdbPath)
+		srv.loadLibraries(libraries)
 	}
 
-	srv.ready = true
+	srv.ready.Store(true)
+	go srv.serverCron()
 
 	return srv
 }
 
-func createStores(rdbPath string) []Store {
+// createStores loads the databases of the RDB file at rdbPath, and returns
+// them along with the code of its function libraries.
+func createStores(rdbPath string) ([]Store, []string) {
 	stores := []Store{store.NewInMemoryStore()}
 	if file, err := os.Open(rdbPath); err == nil {
-		databases, err := persistence.LoadRDB(file)
+		databases, libraries, err := persistence.LoadRDB(file)
 		if err != nil {
 			log.Printf("Error loading RDB file: %v", err)
-			return stores
+			return stores, nil
 		}
 		file.Seek(0, 0)
 		if err := persistence.VerifyChecksum(file); err != nil {
 			log.Printf("Error veryfing RDB file: %v", err)
-			return stores
+			return stores, nil
 		}
 		if len(databases) > 0 {
 			stores = make([]Store, len(databases))
@@ -108,8 +283,17 @@ func createStores(rdbPath string) []Store {
 			}
 		}
 		log.Println("Successfully loaded", rdbPath)
+		return stores, libraries
+	}
+	return stores, nil
+}
+
+// loadLibraries replaces the function libraries with the ones loaded from an
+// RDB file.
+func (s *Server) loadLibraries(libraries []string) {
+	if err := s.restoreLibraries(libraries, true, false); err != nil {
+		log.Printf("Error loading function libraries: %v", err)
 	}
-	return stores
 }
 
 func (s *Server) Listen(address string) error {
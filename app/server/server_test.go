@@ -0,0 +1,1057 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/parser"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func startTestServer(t testing.TB) (*Server, string) {
+	return startTestServerWithConfig(t, Config{})
+}
+
+// startTestServerWithConfig starts a server with config, keeping its RDB
+// file in a temporary directory.
+func startTestServerWithConfig(t testing.TB, config Config) (*Server, string) {
+	dir := t.TempDir()
+	config.Dir, config.DBFilename = dir, "dump.rdb"
+	srv := NewServer(config, path.Join(dir, "dump.rdb"))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.handleClient(conn)
+		}
+	}()
+	return srv, l.Addr().String()
+}
+
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialTestClient(t testing.TB, addr string) *testClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *testClient) send(args ...string) {
+	c.conn.Write(parser.EncodeStringArray(args...))
+}
+
+// do sends a command and returns its reply.
+func (c *testClient) do(t testing.TB, args ...string) any {
+	t.Helper()
+	c.send(args...)
+	reply, err := readReply(c.r)
+	if err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	return reply
+}
+
+// readReply decodes a single reply. Errors are returned as error values,
+// nulls as nil and aggregates as []any.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply line %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case parser.String, parser.Double, parser.BigNumber:
+		return payload, nil
+	case parser.Error:
+		return errors.New(payload), nil
+	case parser.Integer:
+		return strconv.ParseInt(payload, 10, 64)
+	case parser.Boolean:
+		return payload == "t", nil
+	case parser.Null:
+		return nil, nil
+	case parser.Bulk, parser.Verbatim:
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case parser.Array, parser.Set, parser.Push, parser.Map:
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		if kind == parser.Map {
+			n *= 2
+		}
+		elems := make([]any, n)
+		for i := range elems {
+			if elems[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return elems, nil
+	}
+	return nil, fmt.Errorf("unknown reply type %q", kind)
+}
+
+func TestPipelinedCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	const n = 500
+	var pipeline []byte
+	for i := 0; i < n; i++ {
+		pipeline = append(pipeline, parser.EncodeStringArray("SET", fmt.Sprintf("key:%d", i), strconv.Itoa(i))...)
+		pipeline = append(pipeline, fmt.Sprintf("GET key:%d\r\n", i)...)
+	}
+	// Split the pipeline at awkward boundaries so commands span reads.
+	go func() {
+		for len(pipeline) > 0 {
+			chunk := min(len(pipeline), 777)
+			client.conn.Write(pipeline[:chunk])
+			pipeline = pipeline[chunk:]
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		if reply, err := readReply(client.r); err != nil || reply != "OK" {
+			t.Fatalf("SET %d: got %v, %v", i, reply, err)
+		}
+		if reply, err := readReply(client.r); err != nil || reply != strconv.Itoa(i) {
+			t.Fatalf("GET %d: got %v, %v", i, reply, err)
+		}
+	}
+}
+
+func TestProtoMaxBulkLen(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	if reply := client.do(t, "CONFIG", "SET", "proto-max-bulk-len", "1mb"); reply != "OK" {
+		t.Fatalf("CONFIG SET: got %v", reply)
+	}
+	client.conn.Write([]byte("*2\r\n$4\r\nECHO\r\n$2000000\r\n"))
+	reply, err := readReply(client.r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := reply.(error); !ok || e.Error() != "ERR Protocol error: invalid bulk length" {
+		t.Fatalf("got %v, want invalid bulk length error", reply)
+	}
+}
+
+func TestSetRangeOffsetLimit(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	for _, offset := range []string{"9223372036854775807", "9223372036854775806", "536870912"} {
+		reply := client.do(t, "SETRANGE", "key", offset, "x")
+		if e, ok := reply.(error); !ok || !strings.Contains(e.Error(), "proto-max-bulk-len") {
+			t.Fatalf("SETRANGE at %s: got %v, want proto-max-bulk-len error", offset, reply)
+		}
+	}
+	if reply := client.do(t, "SETRANGE", "key", "3", "x"); reply != int64(4) {
+		t.Fatalf("SETRANGE: got %v, want 4", reply)
+	}
+}
+
+func TestClientQueryBufferLimit(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	if reply := client.do(t, "CONFIG", "SET", "client-query-buffer-limit", "1mb"); reply != "OK" {
+		t.Fatalf("CONFIG SET: got %v", reply)
+	}
+	// An argument that never completes keeps growing the query buffer.
+	client.conn.Write([]byte("*2\r\n$4\r\nECHO\r\n$10000000\r\n"))
+	chunk := bytes.Repeat([]byte("x"), 64*1024)
+	for i := 0; i < 32; i++ {
+		if _, err := client.conn.Write(chunk); err != nil {
+			break
+		}
+	}
+	client.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err := client.r.ReadByte()
+	if ne, ok := err.(net.Error); err == nil || (ok && ne.Timeout()) {
+		t.Fatalf("expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestHello(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"HELLO", "4"}, "NOPROTO unsupported protocol version"},
+		{[]string{"HELLO", "x"}, "ERR Protocol version is not an integer or out of range"},
+		{[]string{"HELLO", "3", "AUTH", "admin", "secret"}, "WRONGPASS invalid username-password pair or user is disabled."},
+		{[]string{"HELLO", "3", "SETNAME", "my app"}, "ERR Client names cannot contain spaces, newlines or special characters."},
+		{[]string{"HELLO", "3", "AUTH", "default"}, "ERR Syntax error in HELLO option 'auth'"},
+		// Failed negotiations leave the client on RESP2.
+		{[]string{"GET", "missing"}, "<nil>"},
+	} {
+		if reply := client.do(t, tc.args...); fmt.Sprint(reply) != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.args, reply, tc.want)
+		}
+	}
+
+	// do returns the reply to args along with its RESP type.
+	do := func(args ...string) (byte, any) {
+		t.Helper()
+		client.send(args...)
+		kind, err := client.r.Peek(1)
+		if err != nil {
+			t.Fatalf("%q: %v", args, err)
+		}
+		reply, err := readReply(client.r)
+		if err != nil {
+			t.Fatalf("%q: %v", args, err)
+		}
+		return kind[0], reply
+	}
+	if kind, reply := do("GET", "missing"); kind != parser.Bulk || reply != nil {
+		t.Errorf("GET with RESP2: got %q %v, want a null bulk string", kind, reply)
+	}
+	kind, reply := do("HELLO", "3", "AUTH", "default", "secret", "SETNAME", "app")
+	if kind != parser.Map {
+		t.Fatalf("HELLO 3: got type %q, want a map", kind)
+	}
+	fields, _ := reply.([]any)
+	if len(fields) != 14 || fmt.Sprint(fields[:6]) != "[server redis version 7.4.0 proto 3]" || fmt.Sprint(fields[8:]) != "[mode standalone role master modules []]" {
+		t.Errorf("HELLO 3: got %v", reply)
+	}
+	if kind, reply := do("GET", "missing"); kind != parser.Null || reply != nil {
+		t.Errorf("GET with RESP3: got %q %v, want a null", kind, reply)
+	}
+	client.do(t, "ZADD", "zset", "1.5", "member")
+	if kind, reply := do("ZSCORE", "zset", "member"); kind != parser.Double || reply != "1.5" {
+		t.Errorf("ZSCORE with RESP3: got %q %v, want a double", kind, reply)
+	}
+	if kind, reply := do("CONFIG", "GET", "hz"); kind != parser.Map || fmt.Sprint(reply) != "[hz 10]" {
+		t.Errorf("CONFIG GET with RESP3: got %q %v, want a map", kind, reply)
+	}
+	if reply := client.do(t, "CLIENT", "GETNAME"); reply != "app" {
+		t.Errorf("CLIENT GETNAME after HELLO SETNAME: got %v", reply)
+	}
+	if kind, _ := do("HELLO", "2"); kind != parser.Array {
+		t.Errorf("HELLO 2: got type %q, want an array", kind)
+	}
+	if kind, reply := do("ZSCORE", "zset", "member"); kind != parser.Bulk || reply != "1.5" {
+		t.Errorf("ZSCORE after HELLO 2: got %q %v, want a bulk string", kind, reply)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	info, _ := client.do(t, "INFO", "server").(string)
+	lines := strings.Split(info, "\n")
+	for _, field := range []string{"# Server", "redis_version:7.4.0", "redis_mode:standalone", "tcp_port:0"} {
+		if !slices.Contains(lines, field) {
+			t.Errorf("INFO server: %q missing from %q", field, info)
+		}
+	}
+	if strings.Contains(info, "# Replication") {
+		t.Errorf("INFO server: got other sections in %q", info)
+	}
+}
+
+func TestCommandRegistry(t *testing.T) {
+	srv, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"NOSUCHCOMMAND", "a", "b"}, "ERR unknown command 'NOSUCHCOMMAND', with args beginning with: 'a' 'b' "},
+		{[]string{"GET"}, "ERR wrong number of arguments for 'get' command"},
+		{[]string{"GET", "a", "b"}, "ERR wrong number of arguments for 'get' command"},
+		{[]string{"CONFIG", "NOSUCH"}, "ERR unknown subcommand 'NOSUCH'. Try CONFIG HELP."},
+		{[]string{"CONFIG", "GET"}, "ERR wrong number of arguments for 'config|get' command"},
+		{[]string{"COMMAND", "COUNT"}, strconv.Itoa(len(srv.commands))},
+		{[]string{"COMMAND", "INFO", "get", "nosuch"}, "[[get 2 [readonly fast] 1 1 1 [@read @fast @string] [] [] []] <nil>]"},
+		{[]string{"COMMAND", "INFO", "lpush", "hset", "sadd", "zadd"}, "[" +
+			"[lpush -3 [write denyoom fast] 1 1 1 [@write @fast @list] [] [] []] " +
+			"[hset -4 [write denyoom fast] 1 1 1 [@write @fast @hash] [] [] []] " +
+			"[sadd -3 [write denyoom fast] 1 1 1 [@write @fast @set] [] [] []] " +
+			"[zadd -4 [write denyoom fast] 1 1 1 [@write @fast @sortedset] [] [] []]]"},
+		{[]string{"COMMAND", "DOCS", "get"}, "[get [summary Returns the string value of a key. since 1.0.0 group string complexity O(1) doc_flags []]]"},
+		{[]string{"COMMAND", "GETKEYS", "SET", "key", "value"}, "[key]"},
+		{[]string{"COMMAND", "GETKEYS", "MSET", "a", "1", "b", "2"}, "[a b]"},
+		{[]string{"COMMAND", "GETKEYS", "PING"}, "ERR The command has no key arguments"},
+		{[]string{"COMMAND", "GETKEYS", "NOSUCH", "key"}, "ERR Invalid command specified"},
+		{[]string{"COMMAND", "GETKEYS", "GET"}, "ERR Invalid number of arguments specified for command"},
+	} {
+		if reply := client.do(t, tc.args...); fmt.Sprint(reply) != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.args, reply, tc.want)
+		}
+	}
+}
+
+func TestCommandGetKeysNumKeys(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	for _, args := range [][]string{
+		{"EVAL", "return 1", "9223372036854775807"},
+		{"EVAL", "return 1", "2", "a"},
+		{"SINTERCARD", "9223372036854775807", "a"},
+		{"ZUNIONSTORE", "dst", "9223372036854775807", "a"},
+		{"BLMPOP", "0", "9223372036854775807", "a", "LEFT"},
+	} {
+		reply := client.do(t, append([]string{"COMMAND", "GETKEYS"}, args...)...)
+		if _, ok := reply.(error); !ok {
+			t.Fatalf("COMMAND GETKEYS %v: got %v, want an error", args, reply)
+		}
+	}
+	reply := client.do(t, "COMMAND", "GETKEYS", "EVAL", "return 1", "2", "a", "b", "c")
+	if fmt.Sprint(reply) != "[a b]" {
+		t.Fatalf("COMMAND GETKEYS EVAL: got %v, want [a b]", reply)
+	}
+}
+
+// waitBlocked waits until n clients are blocked.
+func waitBlocked(t *testing.T, srv *Server, n int64) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); srv.numBlocked.Load() != n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d blocked clients, want %d", srv.numBlocked.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBlockingPop(t *testing.T) {
+	srv, addr := startTestServer(t)
+	first, second := dialTestClient(t, addr), dialTestClient(t, addr)
+	client := dialTestClient(t, addr)
+
+	// Waiters are served in the order they blocked.
+	first.send("BLPOP", "other", "list", "0")
+	waitBlocked(t, srv, 1)
+	second.send("BRPOP", "list", "0.5")
+	waitBlocked(t, srv, 2)
+	if reply := client.do(t, "RPUSH", "list", "a", "b", "c"); reply != int64(3) {
+		t.Fatalf("RPUSH: got %v", reply)
+	}
+	if reply, _ := readReply(first.r); fmt.Sprint(reply) != "[list a]" {
+		t.Fatalf("first waiter: got %v", reply)
+	}
+	if reply, _ := readReply(second.r); fmt.Sprint(reply) != "[list c]" {
+		t.Fatalf("second waiter: got %v", reply)
+	}
+	if reply := client.do(t, "LRANGE", "list", "0", "-1"); fmt.Sprint(reply) != "[b]" {
+		t.Fatalf("LRANGE: got %v", reply)
+	}
+
+	start := time.Now()
+	if reply := client.do(t, "BLPOP", "empty", "0.1"); reply != nil {
+		t.Fatalf("BLPOP timeout: got %v", reply)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("BLPOP returned after %v", elapsed)
+	}
+	if reply := client.do(t, "BLPOP", "empty", "0.0000000001"); reply != nil {
+		t.Fatalf("BLPOP tiny timeout: got %v", reply)
+	}
+
+	// An element moved by BLMOVE wakes the clients blocked on the target.
+	first.send("BLMOVE", "src", "dst", "LEFT", "RIGHT", "0")
+	waitBlocked(t, srv, 1)
+	second.send("BLMPOP", "0", "1", "dst", "LEFT", "COUNT", "2")
+	waitBlocked(t, srv, 2)
+	client.do(t, "LPUSH", "src", "x")
+	if reply, _ := readReply(first.r); reply != "x" {
+		t.Fatalf("BLMOVE: got %v", reply)
+	}
+	if reply, _ := readReply(second.r); fmt.Sprint(reply) != "[dst [x]]" {
+		t.Fatalf("BLMPOP: got %v", reply)
+	}
+
+	id := first.do(t, "CLIENT", "ID").(int64)
+	first.send("BRPOPLPUSH", "empty", "dst", "0")
+	waitBlocked(t, srv, 1)
+	if reply := client.do(t, "CLIENT", "UNBLOCK", strconv.FormatInt(id, 10), "ERROR"); reply != int64(1) {
+		t.Fatalf("CLIENT UNBLOCK: got %v", reply)
+	}
+	if reply, _ := readReply(first.r); fmt.Sprint(reply) != "UNBLOCKED client unblocked via CLIENT UNBLOCK" {
+		t.Fatalf("unblocked client: got %v", reply)
+	}
+	if reply := client.do(t, "CLIENT", "UNBLOCK", strconv.FormatInt(id, 10)); reply != int64(0) {
+		t.Fatalf("CLIENT UNBLOCK on a client that is not blocked: got %v", reply)
+	}
+
+	// Disconnected clients stop waiting.
+	second.send("BLPOP", "empty", "0")
+	waitBlocked(t, srv, 1)
+	second.conn.Close()
+	waitBlocked(t, srv, 0)
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	_, addr := startTestServer(b)
+	client := dialTestClient(b, addr)
+
+	const depth = 100
+	var pipeline []byte
+	for i := 0; i < depth; i++ {
+		pipeline = append(pipeline, parser.EncodeStringArray("SET", "key:"+strconv.Itoa(i), "value")...)
+	}
+	reply := make([]byte, len("+OK\r\n")*depth)
+
+	b.SetBytes(int64(len(pipeline)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.conn.Write(pipeline); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.ReadFull(client.r, reply); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N*depth)/b.Elapsed().Seconds(), "cmds/s")
+}
+
+func TestBlockingStreamRead(t *testing.T) {
+	srv, addr := startTestServer(t)
+	first, second := dialTestClient(t, addr), dialTestClient(t, addr)
+	client := dialTestClient(t, addr)
+
+	// $ is resolved when the command blocks, and BLOCK 0 waits forever.
+	client.do(t, "XADD", "stream", "1-0", "f", "old")
+	first.send("XREAD", "BLOCK", "0", "STREAMS", "other", "stream", "$", "$")
+	waitBlocked(t, srv, 1)
+	client.do(t, "XGROUP", "CREATE", "stream", "group", "$")
+	second.send("XREADGROUP", "GROUP", "group", "consumer", "BLOCK", "0", "STREAMS", "stream", ">")
+	waitBlocked(t, srv, 2)
+	client.do(t, "XADD", "stream", "2-0", "f", "new")
+	if reply, _ := readReply(first.r); fmt.Sprint(reply) != "[[stream [[2-0 [f new]]]]]" {
+		t.Fatalf("XREAD: got %v", reply)
+	}
+	if reply, _ := readReply(second.r); fmt.Sprint(reply) != "[[stream [[2-0 [f new]]]]]" {
+		t.Fatalf("XREADGROUP: got %v", reply)
+	}
+
+	if reply := client.do(t, "XREAD", "COUNT", "1", "STREAMS", "stream", "0"); fmt.Sprint(reply) != "[[stream [[1-0 [f old]]]]]" {
+		t.Fatalf("XREAD COUNT: got %v", reply)
+	}
+	start := time.Now()
+	if reply := client.do(t, "XREAD", "BLOCK", "100", "STREAMS", "stream", "2-0"); reply != nil {
+		t.Fatalf("XREAD timeout: got %v", reply)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("XREAD returned after %v", elapsed)
+	}
+
+	// Deleting the group or the stream unblocks XREADGROUP with an error.
+	second.send("XREADGROUP", "GROUP", "group", "consumer", "BLOCK", "0", "STREAMS", "stream", ">")
+	waitBlocked(t, srv, 1)
+	client.do(t, "XGROUP", "DESTROY", "stream", "group")
+	if reply, _ := readReply(second.r); fmt.Sprint(reply) != "NOGROUP No such key 'stream' or consumer group 'group' in XREADGROUP with GROUP option" {
+		t.Fatalf("XREADGROUP after XGROUP DESTROY: got %v", reply)
+	}
+	client.do(t, "XGROUP", "CREATE", "stream", "group", "$")
+	second.send("XREADGROUP", "GROUP", "group", "consumer", "BLOCK", "0", "STREAMS", "stream", ">")
+	waitBlocked(t, srv, 1)
+	client.do(t, "DEL", "stream")
+	if reply, _ := readReply(second.r); fmt.Sprint(reply) != "UNBLOCKED the stream key no longer exists" {
+		t.Fatalf("XREADGROUP after DEL: got %v", reply)
+	}
+	waitBlocked(t, srv, 0)
+}
+
+func TestWatch(t *testing.T) {
+	srv, addr := startTestServer(t)
+	client, other := dialTestClient(t, addr), dialTestClient(t, addr)
+
+	// exec runs a transaction setting key and returns the EXEC reply.
+	exec := func() any {
+		client.do(t, "MULTI")
+		client.do(t, "SET", "key", "tx")
+		return client.do(t, "EXEC")
+	}
+
+	client.do(t, "SET", "key", "1")
+	client.do(t, "WATCH", "key")
+	other.do(t, "SET", "key", "2")
+	if reply := exec(); reply != nil {
+		t.Fatalf("EXEC after a write: got %v", reply)
+	}
+	if reply := client.do(t, "GET", "key"); reply != "2" {
+		t.Fatalf("GET after an aborted EXEC: got %v", reply)
+	}
+
+	// EXEC unwatches the keys, whether it ran or not.
+	other.do(t, "SET", "key", "3")
+	client.do(t, "WATCH", "key", "key")
+	if reply := exec(); fmt.Sprint(reply) != "[OK]" {
+		t.Fatalf("EXEC without writes: got %v", reply)
+	}
+
+	client.do(t, "WATCH", "key")
+	client.do(t, "UNWATCH")
+	other.do(t, "SET", "key", "4")
+	if reply := exec(); fmt.Sprint(reply) != "[OK]" {
+		t.Fatalf("EXEC after UNWATCH: got %v", reply)
+	}
+
+	client.do(t, "SET", "key", "5", "PX", "20")
+	client.do(t, "WATCH", "key")
+	time.Sleep(50 * time.Millisecond)
+	if reply := exec(); reply != nil {
+		t.Fatalf("EXEC after an expiry: got %v", reply)
+	}
+
+	client.do(t, "SET", "key", "6")
+	client.do(t, "WATCH", "key")
+	other.do(t, "FLUSHDB")
+	if reply := exec(); reply != nil {
+		t.Fatalf("EXEC after FLUSHDB: got %v", reply)
+	}
+
+	client.do(t, "MULTI")
+	if reply := client.do(t, "WATCH", "key"); fmt.Sprint(reply) != "ERR WATCH inside MULTI is not allowed" {
+		t.Fatalf("WATCH inside MULTI: got %v", reply)
+	}
+	client.do(t, "DISCARD")
+
+	// Watches are released when the client disconnects.
+	client.do(t, "WATCH", "key", "other")
+	if n := srv.numWatched.Load(); n != 2 {
+		t.Fatalf("watched keys: got %d, want 2", n)
+	}
+	client.conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for srv.numWatched.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("watched keys after disconnect: got %d", srv.numWatched.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	client.do(t, "MULTI")
+	client.do(t, "SET", "key", "1")
+	if reply := client.do(t, "NOSUCHCOMMAND"); reply == nil {
+		t.Fatal("unknown command queued")
+	}
+	client.do(t, "GET")
+	if reply := client.do(t, "EXEC"); fmt.Sprint(reply) != "EXECABORT Transaction discarded because of previous errors." {
+		t.Fatalf("EXEC after queuing errors: got %v", reply)
+	}
+	if reply := client.do(t, "GET", "key"); reply != nil {
+		t.Fatalf("GET after EXECABORT: got %v", reply)
+	}
+	if reply := client.do(t, "EXEC"); fmt.Sprint(reply) != "ERR EXEC without MULTI" {
+		t.Fatalf("EXEC after EXECABORT: got %v", reply)
+	}
+
+	// Errors raised while running do not abort the other commands.
+	client.do(t, "MULTI")
+	client.do(t, "SET", "key", "a")
+	client.do(t, "INCR", "key")
+	client.do(t, "SET", "key", "b")
+	if reply := client.do(t, "EXEC"); fmt.Sprint(reply) != "[OK ERR value is not an integer or out of range OK]" {
+		t.Fatalf("EXEC with a failing command: got %v", reply)
+	}
+
+	// Subscribed clients cannot start a transaction, even with RESP3.
+	client.do(t, "HELLO", "3")
+	client.do(t, "SUBSCRIBE", "channel")
+	if reply := client.do(t, "MULTI"); fmt.Sprint(reply) != "ERR MULTI is not allowed in the subscribed context" {
+		t.Fatalf("MULTI while subscribed: got %v", reply)
+	}
+	if reply := client.do(t, "SET", "key", "c"); reply != "OK" {
+		t.Fatalf("SET after a refused MULTI: got %v", reply)
+	}
+	client.do(t, "UNSUBSCRIBE")
+	if reply := client.do(t, "MULTI"); reply != "OK" {
+		t.Fatalf("MULTI after UNSUBSCRIBE: got %v", reply)
+	}
+}
+
+func TestTransactionIsolation(t *testing.T) {
+	_, addr := startTestServer(t)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	clients := [][]string{{"INCR", "counter"}, {"INCR", "counter"}, {"MGET", "a", "b"}, {"MGET", "a", "b"}}
+	errs := make(chan error, len(clients))
+	// Other clients write and read the keys while the transactions run.
+	for _, args := range clients {
+		c := dialTestClient(t, addr)
+		wg.Add(1)
+		go func(args []string) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				c.send(args...)
+				reply, err := readReply(c.r)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if values, ok := reply.([]any); ok && values[0] != values[1] {
+					errs <- fmt.Errorf("MGET saw a partial transaction: %v", values)
+					return
+				}
+			}
+		}(args)
+	}
+
+	// Each transaction increments counter many times in a row, for long
+	// enough that the other clients are scheduled meanwhile.
+	const incrs = 20000
+	client := dialTestClient(t, addr)
+	for i := 0; i < 5; i++ {
+		var tx bytes.Buffer
+		tx.Write(parser.EncodeStringArray("MULTI"))
+		tx.Write(parser.EncodeStringArray("INCR", "a"))
+		for j := 0; j < incrs; j++ {
+			tx.Write(parser.EncodeStringArray("INCR", "counter"))
+		}
+		tx.Write(parser.EncodeStringArray("INCR", "b"))
+		tx.Write(parser.EncodeStringArray("EXEC"))
+		// The replies are read while the transaction is being sent.
+		go client.conn.Write(tx.Bytes())
+		for j := 0; j < incrs+3; j++ {
+			if _, err := readReply(client.r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		reply, err := readReply(client.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, ok := reply.([]any)
+		if !ok || len(values) != incrs+2 {
+			t.Fatalf("EXEC: got %d replies", len(values))
+		}
+		for j := 2; j <= incrs; j++ {
+			if values[j].(int64) != values[j-1].(int64)+1 {
+				t.Fatalf("EXEC interleaved with other clients: got %v after %v", values[j], values[j-1])
+			}
+		}
+	}
+	close(stop)
+	wg.Wait()
+	select {
+	case err := <-errs:
+		t.Fatal(err)
+	default:
+	}
+}
+
+func TestScripting(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"EVAL", "return {1, 2.7, 'x', true, false, nil, 5}", "0"}, "[1 2 x 1 <nil>]"},
+		{[]string{"EVAL", "return redis.call('SET', KEYS[1], ARGV[1])", "1", "key", "1"}, "OK"},
+		{[]string{"EVAL", "return {redis.call('INCR', KEYS[1]), redis.call('GET', 'missing')}", "1", "key"}, "[2 <nil>]"},
+		{[]string{"EVAL", "return redis.pcall('LPUSH', KEYS[1], 'x')", "1", "key"}, "WRONGTYPE Operation against a key holding the wrong kind of value"},
+		{[]string{"EVAL", "return redis.call('LPUSH', KEYS[1], 'x')", "1", "key"}, "WRONGTYPE Operation against a key holding the wrong kind of value script: c0f4e07d13acc8c1c810243d737b96cf873d836e, on @user_script:1."},
+		{[]string{"EVAL", "return redis.status_reply('DONE')", "0"}, "DONE"},
+		{[]string{"EVAL", "return redis.error_reply('MY error')", "0"}, "MY error"},
+		{[]string{"EVAL", "return redis.sha1hex('')", "0"}, "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+		{[]string{"EVAL", "leak = 1", "0"}, "ERR user_script:1: Attempt to modify a readonly table script: ccd157cd64f4730362f79df48b612334409be85d, on @user_script:1."},
+		{[]string{"EVAL", "return redis.call('MULTI')", "0"}, "ERR This Redis command is not allowed from script script: ba922d924f6e7808414359ed93c37767dd0532ce, on @user_script:1."},
+		{[]string{"EVAL_RO", "return redis.call('DEL', KEYS[1])", "1", "key"}, "ERR Write commands are not allowed from read-only scripts. script: b0d697da25b13e49157b2c214a4033546aba2104, on @user_script:1."},
+		{[]string{"EVAL", "return 1", "2", "key"}, "ERR Number of keys can't be greater than number of args"},
+		{[]string{"SCRIPT", "LOAD", "return ARGV[1]"}, "098e0f0d1448c0a81dafe820f66d460eb09263da"},
+		{[]string{"EVALSHA", "098e0f0d1448c0a81dafe820f66d460eb09263da", "0", "arg"}, "arg"},
+		{[]string{"SCRIPT", "EXISTS", "098e0f0d1448c0a81dafe820f66d460eb09263da", "ffff"}, "[1 0]"},
+		{[]string{"SCRIPT", "FLUSH"}, "OK"},
+		{[]string{"EVALSHA", "098e0f0d1448c0a81dafe820f66d460eb09263da", "0"}, "NOSCRIPT No matching script. Please use EVAL."},
+	} {
+		if reply := client.do(t, tc.args...); fmt.Sprint(reply) != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.args, reply, tc.want)
+		}
+	}
+}
+
+func TestScriptKill(t *testing.T) {
+	_, addr := startTestServer(t)
+	client, other := dialTestClient(t, addr), dialTestClient(t, addr)
+
+	other.do(t, "CONFIG", "SET", "lua-time-limit", "10")
+	if reply := other.do(t, "SCRIPT", "KILL"); fmt.Sprint(reply) != "NOTBUSY No scripts in execution right now." {
+		t.Fatalf("SCRIPT KILL without a script: got %v", reply)
+	}
+	client.send("EVAL", "while true do end", "0")
+	time.Sleep(50 * time.Millisecond)
+	if reply := other.do(t, "GET", "key"); fmt.Sprint(reply) != "BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE." {
+		t.Fatalf("GET while busy: got %v", reply)
+	}
+	if reply := other.do(t, "SCRIPT", "KILL"); reply != "OK" {
+		t.Fatalf("SCRIPT KILL: got %v", reply)
+	}
+	reply, _ := readReply(client.r)
+	if want := "ERR Script killed by user with SCRIPT KILL... script: 694a5fe1ddb97a4c6a1bf299d9537c7d3d0f84e7, on @user_script:1."; fmt.Sprint(reply) != want {
+		t.Fatalf("killed EVAL: got %v", reply)
+	}
+	if reply := other.do(t, "GET", "key"); reply != nil {
+		t.Fatalf("GET after SCRIPT KILL: got %v", reply)
+	}
+
+	// Scripts that wrote cannot be killed.
+	client.send("EVAL", "redis.call('SET', KEYS[1], 1) local i = 0 while i < 3e6 do i = i + 1 end return i", "1", "key")
+	time.Sleep(50 * time.Millisecond)
+	if reply := other.do(t, "SCRIPT", "KILL"); !strings.HasPrefix(fmt.Sprint(reply), "UNKILLABLE") {
+		t.Fatalf("SCRIPT KILL after a write: got %v", reply)
+	}
+	if reply, _ := readReply(client.r); reply != int64(3e6) {
+		t.Fatalf("unkillable EVAL: got %v", reply)
+	}
+}
+
+// TestScriptBusyWhileWaiting checks that commands sent before the script
+// became busy stop waiting for it once it does.
+func TestScriptBusyWhileWaiting(t *testing.T) {
+	_, addr := startTestServer(t)
+	client, other, admin := dialTestClient(t, addr), dialTestClient(t, addr), dialTestClient(t, addr)
+
+	admin.do(t, "CONFIG", "SET", "lua-time-limit", "200")
+	client.send("EVAL", "while true do end", "0")
+	time.Sleep(20 * time.Millisecond)
+	other.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	start := time.Now()
+	for _, args := range [][]string{{"GET", "key"}, {"EVAL", "return 1", "0"}} {
+		if reply := other.do(t, args...); !strings.HasPrefix(fmt.Sprint(reply), "BUSY") {
+			t.Fatalf("%v sent before the time limit: got %v, want BUSY", args, reply)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("BUSY was returned after %v", elapsed)
+	}
+	if reply := admin.do(t, "SCRIPT", "KILL"); reply != "OK" {
+		t.Fatalf("SCRIPT KILL: got %v", reply)
+	}
+	readReply(client.r)
+	other.conn.SetReadDeadline(time.Time{})
+	if reply := other.do(t, "GET", "key"); reply != nil {
+		t.Fatalf("GET after SCRIPT KILL: got %v", reply)
+	}
+}
+
+// TestReplicaBusyScript checks that the writes of the master wait for a slow
+// script running on a replica instead of being dropped with BUSY.
+func TestReplicaBusyScript(t *testing.T) {
+	_, masterAddr := startTestServer(t)
+	_, replicaAddr := startTestServerWithConfig(t, Config{ReplicaOf: masterAddr})
+	master, client, admin := dialTestClient(t, masterAddr), dialTestClient(t, replicaAddr), dialTestClient(t, replicaAddr)
+
+	admin.do(t, "CONFIG", "SET", "lua-time-limit", "50")
+	client.send("EVAL_RO", "while true do end", "0")
+	time.Sleep(100 * time.Millisecond)
+	if reply := master.do(t, "SET", "key", "value"); reply != "OK" {
+		t.Fatalf("SET on the master: got %v", reply)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if reply := admin.do(t, "SCRIPT", "KILL"); reply != "OK" {
+		t.Fatalf("SCRIPT KILL: got %v", reply)
+	}
+	readReply(client.r)
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		reply := admin.do(t, "GET", "key")
+		if reply == "value" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET on the replica: got %v, want the write of the master", reply)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	library := "#!lua name=lib\n" +
+		"redis.register_function('set', function(keys, args) return redis.call('SET', keys[1], args[1]) end)\n" +
+		"redis.register_function{function_name='get', callback=function(keys) return redis.call('GET', keys[1]) end, flags={'no-writes'}}"
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"FUNCTION", "LOAD", library}, "lib"},
+		{[]string{"FUNCTION", "LOAD", library}, "ERR Library 'lib' already exists"},
+		{[]string{"FUNCTION", "LOAD", "REPLACE", library}, "lib"},
+		{[]string{"FUNCTION", "LOAD", "#!lua name=other\nredis.register_function('set', function() end)"}, "ERR Function set already exists"},
+		{[]string{"FUNCTION", "LOAD", "#!lua name=other\nreturn 1"}, "ERR No functions registered"},
+		{[]string{"FUNCTION", "LOAD", "return 1"}, "ERR Missing library metadata"},
+		{[]string{"FCALL", "set", "1", "key", "value"}, "OK"},
+		{[]string{"FCALL", "get", "1", "key"}, "value"},
+		{[]string{"FCALL_RO", "get", "1", "key"}, "value"},
+		{[]string{"FCALL_RO", "set", "1", "key", "value"}, "ERR Can not execute a script with write flag using *_ro command."},
+		{[]string{"FCALL", "missing", "0"}, "ERR Function not found"},
+		{[]string{"FUNCTION", "LIST"}, "[[library_name lib engine LUA functions [[name get description <nil> flags [no-writes]] [name set description <nil> flags []]]]]"},
+		{[]string{"FUNCTION", "LIST", "LIBRARYNAME", "x*"}, "[]"},
+		{[]string{"FUNCTION", "DELETE", "lib"}, "OK"},
+		{[]string{"FUNCTION", "DELETE", "lib"}, "ERR Library not found"},
+		{[]string{"FCALL", "get", "1", "key"}, "ERR Function not found"},
+	} {
+		if reply := client.do(t, tc.args...); fmt.Sprint(reply) != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.args, reply, tc.want)
+		}
+	}
+
+	client.do(t, "FUNCTION", "LOAD", library)
+	payload, ok := client.do(t, "FUNCTION", "DUMP").(string)
+	if !ok {
+		t.Fatalf("FUNCTION DUMP: got %v", payload)
+	}
+	if reply := client.do(t, "FUNCTION", "RESTORE", payload); fmt.Sprint(reply) != "ERR Library 'lib' already exists" {
+		t.Errorf("FUNCTION RESTORE over an existing library: got %v", reply)
+	}
+	if reply := client.do(t, "FUNCTION", "RESTORE", payload[:len(payload)-1]+"x"); fmt.Sprint(reply) != "ERR payload version or checksum are wrong" {
+		t.Errorf("FUNCTION RESTORE with a bad checksum: got %v", reply)
+	}
+	client.do(t, "FUNCTION", "FLUSH")
+	if reply := client.do(t, "FUNCTION", "RESTORE", payload); reply != "OK" {
+		t.Fatalf("FUNCTION RESTORE: got %v", reply)
+	}
+	if reply := client.do(t, "FCALL", "get", "1", "key"); reply != "value" {
+		t.Errorf("FCALL after FUNCTION RESTORE: got %v", reply)
+	}
+}
+
+func TestScanMatch(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	client.do(t, "SADD", "set", "user/1", "user/2", "group/1")
+	client.do(t, "HSET", "hash", "user/1", "a", "group/1", "b")
+	client.do(t, "ZADD", "zset", "1", "user/1", "2", "group/1")
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SSCAN", "set", "0", "MATCH", "user*"}, "[user/1 user/2]"},
+		{[]string{"SSCAN", "set", "0", "MATCH", "*/1"}, "[group/1 user/1]"},
+		{[]string{"HSCAN", "hash", "0", "MATCH", "user*"}, "[user/1 a]"},
+		{[]string{"ZSCAN", "zset", "0", "MATCH", "u*"}, "[user/1 1]"},
+	} {
+		reply, ok := client.do(t, tc.args...).([]any)
+		if !ok || len(reply) != 2 {
+			t.Fatalf("%q: got %v", tc.args, reply)
+		}
+		elems, _ := reply[1].([]any)
+		if tc.args[0] == "SSCAN" {
+			sort.Slice(elems, func(i, j int) bool { return fmt.Sprint(elems[i]) < fmt.Sprint(elems[j]) })
+		}
+		if fmt.Sprint(elems) != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.args, elems, tc.want)
+		}
+	}
+}
+
+func TestHashFieldExpire(t *testing.T) {
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+
+	client.do(t, "HSET", "hash", "a", "1", "b", "2")
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"HEXPIRE", "hash", "100", "FIELDS", "2", "a", "missing"}, "[1 -2]"},
+		{[]string{"HEXPIRE", "hash", "100", "NX", "FIELDS", "1", "a"}, "[0]"},
+		{[]string{"HTTL", "hash", "FIELDS", "2", "a", "b"}, "[100 -1]"},
+		{[]string{"HPERSIST", "hash", "FIELDS", "1", "a"}, "[1]"},
+		{[]string{"HEXPIRE", "hash", "100", "FIELDS", "2", "a"}, "ERR The `numfields` parameter must match the number of arguments"},
+		{[]string{"HEXPIRE", "hash", "100", "FIELDS", "0"}, "ERR Parameter `numFields` should be greater than 0"},
+		{[]string{"HPEXPIRE", "hash", "100", "FIELDS", "0", "a"}, "ERR Parameter `numFields` should be greater than 0"},
+		{[]string{"HEXPIREAT", "hash", "100", "NX", "FIELDS", "0"}, "ERR Parameter `numFields` should be greater than 0"},
+		{[]string{"HPEXPIREAT", "hash", "100", "FIELDS", "0"}, "ERR Parameter `numFields` should be greater than 0"},
+		{[]string{"HPERSIST", "hash", "FIELDS", "0"}, "ERR Parameter `numFields` should be greater than 0"},
+		{[]string{"HTTL", "hash", "FIELDS", "0"}, "ERR Parameter `numFields` should be greater than 0"},
+		{[]string{"HTTL", "hash", "FIELDS", "x", "a"}, "ERR Parameter `numFields` should be greater than 0"},
+	} {
+		if reply := client.do(t, tc.args...); fmt.Sprint(reply) != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.args, reply, tc.want)
+		}
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	_, addr := startTestServer(t)
+	subscriber, publisher := dialTestClient(t, addr), dialTestClient(t, addr)
+
+	if reply := subscriber.do(t, "SUBSCRIBE", "news", "sports"); fmt.Sprint(reply) != "[subscribe news 1]" {
+		t.Fatalf("SUBSCRIBE: got %v", reply)
+	}
+	if reply, _ := readReply(subscriber.r); fmt.Sprint(reply) != "[subscribe sports 2]" {
+		t.Fatalf("SUBSCRIBE: got %v", reply)
+	}
+	if reply := subscriber.do(t, "GET", "key"); fmt.Sprint(reply) != "ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context" {
+		t.Errorf("GET while subscribed: got %v", reply)
+	}
+	if reply := subscriber.do(t, "PING"); fmt.Sprint(reply) != "[pong ]" {
+		t.Errorf("PING while subscribed: got %v", reply)
+	}
+
+	// RESP3 clients receive push messages and may run any command.
+	patterns := dialTestClient(t, addr)
+	patterns.do(t, "HELLO", "3")
+	if reply := patterns.do(t, "PSUBSCRIBE", "n*"); fmt.Sprint(reply) != "[psubscribe n* 1]" {
+		t.Fatalf("PSUBSCRIBE: got %v", reply)
+	}
+	if reply := patterns.do(t, "GET", "key"); reply != nil {
+		t.Errorf("GET while subscribed with RESP3: got %v", reply)
+	}
+
+	if reply := publisher.do(t, "PUBLISH", "news", "hello"); reply != int64(2) {
+		t.Fatalf("PUBLISH: got %v", reply)
+	}
+	if reply, _ := readReply(subscriber.r); fmt.Sprint(reply) != "[message news hello]" {
+		t.Errorf("message: got %v", reply)
+	}
+	if reply, _ := readReply(patterns.r); fmt.Sprint(reply) != "[pmessage n* news hello]" {
+		t.Errorf("pmessage: got %v", reply)
+	}
+	if reply := publisher.do(t, "PUBLISH", "news/local/1", "hi"); reply != int64(1) {
+		t.Fatalf("PUBLISH to a channel with slashes: got %v", reply)
+	}
+	if reply, _ := readReply(patterns.r); fmt.Sprint(reply) != "[pmessage n* news/local/1 hi]" {
+		t.Errorf("pmessage: got %v", reply)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PUBSUB", "CHANNELS"}, "[news sports]"},
+		{[]string{"PUBSUB", "CHANNELS", "s*"}, "[sports]"},
+		{[]string{"PUBSUB", "NUMSUB", "news", "other"}, "[news 1 other 0]"},
+		{[]string{"PUBSUB", "NUMPAT"}, "1"},
+		{[]string{"PUBLISH", "other", "hello"}, "0"},
+	} {
+		if reply := publisher.do(t, tc.args...); fmt.Sprint(reply) != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.args, reply, tc.want)
+		}
+	}
+
+	if reply := subscriber.do(t, "UNSUBSCRIBE", "news"); fmt.Sprint(reply) != "[unsubscribe news 1]" {
+		t.Errorf("UNSUBSCRIBE: got %v", reply)
+	}
+	if reply := subscriber.do(t, "RESET"); reply != "RESET" {
+		t.Errorf("RESET: got %v", reply)
+	}
+	if reply := subscriber.do(t, "GET", "key"); reply != nil {
+		t.Errorf("GET after RESET: got %v", reply)
+	}
+	if reply := publisher.do(t, "PUBSUB", "NUMSUB", "sports"); fmt.Sprint(reply) != "[sports 0]" {
+		t.Errorf("PUBSUB NUMSUB after RESET: got %v", reply)
+	}
+}
+
+func TestStringMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, str string
+		nocase, want bool
+	}{
+		{"*", "a/b/c", false, true},
+		{"events*", "events/user/1", false, true},
+		{"a?c", "a/c", false, true},
+		{"h[ae]llo", "hello", false, true},
+		{"h[^e]llo", "hello", false, false},
+		{"h[a-b]llo", "hbllo", false, true},
+		{"h[b-a]llo", "hallo", false, true},
+		{`h\*llo`, "h*llo", false, true},
+		{`h\*llo`, "hello", false, false},
+		{"h[", "h[", false, false},
+		{"*a*b*c", "xaxbxc", false, true},
+		{"*a*b*c", "xaxbx", false, false},
+		{"MAX*", "maxmemory", true, true},
+		{"MAX*", "maxmemory", false, false},
+		{"", "", false, true},
+		{strings.Repeat("*a", 100) + "b", strings.Repeat("a", 100), false, false},
+	} {
+		if got := stringMatch(tc.pattern, tc.str, tc.nocase); got != tc.want {
+			t.Errorf("stringMatch(%q, %q, %v) = %v, want %v", tc.pattern, tc.str, tc.nocase, got, tc.want)
+		}
+	}
+
+	// CONFIG GET matches parameter names regardless of case.
+	_, addr := startTestServer(t)
+	client := dialTestClient(t, addr)
+	if reply := client.do(t, "CONFIG", "GET", "PROTO-*-LEN", "l?a-time-limit"); fmt.Sprint(reply) != "[proto-max-bulk-len 536870912 lua-time-limit 5000]" {
+		t.Errorf("CONFIG GET: got %v", reply)
+	}
+}
+
+func TestPubSubOutputBufferLimit(t *testing.T) {
+	_, addr := startTestServer(t)
+	subscriber, publisher := dialTestClient(t, addr), dialTestClient(t, addr)
+
+	publisher.do(t, "CONFIG", "SET", "client-output-buffer-limit", "pubsub 256kb 0 0")
+	subscriber.do(t, "SUBSCRIBE", "channel")
+	// The subscriber never reads, so its output piles up once the socket
+	// buffers are full.
+	message := strings.Repeat("x", 64*1024)
+	for i := 0; i < 2000; i++ {
+		if reply := publisher.do(t, "PUBLISH", "channel", message); reply == int64(0) {
+			return
+		}
+	}
+	t.Fatal("slow subscriber was not disconnected")
+}
@@ -0,0 +1,123 @@
+package server
+
+// maxMatchNesting bounds the recursion of stringMatch on patterns made of
+// many stars.
+const maxMatchNesting = 1000
+
+// stringMatch reports whether str matches the glob-style pattern the way
+// Redis matches key, channel and parameter names: * matches any sequence of
+// bytes, ? any single byte, [...] a set of bytes with ranges, negated by a
+// leading ^, and \ escapes the byte that follows. Unlike path globbing, no
+// separator is treated apart.
+func stringMatch(pattern, str string, nocase bool) bool {
+	skipLonger := false
+	return stringMatchNested(pattern, str, nocase, &skipLonger, 0)
+}
+
+// stringMatchNested matches str against pattern. skipLonger is set once a
+// star failed to match the rest of str at any offset, after which the stars
+// before it cannot match either.
+func stringMatchNested(pattern, str string, nocase bool, skipLonger *bool, nesting int) bool {
+	if nesting > maxMatchNesting {
+		return false
+	}
+	p, s := 0, 0
+	for p < len(pattern) && s < len(str) {
+		switch pattern[p] {
+		case '*':
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p++
+			}
+			if p+1 == len(pattern) {
+				return true
+			}
+			for ; s < len(str); s++ {
+				if stringMatchNested(pattern[p+1:], str[s:], nocase, skipLonger, nesting+1) {
+					return true
+				}
+				if *skipLonger {
+					return false
+				}
+			}
+			*skipLonger = true
+			return false
+		case '?':
+			s++
+		case '[':
+			p++
+			not := p < len(pattern) && pattern[p] == '^'
+			if not {
+				p++
+			}
+			match := false
+			for {
+				if p+1 < len(pattern) && pattern[p] == '\\' {
+					p++
+					if pattern[p] == str[s] {
+						match = true
+					}
+				} else if p == len(pattern) {
+					// An unterminated set runs to the end of the pattern.
+					p--
+					break
+				} else if pattern[p] == ']' {
+					break
+				} else if p+2 < len(pattern) && pattern[p+1] == '-' {
+					start, end, c := pattern[p], pattern[p+2], str[s]
+					if start > end {
+						start, end = end, start
+					}
+					if nocase {
+						start, end, c = toLower(start), toLower(end), toLower(c)
+					}
+					p += 2
+					if c >= start && c <= end {
+						match = true
+					}
+				} else if equalBytes(pattern[p], str[s], nocase) {
+					match = true
+				}
+				p++
+			}
+			if not {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			s++
+		case '\\':
+			if p+1 < len(pattern) {
+				p++
+			}
+			fallthrough
+		default:
+			if !equalBytes(pattern[p], str[s], nocase) {
+				return false
+			}
+			s++
+		}
+		p++
+		if s == len(str) {
+			for p < len(pattern) && pattern[p] == '*' {
+				p++
+			}
+			break
+		}
+	}
+	return p == len(pattern) && s == len(str)
+}
+
+func equalBytes(a, b byte, nocase bool) bool {
+	if nocase {
+		return toLower(a) == toLower(b)
+	}
+	return a == b
+}
+
+func toLower(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
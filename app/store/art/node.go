@@ -55,12 +55,7 @@ func createNode(nodeType NodeType, prefix []byte, value interface{}) *Node {
 		}
 
 	case Node48:
-		return &Node{
-			nodeType: Node48,
-			prefix:   prefix,
-			indexMap: [256]int8{-1},
-			children: make([]*Node, 0, 48),
-		}
+		return newNode48(prefix)
 
 	case Node256:
 		return &Node{
@@ -74,12 +69,28 @@ func createNode(nodeType NodeType, prefix []byte, value interface{}) *Node {
 	}
 }
 
+// newNode48 returns an empty Node48, whose indexMap marks every key as
+// missing.
+func newNode48(prefix []byte) *Node {
+	node := &Node{
+		nodeType: Node48,
+		prefix:   prefix,
+		children: make([]*Node, 0, Node48Max),
+	}
+	for i := range node.indexMap {
+		node.indexMap[i] = -1
+	}
+	return node
+}
+
 func (node *Node) addChild(key byte, child *Node) {
 	switch node.nodeType {
 	case Node4:
+		// Keys are kept sorted so that children are visited in key order.
 		if len(node.children) < 4 {
-			node.keys = append(node.keys, key)
-			node.children = append(node.children, child)
+			idx := findInsertPosition(node.keys, key)
+			node.keys = insertAt(node.keys, idx, key)
+			node.children = insertAt(node.children, idx, child)
 			return
 		}
 		node.resize(Node16)
@@ -96,7 +107,7 @@ func (node *Node) addChild(key byte, child *Node) {
 		node.addChild(key, child)
 
 	case Node48:
-		if node.indexMap[key] == -1 {
+		if len(node.children) < Node48Max {
 			node.indexMap[key] = int8(len(node.children))
 			node.children = append(node.children, child)
 			return
@@ -112,41 +123,144 @@ func (node *Node) addChild(key byte, child *Node) {
 }
 
 func (node *Node) resize(newType NodeType) {
-	switch newType {
+	keys, children := node.keyedChildren()
+	newNode := createNode(newType, node.prefix, nil)
+	for i, key := range keys {
+		newNode.addChild(key, children[i])
+	}
+	*node = *newNode
+}
+
+// childRef returns the slot holding the child of node for key, or nil if
+// there is none.
+func (node *Node) childRef(key byte) **Node {
+	switch node.nodeType {
+	case Node4:
+		for i, k := range node.keys {
+			if k == key {
+				return &node.children[i]
+			}
+		}
 	case Node16:
-		newNode := &Node{
-			nodeType: Node16,
-			prefix:   node.prefix,
-			keys:     make([]byte, 0, Node16Max),
-			children: make([]*Node, 0, Node16Max),
+		if idx := binarySearch(node.keys, key); idx != -1 {
+			return &node.children[idx]
+		}
+	case Node48:
+		if idx := node.indexMap[key]; idx != -1 {
+			return &node.children[idx]
+		}
+	case Node256:
+		if node.children[key] != nil {
+			return &node.children[key]
+		}
+	}
+	return nil
+}
+
+// removeChild removes the child of node for key. The node shrinks once its
+// children fill three quarters of the smaller type, so that alternating
+// inserts and deletes do not resize it every time.
+func (node *Node) removeChild(key byte) {
+	switch node.nodeType {
+	case Node4, Node16:
+		idx := findInsertPosition(node.keys, key)
+		if idx == len(node.keys) || node.keys[idx] != key {
+			return
 		}
-		for i, key := range node.keys {
-			newNode.addChild(key, node.children[i])
+		node.keys = append(node.keys[:idx], node.keys[idx+1:]...)
+		node.children = append(node.children[:idx], node.children[idx+1:]...)
+		if node.nodeType == Node16 && len(node.children) <= Node4Max*3/4 {
+			node.resize(Node4)
 		}
-		*node = *newNode
 	case Node48:
-		newNode := &Node{
-			nodeType: Node48,
-			prefix:   node.prefix,
-			indexMap: [256]int8{-1},
+		idx := node.indexMap[key]
+		if idx == -1 {
+			return
+		}
+		// Move the last child into the freed slot.
+		last := int8(len(node.children) - 1)
+		for k, i := range node.indexMap {
+			if i == last {
+				node.indexMap[k] = idx
+				break
+			}
 		}
-		for i, key := range node.keys {
-			newNode.addChild(key, node.children[i])
+		node.children[idx] = node.children[last]
+		node.children = node.children[:last]
+		node.indexMap[key] = -1
+		if len(node.children) <= Node16Max*3/4 {
+			node.resize(Node16)
 		}
-		*node = *newNode
 	case Node256:
-		newNode := &Node{
-			nodeType: Node256,
-			prefix:   node.prefix,
-			children: make([]*Node, 256),
+		node.children[key] = nil
+		if keys, _ := node.keyedChildren(); len(keys) <= Node48Max*3/4 {
+			node.resize(Node48)
+		}
+	}
+}
+
+// keyedChildren returns the children of node and their keys in key order.
+func (node *Node) keyedChildren() ([]byte, []*Node) {
+	var keys []byte
+	var children []*Node
+	switch node.nodeType {
+	case Node4, Node16:
+		return node.keys, node.children
+	case Node48:
+		for key, idx := range node.indexMap {
+			if idx != -1 {
+				keys = append(keys, byte(key))
+				children = append(children, node.children[idx])
+			}
 		}
-		for i, key := range node.keys {
-			newNode.children[key] = node.children[i]
+	case Node256:
+		for key, child := range node.children {
+			if child != nil {
+				keys = append(keys, byte(key))
+				children = append(children, child)
+			}
+		}
+	}
+	return keys, children
+}
+
+// eachChild calls fn for the children of node in key order until fn returns
+// false, and reports whether it never did.
+func (node *Node) eachChild(fn func(child *Node) bool) bool {
+	switch node.nodeType {
+	case Node4, Node16, Node256:
+		for _, child := range node.children {
+			if child != nil && !fn(child) {
+				return false
+			}
+		}
+	case Node48:
+		for _, idx := range node.indexMap {
+			if idx != -1 && !fn(node.children[idx]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// eachChildReverse is like eachChild, in reverse key order.
+func (node *Node) eachChildReverse(fn func(child *Node) bool) bool {
+	switch node.nodeType {
+	case Node4, Node16, Node256:
+		for i := len(node.children) - 1; i >= 0; i-- {
+			if child := node.children[i]; child != nil && !fn(child) {
+				return false
+			}
+		}
+	case Node48:
+		for key := len(node.indexMap) - 1; key >= 0; key-- {
+			if idx := node.indexMap[key]; idx != -1 && !fn(node.children[idx]) {
+				return false
+			}
 		}
-		*node = *newNode
-	default:
-		panic("Invalid node type for resize")
 	}
+	return true
 }
 
 func findInsertPosition(keys []byte, newKey byte) int {
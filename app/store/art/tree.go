@@ -11,6 +11,31 @@ func NewART() *ART {
 
 type ART struct {
 	root *Node
+	size int
+}
+
+// Len returns the number of keys in the tree.
+func (t *ART) Len() int {
+	return t.size
+}
+
+// NodeCount returns the number of nodes in the tree, leaves included.
+func (t *ART) NodeCount() int {
+	return countNodes(t.root)
+}
+
+func countNodes(node *Node) int {
+	if node == nil {
+		return 0
+	}
+	n := 1
+	if !node.isLeaf {
+		node.eachChild(func(child *Node) bool {
+			n += countNodes(child)
+			return true
+		})
+	}
+	return n
 }
 
 func (t *ART) Select(key []byte) (interface{}, bool) {
@@ -49,17 +74,21 @@ func (t *ART) Select(key []byte) (interface{}, bool) {
 	return nil, false
 }
 func (t *ART) Insert(key []byte, value interface{}) {
-	if t.root == nil {
-		t.root = createNode(NodeLeaf, key, value)
+	// ref is the slot holding node, so that it can be replaced when split.
+	ref := &t.root
+	if *ref == nil {
+		*ref = createNode(NodeLeaf, key, value)
+		t.size++
 		return
 	}
-	node := t.root
 
 	for {
+		node := *ref
 		// Compare prefix
 		keyIdx := findMismatchIndex(key, node.prefix)
 		if keyIdx != len(node.prefix) {
-			t.splitNode(node, keyIdx, key, value)
+			splitNode(ref, keyIdx, key, value)
+			t.size++
 			return
 		}
 
@@ -69,157 +98,167 @@ func (t *ART) Insert(key []byte, value interface{}) {
 				node.value = value
 				return
 			} else {
-				t.splitNode(node, len(node.prefix), key, value)
+				splitNode(ref, len(node.prefix), key, value)
+				t.size++
 				return
 			}
 		}
 
 		// Find or create next child
-		var nextKey byte
-		if keyIdx >= len(key) {
-			nextKey = byte(0)
-		} else {
-			nextKey = key[keyIdx]
-		}
-		nextNode := findNextNode(node, nextKey)
-		if nextNode == nil {
-			nextNode = createNode(NodeLeaf, key, value)
-			node.addChild(nextKey, nextNode)
+		nextKey := keyByteAt(key, keyIdx)
+		next := node.childRef(nextKey)
+		if next == nil {
+			node.addChild(nextKey, createNode(NodeLeaf, key, value))
+			t.size++
 			return
 		}
 
 		// Go to the next node
-		node = nextNode
+		ref = next
 	}
 }
 
-func (t *ART) Range(start, end []byte) map[string]interface{} {
-	if t.root == nil {
-		return nil
-	}
-
-	result := make(map[string]interface{})
-	t.rangeTraverse(t.root, start, end, result)
-	return result
-}
-
-func findNextNode(node *Node, nextKey byte) *Node {
-	var nextNode *Node
-	switch node.nodeType {
-	case Node4:
-		for i, k := range node.keys {
-			if k == nextKey {
-				nextNode = node.children[i]
-				break
+// Delete removes key from the tree and reports whether it was present.
+func (t *ART) Delete(key []byte) bool {
+	var parent *Node
+	var parentRef **Node
+	ref := &t.root
+	for *ref != nil {
+		node := *ref
+		if node.isLeaf {
+			if !bytes.Equal(key, node.prefix) {
+				return false
 			}
+			t.size--
+			if parent == nil {
+				t.root = nil
+				return true
+			}
+			parent.removeChild(keyByteAt(key, len(parent.prefix)))
+			// Prefixes hold the whole key, so a node left with a single
+			// child can be replaced by it.
+			if keys, children := parent.keyedChildren(); len(keys) == 1 {
+				*parentRef = children[0]
+			}
+			return true
 		}
-	case Node16:
-		idx := binarySearch(node.keys, nextKey)
-		if idx != -1 {
-			nextNode = node.children[idx]
+		if !matchesPrefix(key, node.prefix) {
+			return false
 		}
-	case Node48:
-		idx := node.indexMap[nextKey]
-		if idx != -1 {
-			nextNode = node.children[idx]
+		next := node.childRef(keyByteAt(key, len(node.prefix)))
+		if next == nil {
+			return false
 		}
-	case Node256:
-		nextNode = node.children[nextKey]
+		parent, parentRef, ref = node, ref, next
 	}
-	return nextNode
+	return false
 }
 
-func (t *ART) splitNode(oldNode *Node, splitIndex int, key []byte, value interface{}) {
-	newParent := createNode(Node4, oldNode.prefix[:splitIndex], nil)
-
-	newLeaf := createNode(NodeLeaf, key, value)
-
-	// Add oldNode to new parent
-	if oldNode.isLeaf {
-		if splitIndex >= len(oldNode.prefix) {
-			newParent.addChild(byte(0), oldNode)
-		} else {
-			newParent.addChild(oldNode.prefix[splitIndex], oldNode)
-		}
-	} else {
-		newParent.addChild(oldNode.prefix[splitIndex], oldNode)
-	}
-	if splitIndex >= len(key) {
-		newParent.addChild(byte(0), newLeaf)
-	} else {
-		newParent.addChild(key[splitIndex], newLeaf)
+// keyByteAt returns the byte of key that selects the child of a node whose
+// prefix is i bytes long. Keys that end at the node use byte 0.
+func keyByteAt(key []byte, i int) byte {
+	if i >= len(key) {
+		return 0
 	}
-
-	replaceNodeInTree(t, oldNode, newParent)
+	return key[i]
 }
 
-func replaceNodeInTree(tree *ART, oldNode, newNode *Node) {
-	if oldNode == tree.root {
-		tree.root = newNode
-		return
-	}
+// Range calls fn in key order for every key between start and end, both
+// included, until fn returns false.
+func (t *ART) Range(start, end []byte, fn func(key []byte, value interface{}) bool) {
+	rangeWalk(t.root, start, end, fn)
+}
 
-	parent := findParent(tree.root, oldNode)
-	if parent == nil {
-		panic("Parent not found!")
+// rangeWalk visits the keys of node between start and end in order. It
+// returns false once fn does or a key past end is found, which ends the walk.
+func rangeWalk(node *Node, start, end []byte, fn func(key []byte, value interface{}) bool) bool {
+	if node == nil {
+		return true
 	}
-
-	for i, child := range parent.children {
-		if child == oldNode {
-			parent.children[i] = newNode
-			return
+	if node.isLeaf {
+		if bytes.Compare(node.prefix, end) > 0 {
+			return false
+		}
+		if bytes.Compare(node.prefix, start) < 0 {
+			return true
 		}
+		return fn(node.prefix, node.value)
 	}
+	// Skip the subtrees that lie entirely outside the range.
+	if comparePrefix(node.prefix, end) > 0 {
+		return false
+	}
+	if comparePrefix(node.prefix, start) < 0 {
+		return true
+	}
+	return node.eachChild(func(child *Node) bool {
+		return rangeWalk(child, start, end, fn)
+	})
 }
 
-func findParent(node, target *Node) *Node {
-	if node == nil || node.isLeaf {
-		return nil
-	}
+// ReverseRange calls fn in reverse key order for every key between start and
+// end, both included, until fn returns false.
+func (t *ART) ReverseRange(start, end []byte, fn func(key []byte, value interface{}) bool) {
+	reverseRangeWalk(t.root, start, end, fn)
+}
 
-	for _, child := range node.children {
-		if child == target {
-			return node
+// reverseRangeWalk is like rangeWalk, visiting the keys from end to start.
+func reverseRangeWalk(node *Node, start, end []byte, fn func(key []byte, value interface{}) bool) bool {
+	if node == nil {
+		return true
+	}
+	if node.isLeaf {
+		if bytes.Compare(node.prefix, start) < 0 {
+			return false
 		}
-		if parent := findParent(child, target); parent != nil {
-			return parent
+		if bytes.Compare(node.prefix, end) > 0 {
+			return true
 		}
+		return fn(node.prefix, node.value)
 	}
-	return nil
+	if comparePrefix(node.prefix, start) < 0 {
+		return false
+	}
+	if comparePrefix(node.prefix, end) > 0 {
+		return true
+	}
+	return node.eachChildReverse(func(child *Node) bool {
+		return reverseRangeWalk(child, start, end, fn)
+	})
+}
+
+// Walk calls fn in key order for every key and value stored in the tree
+// until fn returns false.
+func (t *ART) Walk(fn func(key []byte, value interface{}) bool) {
+	walk(t.root, fn)
 }
 
-func (t *ART) rangeTraverse(node *Node, start, end []byte, result map[string]interface{}) {
+func walk(node *Node, fn func(key []byte, value interface{}) bool) bool {
 	if node == nil {
-		return
+		return true
 	}
-
-	// For leaf nodes, check if the complete key is within range
 	if node.isLeaf {
-		if inRange(node.prefix, start, end) {
-			result[string(node.prefix)] = node.value
-		}
-		return
+		return fn(node.prefix, node.value)
 	}
+	return node.eachChild(func(child *Node) bool {
+		return walk(child, fn)
+	})
+}
 
-	// Check node type and traverse children
-	switch node.nodeType {
-	case Node4, Node16:
-		for i := range node.keys {
-			t.rangeTraverse(node.children[i], start, end, result)
-		}
-
-	case Node48:
-		for i := byte(0); i < byte(255); i++ {
-			if idx := node.indexMap[i]; idx != -1 {
-				t.rangeTraverse(node.children[idx], start, end, result)
-			}
-		}
-
-	case Node256:
-		for i := byte(0); i < byte(255); i++ {
-			if node.children[i] != nil {
-				t.rangeTraverse(node.children[i], start, end, result)
-			}
-		}
+func findNextNode(node *Node, nextKey byte) *Node {
+	if ref := node.childRef(nextKey); ref != nil {
+		return *ref
 	}
+	return nil
+}
+
+// splitNode replaces the node held by ref with a Node4 holding the first
+// splitIndex bytes of its prefix, whose children are the old node and a new
+// leaf for key.
+func splitNode(ref **Node, splitIndex int, key []byte, value interface{}) {
+	oldNode := *ref
+	newParent := createNode(Node4, oldNode.prefix[:splitIndex], nil)
+	newParent.addChild(keyByteAt(oldNode.prefix, splitIndex), oldNode)
+	newParent.addChild(keyByteAt(key, splitIndex), createNode(NodeLeaf, key, value))
+	*ref = newParent
 }
@@ -3,6 +3,7 @@ package art_test
 import (
 	"bytes"
 	"fmt"
+	"slices"
 	"testing"
 
 	"math/rand/v2"
@@ -58,6 +59,11 @@ func TestART_PrefixHandling(t *testing.T) {
 			t.Errorf("Expected '%s', got %v", tc.value, value)
 		}
 	}
+
+	// The keys hang from a single inner node for their common prefix.
+	if n := tree.NodeCount(); n != 5 {
+		t.Errorf("Expected 5 nodes, got %d", n)
+	}
 }
 
 func TestART_PrefixEdgeCases(t *testing.T) {
@@ -283,44 +289,120 @@ func TestART_Range(t *testing.T) {
 	tests := []struct {
 		start    string
 		end      string
-		expected map[string]interface{}
+		expected []string
 	}{
-		{
-			start: "banana",
-			end:   "elderberry",
-			expected: map[string]interface{}{
-				"banana":     "2",
-				"cherry":     "3",
-				"date":       "4",
-				"elderberry": "5",
-			},
-		},
-		{
-			start: "apple",
-			end:   "cherry",
-			expected: map[string]interface{}{
-				"apple":  "1",
-				"banana": "2",
-				"cherry": "3",
-			},
-		},
+		{"banana", "elderberry", []string{"banana", "cherry", "date", "elderberry"}},
+		{"apple", "cherry", []string{"apple", "banana", "cherry"}},
+		{"b", "d", []string{"banana", "cherry"}},
+		{"fig", "zzz", []string{"fig"}},
+		{"g", "z", nil},
 	}
 
 	for _, test := range tests {
-		result := tree.Range([]byte(test.start), []byte(test.end))
-
-		if len(result) != len(test.expected) {
-			t.Errorf("Expected %d results, got %d for range [%s, %s]",
-				len(test.expected), len(result), test.start, test.end)
+		var result []string
+		tree.Range([]byte(test.start), []byte(test.end), func(key []byte, value interface{}) bool {
+			result = append(result, string(key))
+			return true
+		})
+		if !slices.Equal(result, test.expected) {
+			t.Errorf("Expected %v in range [%s, %s], got %v", test.expected, test.start, test.end, result)
 		}
+	}
+}
+
+func TestART_OrderedWalk(t *testing.T) {
+	tree := art.NewART()
+	// Enough children under one node to go through every node type.
+	var keys []string
+	for _, i := range rand.Perm(300) {
+		key := []byte{byte(i / 256), byte(i), 'x'}
+		tree.Insert(key, i)
+		keys = append(keys, string(key))
+	}
+	slices.Sort(keys)
+
+	var walked []string
+	tree.Walk(func(key []byte, value interface{}) bool {
+		walked = append(walked, string(key))
+		return true
+	})
+	if !slices.Equal(walked, keys) {
+		t.Fatalf("Expected the walk to visit %d keys in order, got %d", len(keys), len(walked))
+	}
+
+	var ranged []string
+	tree.Range([]byte{0, 40}, []byte{1, 10, 'y'}, func(key []byte, value interface{}) bool {
+		ranged = append(ranged, string(key))
+		return len(ranged) < 100
+	})
+	start := slices.Index(keys, string([]byte{0, 40, 'x'}))
+	if !slices.Equal(ranged, keys[start:start+100]) {
+		t.Errorf("Expected the range to stop after 100 keys in order, got %d", len(ranged))
+	}
+
+	var reversed []string
+	tree.ReverseRange([]byte{0, 40}, []byte{1, 10, 'y'}, func(key []byte, value interface{}) bool {
+		reversed = append(reversed, string(key))
+		return true
+	})
+	end := slices.Index(keys, string([]byte{1, 10, 'x'}))
+	want := slices.Clone(keys[start : end+1])
+	slices.Reverse(want)
+	if !slices.Equal(reversed, want) {
+		t.Errorf("Expected the reverse range to visit %d keys in reverse order, got %d", len(want), len(reversed))
+	}
+}
+
+func TestART_Delete(t *testing.T) {
+	tree := art.NewART()
+	present := make(map[string]bool)
+	for _, i := range rand.Perm(600) {
+		key := []byte{byte(i / 256), byte(i), 'x'}
+		tree.Insert(key, i)
+		present[string(key)] = true
+	}
+	if tree.Len() != len(present) {
+		t.Errorf("Expected %d keys, got %d", len(present), tree.Len())
+	}
+	if tree.Delete([]byte("missing")) {
+		t.Error("Expected deleting a missing key to report false")
+	}
 
-		for k, v := range test.expected {
-			if rv, exists := result[k]; !exists || rv != v {
-				t.Errorf("Expected %s=%v in range [%s, %s], got %v",
-					k, v, test.start, test.end, rv)
+	// Delete in random order so that nodes shrink and collapse, checking the
+	// remaining keys along the way.
+	for n, i := range rand.Perm(600) {
+		key := []byte{byte(i / 256), byte(i), 'x'}
+		if !tree.Delete(key) {
+			t.Fatalf("Expected key %v to be deleted", key)
+		}
+		if tree.Delete(key) {
+			t.Fatalf("Expected key %v to be gone", key)
+		}
+		delete(present, string(key))
+		if tree.Len() != len(present) {
+			t.Fatalf("Expected %d keys, got %d", len(present), tree.Len())
+		}
+		if n%50 != 0 {
+			continue
+		}
+		var walked []string
+		tree.Walk(func(key []byte, value interface{}) bool {
+			walked = append(walked, string(key))
+			return true
+		})
+		if len(walked) != len(present) || !slices.IsSorted(walked) {
+			t.Fatalf("Expected %d ordered keys after deleting %d, got %d", len(present), n+1, len(walked))
+		}
+		for _, key := range walked {
+			if _, found := tree.Select([]byte(key)); !present[key] || !found {
+				t.Fatalf("Unexpected key %v", []byte(key))
 			}
 		}
 	}
+	tree.Walk(func(key []byte, value interface{}) bool {
+		t.Errorf("Expected an empty tree, found %v", key)
+		return true
+	})
 }
 
 func BenchmarkART_Insert(b *testing.B) {
@@ -43,25 +43,19 @@ func findMismatchIndex(keyPart, prefix []byte) int {
 	return minLength
 }
 
-// inRange checks if key is between start and end (inclusive)
-func inRange(key, start, end []byte) bool {
-    return bytes.Compare(key, start) >= 0 && bytes.Compare(key, end) <= 0
-}
-
-// isPrefixInRange checks if any key with this prefix could be in range
-func isPrefixInRange(prefix, start, end []byte) bool {
-    // If prefix is shorter than start, check if it could lead to keys >= start
-    if len(prefix) < len(start) {
-        return bytes.Compare(prefix, start[:len(prefix)]) >= 0
-    }
-
-    // If prefix is shorter than end, check if it could lead to keys <= end
-    if len(prefix) < len(end) {
-        return bytes.Compare(prefix, end[:len(prefix)]) <= 0
-    }
-
-    // If prefix is longer or equal to both bounds, check if it's in range
-    return bytes.Compare(prefix, start) >= 0 && bytes.Compare(prefix, end) <= 0
+// comparePrefix compares the keys that start with prefix against bound. It
+// returns -1 if all of them sort before bound, 1 if all of them sort after it
+// and 0 otherwise.
+func comparePrefix(prefix, bound []byte) int {
+	n := min(len(prefix), len(bound))
+	if c := bytes.Compare(prefix[:n], bound[:n]); c != 0 {
+		return c
+	}
+	if len(prefix) > len(bound) {
+		// Every key extends bound.
+		return 1
+	}
+	return 0
 }
 
 // asciiPrint generates the ASCII representation with leaf highlighting and colors.
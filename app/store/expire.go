@@ -0,0 +1,107 @@
+package store
+
+import (
+	"time"
+)
+
+const (
+	// expireCycleKeysPerLoop is the number of volatile keys sampled per
+	// iteration of the active expire cycle.
+	expireCycleKeysPerLoop = 20
+	// expireCycleAcceptableStale is the percentage of expired keys in a sample
+	// below which the cycle stops repeating.
+	expireCycleAcceptableStale = 10
+)
+
+// setItem stores item at key, keeping the volatile key index in sync. The
+// caller must hold the write lock.
+func (s *InMemoryStore) setItem(key string, item Item) {
+	s.items[key] = item
+	if item.expiry > 0 {
+		s.volatile.add(key)
+	} else {
+		s.volatile.remove(key)
+	}
+	if h, ok := item.value.(*HashValue); ok {
+		s.trackFieldExpiry(key, h)
+	} else {
+		s.volatileHashes.remove(key)
+	}
+}
+
+// deleteItem removes key, keeping the volatile key index in sync. The caller
+// must hold the write lock.
+func (s *InMemoryStore) deleteItem(key string) {
+	delete(s.items, key)
+	s.volatile.remove(key)
+	s.volatileHashes.remove(key)
+}
+
+// ActiveExpireCycle samples random keys with an expiry and deletes the ones
+// that have expired. Sampling repeats while more than 10% of a sample was
+// expired, until deadline. The lock is only held for one sample at a time so
+// clients are not stalled by large keyspaces. It returns the number of keys
+// sampled and expired.
+func (s *InMemoryStore) ActiveExpireCycle(deadline time.Time) (sampled, expired int) {
+	for iteration := 0; ; iteration++ {
+		s.mu.Lock()
+		n := min(len(s.volatile.keys), expireCycleKeysPerLoop)
+		now := time.Now().UnixMilli()
+		loopExpired := 0
+		for i := 0; i < n; i++ {
+			key := s.volatile.random()
+			if s.items[key].expired(now) {
+				s.deleteItem(key)
+				loopExpired++
+			}
+		}
+		s.mu.Unlock()
+
+		sampled += n
+		expired += loopExpired
+		if n == 0 || loopExpired*100/n <= expireCycleAcceptableStale {
+			return sampled, expired
+		}
+		// Checking the clock is comparatively expensive, so only do it
+		// every few iterations like Redis does.
+		if iteration%16 == 15 && time.Now().After(deadline) {
+			return sampled, expired
+		}
+	}
+}
+
+// ActiveExpireFields samples random hashes with expiring fields and deletes
+// the fields that have expired, deleting hashes left empty. Like
+// ActiveExpireCycle, sampling repeats while more than 10% of a sample had
+// expired fields, until deadline. It returns the number of fields expired.
+func (s *InMemoryStore) ActiveExpireFields(deadline time.Time) (expired int) {
+	for iteration := 0; ; iteration++ {
+		s.mu.Lock()
+		n := min(len(s.volatileHashes.keys), expireCycleKeysPerLoop)
+		now := time.Now().UnixMilli()
+		stale := 0
+		for i := 0; i < n; i++ {
+			key := s.volatileHashes.random()
+			h := s.items[key].value.(*HashValue)
+			fields := h.expireFields(now)
+			if fields == 0 {
+				continue
+			}
+			stale++
+			expired += fields
+			if h.Len() == 0 {
+				s.deleteItem(key)
+			} else {
+				s.trackFieldExpiry(key, h)
+			}
+		}
+		s.mu.Unlock()
+
+		if n == 0 || stale*100/n <= expireCycleAcceptableStale {
+			return expired
+		}
+		if iteration%16 == 15 && time.Now().After(deadline) {
+			return expired
+		}
+	}
+}
@@ -0,0 +1,534 @@
+package store
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// HashValue maps fields to values. Fields may have their own expiry, which
+// is checked whenever the hash is accessed.
+type HashValue struct {
+	fields map[string][]byte
+	// expires holds the unix time in milliseconds at which the fields with
+	// an expiry expire.
+	expires map[string]int64
+	// nextExpiry is a lower bound of the earliest field expiry, or 0 if no
+	// field has one, so accesses only scan expires when a field is due.
+	nextExpiry int64
+}
+
+func newHashValue() *HashValue {
+	return &HashValue{fields: make(map[string][]byte)}
+}
+
+func (_ *HashValue) Type() Type {
+	return HashType
+}
+
+func (_ *HashValue) encoding() string {
+	return "hashtable"
+}
+
+func (h *HashValue) clone() Value {
+	c := &HashValue{
+		fields:     make(map[string][]byte, len(h.fields)),
+		nextExpiry: h.nextExpiry,
+	}
+	for field, value := range h.fields {
+		c.fields[field] = bytes.Clone(value)
+	}
+	if len(h.expires) > 0 {
+		c.expires = make(map[string]int64, len(h.expires))
+		for field, at := range h.expires {
+			c.expires[field] = at
+		}
+	}
+	return c
+}
+
+func (h *HashValue) Len() int {
+	return len(h.fields)
+}
+
+// get returns the value of field. It may be called on a nil hash.
+func (h *HashValue) get(field string) ([]byte, bool) {
+	if h == nil {
+		return nil, false
+	}
+	value, ok := h.fields[field]
+	return value, ok
+}
+
+// set stores value in field, clearing its expiry, and reports whether the
+// field is new.
+func (h *HashValue) set(field string, value []byte) bool {
+	_, exists := h.fields[field]
+	h.fields[field] = value
+	h.persist(field)
+	return !exists
+}
+
+// del removes field and reports whether it existed.
+func (h *HashValue) del(field string) bool {
+	if _, ok := h.fields[field]; !ok {
+		return false
+	}
+	delete(h.fields, field)
+	h.persist(field)
+	return true
+}
+
+// expire sets the expiry of field to the unix time at, in milliseconds.
+func (h *HashValue) expire(field string, at int64) {
+	if h.expires == nil {
+		h.expires = make(map[string]int64)
+	}
+	h.expires[field] = at
+	if h.nextExpiry == 0 || at < h.nextExpiry {
+		h.nextExpiry = at
+	}
+}
+
+// persist removes the expiry of field and reports whether it had one.
+func (h *HashValue) persist(field string) bool {
+	if _, ok := h.expires[field]; !ok {
+		return false
+	}
+	delete(h.expires, field)
+	if len(h.expires) == 0 {
+		h.nextExpiry = 0
+	}
+	return true
+}
+
+// expireFields deletes the fields that expired at now and returns how many.
+func (h *HashValue) expireFields(now int64) int {
+	if h.nextExpiry == 0 || now <= h.nextExpiry {
+		return 0
+	}
+	expired := 0
+	h.nextExpiry = 0
+	for field, at := range h.expires {
+		if now > at {
+			delete(h.fields, field)
+			delete(h.expires, field)
+			expired++
+		} else if h.nextExpiry == 0 || at < h.nextExpiry {
+			h.nextExpiry = at
+		}
+	}
+	return expired
+}
+
+// trackFieldExpiry keeps the index of hashes with expiring fields in sync
+// after the expiries of h changed. The caller must hold the write lock.
+func (s *InMemoryStore) trackFieldExpiry(key string, h *HashValue) {
+	if len(h.expires) > 0 {
+		s.volatileHashes.add(key)
+	} else {
+		s.volatileHashes.remove(key)
+	}
+}
+
+// lookupHash returns the hash stored at key, or nil if it does not exist. It
+// fails with ErrWrongType when the key holds another type. Expired fields are
+// deleted first, so the caller must hold the write lock.
+func (s *InMemoryStore) lookupHash(key string) (*HashValue, error) {
+	item, ok := s.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	h, ok := item.value.(*HashValue)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	if h.expireFields(time.Now().UnixMilli()) > 0 {
+		if h.Len() == 0 {
+			s.deleteItem(key)
+			return nil, nil
+		}
+		s.trackFieldExpiry(key, h)
+	}
+	return h, nil
+}
+
+// lookupOrCreateHash returns the hash stored at key, creating an empty one if
+// it does not exist. The caller must hold the write lock.
+func (s *InMemoryStore) lookupOrCreateHash(key string) (*HashValue, error) {
+	h, err := s.lookupHash(key)
+	if err != nil || h != nil {
+		return h, err
+	}
+	h = newHashValue()
+	s.setItem(key, Item{value: h})
+	return h, nil
+}
+
+// hashStore replaces the value of field in h, which is stored at key or nil
+// if the key does not exist yet, keeping the expiry of the field. The caller
+// must hold the write lock.
+func (s *InMemoryStore) hashStore(key string, h *HashValue, field string, value []byte) {
+	if h == nil {
+		h = newHashValue()
+		s.setItem(key, Item{value: h})
+	}
+	h.fields[field] = value
+}
+
+// HashSet stores the given field and value pairs in the hash at key, creating
+// it if needed, and returns the number of fields that were added. Overwritten
+// fields lose their expiry.
+func (s *InMemoryStore) HashSet(key string, pairs [][]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupOrCreateHash(key)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if h.set(string(pairs[i]), bytes.Clone(pairs[i+1])) {
+			added++
+		}
+	}
+	s.trackFieldExpiry(key, h)
+	return added, nil
+}
+
+// HashSetNX stores value in field of the hash at key unless the field exists.
+// It reports whether the field was set.
+func (s *InMemoryStore) HashSetNX(key string, field, value []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupOrCreateHash(key)
+	if err != nil {
+		return false, err
+	}
+	if _, exists := h.fields[string(field)]; exists {
+		return false, nil
+	}
+	h.set(string(field), bytes.Clone(value))
+	return true, nil
+}
+
+// HashGet returns the value of field in the hash at key.
+func (s *InMemoryStore) HashGet(key string, field []byte) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if h == nil {
+		return nil, false, err
+	}
+	value, ok := h.fields[string(field)]
+	return value, ok, nil
+}
+
+// HashMGet returns the values of fields in the hash at key, with nil for the
+// fields that do not exist.
+func (s *InMemoryStore) HashMGet(key string, fields [][]byte) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(fields))
+	if h != nil {
+		for i, field := range fields {
+			values[i] = h.fields[string(field)]
+		}
+	}
+	return values, nil
+}
+
+// HashDelete removes fields from the hash at key and returns how many
+// existed. Hashes left empty are deleted.
+func (s *InMemoryStore) HashDelete(key string, fields [][]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if h == nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, field := range fields {
+		if h.del(string(field)) {
+			deleted++
+		}
+	}
+	if h.Len() == 0 {
+		s.deleteItem(key)
+	} else {
+		s.trackFieldExpiry(key, h)
+	}
+	return deleted, nil
+}
+
+// HashLen returns the number of fields of the hash at key.
+func (s *InMemoryStore) HashLen(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if h == nil {
+		return 0, err
+	}
+	return h.Len(), nil
+}
+
+// HashExists reports whether field exists in the hash at key.
+func (s *InMemoryStore) HashExists(key string, field []byte) (bool, error) {
+	_, ok, err := s.HashGet(key, field)
+	return ok, err
+}
+
+// HashStrLen returns the length of the value of field in the hash at key, or
+// 0 if it does not exist.
+func (s *InMemoryStore) HashStrLen(key string, field []byte) (int, error) {
+	value, _, err := s.HashGet(key, field)
+	return len(value), err
+}
+
+// HashGetAll returns the fields of the hash at key and their values as
+// alternating field and value pairs.
+func (s *InMemoryStore) HashGetAll(key string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if h == nil {
+		return nil, err
+	}
+	pairs := make([][]byte, 0, 2*h.Len())
+	for field, value := range h.fields {
+		pairs = append(pairs, []byte(field), value)
+	}
+	return pairs, nil
+}
+
+// HashIncrBy atomically adds delta to the integer stored in field of the hash
+// at key, treating a missing field as 0, and returns the new value. The
+// expiry of the field is kept.
+func (s *InMemoryStore) HashIncrBy(key string, field []byte, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if err != nil {
+		return 0, err
+	}
+	var value int64
+	if data, exists := h.get(string(field)); exists {
+		value, err = strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return 0, ErrHashNotInteger
+		}
+	}
+	if (delta < 0 && value < math.MinInt64-delta) || (delta > 0 && value > math.MaxInt64-delta) {
+		return 0, ErrOverflow
+	}
+	value += delta
+	s.hashStore(key, h, string(field), strconv.AppendInt(nil, value, 10))
+	return value, nil
+}
+
+// HashIncrByFloat atomically adds delta to the number stored in field of the
+// hash at key, treating a missing field as 0, and returns the new value as
+// stored. The expiry of the field is kept.
+func (s *InMemoryStore) HashIncrByFloat(key string, field []byte, delta float64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if err != nil {
+		return nil, err
+	}
+	var value float64
+	if data, exists := h.get(string(field)); exists {
+		value, err = strconv.ParseFloat(string(data), 64)
+		if err != nil || math.IsNaN(value) {
+			return nil, ErrHashNotFloat
+		}
+	}
+	value += delta
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, ErrNaNOrInf
+	}
+	data := strconv.AppendFloat(nil, value, 'f', -1, 64)
+	s.hashStore(key, h, string(field), data)
+	return data, nil
+}
+
+// HashRandFields returns up to count random fields of the hash at key and
+// their values as alternating field and value pairs. Without repeat every
+// field is returned at most once, otherwise exactly count fields are picked
+// independently.
+func (s *InMemoryStore) HashRandFields(key string, count int, repeat bool) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if h == nil {
+		return nil, err
+	}
+	fields := make([]string, 0, h.Len())
+	for field := range h.fields {
+		fields = append(fields, field)
+	}
+	if repeat {
+		pairs := make([][]byte, 0, 2*count)
+		for i := 0; i < count; i++ {
+			field := fields[rand.Intn(len(fields))]
+			pairs = append(pairs, []byte(field), h.fields[field])
+		}
+		return pairs, nil
+	}
+	count = min(count, len(fields))
+	pairs := make([][]byte, 0, 2*count)
+	// A partial Fisher-Yates shuffle picks count distinct fields.
+	for i := 0; i < count; i++ {
+		j := i + rand.Intn(len(fields)-i)
+		fields[i], fields[j] = fields[j], fields[i]
+		pairs = append(pairs, []byte(fields[i]), h.fields[fields[i]])
+	}
+	return pairs, nil
+}
+
+// HashScan returns at least count fields of the hash at key, with their
+// values, starting at cursor, and the cursor to continue from, which is 0 once
+// the scan is complete. Every field present during the whole scan is returned
+// at least once, however the hash is modified in between.
+func (s *InMemoryStore) HashScan(key string, cursor uint64, count int) (uint64, [][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if h == nil {
+		return 0, nil, err
+	}
+	next, fields := scanElements(func(yield func(string)) {
+		for field := range h.fields {
+			yield(field)
+		}
+	}, cursor, count)
+	pairs := make([][]byte, 0, 2*len(fields))
+	for _, field := range fields {
+		pairs = append(pairs, []byte(field), h.fields[field])
+	}
+	return next, pairs, nil
+}
+
+// HashExpire sets the expiry of fields of the hash at key to the unix time
+// at, in milliseconds, when cond allows it. An expiry in the past deletes the
+// fields, and hashes left empty are deleted. For each field it returns -2 if
+// the field does not exist, 0 if cond was not met, 1 if the expiry was set and
+// 2 if the field was deleted.
+func (s *InMemoryStore) HashExpire(key string, fields [][]byte, at int64, cond ExpireCondition) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]int, len(fields))
+	now := time.Now().UnixMilli()
+	for i, f := range fields {
+		field := string(f)
+		if h == nil {
+			results[i] = -2
+			continue
+		}
+		if _, ok := h.fields[field]; !ok {
+			results[i] = -2
+			continue
+		}
+		current := h.expires[field]
+		if (cond&ExpireNX != 0 && current != 0) ||
+			(cond&ExpireXX != 0 && current == 0) ||
+			(cond&ExpireGT != 0 && (current == 0 || at <= current)) ||
+			(cond&ExpireLT != 0 && current != 0 && at >= current) {
+			continue
+		}
+		if at <= now {
+			h.del(field)
+			results[i] = 2
+			continue
+		}
+		h.expire(field, at)
+		results[i] = 1
+	}
+	if h != nil {
+		if h.Len() == 0 {
+			s.deleteItem(key)
+		} else {
+			s.trackFieldExpiry(key, h)
+		}
+	}
+	return results, nil
+}
+
+// HashExpireTime returns for each of fields of the hash at key the unix time
+// in milliseconds at which it expires, -1 if it has no expiry or -2 if it does
+// not exist.
+func (s *InMemoryStore) HashExpireTime(key string, fields [][]byte) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]int64, len(fields))
+	for i, f := range fields {
+		field := string(f)
+		if h == nil {
+			results[i] = -2
+		} else if _, ok := h.fields[field]; !ok {
+			results[i] = -2
+		} else if at, ok := h.expires[field]; ok {
+			results[i] = at
+		} else {
+			results[i] = -1
+		}
+	}
+	return results, nil
+}
+
+// HashPersist removes the expiry of fields of the hash at key. For each field
+// it returns 1 if the expiry was removed, -1 if it had none or -2 if it does
+// not exist.
+func (s *InMemoryStore) HashPersist(key string, fields [][]byte) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.lookupHash(key)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]int, len(fields))
+	for i, f := range fields {
+		field := string(f)
+		if h == nil {
+			results[i] = -2
+		} else if _, ok := h.fields[field]; !ok {
+			results[i] = -2
+		} else if h.persist(field) {
+			results[i] = 1
+		} else {
+			results[i] = -1
+		}
+	}
+	if h != nil {
+		s.trackFieldExpiry(key, h)
+	}
+	return results, nil
+}
@@ -0,0 +1,224 @@
+package store_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/persistence"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+func fieldArgs(fields ...string) [][]byte {
+	args := make([][]byte, len(fields))
+	for i, field := range fields {
+		args[i] = []byte(field)
+	}
+	return args
+}
+
+func TestHash_SetGetDelete(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+
+	added, err := IMstore.HashSet("hash", fieldArgs("a", "1", "b", "2", "a", "3"))
+	if err != nil || added != 2 {
+		t.Fatalf("Expected 2 fields to be added, got %d, %v", added, err)
+	}
+	if value, ok, _ := IMstore.HashGet("hash", []byte("a")); !ok || string(value) != "3" {
+		t.Errorf("Expected the last value of a field to win, got %q", value)
+	}
+	if set, _ := IMstore.HashSetNX("hash", []byte("b"), []byte("x")); set {
+		t.Error("Expected HashSetNX not to overwrite an existing field")
+	}
+	values, _ := IMstore.HashMGet("hash", fieldArgs("b", "missing"))
+	if string(values[0]) != "2" || values[1] != nil {
+		t.Errorf("Unexpected HashMGet result %q", values)
+	}
+	if n, _ := IMstore.HashDelete("hash", fieldArgs("a", "b", "missing")); n != 2 {
+		t.Errorf("Expected 2 fields to be deleted, got %d", n)
+	}
+	if IMstore.Exists("hash") != 0 {
+		t.Error("Expected the hash to be deleted with its last field")
+	}
+
+	IMstore.Set("string", []byte("value"), 0)
+	if _, err := IMstore.HashSet("string", fieldArgs("a", "1")); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestHash_Incr(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+
+	if value, err := IMstore.HashIncrBy("hash", []byte("n"), 5); err != nil || value != 5 {
+		t.Fatalf("Expected 5, got %d, %v", value, err)
+	}
+	if value, err := IMstore.HashIncrByFloat("hash", []byte("n"), 0.5); err != nil || string(value) != "5.5" {
+		t.Fatalf("Expected 5.5, got %s, %v", value, err)
+	}
+	if _, err := IMstore.HashIncrBy("hash", []byte("n"), 1); err != store.ErrHashNotInteger {
+		t.Errorf("Expected ErrHashNotInteger, got %v", err)
+	}
+	IMstore.HashSet("hash", fieldArgs("s", "abc"))
+	if _, err := IMstore.HashIncrByFloat("hash", []byte("s"), 1); err != store.ErrHashNotFloat {
+		t.Errorf("Expected ErrHashNotFloat, got %v", err)
+	}
+}
+
+func TestHash_FieldExpiry(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.HashSet("hash", fieldArgs("a", "1", "b", "2", "c", "3"))
+	now := time.Now().UnixMilli()
+
+	results, _ := IMstore.HashExpire("hash", fieldArgs("a", "b", "missing"), now+60_000, store.ExpireAlways)
+	if !reflect.DeepEqual(results, []int{1, 1, -2}) {
+		t.Errorf("Unexpected HashExpire results %v", results)
+	}
+	results, _ = IMstore.HashExpire("hash", fieldArgs("a", "c"), now+30_000, store.ExpireGT)
+	if !reflect.DeepEqual(results, []int{0, 0}) {
+		t.Errorf("Expected GT to reject a smaller expiry and fields without one, got %v", results)
+	}
+	expiries, _ := IMstore.HashExpireTime("hash", fieldArgs("a", "c", "missing"))
+	if !reflect.DeepEqual(expiries, []int64{now + 60_000, -1, -2}) {
+		t.Errorf("Unexpected HashExpireTime results %v", expiries)
+	}
+	results, _ = IMstore.HashPersist("hash", fieldArgs("b", "c"))
+	if !reflect.DeepEqual(results, []int{1, -1}) {
+		t.Errorf("Unexpected HashPersist results %v", results)
+	}
+
+	// Overwriting a field clears its expiry, incrementing it does not.
+	IMstore.HashIncrBy("hash", []byte("a"), 1)
+	if expiries, _ := IMstore.HashExpireTime("hash", fieldArgs("a")); expiries[0] != now+60_000 {
+		t.Errorf("Expected HashIncrBy to keep the expiry, got %v", expiries)
+	}
+	IMstore.HashSet("hash", fieldArgs("a", "x"))
+	if expiries, _ := IMstore.HashExpireTime("hash", fieldArgs("a")); expiries[0] != -1 {
+		t.Errorf("Expected HashSet to clear the expiry, got %v", expiries)
+	}
+
+	results, _ = IMstore.HashExpire("hash", fieldArgs("a", "b"), now-1, store.ExpireAlways)
+	if !reflect.DeepEqual(results, []int{2, 2}) {
+		t.Errorf("Expected an expiry in the past to delete the fields, got %v", results)
+	}
+	results, _ = IMstore.HashExpire("hash", fieldArgs("c"), now-1, store.ExpireAlways)
+	if !reflect.DeepEqual(results, []int{2}) || IMstore.Exists("hash") != 0 {
+		t.Errorf("Expected the hash to be deleted with its last field, got %v", results)
+	}
+	if results, _ = IMstore.HashExpire("hash", fieldArgs("a"), now, store.ExpireAlways); results[0] != -2 {
+		t.Errorf("Expected -2 for a missing key, got %v", results)
+	}
+}
+
+func TestHash_ExpiredFieldsAreRemoved(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.HashSet("lazy", fieldArgs("a", "1", "b", "2"))
+	IMstore.HashSet("active", fieldArgs("a", "1"))
+	for i := 0; i < 10; i++ {
+		IMstore.HashSet(fmt.Sprint("other", i), fieldArgs("a", "1"))
+	}
+	at := time.Now().UnixMilli() + 10
+	IMstore.HashExpire("lazy", fieldArgs("a"), at, store.ExpireAlways)
+	IMstore.HashExpire("active", fieldArgs("a"), at, store.ExpireAlways)
+	time.Sleep(20 * time.Millisecond)
+
+	if n, _ := IMstore.HashLen("lazy"); n != 1 {
+		t.Errorf("Expected the expired field to be gone, got %d fields", n)
+	}
+	if expired := IMstore.ActiveExpireFields(time.Now().Add(time.Second)); expired != 1 {
+		t.Errorf("Expected the active cycle to expire 1 field, got %d", expired)
+	}
+	if IMstore.Exists("active") != 0 {
+		t.Error("Expected the hash left empty by the active cycle to be deleted")
+	}
+}
+
+func TestHash_Scan(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	var fields []string
+	for i := 0; i < 100; i++ {
+		fields = append(fields, fmt.Sprint("field", i))
+		IMstore.HashSet("hash", fieldArgs(fields[i], "v"))
+	}
+
+	// Fields deleted or added during the scan may or may not be returned,
+	// the others exactly once.
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for calls := 0; ; calls++ {
+		next, pairs, err := IMstore.HashScan("hash", cursor, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			seen[string(pairs[i])]++
+		}
+		if calls == 3 {
+			IMstore.HashDelete("hash", fieldArgs("field0", "field1"))
+			IMstore.HashSet("hash", fieldArgs("new", "v"))
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	for _, field := range fields[2:] {
+		if seen[field] != 1 {
+			t.Errorf("Expected %s to be returned once, got %d", field, seen[field])
+		}
+	}
+}
+
+func TestHash_RandFields(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.HashSet("hash", fieldArgs("a", "1", "b", "2", "c", "3"))
+
+	pairs, _ := IMstore.HashRandFields("hash", 10, false)
+	var fields []string
+	for i := 0; i < len(pairs); i += 2 {
+		fields = append(fields, string(pairs[i]))
+	}
+	slices.Sort(fields)
+	if !slices.Equal(fields, []string{"a", "b", "c"}) {
+		t.Errorf("Expected every field once, got %v", fields)
+	}
+	if pairs, _ := IMstore.HashRandFields("hash", 10, true); len(pairs) != 20 {
+		t.Errorf("Expected 10 fields with repetition, got %d", len(pairs)/2)
+	}
+}
+
+func TestHash_ExportAndLoad(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.HashSet("hash", fieldArgs("a", "1", "b", ""))
+	IMstore.HashSet("volatile", fieldArgs("a", "1", "b", "2"))
+	at := time.Now().UnixMilli() + 60_000
+	IMstore.HashExpire("volatile", fieldArgs("a"), at, store.ExpireAlways)
+
+	var buf bytes.Buffer
+	for _, entry := range IMstore.Export() {
+		if err := persistence.WriteKeyValue(&buf, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var entries []persistence.Entry
+	for buf.Len() > 0 {
+		entry, err := persistence.ReadKeyValue(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	loaded := store.NewInMemoryStore()
+	loaded.Load(entries)
+
+	if value, ok, _ := loaded.HashGet("hash", []byte("b")); !ok || len(value) != 0 {
+		t.Errorf("Expected the empty field to survive a round trip, got %q, %v", value, ok)
+	}
+	expiries, _ := loaded.HashExpireTime("volatile", fieldArgs("a", "b"))
+	if !reflect.DeepEqual(expiries, []int64{at, -1}) {
+		t.Errorf("Expected the field expiries to survive a round trip, got %v", expiries)
+	}
+}
@@ -1,7 +1,10 @@
 package store
 
 import (
+	"bytes"
+	"errors"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -10,9 +13,68 @@ import (
 
 type Type string
 
+// ExpireCondition restricts when Expire updates the expiry of a key. XX may
+// be combined with GT or LT.
+type ExpireCondition int
+
+const (
+	ExpireAlways ExpireCondition = 0
+	// ExpireNX only sets an expiry on keys that have none.
+	ExpireNX ExpireCondition = 1 << iota
+	// ExpireXX only sets an expiry on keys that already have one.
+	ExpireXX
+	// ExpireGT only sets an expiry greater than the current one.
+	ExpireGT
+	// ExpireLT only sets an expiry less than the current one.
+	ExpireLT
+)
+
+var (
+	ErrNoSuchKey  = errors.New("ERR no such key")
+	ErrSameObject = errors.New("ERR source and destination objects are the same")
+	ErrWrongType  = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+	ErrNotFloat   = errors.New("ERR value is not a valid float")
+	ErrOverflow   = errors.New("ERR increment or decrement would overflow")
+	ErrNaNOrInf   = errors.New("ERR increment would produce NaN or Infinity")
+
+	ErrHashNotInteger = errors.New("ERR hash value is not an integer")
+	ErrHashNotFloat   = errors.New("ERR hash value is not a float")
+
+	ErrIndexOutOfRange = errors.New("ERR index out of range")
+)
+
+// SetOptions controls how SetWithOptions stores a string.
+type SetOptions struct {
+	// ExpireAt is the unix time in milliseconds at which the key expires, or
+	// 0 for no expiry.
+	ExpireAt int64
+	// KeepTTL retains the expiry of the existing key.
+	KeepTTL bool
+	// NX only sets keys that do not exist and XX only keys that do.
+	NX, XX bool
+	// Get returns the previous value, which must be a string.
+	Get bool
+}
+
+// SetResult describes the outcome of SetWithOptions.
+type SetResult struct {
+	// Set reports whether the value was stored.
+	Set bool
+	// Exists reports whether the key existed. Old holds its previous value
+	// when SetOptions.Get was requested.
+	Exists bool
+	Old    []byte
+}
+
 const (
 	StringType Type = "string"
 	StreamType Type = "stream"
+	ListType   Type = "list"
+	HashType   Type = "hash"
+	SetType    Type = "set"
+	// SortedSetType is reported as zset, as in Redis.
+	SortedSetType Type = "zset"
 )
 
 type StringValue struct {
@@ -23,8 +85,31 @@ func (_ StringValue) Type() Type {
 	return StringType
 }
 
+func (v StringValue) clone() Value {
+	return StringValue{data: bytes.Clone(v.data)}
+}
+
+// encoding follows Redis, which stores short strings along with their object
+// and strings that look like integers as integers.
+func (v StringValue) encoding() string {
+	if len(v.data) <= 20 {
+		if n, err := strconv.ParseInt(string(v.data), 10, 64); err == nil && strconv.FormatInt(n, 10) == string(v.data) {
+			return "int"
+		}
+	}
+	if len(v.data) <= 44 {
+		return "embstr"
+	}
+	return "raw"
+}
+
 type Value interface {
 	Type() Type
+	// clone returns a deep copy of the value.
+	clone() Value
+	// encoding returns the name of the internal representation of the
+	// value, as reported by OBJECT ENCODING.
+	encoding() string
 }
 
 type Item struct {
@@ -34,20 +119,31 @@ type Item struct {
 
 type InMemoryStore struct {
 	items map[string]Item
-	mu    sync.RWMutex
+	// volatile indexes the keys that have an expiry.
+	volatile indexedSet
+	// volatileHashes indexes the hashes that have fields with an expiry.
+	volatileHashes indexedSet
+	mu             sync.RWMutex
 }
 
 func NewInMemoryStore() *InMemoryStore {
-	store := &InMemoryStore{
-		items: make(map[string]Item, 0),
+	return &InMemoryStore{
+		items:          make(map[string]Item, 0),
+		volatile:       newIndexedSet(),
+		volatileHashes: newIndexedSet(),
 	}
-	go store.cleanupExpiredItems()
-	return store
 }
 
 func (s *InMemoryStore) Keys(pattern string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UnixMilli()
 	keys := make([]string, 0)
-	for k := range s.items {
+	for k, item := range s.items {
+		if item.expired(now) {
+			continue
+		}
 		ok, err := filepath.Match(pattern, k)
 		if err != nil {
 			return nil, err
@@ -59,18 +155,28 @@ func (s *InMemoryStore) Keys(pattern string) ([]string, error) {
 	return keys, nil
 }
 
-func (s *InMemoryStore) Get(key string) ([]byte, bool) {
-	s.mu.RLock()
-	item, ok := s.items[key]
-	s.mu.RUnlock()
+func (i Item) expired(now int64) bool {
+	return i.expiry > 0 && now > i.expiry
+}
 
-	if !ok || (item.expiry > 0 && time.Now().UnixMilli() > item.expiry) {
-		return nil, false
-	}
-	if item.value.Type() == StringType {
-		return item.value.(StringValue).data, true
+// lookup returns the item stored at key unless it is missing or expired.
+// The caller must hold the lock.
+func (s *InMemoryStore) lookup(key string) (Item, bool) {
+	item, ok := s.items[key]
+	if !ok || item.expired(time.Now().UnixMilli()) {
+		return Item{}, false
 	}
-	return nil, false
+	return item, true
+}
+
+// Get returns the string at key. It fails with ErrWrongType when the key
+// holds another type.
+func (s *InMemoryStore) Get(key string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, data, ok, err := s.lookupString(key)
+	return data, ok, err
 }
 
 func (s *InMemoryStore) Set(key string, value []byte, expiry int64) error {
@@ -81,16 +187,28 @@ func (s *InMemoryStore) Set(key string, value []byte, expiry int64) error {
 	if expiry > 0 {
 		expirationTime = time.Now().UnixMilli() + expiry
 	}
-	s.items[key] = Item{
-		value:  StringValue{data: value},
+	s.setItem(key, Item{
+		value:  StringValue{data: bytes.Clone(value)},
 		expiry: expirationTime,
-	}
+	})
 	return nil
 }
 
+// Encoding returns the internal representation of the value stored at key.
+func (s *InMemoryStore) Encoding(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.lookup(key)
+	if !ok {
+		return "", false
+	}
+	return item.value.encoding(), true
+}
+
 func (s *InMemoryStore) Type(key string) string {
 	s.mu.RLock()
-	item, ok := s.items[key]
+	item, ok := s.lookup(key)
 	s.mu.RUnlock()
 	if !ok {
 		return "none"
@@ -98,47 +216,332 @@ func (s *InMemoryStore) Type(key string) string {
 	return string(item.value.Type())
 }
 
-func (s *InMemoryStore) cleanupExpiredItems() {
-	for {
-		time.Sleep(time.Minute)
-		s.mu.Lock()
-		now := time.Now().UnixMilli()
-		for key, item := range s.items {
-			if item.expiry > 0 && now > item.expiry {
-				delete(s.items, key)
-			}
+// SetWithOptions stores value at key according to opts. Requesting the
+// previous value of a key that does not hold a string fails with ErrWrongType
+// and leaves the key untouched.
+func (s *InMemoryStore) SetWithOptions(key string, value []byte, opts SetOptions) (SetResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result SetResult
+	item, exists := s.lookup(key)
+	result.Exists = exists
+	if exists && opts.Get {
+		str, ok := item.value.(StringValue)
+		if !ok {
+			return result, ErrWrongType
 		}
-		s.mu.Unlock()
+		result.Old = str.data
+	}
+	if (opts.NX && exists) || (opts.XX && !exists) {
+		return result, nil
 	}
+	expiry := opts.ExpireAt
+	if opts.KeepTTL && exists {
+		expiry = item.expiry
+	}
+	s.setItem(key, Item{
+		value:  StringValue{data: bytes.Clone(value)},
+		expiry: expiry,
+	})
+	result.Set = true
+	return result, nil
+}
+
+// Delete removes the given keys and returns how many of them existed.
+func (s *InMemoryStore) Delete(keys ...string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for _, key := range keys {
+		if _, ok := s.lookup(key); ok {
+			deleted++
+		}
+		s.deleteItem(key)
+	}
+	return deleted
+}
+
+// Flush removes every key and returns how many there were.
+func (s *InMemoryStore) Flush() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.items)
+	s.items = make(map[string]Item)
+	s.volatile = newIndexedSet()
+	s.volatileHashes = newIndexedSet()
+	return n
+}
+
+// Exists returns how many of the given keys exist. A key mentioned several
+// times is counted every time.
+func (s *InMemoryStore) Exists(keys ...string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, key := range keys {
+		if _, ok := s.lookup(key); ok {
+			count++
+		}
+	}
+	return count
+}
+
+// Rename moves the value and expiry of src to dst, overwriting dst unless nx
+// is set. It reports whether the key was renamed.
+func (s *InMemoryStore) Rename(src, dst string, nx bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.lookup(src)
+	if !ok {
+		return false, ErrNoSuchKey
+	}
+	if src == dst {
+		return !nx, nil
+	}
+	if _, exists := s.lookup(dst); exists && nx {
+		return false, nil
+	}
+	s.deleteItem(src)
+	s.setItem(dst, item)
+	return true, nil
+}
+
+// Copy stores a copy of the value and expiry of src at dst. Unless replace is
+// set, nothing is copied when dst already exists. It reports whether the key
+// was copied.
+func (s *InMemoryStore) Copy(src, dst string, replace bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if src == dst {
+		return false, ErrSameObject
+	}
+	item, ok := s.lookup(src)
+	if !ok {
+		return false, nil
+	}
+	if _, exists := s.lookup(dst); exists && !replace {
+		return false, nil
+	}
+	s.setItem(dst, Item{value: item.value.clone(), expiry: item.expiry})
+	return true, nil
+}
+
+// CopyItem returns a copy of the item stored at key, including its expiry, to
+// be stored in another database with PutItem.
+func (s *InMemoryStore) CopyItem(key string) (Item, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.lookup(key)
+	if !ok {
+		return Item{}, false
+	}
+	return Item{value: item.value.clone(), expiry: item.expiry}, true
+}
+
+// PutItem stores item at key. Unless replace is set, nothing is stored when
+// the key already exists. It reports whether the item was stored.
+func (s *InMemoryStore) PutItem(key string, item Item, replace bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.lookup(key); exists && !replace {
+		return false
+	}
+	s.setItem(key, item)
+	return true
+}
+
+// Expire sets the expiry of key to the unix time at, in milliseconds, when
+// cond allows it. Keys without an expiry are treated as having an infinite
+// TTL for the GT and LT conditions. An expiry in the past deletes the key. It
+// reports whether the expiry was set and whether the key was deleted.
+func (s *InMemoryStore) Expire(key string, at int64, cond ExpireCondition) (set bool, deleted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.lookup(key)
+	if !ok {
+		return false, false
+	}
+	if cond&ExpireNX != 0 && item.expiry != 0 {
+		return false, false
+	}
+	if cond&ExpireXX != 0 && item.expiry == 0 {
+		return false, false
+	}
+	if cond&ExpireGT != 0 && (item.expiry == 0 || at <= item.expiry) {
+		return false, false
+	}
+	if cond&ExpireLT != 0 && item.expiry != 0 && at >= item.expiry {
+		return false, false
+	}
+	if at <= time.Now().UnixMilli() {
+		s.deleteItem(key)
+		return true, true
+	}
+	item.expiry = at
+	s.setItem(key, item)
+	return true, false
+}
+
+// ExpireTime returns the unix time in milliseconds at which key expires, or
+// 0 if it has no expiry. It also reports whether the key exists.
+func (s *InMemoryStore) ExpireTime(key string) (int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.lookup(key)
+	if !ok {
+		return 0, false
+	}
+	return item.expiry, true
+}
+
+// Persist removes the expiry of key and reports whether it had one.
+func (s *InMemoryStore) Persist(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.lookup(key)
+	if !ok || item.expiry == 0 {
+		return false
+	}
+	item.expiry = 0
+	s.setItem(key, item)
+	return true
 }
 
 func (s *InMemoryStore) Load(entries []persistence.Entry) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	now := time.Now().UnixMilli()
 	for _, entry := range entries {
 		var expiry int64
 		if entry.Expires != nil {
 			expiry = *entry.Expires
+			if expiry <= now {
+				continue
+			}
 		}
-		s.items[entry.Key] = Item{
-			value:  StringValue{data: []byte(entry.Value)},
-			expiry: expiry,
+		var value Value
+		switch entry.Type {
+		case persistence.StringType:
+			value = StringValue{data: []byte(entry.Value)}
+		case persistence.ListType:
+			if len(entry.List) == 0 {
+				continue
+			}
+			list := &ListValue{}
+			for _, elem := range entry.List {
+				list.pushBack([]byte(elem))
+			}
+			value = list
+		case persistence.HashType, persistence.HashMetadataType:
+			h := newHashValue()
+			for _, field := range entry.Hash {
+				if field.Expires != 0 && field.Expires <= now {
+					continue
+				}
+				h.set(field.Field, []byte(field.Value))
+				if field.Expires != 0 {
+					h.expire(field.Field, field.Expires)
+				}
+			}
+			if h.Len() == 0 {
+				continue
+			}
+			value = h
+		case persistence.SetType:
+			if len(entry.List) == 0 {
+				continue
+			}
+			set := &SetValue{}
+			for _, member := range entry.List {
+				set.add(member)
+			}
+			value = set
+		case persistence.ZSetType:
+			if len(entry.ZSet) == 0 {
+				continue
+			}
+			zset := newSortedSetValue()
+			for _, m := range entry.ZSet {
+				zset.set(m.Member, m.Score)
+			}
+			value = zset
+		case persistence.StreamType:
+			value = loadStream(entry.Stream)
+		default:
+			continue
 		}
+		s.setItem(entry.Key, Item{
+			value:  value,
+			expiry: expiry,
+		})
 	}
 }
 
 func (s *InMemoryStore) Export() []persistence.Entry {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	now := time.Now().UnixMilli()
 	entries := make([]persistence.Entry, 0, len(s.items))
 	for key, item := range s.items {
-		if item.value.Type() == StringType {
-			entries = append(entries, persistence.Entry{
-				Key:     key,
-				Value:   string(item.value.(StringValue).data),
-				Expires: &item.expiry,
+		entry := persistence.Entry{Key: key}
+		switch value := item.value.(type) {
+		case StringValue:
+			entry.Type = persistence.StringType
+			entry.Value = string(value.data)
+		case *ListValue:
+			entry.Type = persistence.ListType
+			entry.List = make([]string, 0, value.length)
+			value.each(true, func(_ int, elem []byte) bool {
+				entry.List = append(entry.List, string(elem))
+				return true
 			})
+		case *SetValue:
+			entry.Type = persistence.SetType
+			entry.List = value.list()
+		case *SortedSetValue:
+			entry.Type = persistence.ZSetType
+			entry.ZSet = make([]persistence.ZSetMember, 0, value.Len())
+			for x := value.zsl.head.level[0].forward; x != nil; x = x.level[0].forward {
+				entry.ZSet = append(entry.ZSet, persistence.ZSetMember{Member: x.member, Score: x.score})
+			}
+		case *HashValue:
+			entry.Type = persistence.HashType
+			entry.Hash = make([]persistence.HashField, 0, value.Len())
+			for field, data := range value.fields {
+				expires := value.expires[field]
+				if expires != 0 {
+					if expires < now {
+						continue
+					}
+					entry.Type = persistence.HashMetadataType
+				}
+				entry.Hash = append(entry.Hash, persistence.HashField{Field: field, Value: string(data), Expires: expires})
+			}
+			if len(entry.Hash) == 0 {
+				continue
+			}
+		case *StreamValue:
+			entry.Type = persistence.StreamType
+			entry.Stream = value.export()
+		default:
+			continue
+		}
+		if item.expiry > 0 {
+			expiry := item.expiry
+			entry.Expires = &expiry
 		}
+		entries = append(entries, entry)
 	}
 	return entries
 }
@@ -1,6 +1,7 @@
 package store_test
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -17,7 +18,7 @@ func TestStore_SetAndGet(t *testing.T) {
 		t.Errorf("Failed to set value: %v", err)
 	}
 
-	value, exists := IMstore.Get("key1")
+	value, exists, _ := IMstore.Get("key1")
 	if !exists {
 		t.Error("Expected key to exist, but it doesn't")
 	}
@@ -26,7 +27,7 @@ func TestStore_SetAndGet(t *testing.T) {
 	}
 
 	// Test non-existent key
-	_, exists = IMstore.Get("nonexistent")
+	_, exists, _ = IMstore.Get("nonexistent")
 	if exists {
 		t.Error("Expected key to not exist, but it does")
 	}
@@ -42,7 +43,7 @@ func TestStore_Expiration(t *testing.T) {
 	}
 
 	// Should exist immediately
-	value, exists := store.Get("expiring")
+	value, exists, _ := store.Get("expiring")
 	if !exists {
 		t.Error("Expected key to exist immediately after setting")
 	}
@@ -54,7 +55,7 @@ func TestStore_Expiration(t *testing.T) {
 	time.Sleep(200 * time.Millisecond)
 
 	// Should not exist after expiration
-	_, exists = store.Get("expiring")
+	_, exists, _ = store.Get("expiring")
 	if exists {
 		t.Error("Expected key to be expired, but it still exists")
 	}
@@ -75,7 +76,7 @@ func TestStore_Overwrite(t *testing.T) {
 		t.Errorf("Failed to overwrite value: %v", err)
 	}
 
-	value, exists := IMstore.Get("key1")
+	value, exists, _ := IMstore.Get("key1")
 	if !exists {
 		t.Error("Expected key to exist")
 	}
@@ -141,7 +142,10 @@ func TestStreamRange(t *testing.T) {
 	}
 
 	// Test range query
-	streamEntries := IMstore.Range("mystream", []byte("-"), []byte("+"))
+	streamEntries, err := IMstore.Range("mystream", []byte("-"), []byte("+"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if len(streamEntries) != 2 {
 		t.Errorf("Expected 2 entries, got %d", len(streamEntries))
@@ -162,3 +166,179 @@ func TestStreamRange(t *testing.T) {
 		t.Errorf("Expected KeyVals %v, got %v", expectedKeyVals, firstEntry.Value)
 	}
 }
+
+func TestStore_DeleteAndExists(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.Set("a", []byte("1"), 0)
+	IMstore.Set("b", []byte("2"), 0)
+	IMstore.SetStream("s")
+
+	if n := IMstore.Exists("a", "a", "s", "missing"); n != 3 {
+		t.Errorf("Expected 3 existing keys, got %d", n)
+	}
+	if n := IMstore.Delete("a", "s", "missing"); n != 2 {
+		t.Errorf("Expected 2 deleted keys, got %d", n)
+	}
+	if n := IMstore.Exists("a", "b", "s"); n != 1 {
+		t.Errorf("Expected 1 existing key, got %d", n)
+	}
+}
+
+func TestStore_RenameKeepsExpiry(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.Set("src", []byte("value"), 100)
+	IMstore.Set("taken", []byte("other"), 0)
+
+	if _, err := IMstore.Rename("missing", "dst", false); err != store.ErrNoSuchKey {
+		t.Errorf("Expected ErrNoSuchKey, got %v", err)
+	}
+	if renamed, _ := IMstore.Rename("src", "taken", true); renamed {
+		t.Error("RENAMENX should not overwrite an existing key")
+	}
+	if renamed, err := IMstore.Rename("src", "dst", false); !renamed || err != nil {
+		t.Fatalf("Expected rename to succeed, got %v, %v", renamed, err)
+	}
+	if _, ok, _ := IMstore.Get("src"); ok {
+		t.Error("Expected source key to be gone after rename")
+	}
+	if value, ok, _ := IMstore.Get("dst"); !ok || string(value) != "value" {
+		t.Errorf("Expected renamed value, got %q", value)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if _, ok, _ := IMstore.Get("dst"); ok {
+		t.Error("Expected renamed key to keep its expiry")
+	}
+}
+
+func TestStore_Copy(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.SetStream("src")
+	IMstore.AddStreamEntry("src", []byte("1-1"), []string{"f", "v"})
+	IMstore.Set("dst", []byte("value"), 0)
+
+	if _, err := IMstore.Copy("src", "src", false); err != store.ErrSameObject {
+		t.Errorf("Expected ErrSameObject, got %v", err)
+	}
+	if copied, _ := IMstore.Copy("src", "dst", false); copied {
+		t.Error("COPY without REPLACE should not overwrite an existing key")
+	}
+	if copied, _ := IMstore.Copy("src", "dst", true); !copied {
+		t.Fatal("Expected COPY REPLACE to succeed")
+	}
+
+	// The copy must not share the stream with its source.
+	IMstore.AddStreamEntry("src", []byte("1-2"), []string{"f", "v"})
+	if entries, _ := IMstore.Range("dst", []byte("-"), []byte("+"), 0); len(entries) != 1 {
+		t.Errorf("Expected 1 entry in the copy, got %d", len(entries))
+	}
+	if entries, _ := IMstore.Range("src", []byte("-"), []byte("+"), 0); len(entries) != 2 {
+		t.Errorf("Expected 2 entries in the source, got %d", len(entries))
+	}
+}
+
+func TestStore_ExpireConditions(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.Set("key", []byte("value"), 0)
+	later := time.Now().Add(time.Hour).UnixMilli()
+
+	if set, _ := IMstore.Expire("missing", later, store.ExpireAlways); set {
+		t.Error("Expected no expiry on a missing key")
+	}
+	if set, _ := IMstore.Expire("key", later, store.ExpireXX); set {
+		t.Error("XX should not set an expiry on a key without one")
+	}
+	if set, _ := IMstore.Expire("key", later, store.ExpireGT); set {
+		t.Error("GT should treat a key without expiry as infinite")
+	}
+	if set, _ := IMstore.Expire("key", later, store.ExpireNX); !set {
+		t.Error("NX should set an expiry on a key without one")
+	}
+	if set, _ := IMstore.Expire("key", later+1000, store.ExpireXX|store.ExpireLT); set {
+		t.Error("LT should not extend the expiry")
+	}
+	if set, _ := IMstore.Expire("key", later+1000, store.ExpireGT); !set {
+		t.Error("GT should extend the expiry")
+	}
+	if expiry, ok := IMstore.ExpireTime("key"); !ok || expiry != later+1000 {
+		t.Errorf("Expected expiry %d, got %d", later+1000, expiry)
+	}
+
+	if !IMstore.Persist("key") {
+		t.Error("Expected PERSIST to remove the expiry")
+	}
+	if IMstore.Persist("key") {
+		t.Error("Expected PERSIST to report a key without expiry")
+	}
+	if expiry, ok := IMstore.ExpireTime("key"); !ok || expiry != 0 {
+		t.Errorf("Expected no expiry, got %d", expiry)
+	}
+
+	if set, deleted := IMstore.Expire("key", time.Now().UnixMilli()-1, store.ExpireAlways); !set || !deleted {
+		t.Error("Expected an expiry in the past to delete the key")
+	}
+	if IMstore.Exists("key") != 0 {
+		t.Error("Expected key to be deleted")
+	}
+}
+
+func TestStore_ActiveExpireCycle(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	for i := 0; i < 1000; i++ {
+		IMstore.Set(fmt.Sprintf("volatile:%d", i), []byte("value"), 10)
+	}
+	for i := 0; i < 100; i++ {
+		IMstore.Set(fmt.Sprintf("persistent:%d", i), []byte("value"), 0)
+	}
+	IMstore.Set("later", []byte("value"), 60000)
+	time.Sleep(20 * time.Millisecond)
+
+	// A single cycle keeps sampling while most sampled keys are expired.
+	sampled, expired := IMstore.ActiveExpireCycle(time.Now().Add(time.Second))
+	if expired < 900 || sampled < expired {
+		t.Errorf("Expected most keys to expire in one cycle, sampled %d and expired %d", sampled, expired)
+	}
+	for i := 0; i < 10; i++ {
+		IMstore.ActiveExpireCycle(time.Now().Add(time.Second))
+	}
+	keys, _ := IMstore.Keys("*")
+	if len(keys) != 101 {
+		t.Errorf("Expected 101 keys left, got %d", len(keys))
+	}
+	if sampled, expired := IMstore.ActiveExpireCycle(time.Now().Add(time.Second)); sampled != 1 || expired != 0 {
+		t.Errorf("Expected only the volatile key left to be sampled, got %d sampled and %d expired", sampled, expired)
+	}
+}
+
+func TestStore_SetWithOptions(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	later := time.Now().Add(time.Hour).UnixMilli()
+
+	if result, _ := IMstore.SetWithOptions("key", []byte("v1"), store.SetOptions{XX: true}); result.Set {
+		t.Error("XX should not set a missing key")
+	}
+	if result, _ := IMstore.SetWithOptions("key", []byte("v1"), store.SetOptions{NX: true, ExpireAt: later}); !result.Set {
+		t.Error("NX should set a missing key")
+	}
+	if result, _ := IMstore.SetWithOptions("key", []byte("v2"), store.SetOptions{NX: true, Get: true}); result.Set || string(result.Old) != "v1" {
+		t.Errorf("Expected NX to fail and return the old value, got %+v", result)
+	}
+	if result, _ := IMstore.SetWithOptions("key", []byte("v3"), store.SetOptions{KeepTTL: true, Get: true}); !result.Set || string(result.Old) != "v1" {
+		t.Errorf("Expected KEEPTTL set to return the old value, got %+v", result)
+	}
+	if expiry, _ := IMstore.ExpireTime("key"); expiry != later {
+		t.Errorf("Expected KEEPTTL to retain expiry %d, got %d", later, expiry)
+	}
+	IMstore.SetWithOptions("key", []byte("v4"), store.SetOptions{})
+	if expiry, _ := IMstore.ExpireTime("key"); expiry != 0 {
+		t.Errorf("Expected a plain set to clear the expiry, got %d", expiry)
+	}
+
+	IMstore.SetStream("stream")
+	if _, err := IMstore.SetWithOptions("stream", []byte("v"), store.SetOptions{Get: true}); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if IMstore.Type("stream") != "stream" {
+		t.Error("Expected a failed GET to leave the key untouched")
+	}
+}
@@ -0,0 +1,624 @@
+package store
+
+import (
+	"bytes"
+	"slices"
+)
+
+// listChunkSize is the maximum number of elements kept in a list node.
+const listChunkSize = 128
+
+// listpackMaxBytes is the size up to which Redis keeps a list in a single
+// listpack, as with the default list-max-listpack-size of -2.
+const listpackMaxBytes = 8 * 1024
+
+// listNode is a chunk of consecutive list elements.
+type listNode struct {
+	prev, next *listNode
+	elems      [][]byte
+}
+
+// ListValue is a quicklist: a doubly linked list of chunks of elements. Pushes
+// and pops at both ends are O(1), while neighbouring elements share a chunk
+// so walking the list touches few allocations.
+type ListValue struct {
+	head, tail *listNode
+	length     int
+}
+
+func (_ *ListValue) Type() Type {
+	return ListType
+}
+
+// encoding reports the encoding Redis would use for the list: a listpack
+// while its elements fit in listpackMaxBytes, and a quicklist beyond. Redis
+// only converts back once the list shrinks to half that size, while this
+// follows the current size.
+func (l *ListValue) encoding() string {
+	size := 0
+	l.each(true, func(_ int, elem []byte) bool {
+		size += listpackEntrySize(elem)
+		return size <= listpackMaxBytes
+	})
+	if size > listpackMaxBytes {
+		return "quicklist"
+	}
+	return "listpack"
+}
+
+// listpackEntrySize approximates the size of elem in a listpack: its bytes,
+// the header encoding its length and the back length that follows it.
+func listpackEntrySize(elem []byte) int {
+	switch {
+	case len(elem) < 64:
+		return len(elem) + 2
+	case len(elem) < 4096:
+		return len(elem) + 4
+	default:
+		return len(elem) + 10
+	}
+}
+
+func (l *ListValue) clone() Value {
+	clone := &ListValue{}
+	l.each(true, func(_ int, elem []byte) bool {
+		clone.pushBack(bytes.Clone(elem))
+		return true
+	})
+	return clone
+}
+
+func (l *ListValue) Len() int {
+	return l.length
+}
+
+func (l *ListValue) pushFront(elem []byte) {
+	if l.head == nil || len(l.head.elems) >= listChunkSize {
+		node := &listNode{next: l.head}
+		if l.head != nil {
+			l.head.prev = node
+		} else {
+			l.tail = node
+		}
+		l.head = node
+	}
+	l.head.elems = slices.Insert(l.head.elems, 0, elem)
+	l.length++
+}
+
+func (l *ListValue) pushBack(elem []byte) {
+	if l.tail == nil || len(l.tail.elems) >= listChunkSize {
+		node := &listNode{prev: l.tail}
+		if l.tail != nil {
+			l.tail.next = node
+		} else {
+			l.head = node
+		}
+		l.tail = node
+	}
+	l.tail.elems = append(l.tail.elems, elem)
+	l.length++
+}
+
+func (l *ListValue) popFront() []byte {
+	node := l.head
+	elem := node.elems[0]
+	node.elems[0] = nil
+	node.elems = node.elems[1:]
+	l.length--
+	if len(node.elems) == 0 {
+		l.unlink(node)
+	}
+	return elem
+}
+
+func (l *ListValue) popBack() []byte {
+	node := l.tail
+	last := len(node.elems) - 1
+	elem := node.elems[last]
+	node.elems[last] = nil
+	node.elems = node.elems[:last]
+	l.length--
+	if len(node.elems) == 0 {
+		l.unlink(node)
+	}
+	return elem
+}
+
+func (l *ListValue) unlink(node *listNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		l.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		l.tail = node.prev
+	}
+	node.prev, node.next = nil, nil
+}
+
+// seek returns the node holding the element at index and its offset in the
+// node, walking from whichever end is closer.
+func (l *ListValue) seek(index int) (*listNode, int) {
+	if index < l.length/2 {
+		node := l.head
+		for index >= len(node.elems) {
+			index -= len(node.elems)
+			node = node.next
+		}
+		return node, index
+	}
+	index = l.length - 1 - index
+	node := l.tail
+	for index >= len(node.elems) {
+		index -= len(node.elems)
+		node = node.prev
+	}
+	return node, len(node.elems) - 1 - index
+}
+
+// insert inserts elem at index, splitting full nodes in half.
+func (l *ListValue) insert(index int, elem []byte) {
+	if index == 0 {
+		l.pushFront(elem)
+		return
+	}
+	if index == l.length {
+		l.pushBack(elem)
+		return
+	}
+	node, off := l.seek(index)
+	if len(node.elems) >= listChunkSize {
+		half := len(node.elems) / 2
+		right := &listNode{prev: node, next: node.next, elems: slices.Clone(node.elems[half:])}
+		clear(node.elems[half:])
+		node.elems = node.elems[:half]
+		if node.next != nil {
+			node.next.prev = right
+		} else {
+			l.tail = right
+		}
+		node.next = right
+		if off > half {
+			node, off = right, off-half
+		}
+	}
+	node.elems = slices.Insert(node.elems, off, elem)
+	l.length++
+}
+
+// each calls fn with the index and value of every element, from the head or
+// from the tail, until fn returns false.
+func (l *ListValue) each(forward bool, fn func(index int, elem []byte) bool) {
+	if forward {
+		index := 0
+		for node := l.head; node != nil; node = node.next {
+			for _, elem := range node.elems {
+				if !fn(index, elem) {
+					return
+				}
+				index++
+			}
+		}
+		return
+	}
+	index := l.length - 1
+	for node := l.tail; node != nil; node = node.prev {
+		for i := len(node.elems) - 1; i >= 0; i-- {
+			if !fn(index, node.elems[i]) {
+				return
+			}
+			index--
+		}
+	}
+}
+
+// trim keeps the elements between start and stop, both inclusive.
+func (l *ListValue) trim(start, stop int) {
+	front, back := start, l.length-1-stop
+	for front > 0 {
+		node := l.head
+		if len(node.elems) <= front {
+			front -= len(node.elems)
+			l.length -= len(node.elems)
+			l.unlink(node)
+			continue
+		}
+		clear(node.elems[:front])
+		node.elems = node.elems[front:]
+		l.length -= front
+		front = 0
+	}
+	for back > 0 {
+		node := l.tail
+		if len(node.elems) <= back {
+			back -= len(node.elems)
+			l.length -= len(node.elems)
+			l.unlink(node)
+			continue
+		}
+		keep := len(node.elems) - back
+		clear(node.elems[keep:])
+		node.elems = node.elems[:keep]
+		l.length -= back
+		back = 0
+	}
+}
+
+// remove removes up to count occurrences of elem, scanning from the tail when
+// count is negative. A count of 0 removes every occurrence.
+func (l *ListValue) remove(elem []byte, count int) int {
+	forward := count >= 0
+	limit := count
+	if count < 0 {
+		limit = -count
+	}
+	removed := 0
+	node := l.head
+	if !forward {
+		node = l.tail
+	}
+	for node != nil && (limit == 0 || removed < limit) {
+		next := node.next
+		if !forward {
+			next = node.prev
+		}
+		if forward {
+			for i := 0; i < len(node.elems) && (limit == 0 || removed < limit); {
+				if bytes.Equal(node.elems[i], elem) {
+					node.elems = slices.Delete(node.elems, i, i+1)
+					removed++
+				} else {
+					i++
+				}
+			}
+		} else {
+			for i := len(node.elems) - 1; i >= 0 && (limit == 0 || removed < limit); i-- {
+				if bytes.Equal(node.elems[i], elem) {
+					node.elems = slices.Delete(node.elems, i, i+1)
+					removed++
+				}
+			}
+		}
+		if len(node.elems) == 0 {
+			l.unlink(node)
+		}
+		node = next
+	}
+	l.length -= removed
+	return removed
+}
+
+// listRange converts the possibly negative offsets start and stop into
+// indexes of a list of the given length. It reports false if the range is
+// empty.
+func listRange(start, stop int64, length int) (int, int, bool) {
+	n := int64(length)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	if start > stop || start >= n {
+		return 0, 0, false
+	}
+	stop = min(stop, n-1)
+	return int(start), int(stop), true
+}
+
+// lookupList returns the list stored at key, or nil if it does not exist. It
+// fails with ErrWrongType when the key holds another type. The caller must
+// hold the lock.
+func (s *InMemoryStore) lookupList(key string) (*ListValue, error) {
+	item, ok := s.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	list, ok := item.value.(*ListValue)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return list, nil
+}
+
+// ListPush pushes values to the head or the tail of the list at key, creating
+// it unless xx is set, and returns the new length.
+func (s *InMemoryStore) ListPush(key string, values [][]byte, left, xx bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key)
+	if err != nil {
+		return 0, err
+	}
+	if list == nil {
+		if xx {
+			return 0, nil
+		}
+		list = &ListValue{}
+		s.setItem(key, Item{value: list})
+	}
+	for _, value := range values {
+		if left {
+			list.pushFront(bytes.Clone(value))
+		} else {
+			list.pushBack(bytes.Clone(value))
+		}
+	}
+	return list.length, nil
+}
+
+// ListPop pops up to count elements from the head or the tail of the list at
+// key. Lists left empty are deleted. It returns nil if the key is missing.
+func (s *InMemoryStore) ListPop(key string, count int, left bool) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key)
+	if list == nil {
+		return nil, err
+	}
+	return s.listPop(key, list, count, left), nil
+}
+
+// listPop pops up to count elements from list, which is stored at key. The
+// caller must hold the write lock.
+func (s *InMemoryStore) listPop(key string, list *ListValue, count int, left bool) [][]byte {
+	count = min(count, list.length)
+	values := make([][]byte, count)
+	for i := range values {
+		if left {
+			values[i] = list.popFront()
+		} else {
+			values[i] = list.popBack()
+		}
+	}
+	if list.length == 0 {
+		s.deleteItem(key)
+	}
+	return values
+}
+
+// ListMPop pops up to count elements from the first non-empty list among
+// keys. It returns the key popped from, or an empty key if all are empty.
+func (s *InMemoryStore) ListMPop(keys []string, count int, left bool) (string, [][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		list, err := s.lookupList(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if list != nil {
+			return key, s.listPop(key, list, count, left), nil
+		}
+	}
+	return "", nil, nil
+}
+
+// ListMove atomically pops an element from one end of the list at src and
+// pushes it to one end of the list at dst. It reports false if src is empty.
+func (s *InMemoryStore) ListMove(src, dst string, srcLeft, dstLeft bool) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srcList, err := s.lookupList(src)
+	if srcList == nil {
+		return nil, false, err
+	}
+	dstList, err := s.lookupList(dst)
+	if err != nil {
+		return nil, false, err
+	}
+	var value []byte
+	if srcLeft {
+		value = srcList.popFront()
+	} else {
+		value = srcList.popBack()
+	}
+	if dstList == nil {
+		dstList = &ListValue{}
+		s.setItem(dst, Item{value: dstList})
+	}
+	if dstLeft {
+		dstList.pushFront(value)
+	} else {
+		dstList.pushBack(value)
+	}
+	// Only delete the source now, as it may also be the destination.
+	if srcList.length == 0 {
+		s.deleteItem(src)
+	}
+	return value, true, nil
+}
+
+// ListLen returns the length of the list at key, or 0 if it does not exist.
+func (s *InMemoryStore) ListLen(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, err := s.lookupList(key)
+	if list == nil {
+		return 0, err
+	}
+	return list.length, nil
+}
+
+// ListRange returns the elements of the list at key between the offsets start
+// and stop, both inclusive. Negative offsets count from the tail.
+func (s *InMemoryStore) ListRange(key string, start, stop int64) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, err := s.lookupList(key)
+	if list == nil {
+		return nil, err
+	}
+	from, to, ok := listRange(start, stop, list.length)
+	if !ok {
+		return nil, nil
+	}
+	values := make([][]byte, 0, to-from+1)
+	node, off := list.seek(from)
+	for len(values) < cap(values) {
+		if off == len(node.elems) {
+			node, off = node.next, 0
+			continue
+		}
+		values = append(values, node.elems[off])
+		off++
+	}
+	return values, nil
+}
+
+// ListIndex returns the element at index of the list at key. Negative indexes
+// count from the tail.
+func (s *InMemoryStore) ListIndex(key string, index int64) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, err := s.lookupList(key)
+	if list == nil {
+		return nil, false, err
+	}
+	if index < 0 {
+		index += int64(list.length)
+	}
+	if index < 0 || index >= int64(list.length) {
+		return nil, false, nil
+	}
+	node, off := list.seek(int(index))
+	return node.elems[off], true, nil
+}
+
+// ListSet replaces the element at index of the list at key.
+func (s *InMemoryStore) ListSet(key string, index int64, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		return ErrNoSuchKey
+	}
+	if index < 0 {
+		index += int64(list.length)
+	}
+	if index < 0 || index >= int64(list.length) {
+		return ErrIndexOutOfRange
+	}
+	node, off := list.seek(int(index))
+	node.elems[off] = bytes.Clone(value)
+	return nil
+}
+
+// ListRemove removes up to count occurrences of value from the list at key,
+// as LREM does, and returns how many were removed.
+func (s *InMemoryStore) ListRemove(key string, count int64, value []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key)
+	if list == nil {
+		return 0, err
+	}
+	// Counts beyond the length of the list remove every occurrence, and
+	// clamping them keeps the count negatable.
+	length := int64(list.length)
+	removed := list.remove(value, int(max(min(count, length), -length)))
+	if list.length == 0 {
+		s.deleteItem(key)
+	}
+	return removed, nil
+}
+
+// ListTrim trims the list at key to the elements between the offsets start
+// and stop, both inclusive, deleting it if nothing is left.
+func (s *InMemoryStore) ListTrim(key string, start, stop int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key)
+	if list == nil {
+		return err
+	}
+	from, to, ok := listRange(start, stop, list.length)
+	if !ok {
+		s.deleteItem(key)
+		return nil
+	}
+	list.trim(from, to)
+	return nil
+}
+
+// ListInsert inserts value before or after the first occurrence of pivot in
+// the list at key and returns the new length. It returns -1 when pivot is
+// not found and 0 when the key does not exist.
+func (s *InMemoryStore) ListInsert(key string, before bool, pivot, value []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.lookupList(key)
+	if list == nil {
+		return 0, err
+	}
+	index := -1
+	list.each(true, func(i int, elem []byte) bool {
+		if bytes.Equal(elem, pivot) {
+			index = i
+			return false
+		}
+		return true
+	})
+	if index < 0 {
+		return -1, nil
+	}
+	if !before {
+		index++
+	}
+	list.insert(index, bytes.Clone(value))
+	return list.length, nil
+}
+
+// ListPos returns the indexes of the elements of the list at key equal to
+// value, as LPOS does. Matches are counted from the tail when rank is
+// negative and the first |rank|-1 are skipped. A count of 0 returns every
+// match and a maxLen of 0 compares every element.
+func (s *InMemoryStore) ListPos(key string, value []byte, rank, count, maxLen int64) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, err := s.lookupList(key)
+	if list == nil {
+		return nil, err
+	}
+	skip := rank - 1
+	if rank < 0 {
+		skip = -rank - 1
+	}
+	var positions []int64
+	compared := int64(0)
+	list.each(rank > 0, func(i int, elem []byte) bool {
+		if maxLen > 0 && compared >= maxLen {
+			return false
+		}
+		compared++
+		if !bytes.Equal(elem, value) {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		positions = append(positions, int64(i))
+		return count == 0 || int64(len(positions)) < count
+	})
+	return positions, nil
+}
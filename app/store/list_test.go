@@ -0,0 +1,203 @@
+package store_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/persistence"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+func listContents(t *testing.T, s *store.InMemoryStore, key string) []string {
+	t.Helper()
+	values, err := s.ListRange(key, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make([]string, len(values))
+	for i, value := range values {
+		contents[i] = string(value)
+	}
+	return contents
+}
+
+// TestList_MatchesSliceModel applies random operations to a list large enough
+// to span many chunks and compares it to a plain slice after each step.
+func TestList_MatchesSliceModel(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	rng := rand.New(rand.NewSource(1))
+	var model []string
+
+	for step := 0; step < 1000; step++ {
+		value := fmt.Sprint(rng.Intn(50))
+		switch op := rng.Intn(8); op {
+		case 0, 1:
+			IMstore.ListPush("list", [][]byte{[]byte(value)}, op == 0, false)
+			if op == 0 {
+				model = append([]string{value}, model...)
+			} else {
+				model = append(model, value)
+			}
+		case 2:
+			if len(model) == 0 {
+				continue
+			}
+			IMstore.ListPop("list", 1, true)
+			model = model[1:]
+		case 3:
+			if len(model) == 0 {
+				continue
+			}
+			pivot := model[rng.Intn(len(model))]
+			IMstore.ListInsert("list", true, []byte(pivot), []byte(value))
+			i := slices.Index(model, pivot)
+			model = slices.Insert(model, i, value)
+		case 4:
+			count := rng.Intn(5) - 2
+			removed, _ := IMstore.ListRemove("list", int64(count), []byte(value))
+			want := removeFromModel(&model, value, count)
+			if removed != want {
+				t.Fatalf("step %d: LREM %d %s removed %d, want %d", step, count, value, removed, want)
+			}
+		case 5:
+			if len(model) < 300 {
+				continue
+			}
+			IMstore.ListTrim("list", 50, -50)
+			model = model[50 : len(model)-49]
+		case 6:
+			if len(model) == 0 {
+				continue
+			}
+			i := rng.Intn(len(model))
+			IMstore.ListSet("list", int64(i), []byte(value))
+			model[i] = value
+		case 7:
+			for i := 0; i < 100; i++ {
+				IMstore.ListPush("list", [][]byte{[]byte(value)}, false, false)
+				model = append(model, value)
+			}
+		}
+		if got := listContents(t, IMstore, "list"); !slices.Equal(got, model) && !(len(got) == 0 && len(model) == 0) {
+			t.Fatalf("step %d: list diverged from model:\ngot  %v\nwant %v", step, got, model)
+		}
+	}
+}
+
+func removeFromModel(model *[]string, value string, count int) int {
+	removed := 0
+	if count >= 0 {
+		for i := 0; i < len(*model) && (count == 0 || removed < count); {
+			if (*model)[i] == value {
+				*model = slices.Delete(*model, i, i+1)
+				removed++
+			} else {
+				i++
+			}
+		}
+		return removed
+	}
+	for i := len(*model) - 1; i >= 0 && removed < -count; i-- {
+		if (*model)[i] == value {
+			*model = slices.Delete(*model, i, i+1)
+			removed++
+		}
+	}
+	return removed
+}
+
+func TestList_PopDeletesEmptyList(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.ListPush("list", [][]byte{[]byte("a"), []byte("b")}, false, false)
+
+	values, _ := IMstore.ListPop("list", 5, false)
+	if !reflect.DeepEqual(values, [][]byte{[]byte("b"), []byte("a")}) {
+		t.Errorf("Expected [b a], got %q", values)
+	}
+	if IMstore.Exists("list") != 0 {
+		t.Error("Expected the empty list to be deleted")
+	}
+	if values, _ := IMstore.ListPop("list", 1, true); values != nil {
+		t.Errorf("Expected nil for a missing list, got %q", values)
+	}
+}
+
+func TestList_RemoveExtremeCounts(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.ListPush("list", [][]byte{[]byte("a"), []byte("b"), []byte("a"), []byte("a")}, false, false)
+
+	if removed, _ := IMstore.ListRemove("list", math.MinInt64, []byte("a")); removed != 3 {
+		t.Errorf("Expected LREM with the minimum count to remove 3 elements, got %d", removed)
+	}
+	IMstore.ListPush("list", [][]byte{[]byte("a"), []byte("a")}, false, false)
+	if removed, _ := IMstore.ListRemove("list", math.MaxInt64, []byte("a")); removed != 2 {
+		t.Errorf("Expected LREM with the maximum count to remove 2 elements, got %d", removed)
+	}
+	if got := listContents(t, IMstore, "list"); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("Expected [b], got %v", got)
+	}
+}
+
+func TestList_Encoding(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.ListPush("list", [][]byte{[]byte("a"), []byte("b")}, false, false)
+
+	if got := encoding(IMstore, "list"); got != "listpack" {
+		t.Errorf("Expected a small list to be a listpack, got %s", got)
+	}
+	IMstore.ListPush("list", [][]byte{bytes.Repeat([]byte("x"), 10000)}, false, false)
+	if got := encoding(IMstore, "list"); got != "quicklist" {
+		t.Errorf("Expected a large list to be a quicklist, got %s", got)
+	}
+	IMstore.ListPop("list", 1, false)
+	if got := encoding(IMstore, "list"); got != "listpack" {
+		t.Errorf("Expected a shrunk list to be a listpack again, got %s", got)
+	}
+}
+
+func TestList_MoveToItself(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.ListPush("list", [][]byte{[]byte("only")}, false, false)
+
+	if value, ok, _ := IMstore.ListMove("list", "list", true, false); !ok || string(value) != "only" {
+		t.Fatalf("Expected to move the only element, got %q", value)
+	}
+	if got := listContents(t, IMstore, "list"); !slices.Equal(got, []string{"only"}) {
+		t.Errorf("Expected the list to survive rotating onto itself, got %v", got)
+	}
+}
+
+func TestList_WrongType(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.Set("string", []byte("value"), 0)
+
+	if _, err := IMstore.ListPush("string", [][]byte{[]byte("a")}, true, false); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	IMstore.ListPush("list", [][]byte{[]byte("a")}, true, false)
+	if _, _, err := IMstore.ListMove("list", "string", true, true); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if got := listContents(t, IMstore, "list"); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("Expected a failed LMOVE to leave the source untouched, got %v", got)
+	}
+}
+
+func TestList_ExportAndLoad(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.ListPush("list", [][]byte{[]byte("a"), []byte(""), []byte("c")}, false, false)
+
+	loaded := store.NewInMemoryStore()
+	loaded.Load(IMstore.Export())
+	if got := listContents(t, loaded, "list"); !slices.Equal(got, []string{"a", "", "c"}) {
+		t.Errorf("Expected the list to survive a round trip, got %v", got)
+	}
+	if entries := IMstore.Export(); len(entries) != 1 || entries[0].Type != persistence.ListType {
+		t.Errorf("Expected a single list entry, got %+v", entries)
+	}
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"cmp"
+	"hash/fnv"
+	"slices"
+)
+
+// scanHash orders the elements of a collection for the SCAN family of
+// commands. It is never 0, the cursor that starts and ends a scan, and never
+// the maximum uint64, so it can be incremented.
+func scanHash(elem string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(elem))
+	return f.Sum64()>>1 + 1
+}
+
+// scanElements returns at least count of the elements yielded by each,
+// starting at cursor, and the cursor to continue from, which is 0 once the
+// scan is complete. Elements are visited in the order of their scanHash, so
+// every element present during the whole scan is returned at least once,
+// however the collection is modified in between.
+func scanElements(each func(yield func(elem string)), cursor uint64, count int) (uint64, []string) {
+	type scanElement struct {
+		hash uint64
+		elem string
+	}
+	var remaining []scanElement
+	each(func(elem string) {
+		if hash := scanHash(elem); hash >= cursor {
+			remaining = append(remaining, scanElement{hash, elem})
+		}
+	})
+	slices.SortFunc(remaining, func(a, b scanElement) int {
+		return cmp.Compare(a.hash, b.hash)
+	})
+	// Elements sharing a hash are returned together so the cursor can move
+	// past them.
+	n := min(count, len(remaining))
+	for n < len(remaining) && n > 0 && remaining[n].hash == remaining[n-1].hash {
+		n++
+	}
+	elems := make([]string, n)
+	for i, e := range remaining[:n] {
+		elems[i] = e.elem
+	}
+	if n == len(remaining) {
+		return 0, elems
+	}
+	return remaining[n-1].hash + 1, elems
+}
@@ -0,0 +1,559 @@
+package store
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// setMaxIntsetEntries is the largest number of members a set of integers
+// keeps in the intset encoding, as in Redis.
+const setMaxIntsetEntries = 512
+
+// SetOperation selects the algebra computed by SetAlgebra.
+type SetOperation int
+
+const (
+	SetOpUnion SetOperation = iota
+	SetOpInter
+	SetOpDiff
+)
+
+// indexedSet is a set of strings that can be sampled at random in O(1). It
+// indexes the keys with an expiry and holds the members of large sets.
+type indexedSet struct {
+	keys  []string
+	index map[string]int
+}
+
+func newIndexedSet() indexedSet {
+	return indexedSet{index: make(map[string]int)}
+}
+
+// add adds key and reports whether it was missing.
+func (v *indexedSet) add(key string) bool {
+	if _, ok := v.index[key]; ok {
+		return false
+	}
+	v.index[key] = len(v.keys)
+	v.keys = append(v.keys, key)
+	return true
+}
+
+// remove removes key and reports whether it was present.
+func (v *indexedSet) remove(key string) bool {
+	i, ok := v.index[key]
+	if !ok {
+		return false
+	}
+	last := len(v.keys) - 1
+	v.keys[i] = v.keys[last]
+	v.index[v.keys[i]] = i
+	v.keys[last] = ""
+	v.keys = v.keys[:last]
+	delete(v.index, key)
+	return true
+}
+
+func (v *indexedSet) contains(key string) bool {
+	_, ok := v.index[key]
+	return ok
+}
+
+func (v *indexedSet) random() string {
+	return v.keys[rand.Intn(len(v.keys))]
+}
+
+// SetValue is an unordered set of unique members. Small sets of integers are
+// kept as a sorted slice, the intset encoding, until they grow too large or a
+// member that is not an integer is added.
+type SetValue struct {
+	intset []int64
+	// members holds the members once the set no longer fits an intset.
+	members *indexedSet
+}
+
+func (_ *SetValue) Type() Type {
+	return SetType
+}
+
+func (v *SetValue) clone() Value {
+	c := &SetValue{intset: slices.Clone(v.intset)}
+	if v.members != nil {
+		members := newIndexedSet()
+		for _, member := range v.members.keys {
+			members.add(member)
+		}
+		c.members = &members
+	}
+	return c
+}
+
+func (v *SetValue) encoding() string {
+	if v.members == nil {
+		return "intset"
+	}
+	return "hashtable"
+}
+
+func (v *SetValue) Len() int {
+	if v.members == nil {
+		return len(v.intset)
+	}
+	return len(v.members.keys)
+}
+
+// parseSetInt parses member as an integer if it is in canonical form, so that
+// storing it as an integer does not change its string representation.
+func parseSetInt(member string) (int64, bool) {
+	n, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != member {
+		return 0, false
+	}
+	return n, true
+}
+
+// searchInt returns the position of n in the intset and whether it is there.
+func (v *SetValue) searchInt(n int64) (int, bool) {
+	i := sort.Search(len(v.intset), func(i int) bool { return v.intset[i] >= n })
+	return i, i < len(v.intset) && v.intset[i] == n
+}
+
+// add adds member and reports whether it was missing, converting the set to
+// the hashtable encoding when it no longer fits an intset.
+func (v *SetValue) add(member string) bool {
+	if v.members == nil {
+		if n, ok := parseSetInt(member); ok {
+			i, found := v.searchInt(n)
+			if found {
+				return false
+			}
+			if len(v.intset) < setMaxIntsetEntries {
+				v.intset = slices.Insert(v.intset, i, n)
+				return true
+			}
+		}
+		v.convert()
+	}
+	return v.members.add(member)
+}
+
+// convert moves the members of an intset to the hashtable encoding.
+func (v *SetValue) convert() {
+	members := newIndexedSet()
+	for _, n := range v.intset {
+		members.add(strconv.FormatInt(n, 10))
+	}
+	v.members = &members
+	v.intset = nil
+}
+
+// remove removes member and reports whether it was present.
+func (v *SetValue) remove(member string) bool {
+	if v.members != nil {
+		return v.members.remove(member)
+	}
+	n, ok := parseSetInt(member)
+	if !ok {
+		return false
+	}
+	i, found := v.searchInt(n)
+	if found {
+		v.intset = slices.Delete(v.intset, i, i+1)
+	}
+	return found
+}
+
+func (v *SetValue) contains(member string) bool {
+	if v.members != nil {
+		return v.members.contains(member)
+	}
+	n, ok := parseSetInt(member)
+	if !ok {
+		return false
+	}
+	_, found := v.searchInt(n)
+	return found
+}
+
+// random returns a random member of a non-empty set.
+func (v *SetValue) random() string {
+	if v.members != nil {
+		return v.members.random()
+	}
+	return strconv.FormatInt(v.intset[rand.Intn(len(v.intset))], 10)
+}
+
+// list returns the members of the set, in ascending order for intsets.
+func (v *SetValue) list() []string {
+	if v.members != nil {
+		return slices.Clone(v.members.keys)
+	}
+	members := make([]string, len(v.intset))
+	for i, n := range v.intset {
+		members[i] = strconv.FormatInt(n, 10)
+	}
+	return members
+}
+
+// lookupSet returns the set stored at key, or nil if it does not exist. It
+// fails with ErrWrongType when the key holds another type. The caller must
+// hold the lock.
+func (s *InMemoryStore) lookupSet(key string) (*SetValue, error) {
+	item, ok := s.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	set, ok := item.value.(*SetValue)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return set, nil
+}
+
+func bytesList(members []string) [][]byte {
+	list := make([][]byte, len(members))
+	for i, member := range members {
+		list[i] = []byte(member)
+	}
+	return list
+}
+
+// SetAdd adds members to the set at key, creating it if needed, and returns
+// the number of members that were added.
+func (s *InMemoryStore) SetAdd(key string, members [][]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if set == nil {
+		set = &SetValue{}
+		s.setItem(key, Item{value: set})
+	}
+	added := 0
+	for _, member := range members {
+		if set.add(string(member)) {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// SetRemove removes members from the set at key and returns how many of them
+// were present. Sets left empty are deleted.
+func (s *InMemoryStore) SetRemove(key string, members [][]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key)
+	if set == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		if set.remove(string(member)) {
+			removed++
+		}
+	}
+	if set.Len() == 0 {
+		s.deleteItem(key)
+	}
+	return removed, nil
+}
+
+// SetMembers returns the members of the set at key.
+func (s *InMemoryStore) SetMembers(key string) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.lookupSet(key)
+	if set == nil {
+		return nil, err
+	}
+	return bytesList(set.list()), nil
+}
+
+// SetIsMember reports for each of members whether it belongs to the set at
+// key.
+func (s *InMemoryStore) SetIsMember(key string, members [][]byte) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.lookupSet(key)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]bool, len(members))
+	if set != nil {
+		for i, member := range members {
+			results[i] = set.contains(string(member))
+		}
+	}
+	return results, nil
+}
+
+// SetCard returns the number of members of the set at key.
+func (s *InMemoryStore) SetCard(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.lookupSet(key)
+	if set == nil {
+		return 0, err
+	}
+	return set.Len(), nil
+}
+
+// SetPop removes and returns up to count random members of the set at key.
+// Sets left empty are deleted.
+func (s *InMemoryStore) SetPop(key string, count int) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.lookupSet(key)
+	if set == nil {
+		return nil, err
+	}
+	if count >= set.Len() {
+		s.deleteItem(key)
+		return bytesList(set.list()), nil
+	}
+	popped := make([][]byte, count)
+	for i := range popped {
+		member := set.random()
+		set.remove(member)
+		popped[i] = []byte(member)
+	}
+	return popped, nil
+}
+
+// SetRandMembers returns up to count random members of the set at key.
+// Without repeat every member is returned at most once, otherwise exactly
+// count members are picked independently.
+func (s *InMemoryStore) SetRandMembers(key string, count int, repeat bool) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.lookupSet(key)
+	if set == nil {
+		return nil, err
+	}
+	if repeat {
+		members := make([][]byte, count)
+		for i := range members {
+			members[i] = []byte(set.random())
+		}
+		return members, nil
+	}
+	if count >= set.Len() {
+		return bytesList(set.list()), nil
+	}
+	if count*3 > set.Len() {
+		// A partial Fisher-Yates shuffle of the whole set is cheaper than
+		// drawing most of it at random.
+		list := set.list()
+		for i := 0; i < count; i++ {
+			j := i + rand.Intn(len(list)-i)
+			list[i], list[j] = list[j], list[i]
+		}
+		return bytesList(list[:count]), nil
+	}
+	picked := make(map[string]bool, count)
+	members := make([][]byte, 0, count)
+	for len(members) < count {
+		member := set.random()
+		if !picked[member] {
+			picked[member] = true
+			members = append(members, []byte(member))
+		}
+	}
+	return members, nil
+}
+
+// SetMove moves member from the set at src to the set at dst, creating it if
+// needed. It reports whether member was moved, failing with ErrWrongType when
+// either key holds another type.
+func (s *InMemoryStore) SetMove(src, dst string, member []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srcSet, err := s.lookupSet(src)
+	if err != nil {
+		return false, err
+	}
+	dstSet, err := s.lookupSet(dst)
+	if err != nil {
+		return false, err
+	}
+	if srcSet == nil || !srcSet.contains(string(member)) {
+		return false, nil
+	}
+	if src == dst {
+		return true, nil
+	}
+	srcSet.remove(string(member))
+	if srcSet.Len() == 0 {
+		s.deleteItem(src)
+	}
+	if dstSet == nil {
+		dstSet = &SetValue{}
+		s.setItem(dst, Item{value: dstSet})
+	}
+	dstSet.add(string(member))
+	return true, nil
+}
+
+// SetScan returns at least count members of the set at key starting at
+// cursor, and the cursor to continue from, which is 0 once the scan is
+// complete. Every member present during the whole scan is returned at least
+// once, however the set is modified in between.
+func (s *InMemoryStore) SetScan(key string, cursor uint64, count int) (uint64, [][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.lookupSet(key)
+	if set == nil {
+		return 0, nil, err
+	}
+	next, members := scanElements(func(yield func(string)) {
+		for _, member := range set.list() {
+			yield(member)
+		}
+	}, cursor, count)
+	return next, bytesList(members), nil
+}
+
+// lookupSets returns the sets stored at keys, with nil for missing keys. It
+// fails with ErrWrongType if any key holds another type. The caller must hold
+// the lock.
+func (s *InMemoryStore) lookupSets(keys []string) ([]*SetValue, error) {
+	sets := make([]*SetValue, len(keys))
+	for i, key := range keys {
+		set, err := s.lookupSet(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+// setAlgebra computes op over sets, where nil sets are empty. The caller must
+// hold the lock.
+func setAlgebra(op SetOperation, sets []*SetValue) *SetValue {
+	result := &SetValue{}
+	switch op {
+	case SetOpUnion:
+		for _, set := range sets {
+			if set != nil {
+				for _, member := range set.list() {
+					result.add(member)
+				}
+			}
+		}
+	case SetOpInter:
+		if slices.Contains(sets, nil) {
+			return result
+		}
+		// Check the members of the smallest set against the others, from
+		// the smallest to the largest, so misses are found early.
+		sets = slices.Clone(sets)
+		slices.SortFunc(sets, func(a, b *SetValue) int { return a.Len() - b.Len() })
+		for _, member := range sets[0].list() {
+			if allContain(sets[1:], member) {
+				result.add(member)
+			}
+		}
+	case SetOpDiff:
+		if sets[0] == nil {
+			return result
+		}
+		for _, member := range sets[0].list() {
+			if !anyContains(sets[1:], member) {
+				result.add(member)
+			}
+		}
+	}
+	return result
+}
+
+func allContain(sets []*SetValue, member string) bool {
+	for _, set := range sets {
+		if !set.contains(member) {
+			return false
+		}
+	}
+	return true
+}
+
+func anyContains(sets []*SetValue, member string) bool {
+	for _, set := range sets {
+		if set != nil && set.contains(member) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAlgebra returns the union, intersection or difference of the sets at
+// keys, where missing keys are empty sets. The difference is the first set
+// minus all the others.
+func (s *InMemoryStore) SetAlgebra(op SetOperation, keys []string) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sets, err := s.lookupSets(keys)
+	if err != nil {
+		return nil, err
+	}
+	return bytesList(setAlgebra(op, sets).list()), nil
+}
+
+// SetAlgebraStore stores the result of SetAlgebra at dst, replacing any value
+// it held, and returns its size. dst is deleted if the result is empty.
+func (s *InMemoryStore) SetAlgebraStore(op SetOperation, dst string, keys []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sets, err := s.lookupSets(keys)
+	if err != nil {
+		return 0, err
+	}
+	result := setAlgebra(op, sets)
+	if result.Len() == 0 {
+		s.deleteItem(dst)
+	} else {
+		s.setItem(dst, Item{value: result})
+	}
+	return result.Len(), nil
+}
+
+// SetInterCard returns the size of the intersection of the sets at keys,
+// stopping early once it reaches limit unless limit is 0.
+func (s *InMemoryStore) SetInterCard(keys []string, limit int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sets, err := s.lookupSets(keys)
+	if err != nil {
+		return 0, err
+	}
+	if slices.Contains(sets, nil) {
+		return 0, nil
+	}
+	slices.SortFunc(sets, func(a, b *SetValue) int { return a.Len() - b.Len() })
+	card := 0
+	for _, member := range sets[0].list() {
+		if allContain(sets[1:], member) {
+			card++
+			if card == limit {
+				break
+			}
+		}
+	}
+	return card, nil
+}
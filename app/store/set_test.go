@@ -0,0 +1,226 @@
+package store_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/persistence"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+func setMembers(t *testing.T, s *store.InMemoryStore, key string) []string {
+	t.Helper()
+	members, err := s.SetMembers(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := make([]string, len(members))
+	for i, member := range members {
+		list[i] = string(member)
+	}
+	slices.Sort(list)
+	return list
+}
+
+func encoding(s *store.InMemoryStore, key string) string {
+	encoding, _ := s.Encoding(key)
+	return encoding
+}
+
+func TestSet_IntsetEncoding(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+
+	IMstore.SetAdd("set", fieldArgs("3", "1", "-2", "1"))
+	if got := encoding(IMstore, "set"); got != "intset" {
+		t.Errorf("Expected a set of integers to be an intset, got %s", got)
+	}
+	if members, _ := IMstore.SetMembers("set"); fmt.Sprintf("%s", members) != "[-2 1 3]" {
+		t.Errorf("Expected the intset to be sorted, got %s", members)
+	}
+	// Integers not in canonical form would not survive the round trip.
+	IMstore.SetAdd("set", fieldArgs("007"))
+	if got := encoding(IMstore, "set"); got != "hashtable" {
+		t.Errorf("Expected a non canonical integer to convert the set, got %s", got)
+	}
+	if got := setMembers(t, IMstore, "set"); !slices.Equal(got, []string{"-2", "007", "1", "3"}) {
+		t.Errorf("Unexpected members after the conversion %v", got)
+	}
+
+	for i := 0; i < 512; i++ {
+		IMstore.SetAdd("large", fieldArgs(fmt.Sprint(i)))
+	}
+	if got := encoding(IMstore, "large"); got != "intset" {
+		t.Errorf("Expected 512 integers to fit an intset, got %s", got)
+	}
+	IMstore.SetAdd("large", fieldArgs("512"))
+	if got := encoding(IMstore, "large"); got != "hashtable" {
+		t.Errorf("Expected 513 integers to convert the set, got %s", got)
+	}
+	if card, _ := IMstore.SetCard("large"); card != 513 {
+		t.Errorf("Expected 513 members, got %d", card)
+	}
+}
+
+func TestSet_AddRemoveMove(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+
+	if added, _ := IMstore.SetAdd("src", fieldArgs("a", "b", "a", "1")); added != 3 {
+		t.Errorf("Expected 3 members to be added, got %d", added)
+	}
+	results, _ := IMstore.SetIsMember("src", fieldArgs("a", "1", "z"))
+	if !slices.Equal(results, []bool{true, true, false}) {
+		t.Errorf("Unexpected membership %v", results)
+	}
+	if moved, _ := IMstore.SetMove("src", "dst", []byte("a")); !moved {
+		t.Error("Expected the member to be moved")
+	}
+	if moved, _ := IMstore.SetMove("src", "dst", []byte("a")); moved {
+		t.Error("Expected a missing member not to be moved")
+	}
+	if removed, _ := IMstore.SetRemove("src", fieldArgs("b", "1", "z")); removed != 2 {
+		t.Errorf("Expected 2 members to be removed, got %d", removed)
+	}
+	if IMstore.Exists("src") != 0 {
+		t.Error("Expected the set to be deleted with its last member")
+	}
+
+	IMstore.Set("string", []byte("value"), 0)
+	if _, err := IMstore.SetMove("dst", "string", []byte("a")); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType for the destination, got %v", err)
+	}
+}
+
+func TestSet_PopAndRandMembers(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	for i := 0; i < 100; i++ {
+		IMstore.SetAdd("set", fieldArgs(fmt.Sprint("m", i)))
+	}
+
+	for _, count := range []int{1, 10, 50, 200} {
+		members, _ := IMstore.SetRandMembers("set", count, false)
+		seen := make(map[string]bool)
+		for _, member := range members {
+			seen[string(member)] = true
+		}
+		if len(seen) != min(count, 100) {
+			t.Errorf("Expected %d distinct members, got %d", min(count, 100), len(seen))
+		}
+	}
+	if members, _ := IMstore.SetRandMembers("set", 300, true); len(members) != 300 {
+		t.Errorf("Expected 300 members with repetition, got %d", len(members))
+	}
+
+	popped, _ := IMstore.SetPop("set", 60)
+	if card, _ := IMstore.SetCard("set"); len(popped) != 60 || card != 40 {
+		t.Errorf("Expected 60 members popped and 40 left, got %d and %d", len(popped), card)
+	}
+	results, _ := IMstore.SetIsMember("set", popped)
+	if slices.Contains(results, true) {
+		t.Error("Expected popped members to be removed")
+	}
+	if popped, _ := IMstore.SetPop("set", 100); len(popped) != 40 || IMstore.Exists("set") != 0 {
+		t.Errorf("Expected the remaining 40 members and the set to be deleted, got %d", len(popped))
+	}
+}
+
+func TestSet_Algebra(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.SetAdd("a", fieldArgs("1", "2", "3", "x"))
+	IMstore.SetAdd("b", fieldArgs("2", "3", "4", "x"))
+	IMstore.SetAdd("c", fieldArgs("3", "x", "y"))
+
+	sorted := func(members [][]byte, err error) string {
+		if err != nil {
+			return err.Error()
+		}
+		list := make([]string, len(members))
+		for i, member := range members {
+			list[i] = string(member)
+		}
+		slices.Sort(list)
+		return fmt.Sprint(list)
+	}
+	tests := []struct {
+		op   store.SetOperation
+		keys []string
+		want string
+	}{
+		{store.SetOpInter, []string{"a", "b", "c"}, "[3 x]"},
+		{store.SetOpInter, []string{"a", "missing"}, "[]"},
+		{store.SetOpUnion, []string{"a", "c", "missing"}, "[1 2 3 x y]"},
+		{store.SetOpDiff, []string{"a", "b"}, "[1]"},
+		{store.SetOpDiff, []string{"missing", "a"}, "[]"},
+	}
+	for _, tt := range tests {
+		if got := sorted(IMstore.SetAlgebra(tt.op, tt.keys)); got != tt.want {
+			t.Errorf("SetAlgebra(%d, %v) = %s, want %s", tt.op, tt.keys, got, tt.want)
+		}
+	}
+
+	if card, _ := IMstore.SetAlgebraStore(store.SetOpUnion, "a", []string{"a", "b"}); card != 5 {
+		t.Errorf("Expected the union to be stored over a source, got %d members", card)
+	}
+	if card, _ := IMstore.SetAlgebraStore(store.SetOpInter, "a", []string{"a", "missing"}); card != 0 || IMstore.Exists("a") != 0 {
+		t.Error("Expected an empty result to delete the destination")
+	}
+	if card, _ := IMstore.SetInterCard([]string{"b", "c"}, 0); card != 2 {
+		t.Errorf("Expected an intersection of 2 members, got %d", card)
+	}
+	if card, _ := IMstore.SetInterCard([]string{"b", "c"}, 1); card != 1 {
+		t.Errorf("Expected the limit to stop the count, got %d", card)
+	}
+
+	IMstore.Set("string", []byte("value"), 0)
+	if _, err := IMstore.SetAlgebra(store.SetOpInter, []string{"missing", "string"}); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestSet_Scan(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	for i := 0; i < 50; i++ {
+		IMstore.SetAdd("set", fieldArgs(fmt.Sprint(i)))
+	}
+	seen := make(map[string]int)
+	var cursor uint64
+	for {
+		next, members, err := IMstore.SetScan("set", cursor, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, member := range members {
+			seen[string(member)]++
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	for i := 0; i < 50; i++ {
+		if seen[fmt.Sprint(i)] != 1 {
+			t.Errorf("Expected %d to be returned once, got %d", i, seen[fmt.Sprint(i)])
+		}
+	}
+}
+
+func TestSet_ExportAndLoad(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.SetAdd("ints", fieldArgs("1", "2"))
+	IMstore.SetAdd("strings", fieldArgs("a", "b", ""))
+
+	loaded := store.NewInMemoryStore()
+	entries := IMstore.Export()
+	for _, entry := range entries {
+		if entry.Type != persistence.SetType {
+			t.Errorf("Expected a set entry, got %+v", entry)
+		}
+	}
+	loaded.Load(entries)
+	if got := setMembers(t, loaded, "strings"); !slices.Equal(got, []string{"", "a", "b"}) {
+		t.Errorf("Expected the set to survive a round trip, got %v", got)
+	}
+	if got := encoding(loaded, "ints"); got != "intset" {
+		t.Errorf("Expected a loaded set of integers to be an intset, got %s", got)
+	}
+}
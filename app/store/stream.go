@@ -1,23 +1,148 @@
 package store
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/persistence"
 	"github.com/codecrafters-io/redis-starter-go/app/store/art"
 )
 
+var ErrInvalidStreamID = errors.New("ERR Invalid stream ID specified as stream command argument")
+
+// streamID is the ID of a stream entry: the time it was added in
+// milliseconds and its sequence number within that millisecond.
+type streamID struct {
+	ms, seq uint64
+}
+
+var maxStreamID = streamID{math.MaxUint64, math.MaxUint64}
+
+func (id streamID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
+
+// key encodes id as 16 big-endian bytes, so that the byte order of the keys
+// of the stream tree is the numeric order of the IDs.
+func (id streamID) key() []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, id.ms)
+	binary.BigEndian.PutUint64(key[8:], id.seq)
+	return key
+}
+
+func streamIDFromKey(key []byte) streamID {
+	return streamID{binary.BigEndian.Uint64(key), binary.BigEndian.Uint64(key[8:])}
+}
+
+func (id streamID) less(other streamID) bool {
+	return id.ms < other.ms || id.ms == other.ms && id.seq < other.seq
+}
+
+// next returns the ID that follows id, failing if id is the largest one.
+func (id streamID) next() (streamID, bool) {
+	switch {
+	case id.seq < math.MaxUint64:
+		return streamID{id.ms, id.seq + 1}, true
+	case id.ms < math.MaxUint64:
+		return streamID{id.ms + 1, 0}, true
+	}
+	return id, false
+}
+
+// prev returns the ID that precedes id, failing if id is 0-0.
+func (id streamID) prev() (streamID, bool) {
+	switch {
+	case id.seq > 0:
+		return streamID{id.ms, id.seq - 1}, true
+	case id.ms > 0:
+		return streamID{id.ms - 1, math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// parseStreamID parses an ms-seq ID, or an ms one whose sequence number is
+// missingSeq.
+func parseStreamID(arg []byte, missingSeq uint64) (streamID, error) {
+	msPart, seqPart, found := strings.Cut(string(arg), "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return streamID{}, ErrInvalidStreamID
+	}
+	if !found {
+		return streamID{ms, missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return streamID{}, ErrInvalidStreamID
+	}
+	return streamID{ms, seq}, nil
+}
+
+// IsStreamID reports whether arg is an ms-seq or ms ID, which commands that
+// take a list of IDs followed by options use to find where the IDs end.
+func IsStreamID(arg []byte) bool {
+	_, err := parseStreamID(arg, 0)
+	return err == nil
+}
+
+// parseRangeID parses the start or the end of a range of entries: - or +
+// for the ends of the stream, or an ID prefixed with ( to exclude it. The
+// sequence number of an incomplete ID is the lowest one for a start and the
+// highest one for an end.
+func parseRangeID(arg []byte, start bool) (streamID, error) {
+	switch string(arg) {
+	case "-":
+		return streamID{}, nil
+	case "+":
+		return maxStreamID, nil
+	}
+	exclusive := len(arg) > 1 && arg[0] == '('
+	if exclusive {
+		arg = arg[1:]
+	}
+	missingSeq := uint64(0)
+	if !start {
+		missingSeq = math.MaxUint64
+	}
+	id, err := parseStreamID(arg, missingSeq)
+	if err != nil || !exclusive {
+		return id, err
+	}
+	ok := false
+	if start {
+		if id, ok = id.next(); !ok {
+			return id, errors.New("ERR invalid start ID for the interval")
+		}
+	} else if id, ok = id.prev(); !ok {
+		return id, errors.New("ERR invalid end ID for the interval")
+	}
+	return id, nil
+}
+
 type StreamValue struct {
-	tree                 *art.ART
-	lastEntryIDTimestamp int64
-	lastEntryIDSequence  int64
+	// tree maps the keys of the entry IDs to their fields.
+	tree   *art.ART
+	lastID streamID
+	// maxDeletedID is the largest ID deleted with XDEL, and entriesAdded
+	// counts the entries added over the life of the stream.
+	maxDeletedID streamID
+	entriesAdded uint64
+	// groups holds the consumer groups by name.
+	groups map[string]*streamGroup
 }
 
+// StreamEntry is an entry of a stream. Reads of pending entries return
+// entries that were deleted with a nil Value.
 type StreamEntry struct {
 	ID    string
 	Value []KeyVal
@@ -32,166 +157,470 @@ func (_ StreamValue) Type() Type {
 	return StreamType
 }
 
+func (_ StreamValue) encoding() string {
+	return "stream"
+}
+
+func (s *StreamValue) clone() Value {
+	tree := art.NewART()
+	s.tree.Walk(func(key []byte, value interface{}) bool {
+		tree.Insert(bytes.Clone(key), value)
+		return true
+	})
+	var groups map[string]*streamGroup
+	if len(s.groups) > 0 {
+		groups = make(map[string]*streamGroup, len(s.groups))
+		for name, g := range s.groups {
+			groups[name] = g.clone()
+		}
+	}
+	return &StreamValue{
+		tree:         tree,
+		lastID:       s.lastID,
+		maxDeletedID: s.maxDeletedID,
+		entriesAdded: s.entriesAdded,
+		groups:       groups,
+	}
+}
+
 func (s *StreamValue) GetLastEntryID() string {
-	return fmt.Sprintf("%d-%d", s.lastEntryIDTimestamp, s.lastEntryIDSequence)
+	return s.lastID.String()
 }
 
-func (s *StreamValue) setLastEntryID(timestamp, sequence int64) {
-	s.lastEntryIDTimestamp = timestamp
-	s.lastEntryIDSequence = sequence
+// lookupStream returns the stream stored at key, or nil if it does not
+// exist. It fails with ErrWrongType when the key holds another type. The
+// caller must hold the lock.
+func (s *InMemoryStore) lookupStream(key string) (*StreamValue, error) {
+	item, ok := s.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	stream, ok := item.value.(*StreamValue)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return stream, nil
 }
 
 func (s *InMemoryStore) SetStream(key string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.items[key] = Item{
+	s.setItem(key, Item{
 		value: &StreamValue{
-			tree:                 art.NewART(),
-			lastEntryIDTimestamp: 0,
-			lastEntryIDSequence:  0,
+			tree: art.NewART(),
 		},
-	}
+	})
 	return nil
 }
 
+// GetStreamLastEntryID returns the last ID of the stream at key, which is
+// 0-0 when the key does not exist.
 func (s *InMemoryStore) GetStreamLastEntryID(key string) ([]byte, error) {
-	item, ok := s.items[key]
-	if !ok {
-		return nil, fmt.Errorf("ERR stream key %s does not exist", key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream, err := s.lookupStream(key)
+	if err != nil {
+		return nil, err
 	}
-	if item.value.Type() != StreamType {
-		return nil, fmt.Errorf("ERR Invalid type for key %s - %v", key, item.value.Type())
+	if stream == nil {
+		return []byte(streamID{}.String()), nil
 	}
-	stream := item.value.(*StreamValue)
 	return []byte(stream.GetLastEntryID()), nil
 }
 
+// StreamRead returns the entries of the streams at keys with IDs larger than
+// the matching ids, at most count of them from each stream unless count is 0.
+// Missing keys have no entries.
+func (s *InMemoryStore) StreamRead(keys []string, ids [][]byte, count int) ([][]StreamEntry, error) {
+	starts := make([]streamID, len(ids))
+	for i, arg := range ids {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		starts[i] = id
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([][]StreamEntry, len(keys))
+	for i, key := range keys {
+		stream, err := s.lookupStream(key)
+		if err != nil {
+			return nil, err
+		}
+		start, ok := starts[i].next()
+		if stream == nil || !ok {
+			continue
+		}
+		stream.tree.Range(start.key(), maxStreamID.key(), func(key []byte, value interface{}) bool {
+			result[i] = append(result[i], streamEntry(key, value))
+			return count <= 0 || len(result[i]) < count
+		})
+	}
+	return result, nil
+}
+
+// StreamTrimStrategy selects the entries that trimming a stream removes.
+type StreamTrimStrategy int
+
+const (
+	// TrimNone leaves the stream untrimmed.
+	TrimNone StreamTrimStrategy = iota
+	// TrimMaxLen removes the oldest entries past MaxLen of them.
+	TrimMaxLen
+	// TrimMinID removes the entries with IDs smaller than MinID.
+	TrimMinID
+)
+
+// StreamTrimOptions are the trimming options of XADD and XTRIM. Entries are
+// not grouped in nodes here, so approximate trimming is exact and only
+// differs by its Limit.
+type StreamTrimOptions struct {
+	Strategy StreamTrimStrategy
+	MaxLen   int64
+	MinID    []byte
+	// Limit caps the number of entries removed, unless it is 0.
+	Limit int64
+}
+
+// StreamAddOptions are the options of XADD.
+type StreamAddOptions struct {
+	// NoMkStream leaves a missing key alone instead of creating the stream.
+	NoMkStream bool
+	Trim       StreamTrimOptions
+}
+
+// AddStreamEntry adds an entry to the stream at key, creating it if needed,
+// and returns its ID.
 func (s *InMemoryStore) AddStreamEntry(key string, entryID []byte, fields []string) (string, error) {
+	return s.StreamAdd(key, entryID, fields, StreamAddOptions{})
+}
+
+// StreamAdd adds an entry to the stream at key and trims the stream as opts
+// asks. The entry ID is * to generate it, ms-* to generate its sequence
+// number, or a full ID. It returns the ID of the new entry, or an empty one
+// when the key does not exist and opts.NoMkStream is set.
+func (s *InMemoryStore) StreamAdd(key string, entryID []byte, fields []string, opts StreamAddOptions) (string, error) {
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return "", errors.New("ERR wrong number of arguments for 'xadd' command")
+	}
+	minID, err := parseTrimMinID(opts.Trim)
+	if err != nil {
+		return "", err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	item, ok := s.items[key]
-	if !ok {
-		return "", fmt.Errorf("ERR stream key %s does not exist", key)
+	stream, err := s.lookupStream(key)
+	if err != nil {
+		return "", err
 	}
-
-	if len(fields)%2 != 0 {
-		return "", errors.New("ERR wrong number of arguments for XADD")
+	if stream == nil && opts.NoMkStream {
+		return "", nil
 	}
-
-	if item.value.Type() != StreamType {
-		return "", fmt.Errorf("ERR Invalid type for key %s - %v", key, item.value.Type())
+	created := stream == nil
+	if created {
+		stream = &StreamValue{tree: art.NewART()}
 	}
-	stream := item.value.(*StreamValue)
-	EIDTimestamp, EIDSequence, err := stream.parseEntryID(entryID)
+	id, err := stream.parseEntryID(entryID)
 	if err != nil {
 		return "", err
 	}
-	if err := stream.validateEntryID(EIDTimestamp, EIDSequence); err != nil {
-		return "", err
+	if created {
+		s.setItem(key, Item{value: stream})
 	}
-	strEntryID := fmt.Sprintf("%d-%d", EIDTimestamp, EIDSequence)
-	stream.tree.Insert([]byte(strEntryID), fields)
-	stream.setLastEntryID(EIDTimestamp, EIDSequence)
-	return strEntryID, nil
+	stream.tree.Insert(id.key(), slices.Clone(fields))
+	stream.lastID = id
+	stream.entriesAdded++
+	stream.trim(opts.Trim, minID)
+	return id.String(), nil
 }
 
-func (s *InMemoryStore) Range(key string, start, end []byte) []StreamEntry {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	item, ok := s.items[key]
-	if !ok {
-		return nil
+// StreamTrim trims the stream at key as opts asks and returns the number of
+// entries removed.
+func (s *InMemoryStore) StreamTrim(key string, opts StreamTrimOptions) (int, error) {
+	minID, err := parseTrimMinID(opts)
+	if err != nil {
+		return 0, err
 	}
 
-	if item.value.Type() != StreamType {
-		return nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.lookupStream(key)
+	if stream == nil {
+		return 0, err
 	}
+	return stream.trim(opts, minID), nil
+}
 
-	stream := item.value.(*StreamValue)
+func parseTrimMinID(opts StreamTrimOptions) (streamID, error) {
+	if opts.Strategy != TrimMinID {
+		return streamID{}, nil
+	}
+	return parseStreamID(opts.MinID, 0)
+}
 
-	startID := string(start)
-	endID := string(end)
-	// Convert "-" to minimum possible ID
-	if startID == "-" {
-		startID = "0-0"
+// trim removes the oldest entries that opts selects, and returns how many it
+// removed.
+func (s *StreamValue) trim(opts StreamTrimOptions, minID streamID) int {
+	if opts.Strategy == TrimNone {
+		return 0
 	}
+	excess := int64(s.tree.Len()) - opts.MaxLen
+	var keys [][]byte
+	s.tree.Walk(func(key []byte, value interface{}) bool {
+		n := int64(len(keys))
+		if opts.Limit > 0 && n >= opts.Limit {
+			return false
+		}
+		if opts.Strategy == TrimMaxLen && n >= excess ||
+			opts.Strategy == TrimMinID && !streamIDFromKey(key).less(minID) {
+			return false
+		}
+		keys = append(keys, key)
+		return true
+	})
+	for _, key := range keys {
+		s.tree.Delete(key)
+	}
+	return len(keys)
+}
 
-	// Convert "+" to maximum possible ID
-	if endID == "+" {
-		endID = "9223372036854775807-9223372036854775807" // Max int64-int64
+// StreamDelete deletes the entries with the given IDs from the stream at key
+// and returns how many existed.
+func (s *InMemoryStore) StreamDelete(key string, ids [][]byte) (int, error) {
+	parsed := make([]streamID, len(ids))
+	for i, arg := range ids {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return 0, err
+		}
+		parsed[i] = id
 	}
 
-	// Get range from the ART
-	entries := stream.tree.Range([]byte(startID), []byte(endID))
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Convert to array of entries
-	result := make([]StreamEntry, 0, len(entries))
-	for id, value := range entries {
-		keyVals, err := parseStreamValue(value)
-		if err != nil {
-			log.Printf("Error parsing stream value: %v", err)
+	stream, err := s.lookupStream(key)
+	if stream == nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, id := range parsed {
+		if !stream.tree.Delete(id.key()) {
 			continue
 		}
-		result = append(result, StreamEntry{
-			ID:    id,
-			Value: keyVals,
-		})
+		deleted++
+		if stream.maxDeletedID.less(id) {
+			stream.maxDeletedID = id
+		}
 	}
+	return deleted, nil
+}
 
-	// Sort entries by ID
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].ID < result[j].ID
-	})
-	return result
+// StreamLen returns the number of entries in the stream at key.
+func (s *InMemoryStore) StreamLen(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream, err := s.lookupStream(key)
+	if stream == nil {
+		return 0, err
+	}
+	return stream.tree.Len(), nil
 }
 
-func (s *StreamValue) parseEntryID(entryID []byte) (timestamp int64, sequence int64, err error) {
-	if string(entryID) == "*" {
-		timestamp = time.Now().UnixMilli()
-		sequence = s.generateEntryIDSequence(timestamp)
-	} else {
-		entry := strings.Split(string(entryID), "-")
-		if len(entry) != 2 {
-			return 0, 0, errors.New("ERR Invalid EntryID format")
+// StreamSetID sets the last ID of the stream at key, and its count of added
+// entries and largest deleted ID unless entriesAdded is negative and
+// maxDeletedID is nil.
+func (s *InMemoryStore) StreamSetID(key string, id []byte, entriesAdded int64, maxDeletedID []byte) error {
+	lastID, err := parseStreamID(id, 0)
+	if err != nil {
+		return err
+	}
+	var maxDeleted streamID
+	if maxDeletedID != nil {
+		if maxDeleted, err = parseStreamID(maxDeletedID, 0); err != nil {
+			return err
 		}
-
-		timestamp, err = strconv.ParseInt(entry[0], 10, 64)
-		if err != nil {
-			return 0, 0, errors.New("ERR Invalid EntryID format")
+		if lastID.less(maxDeleted) {
+			return errors.New("ERR The ID specified in XSETID is smaller than the provided max_deleted_entry_id")
 		}
+	}
 
-		if entry[1] == "*" {
-			sequence = s.generateEntryIDSequence(timestamp)
-		} else {
-			sequence, err = strconv.ParseInt(entry[1], 10, 64)
-			if err != nil {
-				return 0, 0, errors.New("ERR Invalid EntryID format")
-			}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.lookupStream(key)
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		return ErrNoSuchKey
+	}
+	if lastID.less(stream.maxDeletedID) {
+		return errors.New("ERR The ID specified in XSETID is smaller than current max_deleted_entry_id")
+	}
+	if last, ok := stream.lastEntryID(); ok {
+		if lastID.less(last) {
+			return errors.New("ERR The ID specified in XSETID is smaller than the target stream top item")
 		}
+		if entriesAdded >= 0 && entriesAdded < int64(stream.tree.Len()) {
+			return errors.New("ERR The entries_added specified in XSETID is smaller than the target stream length")
+		}
+	}
+	stream.lastID = lastID
+	if entriesAdded >= 0 {
+		stream.entriesAdded = uint64(entriesAdded)
+	}
+	if maxDeleted != (streamID{}) {
+		stream.maxDeletedID = maxDeleted
 	}
-	return timestamp, sequence, err
+	return nil
+}
+
+// firstEntryID returns the ID of the first entry of the stream, failing if it
+// is empty.
+func (s *StreamValue) firstEntryID() (streamID, bool) {
+	var id streamID
+	found := false
+	s.tree.Walk(func(key []byte, value interface{}) bool {
+		id, found = streamIDFromKey(key), true
+		return false
+	})
+	return id, found
+}
+
+// lastEntryID returns the ID of the last entry of the stream, failing if it
+// is empty.
+func (s *StreamValue) lastEntryID() (streamID, bool) {
+	var id streamID
+	found := false
+	s.tree.ReverseRange(streamID{}.key(), maxStreamID.key(), func(key []byte, value interface{}) bool {
+		id, found = streamIDFromKey(key), true
+		return false
+	})
+	return id, found
 }
 
-func (s *StreamValue) generateEntryIDSequence(timestamp int64) (sequence int64) {
-	if timestamp == s.lastEntryIDTimestamp {
-		sequence = s.lastEntryIDSequence + 1
+// Range returns the entries of the stream at key from start to end, at most
+// count of them unless count is 0. The bounds are - and + for the ends of the
+// stream, or IDs that are excluded when prefixed with (. The sequence number
+// of an incomplete ID is the lowest one for start and the highest one for
+// end.
+func (s *InMemoryStore) Range(key string, start, end []byte, count int) ([]StreamEntry, error) {
+	startID, err := parseRangeID(start, true)
+	if err != nil {
+		return nil, err
 	}
-	if timestamp == 0 {
-		sequence = 1
+	endID, err := parseRangeID(end, false)
+	if err != nil {
+		return nil, err
 	}
-	return sequence
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream, err := s.lookupStream(key)
+	if stream == nil {
+		return nil, err
+	}
+	var result []StreamEntry
+	stream.tree.Range(startID.key(), endID.key(), func(key []byte, value interface{}) bool {
+		result = append(result, streamEntry(key, value))
+		return count <= 0 || len(result) < count
+	})
+	return result, nil
 }
 
-func (s *StreamValue) validateEntryID(timestamp, sequence int64) error {
-	if timestamp <= 0 && sequence <= 0 {
-		return errors.New("ERR The ID specified in XADD must be greater than 0-0")
+// ReverseRange is like Range, returning the entries from end to start.
+func (s *InMemoryStore) ReverseRange(key string, end, start []byte, count int) ([]StreamEntry, error) {
+	endID, err := parseRangeID(end, false)
+	if err != nil {
+		return nil, err
 	}
-	if timestamp < s.lastEntryIDTimestamp ||
-		(timestamp == s.lastEntryIDTimestamp && sequence <= s.lastEntryIDSequence) {
-		return errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	startID, err := parseRangeID(start, true)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream, err := s.lookupStream(key)
+	if stream == nil {
+		return nil, err
+	}
+	var result []StreamEntry
+	stream.tree.ReverseRange(startID.key(), endID.key(), func(key []byte, value interface{}) bool {
+		result = append(result, streamEntry(key, value))
+		return count <= 0 || len(result) < count
+	})
+	return result, nil
+}
+
+// streamEntry returns the entry stored in the tree of a stream under key.
+func streamEntry(key []byte, value interface{}) StreamEntry {
+	keyVals, err := parseStreamValue(value)
+	if err != nil {
+		log.Printf("Error parsing stream value: %v", err)
+	}
+	return StreamEntry{
+		ID:    streamIDFromKey(key).String(),
+		Value: keyVals,
+	}
+}
+
+// parseEntryID returns the ID of an entry added with entryID, which must be
+// larger than the last ID of the stream.
+func (s *StreamValue) parseEntryID(entryID []byte) (streamID, error) {
+	if string(entryID) == "*" {
+		ms := uint64(time.Now().UnixMilli())
+		if ms > s.lastID.ms {
+			return streamID{ms, 0}, nil
+		}
+		id, ok := s.lastID.next()
+		if !ok {
+			return id, errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+		}
+		return id, nil
+	}
+	if ms, found := bytes.CutSuffix(entryID, []byte("-*")); found {
+		id, err := parseStreamID(ms, 0)
+		if err != nil || bytes.IndexByte(ms, '-') != -1 {
+			return streamID{}, ErrInvalidStreamID
+		}
+		// The sequence number is 1 in an empty stream as 0-0 is not a
+		// valid ID.
+		if id.ms == s.lastID.ms {
+			if s.lastID.seq == math.MaxUint64 {
+				return id, errXAddTooSmall
+			}
+			id.seq = s.lastID.seq + 1
+		}
+		return id, s.validateEntryID(id)
+	}
+	id, err := parseStreamID(entryID, 0)
+	if err != nil {
+		return id, err
+	}
+	if id == (streamID{}) {
+		return id, errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	}
+	return id, s.validateEntryID(id)
+}
+
+var errXAddTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+
+func (s *StreamValue) validateEntryID(id streamID) error {
+	if !s.lastID.less(id) {
+		return errXAddTooSmall
 	}
 	return nil
 }
@@ -215,3 +644,94 @@ func parseStreamValue(value interface{}) ([]KeyVal, error) {
 	}
 	return result, nil
 }
+
+// export returns the content of the stream for persistence.
+func (s *StreamValue) export() *persistence.Stream {
+	stream := &persistence.Stream{
+		LastID:       s.lastID.persistent(),
+		MaxDeletedID: s.maxDeletedID.persistent(),
+		EntriesAdded: s.entriesAdded,
+	}
+	s.tree.Walk(func(key []byte, value interface{}) bool {
+		stream.Entries = append(stream.Entries, persistence.StreamEntry{
+			ID:     streamIDFromKey(key).persistent(),
+			Fields: value.([]string),
+		})
+		return true
+	})
+	if len(stream.Entries) > 0 {
+		stream.FirstID = stream.Entries[0].ID
+	}
+
+	names := make([]string, 0, len(s.groups))
+	for name := range s.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g := s.groups[name]
+		group := persistence.StreamGroup{Name: name, LastID: g.lastID.persistent(), EntriesRead: g.entriesRead}
+		g.pel.Walk(func(key []byte, value interface{}) bool {
+			p := value.(*pendingEntry)
+			group.Pending = append(group.Pending, persistence.StreamPendingEntry{
+				ID:            streamIDFromKey(key).persistent(),
+				Consumer:      p.consumer.name,
+				DeliveryTime:  p.deliveryTime,
+				DeliveryCount: uint64(p.deliveryCount),
+			})
+			return true
+		})
+		for _, c := range g.consumers {
+			group.Consumers = append(group.Consumers, persistence.StreamConsumer{
+				Name:       c.name,
+				SeenTime:   c.seenTime,
+				ActiveTime: c.activeTime,
+			})
+		}
+		sort.Slice(group.Consumers, func(i, j int) bool {
+			return group.Consumers[i].Name < group.Consumers[j].Name
+		})
+		stream.Groups = append(stream.Groups, group)
+	}
+	return stream
+}
+
+// loadStream builds a stream from its persisted content.
+func loadStream(stream *persistence.Stream) *StreamValue {
+	s := &StreamValue{
+		tree:         art.NewART(),
+		lastID:       streamID{stream.LastID.Ms, stream.LastID.Seq},
+		maxDeletedID: streamID{stream.MaxDeletedID.Ms, stream.MaxDeletedID.Seq},
+		entriesAdded: stream.EntriesAdded,
+	}
+	for _, entry := range stream.Entries {
+		s.tree.Insert(streamID{entry.ID.Ms, entry.ID.Seq}.key(), entry.Fields)
+	}
+	for _, group := range stream.Groups {
+		g := newStreamGroup(streamID{group.LastID.Ms, group.LastID.Seq}, group.EntriesRead)
+		for _, consumer := range group.Consumers {
+			g.consumers[consumer.Name] = &streamConsumer{
+				name:       consumer.Name,
+				seenTime:   consumer.SeenTime,
+				activeTime: consumer.ActiveTime,
+				pel:        art.NewART(),
+			}
+		}
+		for _, entry := range group.Pending {
+			key := streamID{entry.ID.Ms, entry.ID.Seq}.key()
+			c, _ := g.consumer(entry.Consumer, entry.DeliveryTime)
+			p := &pendingEntry{deliveryTime: entry.DeliveryTime, deliveryCount: int64(entry.DeliveryCount)}
+			g.pel.Insert(key, p)
+			g.claim(key, p, c)
+		}
+		if s.groups == nil {
+			s.groups = make(map[string]*streamGroup)
+		}
+		s.groups[group.Name] = g
+	}
+	return s
+}
+
+func (id streamID) persistent() persistence.StreamID {
+	return persistence.StreamID{Ms: id.ms, Seq: id.seq}
+}
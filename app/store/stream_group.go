@@ -0,0 +1,737 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/store/art"
+)
+
+var (
+	ErrBusyGroup   = errors.New("BUSYGROUP Consumer Group name already exists")
+	ErrNoStreamKey = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	ErrGroupDollar = errors.New("ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set.")
+)
+
+// noGroupError is the error of the XGROUP subcommands for a missing group.
+func noGroupError(key, group string) error {
+	return fmt.Errorf("NOGROUP No such consumer group '%s' for key name '%s'", group, key)
+}
+
+// noKeyOrGroupError is the error of the commands that read the entries of a
+// group when the stream or the group is missing.
+func noKeyOrGroupError(key, group string) error {
+	return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+}
+
+// streamGroup is a consumer group of a stream.
+type streamGroup struct {
+	// lastID is the ID of the last entry delivered to the group.
+	lastID streamID
+	// entriesRead counts the entries of the stream read by the group up to
+	// lastID, or is -1 when it is unknown.
+	entriesRead int64
+	// pel is the pending entries list of the group: the keys of the IDs
+	// delivered to its consumers and not acknowledged yet, mapped to their
+	// *pendingEntry.
+	pel       *art.ART
+	consumers map[string]*streamConsumer
+}
+
+type pendingEntry struct {
+	consumer *streamConsumer
+	// deliveryTime is the unix time in milliseconds of the last delivery.
+	deliveryTime  int64
+	deliveryCount int64
+}
+
+type streamConsumer struct {
+	name string
+	// seenTime is the unix time in milliseconds of the last attempt of the
+	// consumer to read or claim entries, and activeTime the one of the last
+	// successful attempt, or -1 if there was none.
+	seenTime, activeTime int64
+	// pel holds the entries of the pending entries list of the group that
+	// were delivered to the consumer.
+	pel *art.ART
+}
+
+// PendingEntry describes an entry delivered to a consumer and not
+// acknowledged yet. DeliveryTime is the unix time in milliseconds of its
+// last delivery, Idle the milliseconds elapsed since.
+type PendingEntry struct {
+	ID            string
+	Consumer      string
+	DeliveryTime  int64
+	Idle          int64
+	DeliveryCount int64
+}
+
+// PendingSummary describes the pending entries list of a group: the number
+// of entries, the smallest and largest IDs and the entries of each consumer
+// that has any, by consumer name.
+type PendingSummary struct {
+	Count       int
+	First, Last string
+	Consumers   []ConsumerPending
+}
+
+type ConsumerPending struct {
+	Name  string
+	Count int
+}
+
+// GroupUpdate describes how a command changed the consumer group of a
+// stream, for it to be propagated to replicas.
+type GroupUpdate struct {
+	// ConsumerCreated reports whether the consumer was created.
+	ConsumerCreated bool
+	// Claimed holds the pending entries that were delivered or claimed.
+	Claimed []PendingEntry
+	// Removed holds the IDs that left the pending entries list because
+	// their entries were deleted.
+	Removed []string
+	// LastID is the last ID delivered to the group, and LastIDChanged
+	// reports whether the command changed it. EntriesRead is the count of
+	// entries read by the group.
+	LastID        string
+	LastIDChanged bool
+	EntriesRead   int64
+}
+
+// StreamClaimOptions are the options of XCLAIM.
+type StreamClaimOptions struct {
+	// DeliveryTime is the delivery time set on the claimed entries.
+	DeliveryTime int64
+	// RetryCount sets the delivery count of the claimed entries, unless it
+	// is negative and the count is incremented.
+	RetryCount int64
+	// Force claims IDs that are not pending, as long as the entries exist.
+	Force bool
+	// JustID only returns the IDs of the claimed entries and leaves their
+	// delivery count unchanged.
+	JustID bool
+	// LastID, when set, becomes the last delivered ID of the group if it is
+	// larger.
+	LastID []byte
+}
+
+func newStreamGroup(lastID streamID, entriesRead int64) *streamGroup {
+	return &streamGroup{
+		lastID:      lastID,
+		entriesRead: entriesRead,
+		pel:         art.NewART(),
+		consumers:   make(map[string]*streamConsumer),
+	}
+}
+
+// consumer returns the consumer called name, creating it if needed, and
+// reports whether it was created.
+func (g *streamGroup) consumer(name string, now int64) (*streamConsumer, bool) {
+	if c, ok := g.consumers[name]; ok {
+		return c, false
+	}
+	c := &streamConsumer{name: name, seenTime: now, activeTime: -1, pel: art.NewART()}
+	g.consumers[name] = c
+	return c, true
+}
+
+// claim makes c the owner of the pending entry p stored under key.
+func (g *streamGroup) claim(key []byte, p *pendingEntry, c *streamConsumer) {
+	if p.consumer == c {
+		return
+	}
+	if p.consumer != nil {
+		p.consumer.pel.Delete(key)
+	}
+	p.consumer = c
+	c.pel.Insert(bytes.Clone(key), p)
+}
+
+// deliver records the delivery of the entry stored under key to c as a first
+// delivery, even if it was delivered before.
+func (g *streamGroup) deliver(key []byte, c *streamConsumer, now int64) *pendingEntry {
+	var p *pendingEntry
+	if value, ok := g.pel.Select(key); ok {
+		p = value.(*pendingEntry)
+	} else {
+		p = &pendingEntry{}
+		g.pel.Insert(bytes.Clone(key), p)
+	}
+	g.claim(key, p, c)
+	p.deliveryTime = now
+	p.deliveryCount = 1
+	return p
+}
+
+// advance makes id, an entry of s, the last one delivered to the group, and
+// keeps count of the entries read while no deleted entry lies ahead.
+func (g *streamGroup) advance(s *StreamValue, id streamID) {
+	if g.entriesRead >= 0 && !s.hasTombstonesFrom(id) {
+		g.entriesRead++
+	} else if s.entriesAdded > 0 {
+		g.entriesRead = s.entriesReadAt(id)
+	}
+	g.lastID = id
+}
+
+// ack removes the entry stored under key from the pending entries list and
+// reports whether it was there.
+func (g *streamGroup) ack(key []byte) bool {
+	value, ok := g.pel.Select(key)
+	if !ok {
+		return false
+	}
+	if p := value.(*pendingEntry); p.consumer != nil {
+		p.consumer.pel.Delete(key)
+	}
+	return g.pel.Delete(key)
+}
+
+func (g *streamGroup) clone() *streamGroup {
+	clone := newStreamGroup(g.lastID, g.entriesRead)
+	for name, c := range g.consumers {
+		clone.consumers[name] = &streamConsumer{
+			name:       name,
+			seenTime:   c.seenTime,
+			activeTime: c.activeTime,
+			pel:        art.NewART(),
+		}
+	}
+	g.pel.Walk(func(key []byte, value interface{}) bool {
+		p := value.(*pendingEntry)
+		c := clone.consumers[p.consumer.name]
+		entry := &pendingEntry{consumer: c, deliveryTime: p.deliveryTime, deliveryCount: p.deliveryCount}
+		clone.pel.Insert(bytes.Clone(key), entry)
+		c.pel.Insert(bytes.Clone(key), entry)
+		return true
+	})
+	return clone
+}
+
+func (p *pendingEntry) describe(key []byte, now int64) PendingEntry {
+	return PendingEntry{
+		ID:            streamIDFromKey(key).String(),
+		Consumer:      p.consumer.name,
+		DeliveryTime:  p.deliveryTime,
+		Idle:          max(now-p.deliveryTime, 0),
+		DeliveryCount: p.deliveryCount,
+	}
+}
+
+// lookupGroup returns the stream stored at key and its consumer group called
+// group, which are nil when missing. The caller must hold the lock.
+func (s *InMemoryStore) lookupGroup(key, group string) (*StreamValue, *streamGroup, error) {
+	stream, err := s.lookupStream(key)
+	if stream == nil {
+		return nil, nil, err
+	}
+	return stream, stream.groups[group], nil
+}
+
+// lookupGroupForXGroup returns the consumer group for the XGROUP
+// subcommands, which fail when the stream or the group are missing.
+func (s *InMemoryStore) lookupGroupForXGroup(key, group string) (*streamGroup, error) {
+	stream, g, err := s.lookupGroup(key, group)
+	switch {
+	case err != nil:
+		return nil, err
+	case stream == nil:
+		return nil, ErrNoStreamKey
+	case g == nil:
+		return nil, noGroupError(key, group)
+	}
+	return g, nil
+}
+
+// parseGroupID parses the ID of XGROUP CREATE and SETID, where $ is the last
+// ID of the stream.
+func parseGroupID(stream *StreamValue, id []byte) (streamID, error) {
+	if string(id) == "$" {
+		if stream == nil {
+			return streamID{}, nil
+		}
+		return stream.lastID, nil
+	}
+	return parseStreamID(id, 0)
+}
+
+// StreamGroupCreate creates a consumer group whose last delivered ID is id,
+// and that read entriesRead entries of the stream, or -1 when unknown. With
+// mkstream, a missing stream is created empty.
+func (s *InMemoryStore) StreamGroupCreate(key, group string, id []byte, entriesRead int64, mkstream bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.lookupStream(key)
+	if err != nil {
+		return err
+	}
+	if stream == nil && !mkstream {
+		return ErrNoStreamKey
+	}
+	lastID, err := parseGroupID(stream, id)
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		stream = &StreamValue{tree: art.NewART()}
+		s.setItem(key, Item{value: stream})
+	}
+	if _, ok := stream.groups[group]; ok {
+		return ErrBusyGroup
+	}
+	if stream.groups == nil {
+		stream.groups = make(map[string]*streamGroup)
+	}
+	stream.groups[group] = newStreamGroup(lastID, entriesRead)
+	return nil
+}
+
+// StreamGroupSetID sets the last delivered ID of a consumer group and its
+// count of entries read, which is -1 when unknown.
+func (s *InMemoryStore) StreamGroupSetID(key, group string, id []byte, entriesRead int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.lookupGroupForXGroup(key, group)
+	if err != nil {
+		return err
+	}
+	stream, _ := s.lookupStream(key)
+	lastID, err := parseGroupID(stream, id)
+	if err != nil {
+		return err
+	}
+	g.lastID = lastID
+	g.entriesRead = entriesRead
+	return nil
+}
+
+// StreamGroupDestroy deletes a consumer group and reports whether it existed.
+func (s *InMemoryStore) StreamGroupDestroy(key, group string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return false, err
+	}
+	if stream == nil {
+		return false, ErrNoStreamKey
+	}
+	if g == nil {
+		return false, nil
+	}
+	delete(stream.groups, group)
+	return true, nil
+}
+
+// StreamCreateConsumer adds a consumer to a group and reports whether it was
+// created.
+func (s *InMemoryStore) StreamCreateConsumer(key, group, consumer string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.lookupGroupForXGroup(key, group)
+	if err != nil {
+		return false, err
+	}
+	_, created := g.consumer(consumer, time.Now().UnixMilli())
+	return created, nil
+}
+
+// StreamDeleteConsumer removes a consumer from a group along with its pending
+// entries, and returns how many it had.
+func (s *InMemoryStore) StreamDeleteConsumer(key, group, consumer string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.lookupGroupForXGroup(key, group)
+	if err != nil {
+		return 0, err
+	}
+	c, ok := g.consumers[consumer]
+	if !ok {
+		return 0, nil
+	}
+	pending := c.pel.Len()
+	var keys [][]byte
+	c.pel.Walk(func(key []byte, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	for _, key := range keys {
+		g.ack(key)
+	}
+	delete(g.consumers, consumer)
+	return pending, nil
+}
+
+// StreamReadGroup reads entries from the streams at keys as consumer of
+// group, creating the consumer if needed. An ID of > reads the entries that
+// were never delivered to the group, at most count of them unless count is
+// 0, and adds them to the pending entries list unless noack is set. Any other
+// ID reads the pending entries of the consumer past it, where entries that
+// were deleted have a nil Value. The groups of all the keys are checked
+// before reading any of them. It returns the entries read from each key and
+// the changes made to its group.
+func (s *InMemoryStore) StreamReadGroup(group, consumer string, keys []string, ids [][]byte, count int, noack bool) ([][]StreamEntry, []GroupUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	streams := make([]*StreamValue, len(keys))
+	groups := make([]*streamGroup, len(keys))
+	starts := make([]streamID, len(keys))
+	for i, key := range keys {
+		stream, g, err := s.lookupGroup(key, group)
+		if err != nil {
+			return nil, nil, err
+		}
+		if g == nil {
+			return nil, nil, fmt.Errorf("%w in XREADGROUP with GROUP option", noKeyOrGroupError(key, group))
+		}
+		streams[i], groups[i] = stream, g
+		switch string(ids[i]) {
+		case ">":
+		case "$":
+			return nil, nil, ErrGroupDollar
+		default:
+			if starts[i], err = parseStreamID(ids[i], 0); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	entries := make([][]StreamEntry, len(keys))
+	updates := make([]GroupUpdate, len(keys))
+	for i, g := range groups {
+		c, created := g.consumer(consumer, now)
+		c.seenTime = now
+		if string(ids[i]) == ">" {
+			entries[i], updates[i] = streams[i].readNew(g, c, count, noack, now)
+		} else {
+			entries[i], updates[i] = streams[i].readPending(g, c, starts[i], count, now)
+		}
+		updates[i].ConsumerCreated = created
+		updates[i].LastID = g.lastID.String()
+		updates[i].EntriesRead = g.entriesRead
+	}
+	return entries, updates, nil
+}
+
+// readNew delivers the entries past the last delivered ID of g to c.
+func (s *StreamValue) readNew(g *streamGroup, c *streamConsumer, count int, noack bool, now int64) ([]StreamEntry, GroupUpdate) {
+	var entries []StreamEntry
+	var update GroupUpdate
+	start, ok := g.lastID.next()
+	if !ok {
+		return nil, update
+	}
+	s.tree.Range(start.key(), maxStreamID.key(), func(key []byte, value interface{}) bool {
+		entries = append(entries, streamEntry(key, value))
+		g.advance(s, streamIDFromKey(key))
+		if !noack {
+			p := g.deliver(key, c, now)
+			update.Claimed = append(update.Claimed, p.describe(key, now))
+		}
+		return count <= 0 || len(entries) < count
+	})
+	if len(entries) > 0 {
+		update.LastIDChanged = true
+		c.activeTime = now
+	}
+	return entries, update
+}
+
+// readPending delivers again the pending entries of c past start.
+func (s *StreamValue) readPending(g *streamGroup, c *streamConsumer, start streamID, count int, now int64) ([]StreamEntry, GroupUpdate) {
+	entries := []StreamEntry{}
+	var update GroupUpdate
+	start, ok := start.next()
+	if !ok {
+		return entries, update
+	}
+	c.pel.Range(start.key(), maxStreamID.key(), func(key []byte, value interface{}) bool {
+		fields, ok := s.tree.Select(key)
+		if !ok {
+			entries = append(entries, StreamEntry{ID: streamIDFromKey(key).String()})
+		} else {
+			entries = append(entries, streamEntry(key, fields))
+			p := value.(*pendingEntry)
+			p.deliveryTime = now
+			p.deliveryCount++
+			update.Claimed = append(update.Claimed, p.describe(key, now))
+		}
+		return count <= 0 || len(entries) < count
+	})
+	return entries, update
+}
+
+// StreamAck removes ids from the pending entries list of a group and returns
+// how many were pending.
+func (s *InMemoryStore) StreamAck(key, group string, ids [][]byte) (int, error) {
+	parsed := make([]streamID, len(ids))
+	for i, arg := range ids {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return 0, err
+		}
+		parsed[i] = id
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.lookupGroup(key, group)
+	if g == nil {
+		return 0, err
+	}
+	acked := 0
+	for _, id := range parsed {
+		if g.ack(id.key()) {
+			acked++
+		}
+	}
+	return acked, nil
+}
+
+// StreamPendingSummary describes the pending entries list of a group.
+func (s *InMemoryStore) StreamPendingSummary(key, group string) (PendingSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var summary PendingSummary
+	_, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return summary, err
+	}
+	if g == nil {
+		return summary, noKeyOrGroupError(key, group)
+	}
+	summary.Count = g.pel.Len()
+	g.pel.Walk(func(key []byte, _ interface{}) bool {
+		summary.First = streamIDFromKey(key).String()
+		return false
+	})
+	g.pel.ReverseRange(streamID{}.key(), maxStreamID.key(), func(key []byte, _ interface{}) bool {
+		summary.Last = streamIDFromKey(key).String()
+		return false
+	})
+	for name, c := range g.consumers {
+		if n := c.pel.Len(); n > 0 {
+			summary.Consumers = append(summary.Consumers, ConsumerPending{Name: name, Count: n})
+		}
+	}
+	sort.Slice(summary.Consumers, func(i, j int) bool {
+		return summary.Consumers[i].Name < summary.Consumers[j].Name
+	})
+	return summary, nil
+}
+
+// StreamPendingRange returns at most count pending entries of a group
+// between start and end, which are parsed like the bounds of Range. When
+// consumer is not empty, only its entries are returned. Entries idle for
+// less than minIdle milliseconds are skipped.
+func (s *InMemoryStore) StreamPendingRange(key, group string, start, end []byte, count int, consumer string, minIdle int64) ([]PendingEntry, error) {
+	startID, err := parseRangeID(start, true)
+	if err != nil {
+		return nil, err
+	}
+	endID, err := parseRangeID(end, false)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, noKeyOrGroupError(key, group)
+	}
+	pel := g.pel
+	if consumer != "" {
+		c, ok := g.consumers[consumer]
+		if !ok {
+			return nil, nil
+		}
+		pel = c.pel
+	}
+	if count <= 0 {
+		return nil, nil
+	}
+	now := time.Now().UnixMilli()
+	var entries []PendingEntry
+	pel.Range(startID.key(), endID.key(), func(key []byte, value interface{}) bool {
+		p := value.(*pendingEntry)
+		if now-p.deliveryTime < minIdle {
+			return true
+		}
+		entries = append(entries, p.describe(key, now))
+		return len(entries) < count
+	})
+	return entries, nil
+}
+
+// StreamClaim gives consumer the ownership of the pending entries with ids
+// that have been idle for at least minIdle milliseconds, creating the
+// consumer if needed. IDs whose entries were deleted are removed from the
+// pending entries list instead. It returns the claimed entries, with only
+// their IDs under the JustID option.
+func (s *InMemoryStore) StreamClaim(key, group, consumer string, minIdle int64, ids [][]byte, opts StreamClaimOptions) ([]StreamEntry, GroupUpdate, error) {
+	var update GroupUpdate
+	parsed := make([]streamID, len(ids))
+	for i, arg := range ids {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return nil, update, err
+		}
+		parsed[i] = id
+	}
+	var lastID streamID
+	if opts.LastID != nil {
+		var err error
+		if lastID, err = parseStreamID(opts.LastID, 0); err != nil {
+			return nil, update, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return nil, update, err
+	}
+	if g == nil {
+		return nil, update, noKeyOrGroupError(key, group)
+	}
+	now := time.Now().UnixMilli()
+	if g.lastID.less(lastID) {
+		g.lastID = lastID
+		update.LastIDChanged = true
+	}
+	c, created := g.consumer(consumer, now)
+	c.seenTime = now
+	update.ConsumerCreated = created
+
+	var entries []StreamEntry
+	for _, id := range parsed {
+		key := id.key()
+		fields, exists := stream.tree.Select(key)
+		var p *pendingEntry
+		if value, ok := g.pel.Select(key); ok {
+			p = value.(*pendingEntry)
+		} else if opts.Force && exists {
+			p = &pendingEntry{deliveryTime: now, deliveryCount: 1}
+			g.pel.Insert(key, p)
+		} else {
+			continue
+		}
+		if !exists {
+			g.ack(key)
+			update.Removed = append(update.Removed, id.String())
+			continue
+		}
+		// Entries created by Force have no owner and are claimed whatever
+		// their idle time.
+		if p.consumer != nil && now-p.deliveryTime < minIdle {
+			continue
+		}
+		g.claim(key, p, c)
+		p.deliveryTime = opts.DeliveryTime
+		if opts.RetryCount >= 0 {
+			p.deliveryCount = opts.RetryCount
+		} else if !opts.JustID {
+			p.deliveryCount++
+		}
+		c.activeTime = now
+		if opts.JustID {
+			entries = append(entries, StreamEntry{ID: id.String()})
+		} else {
+			entries = append(entries, streamEntry(key, fields))
+		}
+		update.Claimed = append(update.Claimed, p.describe(key, now))
+	}
+	update.LastID = g.lastID.String()
+	update.EntriesRead = g.entriesRead
+	return entries, update, nil
+}
+
+// StreamAutoClaim claims like StreamClaim at most count pending entries from
+// start on that have been idle for at least minIdle milliseconds, scanning
+// at most ten times count entries. It returns the ID to continue the scan
+// from, which is 0-0 once the end of the pending entries list is reached,
+// along with the claimed entries.
+func (s *InMemoryStore) StreamAutoClaim(key, group, consumer string, minIdle int64, start []byte, count int, justID bool) (string, []StreamEntry, GroupUpdate, error) {
+	var update GroupUpdate
+	startID, err := parseRangeID(start, true)
+	if err != nil {
+		return "", nil, update, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return "", nil, update, err
+	}
+	if g == nil {
+		return "", nil, update, noKeyOrGroupError(key, group)
+	}
+	now := time.Now().UnixMilli()
+	c, created := g.consumer(consumer, now)
+	c.seenTime = now
+	update.ConsumerCreated = created
+
+	var entries []StreamEntry
+	var deleted [][]byte
+	next := streamID{}
+	attempts := count * 10
+	g.pel.Range(startID.key(), maxStreamID.key(), func(key []byte, value interface{}) bool {
+		if attempts == 0 || len(entries) == count {
+			next = streamIDFromKey(key)
+			return false
+		}
+		attempts--
+		fields, exists := stream.tree.Select(key)
+		if !exists {
+			// The tree cannot change while it is walked.
+			deleted = append(deleted, key)
+			return true
+		}
+		p := value.(*pendingEntry)
+		if now-p.deliveryTime < minIdle {
+			return true
+		}
+		g.claim(key, p, c)
+		p.deliveryTime = now
+		if !justID {
+			p.deliveryCount++
+		}
+		c.activeTime = now
+		if justID {
+			entries = append(entries, StreamEntry{ID: streamIDFromKey(key).String()})
+		} else {
+			entries = append(entries, streamEntry(key, fields))
+		}
+		update.Claimed = append(update.Claimed, p.describe(key, now))
+		return true
+	})
+	for _, key := range deleted {
+		update.Removed = append(update.Removed, streamIDFromKey(key).String())
+		g.ack(key)
+	}
+	update.LastID = g.lastID.String()
+	update.EntriesRead = g.entriesRead
+	return next.String(), entries, update, nil
+}
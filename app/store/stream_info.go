@@ -0,0 +1,246 @@
+package store
+
+import (
+	"sort"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/store/art"
+)
+
+// StreamInfo describes a stream for XINFO STREAM. The radix tree counts are
+// those of the tree holding the entries, one key per entry.
+type StreamInfo struct {
+	Length          int
+	RadixTreeKeys   int
+	RadixTreeNodes  int
+	LastGeneratedID string
+	MaxDeletedID    string
+	EntriesAdded    uint64
+	// RecordedFirstID is the ID of the first entry, or 0-0 when empty.
+	RecordedFirstID string
+	Groups          []GroupInfo
+	// FirstEntry and LastEntry are nil in an empty stream.
+	FirstEntry, LastEntry *StreamEntry
+	// Entries holds the first entries of the stream in the full form.
+	Entries []StreamEntry
+}
+
+// GroupInfo describes a consumer group for XINFO. EntriesRead and Lag are -1
+// when they cannot be known.
+type GroupInfo struct {
+	Name            string
+	Consumers       []ConsumerInfo
+	Pending         int
+	LastDeliveredID string
+	EntriesRead     int64
+	Lag             int64
+	// PendingEntries holds the first pending entries in the full form.
+	PendingEntries []PendingEntry
+}
+
+// ConsumerInfo describes a consumer of a group for XINFO. SeenTime and
+// ActiveTime are unix times in milliseconds, and Idle and Inactive the
+// milliseconds elapsed since. ActiveTime and Inactive are -1 for a consumer
+// that never read or claimed an entry.
+type ConsumerInfo struct {
+	Name                 string
+	Pending              int
+	SeenTime, ActiveTime int64
+	Idle, Inactive       int64
+	// PendingEntries holds the first pending entries of the consumer in
+	// the full form.
+	PendingEntries []PendingEntry
+}
+
+// hasTombstonesFrom reports whether an entry deleted with XDEL may lie at or
+// past id, in which case the number of entries between them is unknown.
+func (s *StreamValue) hasTombstonesFrom(id streamID) bool {
+	first, ok := s.firstEntryID()
+	if !ok || s.maxDeletedID == (streamID{}) || s.maxDeletedID.less(first) {
+		return false
+	}
+	return !s.maxDeletedID.less(id)
+}
+
+// entriesReadAt returns the number of entries added to the stream up to id,
+// or -1 when deleted entries make it unknown.
+func (s *StreamValue) entriesReadAt(id streamID) int64 {
+	added := int64(s.entriesAdded)
+	switch {
+	case added == 0:
+		return 0
+	case s.tree.Len() == 0 && !s.lastID.less(id):
+		return added
+	case id == s.lastID:
+		return added
+	case s.lastID.less(id):
+		return -1
+	}
+	first, _ := s.firstEntryID()
+	if s.maxDeletedID == (streamID{}) || s.maxDeletedID.less(first) {
+		// No entry was deleted past the first one.
+		length := int64(s.tree.Len())
+		if id.less(first) {
+			return added - length
+		}
+		if id == first {
+			return added - length + 1
+		}
+	}
+	return -1
+}
+
+// lag returns the number of entries of the stream that the group has yet to
+// read, or -1 when it is unknown.
+func (s *StreamValue) lag(g *streamGroup) int64 {
+	if s.entriesAdded == 0 {
+		return 0
+	}
+	if g.entriesRead >= 0 && !s.hasTombstonesFrom(g.lastID) {
+		return int64(s.entriesAdded) - g.entriesRead
+	}
+	if read := s.entriesReadAt(g.lastID); read >= 0 {
+		return int64(s.entriesAdded) - read
+	}
+	return -1
+}
+
+// StreamInfo describes the stream at key. The full form adds at most count
+// entries of the stream, and at most count pending entries for each group
+// and consumer, unless count is 0, instead of the first and last entries.
+func (s *InMemoryStore) StreamInfo(key string, full bool, count int) (*StreamInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream, err := s.lookupStream(key)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return nil, ErrNoSuchKey
+	}
+	info := &StreamInfo{
+		Length:          stream.tree.Len(),
+		RadixTreeKeys:   stream.tree.Len(),
+		RadixTreeNodes:  stream.tree.NodeCount(),
+		LastGeneratedID: stream.lastID.String(),
+		MaxDeletedID:    stream.maxDeletedID.String(),
+		EntriesAdded:    stream.entriesAdded,
+		RecordedFirstID: streamID{}.String(),
+	}
+	if first, ok := stream.firstEntryID(); ok {
+		info.RecordedFirstID = first.String()
+	}
+	now := time.Now().UnixMilli()
+	info.Groups = stream.groupsInfo(full, count, now)
+	if full {
+		info.Entries = []StreamEntry{}
+		stream.tree.Walk(func(key []byte, value interface{}) bool {
+			info.Entries = append(info.Entries, streamEntry(key, value))
+			return count <= 0 || len(info.Entries) < count
+		})
+		return info, nil
+	}
+	stream.tree.Walk(func(key []byte, value interface{}) bool {
+		entry := streamEntry(key, value)
+		info.FirstEntry = &entry
+		return false
+	})
+	stream.tree.ReverseRange(streamID{}.key(), maxStreamID.key(), func(key []byte, value interface{}) bool {
+		entry := streamEntry(key, value)
+		info.LastEntry = &entry
+		return false
+	})
+	return info, nil
+}
+
+// StreamGroupsInfo describes the consumer groups of the stream at key, by
+// name.
+func (s *InMemoryStore) StreamGroupsInfo(key string) ([]GroupInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream, err := s.lookupStream(key)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return nil, ErrNoSuchKey
+	}
+	return stream.groupsInfo(false, 0, time.Now().UnixMilli()), nil
+}
+
+// StreamConsumersInfo describes the consumers of a group, by name.
+func (s *InMemoryStore) StreamConsumersInfo(key, group string) ([]ConsumerInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream, g, err := s.lookupGroup(key, group)
+	switch {
+	case err != nil:
+		return nil, err
+	case stream == nil:
+		return nil, ErrNoSuchKey
+	case g == nil:
+		return nil, noGroupError(key, group)
+	}
+	return g.consumersInfo(false, 0, time.Now().UnixMilli()), nil
+}
+
+func (s *StreamValue) groupsInfo(full bool, count int, now int64) []GroupInfo {
+	groups := make([]GroupInfo, 0, len(s.groups))
+	for name, g := range s.groups {
+		info := GroupInfo{
+			Name:            name,
+			Consumers:       g.consumersInfo(full, count, now),
+			Pending:         g.pel.Len(),
+			LastDeliveredID: g.lastID.String(),
+			EntriesRead:     g.entriesRead,
+			Lag:             s.lag(g),
+		}
+		if full {
+			info.PendingEntries = pendingEntries(g.pel, count, now)
+		}
+		groups = append(groups, info)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+func (g *streamGroup) consumersInfo(full bool, count int, now int64) []ConsumerInfo {
+	consumers := make([]ConsumerInfo, 0, len(g.consumers))
+	for name, c := range g.consumers {
+		info := ConsumerInfo{
+			Name:       name,
+			Pending:    c.pel.Len(),
+			SeenTime:   c.seenTime,
+			ActiveTime: c.activeTime,
+			Idle:       max(now-c.seenTime, 0),
+			Inactive:   -1,
+		}
+		if c.activeTime >= 0 {
+			info.Inactive = max(now-c.activeTime, 0)
+		}
+		if full {
+			info.PendingEntries = pendingEntries(c.pel, count, now)
+		}
+		consumers = append(consumers, info)
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Name < consumers[j].Name
+	})
+	return consumers
+}
+
+// pendingEntries describes the first count entries of a pending entries list,
+// or all of them if count is 0.
+func pendingEntries(pel *art.ART, count int, now int64) []PendingEntry {
+	entries := []PendingEntry{}
+	pel.Walk(func(key []byte, value interface{}) bool {
+		entries = append(entries, value.(*pendingEntry).describe(key, now))
+		return count <= 0 || len(entries) < count
+	})
+	return entries
+}
@@ -0,0 +1,487 @@
+package store_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/persistence"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+func entryIDs(entries []store.StreamEntry) []string {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	return ids
+}
+
+func TestStream_RangeIsNumeric(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.SetStream("stream")
+	ids := []string{"9-0", "9-9", "9-10", "10-0", "100-1", "1000-0"}
+	for _, id := range ids {
+		if _, err := IMstore.AddStreamEntry("stream", []byte(id), []string{"f", "v"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		start, end string
+		count      int
+		want       []string
+	}{
+		{"-", "+", 0, ids},
+		{"9-5", "100", 0, []string{"9-9", "9-10", "10-0", "100-1"}},
+		{"9", "9", 0, []string{"9-0", "9-9", "9-10"}},
+		{"(9-9", "(100-1", 0, []string{"9-10", "10-0"}},
+		{"10", "+", 2, []string{"10-0", "100-1"}},
+		{"1001", "+", 0, nil},
+	}
+	for _, tt := range tests {
+		entries, err := IMstore.Range("stream", []byte(tt.start), []byte(tt.end), tt.count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := entryIDs(entries); !slices.Equal(got, tt.want) {
+			t.Errorf("Range(%s, %s, %d) = %v, want %v", tt.start, tt.end, tt.count, got, tt.want)
+		}
+	}
+
+	entries, err := IMstore.ReverseRange("stream", []byte("(1000-0"), []byte("9-9"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryIDs(entries), []string{"100-1", "10-0", "9-10"}; !slices.Equal(got, want) {
+		t.Errorf("ReverseRange((1000-0, 9-9, 3) = %v, want %v", got, want)
+	}
+}
+
+func TestStream_RangeErrors(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.Set("string", []byte("value"), 0)
+
+	if entries, err := IMstore.Range("missing", []byte("-"), []byte("+"), 0); entries != nil || err != nil {
+		t.Errorf("Expected no entries for a missing key, got %v, %v", entries, err)
+	}
+	if _, err := IMstore.Range("string", []byte("-"), []byte("+"), 0); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	for _, bounds := range [][2]string{{"x", "+"}, {"-", "1-x"}, {"(-", "+"}, {"1-2-3", "+"}} {
+		if _, err := IMstore.Range("missing", []byte(bounds[0]), []byte(bounds[1]), 0); err != store.ErrInvalidStreamID {
+			t.Errorf("Expected ErrInvalidStreamID for %v, got %v", bounds, err)
+		}
+	}
+	max := fmt.Sprint(uint64(1<<64 - 1))
+	if _, err := IMstore.Range("missing", []byte("("+max+"-"+max), []byte("+"), 0); err == nil {
+		t.Error("Expected an error for an exclusive start past the last ID")
+	}
+}
+
+func TestStream_AddIDs(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.Set("string", []byte("value"), 0)
+
+	tests := []struct {
+		id, want, err string
+	}{
+		{"0-*", "0-1", ""},
+		{"0-0", "", "ERR The ID specified in XADD must be greater than 0-0"},
+		{"5", "5-0", ""},
+		{"5-*", "5-1", ""},
+		{"4-*", "", "ERR The ID specified in XADD is equal or smaller than the target stream top item"},
+		{"5-1", "", "ERR The ID specified in XADD is equal or smaller than the target stream top item"},
+		{"5-x", "", store.ErrInvalidStreamID.Error()},
+		{"1-2-*", "", store.ErrInvalidStreamID.Error()},
+		{"18446744073709551615-18446744073709551615", "18446744073709551615-18446744073709551615", ""},
+		{"*", "", "ERR The stream has exhausted the last possible ID, unable to add more items"},
+	}
+	for _, tt := range tests {
+		id, err := IMstore.AddStreamEntry("stream", []byte(tt.id), []string{"f", "v"})
+		if id != tt.want || tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("AddStreamEntry(%s) = %q, %v, want %q, %q", tt.id, id, err, tt.want, tt.err)
+		}
+	}
+
+	opts := store.StreamAddOptions{NoMkStream: true}
+	if id, err := IMstore.StreamAdd("missing", []byte("*"), []string{"f", "v"}, opts); id != "" || err != nil {
+		t.Errorf("Expected NOMKSTREAM to skip a missing key, got %q, %v", id, err)
+	}
+	if _, err := IMstore.StreamAdd("missing", []byte("0-0"), []string{"f", "v"}, store.StreamAddOptions{}); err == nil {
+		t.Error("Expected an error for an invalid ID")
+	}
+	if IMstore.Exists("missing") != 0 {
+		t.Error("Expected a failed XADD not to create the stream")
+	}
+	if _, err := IMstore.AddStreamEntry("string", []byte("*"), []string{"f", "v"}); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestStream_TrimAndDelete(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	for i := 1; i <= 10; i++ {
+		addEntries(t, IMstore, "stream", fmt.Sprintf("%d-0", i))
+	}
+	allIDs := func() []string {
+		entries, _ := IMstore.Range("stream", []byte("-"), []byte("+"), 0)
+		return entryIDs(entries)
+	}
+
+	if n, _ := IMstore.StreamDelete("stream", [][]byte{[]byte("2"), []byte("4-0"), []byte("4-0"), []byte("11-0")}); n != 2 {
+		t.Errorf("Expected 2 deleted entries, got %d", n)
+	}
+	if _, err := IMstore.StreamDelete("stream", [][]byte{[]byte("3-0"), []byte("bad")}); err != store.ErrInvalidStreamID {
+		t.Errorf("Expected ErrInvalidStreamID, got %v", err)
+	}
+	if n, _ := IMstore.StreamLen("stream"); n != 8 {
+		t.Errorf("Expected 8 entries, got %d", n)
+	}
+
+	limited := store.StreamTrimOptions{Strategy: store.TrimMaxLen, MaxLen: 0, Limit: 2}
+	if n, _ := IMstore.StreamTrim("stream", limited); n != 2 {
+		t.Errorf("Expected LIMIT to cap the trimmed entries at 2, got %d", n)
+	}
+	if n, _ := IMstore.StreamTrim("stream", store.StreamTrimOptions{Strategy: store.TrimMinID, MinID: []byte("7")}); n != 2 {
+		t.Errorf("Expected MINID to trim 2 entries, got %d", n)
+	}
+	if got, want := allIDs(), []string{"7-0", "8-0", "9-0", "10-0"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v after trimming, got %v", want, got)
+	}
+
+	opts := store.StreamAddOptions{Trim: store.StreamTrimOptions{Strategy: store.TrimMaxLen, MaxLen: 2}}
+	if _, err := IMstore.StreamAdd("stream", []byte("11-0"), []string{"f", "v"}, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := allIDs(), []string{"10-0", "11-0"}; !slices.Equal(got, want) {
+		t.Errorf("Expected XADD with MAXLEN to keep %v, got %v", want, got)
+	}
+
+	// Trimming leaves the last ID, the count of added entries and the
+	// largest deleted ID, which survive persistence.
+	loaded := store.NewInMemoryStore()
+	loaded.Load(IMstore.Export())
+	stream := loaded.Export()[0].Stream
+	if stream.LastID != (persistence.StreamID{Ms: 11}) || stream.FirstID != (persistence.StreamID{Ms: 10}) ||
+		stream.MaxDeletedID != (persistence.StreamID{Ms: 4}) || stream.EntriesAdded != 11 {
+		t.Errorf("Unexpected stream metadata %+v", stream)
+	}
+}
+
+func TestStream_SetID(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	addEntries(t, IMstore, "stream", "1-0", "2-0", "3-0")
+	IMstore.StreamDelete("stream", [][]byte{[]byte("3-0")})
+
+	tests := []struct {
+		id           string
+		entriesAdded int64
+		maxDeletedID string
+		err          string
+	}{
+		{"1-5", -1, "", "ERR The ID specified in XSETID is smaller than current max_deleted_entry_id"},
+		{"5-0", -1, "6-0", "ERR The ID specified in XSETID is smaller than the provided max_deleted_entry_id"},
+		{"5-0", 1, "", "ERR The entries_added specified in XSETID is smaller than the target stream length"},
+		{"x", -1, "", store.ErrInvalidStreamID.Error()},
+		{"5-0", 10, "4-0", ""},
+	}
+	for _, tt := range tests {
+		var maxDeletedID []byte
+		if tt.maxDeletedID != "" {
+			maxDeletedID = []byte(tt.maxDeletedID)
+		}
+		err := IMstore.StreamSetID("stream", []byte(tt.id), tt.entriesAdded, maxDeletedID)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("StreamSetID(%s, %d, %s) = %v, want %q", tt.id, tt.entriesAdded, tt.maxDeletedID, err, tt.err)
+		}
+	}
+	if _, err := IMstore.AddStreamEntry("stream", []byte("5-0"), []string{"f", "v"}); err == nil {
+		t.Error("Expected XADD to reject the ID set by XSETID")
+	}
+	IMstore.StreamDelete("stream", [][]byte{[]byte("1-0"), []byte("2-0")})
+	if err := IMstore.StreamSetID("stream", []byte("4-0"), -1, nil); err != nil {
+		t.Errorf("Expected an empty stream to accept any ID above its largest deleted one, got %v", err)
+	}
+	if err := IMstore.StreamSetID("missing", []byte("1-0"), -1, nil); err != store.ErrNoSuchKey {
+		t.Errorf("Expected ErrNoSuchKey, got %v", err)
+	}
+}
+
+func addEntries(t *testing.T, IMstore *store.InMemoryStore, key string, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if _, err := IMstore.AddStreamEntry(key, []byte(id), []string{"f", id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func pendingIDs(entries []store.PendingEntry) []string {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.Consumer + ":" + entry.ID
+	}
+	return ids
+}
+
+func TestStream_GroupReadAndAck(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	if err := IMstore.StreamGroupCreate("stream", "group", []byte("$"), -1, false); err != store.ErrNoStreamKey {
+		t.Errorf("Expected ErrNoStreamKey, got %v", err)
+	}
+	if err := IMstore.StreamGroupCreate("stream", "group", []byte("$"), -1, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := IMstore.StreamGroupCreate("stream", "group", []byte("0"), -1, true); err != store.ErrBusyGroup {
+		t.Errorf("Expected ErrBusyGroup, got %v", err)
+	}
+	addEntries(t, IMstore, "stream", "1-1", "2-1", "3-1")
+
+	read := func(consumer, id string, count int) []string {
+		t.Helper()
+		entries, _, err := IMstore.StreamReadGroup("group", consumer, []string{"stream"}, [][]byte{[]byte(id)}, count, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entryIDs(entries[0])
+	}
+	if got := read("alice", ">", 2); !slices.Equal(got, []string{"1-1", "2-1"}) {
+		t.Errorf("Expected alice to read 1-1 and 2-1, got %v", got)
+	}
+	if got := read("bob", ">", 0); !slices.Equal(got, []string{"3-1"}) {
+		t.Errorf("Expected bob to read 3-1, got %v", got)
+	}
+	if got := read("bob", ">", 0); len(got) != 0 {
+		t.Errorf("Expected no new entries, got %v", got)
+	}
+	if got := read("alice", "1-1", 0); !slices.Equal(got, []string{"2-1"}) {
+		t.Errorf("Expected the history of alice past 1-1, got %v", got)
+	}
+
+	summary, _ := IMstore.StreamPendingSummary("stream", "group")
+	want := store.PendingSummary{Count: 3, First: "1-1", Last: "3-1", Consumers: []store.ConsumerPending{{"alice", 2}, {"bob", 1}}}
+	if !reflect.DeepEqual(summary, want) {
+		t.Errorf("Expected summary %+v, got %+v", want, summary)
+	}
+	if acked, _ := IMstore.StreamAck("stream", "group", [][]byte{[]byte("1-1"), []byte("1-1"), []byte("9-9")}); acked != 1 {
+		t.Errorf("Expected 1 entry acknowledged, got %d", acked)
+	}
+	pending, _ := IMstore.StreamPendingRange("stream", "group", []byte("-"), []byte("+"), 10, "", 0)
+	if got := pendingIDs(pending); !slices.Equal(got, []string{"alice:2-1", "bob:3-1"}) {
+		t.Errorf("Expected the pending entries of alice and bob, got %v", got)
+	}
+	if pending[0].DeliveryCount != 2 {
+		t.Errorf("Expected 2-1 to be delivered twice, got %d", pending[0].DeliveryCount)
+	}
+
+	// Rewinding the group delivers the entries again, to the new reader.
+	if err := IMstore.StreamGroupSetID("stream", "group", []byte("0"), -1); err != nil {
+		t.Fatal(err)
+	}
+	if got := read("carol", ">", 0); !slices.Equal(got, []string{"1-1", "2-1", "3-1"}) {
+		t.Errorf("Expected carol to read every entry, got %v", got)
+	}
+	pending, _ = IMstore.StreamPendingRange("stream", "group", []byte("-"), []byte("+"), 10, "", 0)
+	if got := pendingIDs(pending); !slices.Equal(got, []string{"carol:1-1", "carol:2-1", "carol:3-1"}) {
+		t.Errorf("Expected carol to own every entry, got %v", got)
+	}
+	if n, _ := IMstore.StreamDeleteConsumer("stream", "group", "carol"); n != 3 {
+		t.Errorf("Expected carol to have 3 pending entries, got %d", n)
+	}
+	if summary, _ := IMstore.StreamPendingSummary("stream", "group"); summary.Count != 0 {
+		t.Errorf("Expected no pending entries, got %d", summary.Count)
+	}
+
+	_, _, err := IMstore.StreamReadGroup("missing", "alice", []string{"stream"}, [][]byte{[]byte(">")}, 0, false)
+	if err == nil {
+		t.Error("Expected an error for a missing group")
+	}
+}
+
+func TestStream_Claim(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.StreamGroupCreate("stream", "group", []byte("0"), -1, true)
+	addEntries(t, IMstore, "stream", "1-1", "2-1", "3-1", "4-1")
+	IMstore.StreamReadGroup("group", "alice", []string{"stream"}, [][]byte{[]byte(">")}, 3, false)
+
+	now := int64(1 << 40)
+	claim := func(consumer string, minIdle int64, opts store.StreamClaimOptions, ids ...string) []string {
+		t.Helper()
+		args := make([][]byte, len(ids))
+		for i, id := range ids {
+			args[i] = []byte(id)
+		}
+		entries, _, err := IMstore.StreamClaim("stream", "group", consumer, minIdle, args, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entryIDs(entries)
+	}
+	recent := store.StreamClaimOptions{DeliveryTime: now, RetryCount: -1}
+	if got := claim("bob", 1_000_000, recent, "1-1", "2-1"); len(got) != 0 {
+		t.Errorf("Expected entries delivered right now not to be claimed, got %v", got)
+	}
+	if got := claim("bob", 0, recent, "1-1", "4-1"); !slices.Equal(got, []string{"1-1"}) {
+		t.Errorf("Expected only the pending entry to be claimed, got %v", got)
+	}
+	forced := store.StreamClaimOptions{DeliveryTime: now, RetryCount: 7, Force: true, LastID: []byte("4-1")}
+	if got := claim("bob", 0, forced, "4-1", "5-1"); !slices.Equal(got, []string{"4-1"}) {
+		t.Errorf("Expected FORCE to claim the existing entry only, got %v", got)
+	}
+	pending, _ := IMstore.StreamPendingRange("stream", "group", []byte("-"), []byte("+"), 10, "bob", 0)
+	if got := pendingIDs(pending); !slices.Equal(got, []string{"bob:1-1", "bob:4-1"}) {
+		t.Errorf("Expected bob to own 1-1 and 4-1, got %v", got)
+	}
+	if pending[0].DeliveryCount != 2 || pending[1].DeliveryCount != 7 {
+		t.Errorf("Expected delivery counts 2 and 7, got %d and %d", pending[0].DeliveryCount, pending[1].DeliveryCount)
+	}
+	entries, _, _ := IMstore.StreamReadGroup("group", "carol", []string{"stream"}, [][]byte{[]byte(">")}, 0, false)
+	if len(entries[0]) != 0 {
+		t.Errorf("Expected LASTID to move the group past 4-1, got %v", entryIDs(entries[0]))
+	}
+
+	// XAUTOCLAIM scans the pending entries list in order from the cursor.
+	next, claimed, _, err := IMstore.StreamAutoClaim("stream", "group", "dave", 0, []byte("-"), 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "3-1" || !slices.Equal(entryIDs(claimed), []string{"1-1", "2-1"}) {
+		t.Errorf("Expected 1-1 and 2-1 with cursor 3-1, got %v and %s", entryIDs(claimed), next)
+	}
+	next, claimed, _, _ = IMstore.StreamAutoClaim("stream", "group", "dave", 0, []byte(next), 2, true)
+	if next != "0-0" || !slices.Equal(entryIDs(claimed), []string{"3-1", "4-1"}) {
+		t.Errorf("Expected 3-1 and 4-1 with cursor 0-0, got %v and %s", entryIDs(claimed), next)
+	}
+}
+
+func TestStream_ExportAndLoad(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.SetStream("stream")
+	// Enough entries for several nodes, with sequence numbers that go back
+	// from the first entry of a node and fields that change.
+	var ids []string
+	for i := 1; i <= 250; i++ {
+		id := fmt.Sprintf("%d-%d", 1_700_000_000_000+i, i*7919%1000)
+		fields := []string{"f", id}
+		if i%7 == 0 {
+			fields = []string{"other", "-12", "n", "123456789012"}
+		}
+		if _, err := IMstore.AddStreamEntry("stream", []byte(id), fields); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	IMstore.StreamGroupCreate("stream", "group", []byte("0"), -1, false)
+	IMstore.StreamGroupCreate("stream", "empty", []byte("$"), -1, false)
+	IMstore.StreamReadGroup("group", "alice", []string{"stream"}, [][]byte{[]byte(">")}, 3, false)
+	IMstore.StreamReadGroup("group", "bob", []string{"stream"}, [][]byte{[]byte(">")}, 2, false)
+	IMstore.StreamCreateConsumer("stream", "group", "idle")
+
+	var buf bytes.Buffer
+	for _, entry := range IMstore.Export() {
+		if err := persistence.WriteKeyValue(&buf, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entry, err := persistence.ReadKeyValue(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := store.NewInMemoryStore()
+	loaded.Load([]persistence.Entry{entry})
+
+	original, _ := IMstore.Range("stream", []byte("-"), []byte("+"), 0)
+	entries, _ := loaded.Range("stream", []byte("-"), []byte("+"), 0)
+	if !reflect.DeepEqual(entries, original) || len(entries) != len(ids) {
+		t.Errorf("Expected %d entries to survive a round trip, got %d", len(ids), len(entries))
+	}
+	for _, group := range []string{"group", "empty"} {
+		want, _ := IMstore.StreamPendingRange("stream", group, []byte("-"), []byte("+"), 10, "", 0)
+		got, _ := loaded.StreamPendingRange("stream", group, []byte("-"), []byte("+"), 10, "", 0)
+		if !slices.Equal(pendingIDs(got), pendingIDs(want)) {
+			t.Errorf("Expected the pending entries of %s to survive a round trip, got %v", group, pendingIDs(got))
+		}
+	}
+	if created, _ := loaded.StreamCreateConsumer("stream", "group", "idle"); created {
+		t.Error("Expected the consumer without pending entries to survive a round trip")
+	}
+	next, _, _ := loaded.StreamReadGroup("group", "carol", []string{"stream"}, [][]byte{[]byte(">")}, 1, false)
+	if got := entryIDs(next[0]); !slices.Equal(got, ids[5:6]) {
+		t.Errorf("Expected the group to resume after the delivered entries, got %v", got)
+	}
+	if id, _ := loaded.GetStreamLastEntryID("stream"); string(id) != ids[len(ids)-1] {
+		t.Errorf("Expected the last ID to survive a round trip, got %s", id)
+	}
+}
+
+func TestStream_InfoAndLag(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	addEntries(t, IMstore, "stream", "1-0", "2-0", "3-0", "4-0", "5-0")
+	IMstore.StreamGroupCreate("stream", "group", []byte("0"), -1, false)
+	IMstore.StreamCreateConsumer("stream", "group", "idle")
+
+	groupInfo := func() store.GroupInfo {
+		t.Helper()
+		groups, err := IMstore.StreamGroupsInfo("stream")
+		if err != nil || len(groups) != 1 {
+			t.Fatalf("Expected one group, got %v, %v", groups, err)
+		}
+		return groups[0]
+	}
+	read := func(count int) {
+		t.Helper()
+		if _, _, err := IMstore.StreamReadGroup("group", "reader", []string{"stream"}, [][]byte{[]byte(">")}, count, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if g := groupInfo(); g.EntriesRead != -1 || g.Lag != 5 {
+		t.Errorf("Expected an unknown read count and a lag of 5, got %d and %d", g.EntriesRead, g.Lag)
+	}
+	read(2)
+	if g := groupInfo(); g.EntriesRead != 2 || g.Lag != 3 || g.Pending != 2 {
+		t.Errorf("Expected 2 entries read and pending with a lag of 3, got %+v", g)
+	}
+	// A deleted entry ahead of the group makes the lag unknown until the
+	// group reads past it.
+	IMstore.StreamDelete("stream", [][]byte{[]byte("4-0")})
+	if g := groupInfo(); g.Lag != -1 {
+		t.Errorf("Expected an unknown lag, got %d", g.Lag)
+	}
+	read(0)
+	if g := groupInfo(); g.EntriesRead != 5 || g.Lag != 0 || g.LastDeliveredID != "5-0" {
+		t.Errorf("Expected 5 entries read and no lag, got %+v", g)
+	}
+
+	info, err := IMstore.StreamInfo("stream", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Length != 4 || info.RadixTreeKeys != 4 || info.RadixTreeNodes < 5 || info.EntriesAdded != 5 ||
+		info.MaxDeletedID != "4-0" || info.RecordedFirstID != "1-0" || info.LastGeneratedID != "5-0" ||
+		info.FirstEntry.ID != "1-0" || info.LastEntry.ID != "5-0" || len(info.Groups) != 1 {
+		t.Errorf("Unexpected stream info %+v", info)
+	}
+	full, _ := IMstore.StreamInfo("stream", true, 2)
+	if got := entryIDs(full.Entries); !slices.Equal(got, []string{"1-0", "2-0"}) {
+		t.Errorf("Expected the first 2 entries, got %v", got)
+	}
+	if g := full.Groups[0]; len(g.PendingEntries) != 2 || len(g.Consumers[1].PendingEntries) != 2 {
+		t.Errorf("Expected COUNT to cap the pending entries, got %+v", g)
+	}
+
+	consumers, _ := IMstore.StreamConsumersInfo("stream", "group")
+	if len(consumers) != 2 || consumers[0].Name != "idle" || consumers[0].Inactive != -1 ||
+		consumers[1].Pending != 4 || consumers[1].Inactive < 0 {
+		t.Errorf("Unexpected consumers %+v", consumers)
+	}
+	if _, err := IMstore.StreamConsumersInfo("stream", "missing"); err == nil {
+		t.Error("Expected an error for a missing group")
+	}
+	if _, err := IMstore.StreamInfo("missing", false, 0); err != store.ErrNoSuchKey {
+		t.Errorf("Expected ErrNoSuchKey, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package store
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"time"
+)
+
+// lookupString returns the string stored at key. It fails with ErrWrongType
+// when the key holds another type. The caller must hold the lock.
+func (s *InMemoryStore) lookupString(key string) (Item, []byte, bool, error) {
+	item, ok := s.lookup(key)
+	if !ok {
+		return Item{}, nil, false, nil
+	}
+	str, ok := item.value.(StringValue)
+	if !ok {
+		return Item{}, nil, false, ErrWrongType
+	}
+	return item, str.data, true, nil
+}
+
+// MGet returns the values of the given keys, with nil for keys that are
+// missing or do not hold a string.
+func (s *InMemoryStore) MGet(keys ...string) [][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		_, data, ok, _ := s.lookupString(key)
+		if ok && data == nil {
+			data = []byte{}
+		}
+		values[i] = data
+	}
+	return values
+}
+
+// MSet atomically sets keys[i] to values[i], clearing any expiry. With nx
+// set, nothing is stored if any of the keys exists. It reports whether the
+// values were stored.
+func (s *InMemoryStore) MSet(keys []string, values [][]byte, nx bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if nx {
+		for _, key := range keys {
+			if _, ok := s.lookup(key); ok {
+				return false
+			}
+		}
+	}
+	for i, key := range keys {
+		s.setItem(key, Item{value: StringValue{data: bytes.Clone(values[i])}})
+	}
+	return true
+}
+
+// Append appends value to the string at key, creating it if needed, and
+// returns the new length.
+func (s *InMemoryStore) Append(key string, value []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, data, _, err := s.lookupString(key)
+	if err != nil {
+		return 0, err
+	}
+	// Always copy so values handed out by Get are never modified.
+	data = append(bytes.Clone(data), value...)
+	item.value = StringValue{data: data}
+	s.setItem(key, item)
+	return len(data), nil
+}
+
+// StrLen returns the length of the string at key, or 0 if it does not exist.
+func (s *InMemoryStore) StrLen(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, data, _, err := s.lookupString(key)
+	return len(data), err
+}
+
+// GetRange returns the substring of the string at key between the offsets
+// start and end, both inclusive. Negative offsets count from the end.
+func (s *InMemoryStore) GetRange(key string, start, end int64) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, data, _, err := s.lookupString(key)
+	if err != nil {
+		return nil, err
+	}
+	length := int64(len(data))
+	if start < 0 && end < 0 && start > end {
+		return []byte{}, nil
+	}
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if end < 0 {
+		end = max(length+end, 0)
+	}
+	end = min(end, length-1)
+	if start > end || length == 0 {
+		return []byte{}, nil
+	}
+	return bytes.Clone(data[start : end+1]), nil
+}
+
+// SetRange overwrites the string at key starting at offset, padding it with
+// zero bytes if needed, and returns the new length. An empty value does not
+// create the key.
+func (s *InMemoryStore) SetRange(key string, offset int, value []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, data, exists, err := s.lookupString(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) == 0 {
+		return len(data), nil
+	}
+	size := max(len(data), offset+len(value))
+	updated := make([]byte, size)
+	copy(updated, data)
+	copy(updated[offset:], value)
+	if !exists {
+		item = Item{}
+	}
+	item.value = StringValue{data: updated}
+	s.setItem(key, item)
+	return size, nil
+}
+
+// GetDel returns the string at key and deletes it.
+func (s *InMemoryStore) GetDel(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, data, ok, err := s.lookupString(key)
+	if !ok {
+		return nil, false, err
+	}
+	s.deleteItem(key)
+	return data, true, nil
+}
+
+// GetEx returns the string at key. A positive expireAt sets its expiry to
+// that unix time in milliseconds, deleting the key if it is in the past, and
+// persist removes its expiry.
+func (s *InMemoryStore) GetEx(key string, expireAt int64, persist bool) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, data, ok, err := s.lookupString(key)
+	if !ok {
+		return nil, false, err
+	}
+	switch {
+	case expireAt > 0 && expireAt <= time.Now().UnixMilli():
+		s.deleteItem(key)
+	case expireAt > 0:
+		item.expiry = expireAt
+		s.setItem(key, item)
+	case persist:
+		item.expiry = 0
+		s.setItem(key, item)
+	}
+	return data, true, nil
+}
+
+// IncrBy atomically adds delta to the integer stored at key, treating a
+// missing key as 0, and returns the new value. The expiry of the key is kept.
+func (s *InMemoryStore) IncrBy(key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, data, exists, err := s.lookupString(key)
+	if err != nil {
+		return 0, err
+	}
+	var value int64
+	if exists {
+		value, err = strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+	if (delta < 0 && value < math.MinInt64-delta) || (delta > 0 && value > math.MaxInt64-delta) {
+		return 0, ErrOverflow
+	}
+	value += delta
+	item.value = StringValue{data: strconv.AppendInt(nil, value, 10)}
+	s.setItem(key, item)
+	return value, nil
+}
+
+// IncrByFloat atomically adds delta to the number stored at key, treating a
+// missing key as 0, and returns the new value as stored. The expiry of the
+// key is kept.
+func (s *InMemoryStore) IncrByFloat(key string, delta float64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, data, exists, err := s.lookupString(key)
+	if err != nil {
+		return nil, err
+	}
+	var value float64
+	if exists {
+		value, err = strconv.ParseFloat(string(data), 64)
+		if err != nil || math.IsNaN(value) {
+			return nil, ErrNotFloat
+		}
+	}
+	value += delta
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, ErrNaNOrInf
+	}
+	// Like Redis, use a human friendly notation without exponent.
+	data = strconv.AppendFloat(nil, value, 'f', -1, 64)
+	item.value = StringValue{data: data}
+	s.setItem(key, item)
+	return data, nil
+}
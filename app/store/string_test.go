@@ -0,0 +1,165 @@
+package store_test
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+func TestStore_MSetAndMGet(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.Set("expiring", []byte("old"), 60000)
+
+	if !IMstore.MSet([]string{"a", "empty", "expiring"}, [][]byte{[]byte("1"), {}, []byte("new")}, false) {
+		t.Fatal("Expected MSET to succeed")
+	}
+	if expiry, _ := IMstore.ExpireTime("expiring"); expiry != 0 {
+		t.Errorf("Expected MSET to clear the expiry, got %d", expiry)
+	}
+	if IMstore.MSet([]string{"b", "a"}, [][]byte{[]byte("2"), []byte("2")}, true) {
+		t.Error("Expected MSETNX to fail when a key exists")
+	}
+	if IMstore.Exists("b") != 0 {
+		t.Error("Expected a failed MSETNX to set nothing")
+	}
+
+	IMstore.SetStream("stream")
+	values := IMstore.MGet("a", "empty", "missing", "stream")
+	if string(values[0]) != "1" || values[1] == nil || len(values[1]) != 0 || values[2] != nil || values[3] != nil {
+		t.Errorf("Unexpected MGET result %q", values)
+	}
+}
+
+func TestStore_StringRanges(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+
+	if length, _ := IMstore.Append("key", []byte("Hello")); length != 5 {
+		t.Errorf("Expected length 5, got %d", length)
+	}
+	value, _, _ := IMstore.Get("key")
+	if length, _ := IMstore.Append("key", []byte(" World")); length != 11 {
+		t.Errorf("Expected length 11, got %d", length)
+	}
+	if string(value) != "Hello" {
+		t.Errorf("Expected APPEND to leave earlier values untouched, got %q", value)
+	}
+
+	for _, tt := range []struct {
+		start, end int64
+		want       string
+	}{
+		{0, 4, "Hello"},
+		{-5, -1, "World"},
+		{0, -1, "Hello World"},
+		{6, 100, "World"},
+		{-1, -5, ""},
+		{20, 30, ""},
+	} {
+		if got, _ := IMstore.GetRange("key", tt.start, tt.end); string(got) != tt.want {
+			t.Errorf("GETRANGE %d %d: expected %q, got %q", tt.start, tt.end, tt.want, got)
+		}
+	}
+
+	if length, _ := IMstore.SetRange("padded", 3, []byte("ab")); length != 5 {
+		t.Errorf("Expected length 5, got %d", length)
+	}
+	if value, _, _ := IMstore.Get("padded"); string(value) != "\x00\x00\x00ab" {
+		t.Errorf("Expected zero padding, got %q", value)
+	}
+	if length, _ := IMstore.SetRange("missing", 3, nil); length != 0 || IMstore.Exists("missing") != 0 {
+		t.Error("Expected an empty SETRANGE not to create the key")
+	}
+
+	IMstore.SetStream("stream")
+	if _, err := IMstore.StrLen("stream"); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestStore_GetDelAndGetEx(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.Set("key", []byte("value"), 0)
+
+	if value, ok, _ := IMstore.GetEx("key", time.Now().Add(time.Hour).UnixMilli(), false); !ok || string(value) != "value" {
+		t.Errorf("Expected GETEX to return the value, got %q", value)
+	}
+	if expiry, _ := IMstore.ExpireTime("key"); expiry == 0 {
+		t.Error("Expected GETEX to set the expiry")
+	}
+	IMstore.GetEx("key", 0, true)
+	if expiry, _ := IMstore.ExpireTime("key"); expiry != 0 {
+		t.Error("Expected GETEX PERSIST to remove the expiry")
+	}
+
+	if value, ok, _ := IMstore.GetDel("key"); !ok || string(value) != "value" {
+		t.Errorf("Expected GETDEL to return the value, got %q", value)
+	}
+	if _, ok, _ := IMstore.GetDel("key"); ok {
+		t.Error("Expected GETDEL to delete the key")
+	}
+
+	IMstore.ListPush("list", [][]byte{[]byte("a")}, false, false)
+	if _, _, err := IMstore.Get("list"); err != store.ErrWrongType {
+		t.Errorf("Expected GET on a list to fail with ErrWrongType, got %v", err)
+	}
+	if _, _, err := IMstore.GetDel("list"); err != store.ErrWrongType {
+		t.Errorf("Expected GETDEL on a list to fail with ErrWrongType, got %v", err)
+	}
+}
+
+func TestStore_IncrByIsAtomic(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.Set("counter", []byte("0"), 60000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				IMstore.IncrBy("counter", 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _, _ := IMstore.Get("counter"); string(value) != "1000" {
+		t.Errorf("Expected 1000 after concurrent increments, got %s", value)
+	}
+	if expiry, _ := IMstore.ExpireTime("counter"); expiry == 0 {
+		t.Error("Expected INCRBY to keep the expiry")
+	}
+
+	IMstore.Set("max", []byte("9223372036854775807"), 0)
+	if _, err := IMstore.IncrBy("max", 1); err != store.ErrOverflow {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+	IMstore.Set("text", []byte("abc"), 0)
+	if _, err := IMstore.IncrBy("text", 1); err != store.ErrNotInteger {
+		t.Errorf("Expected ErrNotInteger, got %v", err)
+	}
+}
+
+func TestStore_IncrByFloat(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.Set("float", []byte("10.50"), 0)
+
+	for _, tt := range []struct {
+		delta float64
+		want  string
+	}{
+		{0.1, "10.6"},
+		{-5, "5.6"},
+		{5e3, "5005.6"},
+	} {
+		if value, err := IMstore.IncrByFloat("float", tt.delta); err != nil || string(value) != tt.want {
+			t.Errorf("INCRBYFLOAT %v: expected %s, got %s, %v", tt.delta, tt.want, value, err)
+		}
+	}
+	if _, err := IMstore.IncrByFloat("float", math.Inf(1)); err != store.ErrNaNOrInf {
+		t.Errorf("Expected ErrNaNOrInf, got %v", err)
+	}
+}
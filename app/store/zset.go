@@ -0,0 +1,857 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+)
+
+const (
+	zskiplistMaxLevel = 32
+	// zskiplistP is the probability that a node of level n also has level
+	// n+1.
+	zskiplistP = 0.25
+)
+
+var ErrZSetNaN = errors.New("ERR resulting score is not a number (NaN)")
+
+// ZAggregate selects how ZAlgebraStore combines the scores of a member found
+// in several inputs.
+type ZAggregate int
+
+const (
+	ZAggregateSum ZAggregate = iota
+	ZAggregateMin
+	ZAggregateMax
+)
+
+// ZRangeBy selects how a ZRangeQuery interprets its range.
+type ZRangeBy int
+
+const (
+	ZRangeByRank ZRangeBy = iota
+	ZRangeByScore
+	ZRangeByLex
+)
+
+// ScoreMember is a member of a sorted set and its score.
+type ScoreMember struct {
+	Member string
+	Score  float64
+}
+
+// zskiplistNode is a member of the skiplist. The span of each level is the
+// number of nodes its forward pointer skips, which makes ranks O(log n).
+type zskiplistNode struct {
+	member   string
+	score    float64
+	backward *zskiplistNode
+	level    []zskiplistLevel
+}
+
+type zskiplistLevel struct {
+	forward *zskiplistNode
+	span    int
+}
+
+// zskiplist keeps the members of a sorted set ordered by score, then by
+// member, as in Redis.
+type zskiplist struct {
+	head   *zskiplistNode
+	tail   *zskiplistNode
+	length int
+	level  int
+}
+
+func newZskiplist() *zskiplist {
+	return &zskiplist{
+		head:  &zskiplistNode{level: make([]zskiplistLevel, zskiplistMaxLevel)},
+		level: 1,
+	}
+}
+
+func randomZskiplistLevel() int {
+	level := 1
+	for level < zskiplistMaxLevel && rand.Float64() < zskiplistP {
+		level++
+	}
+	return level
+}
+
+// before reports whether n sorts before score and member.
+func (n *zskiplistNode) before(score float64, member string) bool {
+	return n.score < score || n.score == score && n.member < member
+}
+
+// insert adds a member that is not in the skiplist yet.
+func (zsl *zskiplist) insert(score float64, member string) {
+	var update [zskiplistMaxLevel]*zskiplistNode
+	var rank [zskiplistMaxLevel]int
+	x := zsl.head
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i < zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	level := randomZskiplistLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.head
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = &zskiplistNode{member: member, score: score, level: make([]zskiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+	if update[0] != zsl.head {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+}
+
+// delete removes a member and reports whether it was found.
+func (zsl *zskiplist) delete(score float64, member string) bool {
+	var update [zskiplistMaxLevel]*zskiplistNode
+	x := zsl.head
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.head.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+	return true
+}
+
+// rank returns the 1-based rank of a member, or 0 if it is not found.
+func (zsl *zskiplist) rank(score float64, member string) int {
+	rank := 0
+	x := zsl.head
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !(score < x.level[i].forward.score ||
+			score == x.level[i].forward.score && member < x.level[i].forward.member) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != zsl.head && x.score == score && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node with the 1-based rank, or nil if it is out of
+// range.
+func (zsl *zskiplist) byRank(rank int) *zskiplistNode {
+	traversed := 0
+	x := zsl.head
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank && x != zsl.head {
+			return x
+		}
+	}
+	return nil
+}
+
+// zrangeSpec is a range of a sorted set, by score or by member.
+type zrangeSpec interface {
+	// gteMin reports whether n is above the start of the range.
+	gteMin(n *zskiplistNode) bool
+	// lteMax reports whether n is below the end of the range.
+	lteMax(n *zskiplistNode) bool
+	empty() bool
+}
+
+// ScoreRange is a range of scores, each end of which may be excluded.
+type ScoreRange struct {
+	Min, Max                   float64
+	MinExclusive, MaxExclusive bool
+}
+
+func (r ScoreRange) gteMin(n *zskiplistNode) bool {
+	if r.MinExclusive {
+		return n.score > r.Min
+	}
+	return n.score >= r.Min
+}
+
+func (r ScoreRange) lteMax(n *zskiplistNode) bool {
+	if r.MaxExclusive {
+		return n.score < r.Max
+	}
+	return n.score <= r.Max
+}
+
+func (r ScoreRange) empty() bool {
+	return r.Min > r.Max || r.Min == r.Max && (r.MinExclusive || r.MaxExclusive)
+}
+
+// LexBound is an end of a LexRange. Inf is -1 for the bound below every
+// member and 1 for the bound above every member, in which case Value is
+// ignored.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int
+}
+
+// compare returns the order of member relative to the bound.
+func (b LexBound) compare(member string) int {
+	if b.Inf != 0 {
+		return -b.Inf
+	}
+	return strings.Compare(member, b.Value)
+}
+
+// LexRange is a range of members, meaningful when all the members of the
+// sorted set have the same score.
+type LexRange struct {
+	Min, Max LexBound
+}
+
+func (r LexRange) gteMin(n *zskiplistNode) bool {
+	c := r.Min.compare(n.member)
+	return c > 0 || c == 0 && !r.Min.Exclusive
+}
+
+func (r LexRange) lteMax(n *zskiplistNode) bool {
+	c := r.Max.compare(n.member)
+	return c < 0 || c == 0 && !r.Max.Exclusive
+}
+
+func (r LexRange) empty() bool {
+	if r.Min.Inf > 0 || r.Max.Inf < 0 {
+		return true
+	}
+	if r.Min.Inf < 0 || r.Max.Inf > 0 {
+		return false
+	}
+	c := strings.Compare(r.Min.Value, r.Max.Value)
+	return c > 0 || c == 0 && (r.Min.Exclusive || r.Max.Exclusive)
+}
+
+// rangeRanks returns the 1-based ranks of the first and last members in r.
+func (zsl *zskiplist) rangeRanks(r zrangeSpec) (int, int, bool) {
+	if r.empty() || zsl.tail == nil || !r.gteMin(zsl.tail) || !r.lteMax(zsl.head.level[0].forward) {
+		return 0, 0, false
+	}
+	// before is the last node below the range and last the last node in it.
+	before, last := zsl.head, zsl.head
+	beforeRank, lastRank := 0, 0
+	for i := zsl.level - 1; i >= 0; i-- {
+		for before.level[i].forward != nil && !r.gteMin(before.level[i].forward) {
+			beforeRank += before.level[i].span
+			before = before.level[i].forward
+		}
+		for last.level[i].forward != nil && r.lteMax(last.level[i].forward) {
+			lastRank += last.level[i].span
+			last = last.level[i].forward
+		}
+	}
+	if beforeRank >= lastRank {
+		return 0, 0, false
+	}
+	return beforeRank + 1, lastRank, true
+}
+
+// SortedSetValue is a set of unique members ordered by score. The dict maps
+// members to their scores and the skiplist keeps them ordered.
+type SortedSetValue struct {
+	dict map[string]float64
+	zsl  *zskiplist
+}
+
+func newSortedSetValue() *SortedSetValue {
+	return &SortedSetValue{dict: make(map[string]float64), zsl: newZskiplist()}
+}
+
+func (_ *SortedSetValue) Type() Type {
+	return SortedSetType
+}
+
+func (v *SortedSetValue) clone() Value {
+	c := newSortedSetValue()
+	for x := v.zsl.head.level[0].forward; x != nil; x = x.level[0].forward {
+		c.set(x.member, x.score)
+	}
+	return c
+}
+
+func (v *SortedSetValue) encoding() string {
+	return "skiplist"
+}
+
+func (v *SortedSetValue) Len() int {
+	return len(v.dict)
+}
+
+// set sets the score of member and reports whether it was added.
+func (v *SortedSetValue) set(member string, score float64) bool {
+	old, ok := v.dict[member]
+	if ok && old == score {
+		return false
+	}
+	if ok {
+		v.zsl.delete(old, member)
+	}
+	v.zsl.insert(score, member)
+	v.dict[member] = score
+	return !ok
+}
+
+// remove removes member and reports whether it was present.
+func (v *SortedSetValue) remove(member string) bool {
+	score, ok := v.dict[member]
+	if !ok {
+		return false
+	}
+	delete(v.dict, member)
+	v.zsl.delete(score, member)
+	return true
+}
+
+// ranks returns the members with 1-based ranks from first to last, in
+// reverse order if rev is set.
+func (v *SortedSetValue) ranks(first, last int, rev bool) []ScoreMember {
+	members := make([]ScoreMember, 0, last-first+1)
+	if rev {
+		for x := v.zsl.byRank(last); len(members) < cap(members); x = x.backward {
+			members = append(members, ScoreMember{x.member, x.score})
+		}
+	} else {
+		for x := v.zsl.byRank(first); len(members) < cap(members); x = x.level[0].forward {
+			members = append(members, ScoreMember{x.member, x.score})
+		}
+	}
+	return members
+}
+
+// ZRangeQuery selects the members returned by ZRange.
+type ZRangeQuery struct {
+	By ZRangeBy
+	// Start and Stop are the 0-based ranks of a ZRangeByRank query, where
+	// negative ranks count from the end.
+	Start, Stop int64
+	Score       ScoreRange
+	Lex         LexRange
+	// Rev reverses the order of the members, ranks counting from the
+	// highest score.
+	Rev bool
+	// Offset and Count limit the members of score and lex queries. A
+	// negative Count returns every member past Offset.
+	Offset, Count int
+}
+
+// query returns the members selected by q.
+func (v *SortedSetValue) query(q ZRangeQuery) []ScoreMember {
+	n := v.Len()
+	if q.By == ZRangeByRank {
+		start, stop, ok := listRange(q.Start, q.Stop, n)
+		if !ok {
+			return nil
+		}
+		if q.Rev {
+			start, stop = n-1-stop, n-1-start
+		}
+		return v.ranks(start+1, stop+1, q.Rev)
+	}
+
+	var spec zrangeSpec = q.Score
+	if q.By == ZRangeByLex {
+		spec = q.Lex
+	}
+	first, last, ok := v.zsl.rangeRanks(spec)
+	if !ok || q.Offset < 0 {
+		return nil
+	}
+	// Skipping to the offset is a jump by rank rather than a walk.
+	if q.Rev {
+		last -= q.Offset
+		if q.Count >= 0 {
+			first = max(first, last-q.Count+1)
+		}
+	} else {
+		first += q.Offset
+		if q.Count >= 0 {
+			last = min(last, first+q.Count-1)
+		}
+	}
+	if first > last {
+		return nil
+	}
+	return v.ranks(first, last, q.Rev)
+}
+
+// lookupSortedSet returns the sorted set stored at key, or nil if it does not
+// exist. It fails with ErrWrongType when the key holds another type. The
+// caller must hold the lock.
+func (s *InMemoryStore) lookupSortedSet(key string) (*SortedSetValue, error) {
+	item, ok := s.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	zset, ok := item.value.(*SortedSetValue)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return zset, nil
+}
+
+// storeSortedSet stores zset at key, replacing any value it held, or deletes
+// key if zset is empty. The caller must hold the lock.
+func (s *InMemoryStore) storeSortedSet(key string, zset *SortedSetValue) {
+	if zset.Len() == 0 {
+		s.deleteItem(key)
+	} else {
+		s.setItem(key, Item{value: zset})
+	}
+}
+
+// ZAddOptions are the flags of ZADD.
+type ZAddOptions struct {
+	// NX only adds new members and XX only updates existing ones.
+	NX, XX bool
+	// GT and LT only update scores that grow or shrink.
+	GT, LT bool
+	// Incr adds the score to the current score of the member.
+	Incr bool
+}
+
+// ZAddResult reports the changes made by ZAdd. Score is the score of the
+// last member once applied, which is only meaningful with the Incr option.
+type ZAddResult struct {
+	Added, Updated int
+	Score          float64
+	Applied        bool
+}
+
+// ZAdd adds members to the sorted set at key, or updates their scores,
+// following opts. The key is created unless opts.XX is set. It fails with
+// ErrZSetNaN, leaving the set unchanged, when an increment yields NaN.
+func (s *InMemoryStore) ZAdd(key string, members []ScoreMember, opts ZAddOptions) (ZAddResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result ZAddResult
+	zset, err := s.lookupSortedSet(key)
+	if err != nil {
+		return result, err
+	}
+	if zset == nil {
+		if opts.XX {
+			return result, nil
+		}
+		// Every member is new, so the set cannot be left empty.
+		zset = newSortedSetValue()
+		s.setItem(key, Item{value: zset})
+	}
+	if opts.Incr {
+		if old, ok := zset.dict[members[0].Member]; ok && !opts.NX {
+			score := old + members[0].Score
+			if math.IsNaN(score) {
+				return result, ErrZSetNaN
+			}
+			members = []ScoreMember{{members[0].Member, score}}
+		}
+	}
+	for _, m := range members {
+		result.Applied = false
+		old, ok := zset.dict[m.Member]
+		switch {
+		case ok && (opts.NX || opts.GT && m.Score <= old || opts.LT && m.Score >= old):
+		case ok:
+			result.Applied = true
+			if m.Score != old {
+				zset.set(m.Member, m.Score)
+				result.Updated++
+			}
+		case !opts.XX:
+			result.Applied = true
+			zset.set(m.Member, m.Score)
+			result.Added++
+		}
+		result.Score = m.Score
+	}
+	return result, nil
+}
+
+// ZRem removes members from the sorted set at key and returns how many of
+// them were present. Sorted sets left empty are deleted.
+func (s *InMemoryStore) ZRem(key string, members [][]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.lookupSortedSet(key)
+	if zset == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		if zset.remove(string(member)) {
+			removed++
+		}
+	}
+	if zset.Len() == 0 {
+		s.deleteItem(key)
+	}
+	return removed, nil
+}
+
+// ZScores returns the scores of members in the sorted set at key, and for
+// each whether it was found.
+func (s *InMemoryStore) ZScores(key string, members [][]byte) ([]float64, []bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.lookupSortedSet(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	scores := make([]float64, len(members))
+	found := make([]bool, len(members))
+	if zset != nil {
+		for i, member := range members {
+			scores[i], found[i] = zset.dict[string(member)]
+		}
+	}
+	return scores, found, nil
+}
+
+// ZCard returns the number of members of the sorted set at key.
+func (s *InMemoryStore) ZCard(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.lookupSortedSet(key)
+	if zset == nil {
+		return 0, err
+	}
+	return zset.Len(), nil
+}
+
+// ZCount returns the number of members of the sorted set at key with a score
+// in r.
+func (s *InMemoryStore) ZCount(key string, r ScoreRange) (int, error) {
+	return s.zcount(key, r)
+}
+
+// ZLexCount returns the number of members of the sorted set at key in r.
+func (s *InMemoryStore) ZLexCount(key string, r LexRange) (int, error) {
+	return s.zcount(key, r)
+}
+
+func (s *InMemoryStore) zcount(key string, r zrangeSpec) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.lookupSortedSet(key)
+	if zset == nil {
+		return 0, err
+	}
+	first, last, ok := zset.zsl.rangeRanks(r)
+	if !ok {
+		return 0, nil
+	}
+	return last - first + 1, nil
+}
+
+// ZRank returns the 0-based rank of member in the sorted set at key, counting
+// from the highest score if rev is set, and its score. It reports whether the
+// member was found.
+func (s *InMemoryStore) ZRank(key string, member []byte, rev bool) (int, float64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.lookupSortedSet(key)
+	if zset == nil {
+		return 0, 0, false, err
+	}
+	score, ok := zset.dict[string(member)]
+	if !ok {
+		return 0, 0, false, nil
+	}
+	rank := zset.zsl.rank(score, string(member)) - 1
+	if rev {
+		rank = zset.Len() - 1 - rank
+	}
+	return rank, score, true, nil
+}
+
+// ZRange returns the members of the sorted set at key selected by q.
+func (s *InMemoryStore) ZRange(key string, q ZRangeQuery) ([]ScoreMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.lookupSortedSet(key)
+	if zset == nil {
+		return nil, err
+	}
+	return zset.query(q), nil
+}
+
+// ZRangeStore stores the members of the sorted set at src selected by q at
+// dst, replacing any value it held, and returns their number. dst is deleted
+// if no member is selected.
+func (s *InMemoryStore) ZRangeStore(dst, src string, q ZRangeQuery) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.lookupSortedSet(src)
+	if err != nil {
+		return 0, err
+	}
+	result := newSortedSetValue()
+	if zset != nil {
+		for _, m := range zset.query(q) {
+			result.set(m.Member, m.Score)
+		}
+	}
+	s.storeSortedSet(dst, result)
+	return result.Len(), nil
+}
+
+// ZPop removes and returns up to count members with the lowest scores from
+// the sorted set at key, or with the highest if max is set. Sorted sets left
+// empty are deleted.
+func (s *InMemoryStore) ZPop(key string, count int, max bool) ([]ScoreMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.lookupSortedSet(key)
+	if zset == nil {
+		return nil, err
+	}
+	count = min(count, zset.Len())
+	if count == 0 {
+		return nil, nil
+	}
+	var popped []ScoreMember
+	if max {
+		popped = zset.ranks(zset.Len()-count+1, zset.Len(), true)
+	} else {
+		popped = zset.ranks(1, count, false)
+	}
+	for _, m := range popped {
+		zset.remove(m.Member)
+	}
+	if zset.Len() == 0 {
+		s.deleteItem(key)
+	}
+	return popped, nil
+}
+
+// ZScan returns at least count members of the sorted set at key starting at
+// cursor, and the cursor to continue from, which is 0 once the scan is
+// complete.
+func (s *InMemoryStore) ZScan(key string, cursor uint64, count int) (uint64, []ScoreMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.lookupSortedSet(key)
+	if zset == nil {
+		return 0, nil, err
+	}
+	next, members := scanElements(func(yield func(string)) {
+		for member := range zset.dict {
+			yield(member)
+		}
+	}, cursor, count)
+	result := make([]ScoreMember, len(members))
+	for i, member := range members {
+		result[i] = ScoreMember{member, zset.dict[member]}
+	}
+	return next, result, nil
+}
+
+// zsetInput is an input of ZAlgebraStore, a sorted set or a set whose
+// members all score 1, scaled by a weight. Both are nil for missing keys.
+type zsetInput struct {
+	zset   *SortedSetValue
+	set    *SetValue
+	weight float64
+}
+
+func (in zsetInput) len() int {
+	if in.zset != nil {
+		return in.zset.Len()
+	}
+	if in.set != nil {
+		return in.set.Len()
+	}
+	return 0
+}
+
+// score returns the weighted score of member and whether it was found.
+func (in zsetInput) score(member string) (float64, bool) {
+	var score float64
+	switch {
+	case in.zset != nil:
+		s, ok := in.zset.dict[member]
+		if !ok {
+			return 0, false
+		}
+		score = s
+	case in.set != nil && in.set.contains(member):
+		score = 1
+	default:
+		return 0, false
+	}
+	return weightScore(score, in.weight), true
+}
+
+// each calls fn with every member and its weighted score.
+func (in zsetInput) each(fn func(member string, score float64)) {
+	switch {
+	case in.zset != nil:
+		for member, score := range in.zset.dict {
+			fn(member, weightScore(score, in.weight))
+		}
+	case in.set != nil:
+		for _, member := range in.set.list() {
+			fn(member, weightScore(1, in.weight))
+		}
+	}
+}
+
+// weightScore scales score by weight, where 0 times an infinite score is 0
+// rather than NaN.
+func weightScore(score, weight float64) float64 {
+	if score = score * weight; math.IsNaN(score) {
+		return 0
+	}
+	return score
+}
+
+func (a ZAggregate) apply(x, y float64) float64 {
+	switch a {
+	case ZAggregateMin:
+		return min(x, y)
+	case ZAggregateMax:
+		return max(x, y)
+	}
+	// The sum of opposite infinities is 0 rather than NaN.
+	if sum := x + y; !math.IsNaN(sum) {
+		return sum
+	}
+	return 0
+}
+
+// ZAlgebraStore stores the union, intersection or difference of the sorted
+// sets at keys at dst, replacing any value it held, and returns its size.
+// Sets are accepted as inputs whose members all score 1, and missing keys
+// are empty. The scores of each input are multiplied by its weight, 1 if
+// weights is nil, and combined with aggregate. The difference keeps the
+// scores of the first input. dst is deleted if the result is empty.
+func (s *InMemoryStore) ZAlgebraStore(op SetOperation, dst string, keys []string, weights []float64, aggregate ZAggregate) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inputs := make([]zsetInput, len(keys))
+	for i, key := range keys {
+		inputs[i].weight = 1
+		if weights != nil {
+			inputs[i].weight = weights[i]
+		}
+		item, ok := s.lookup(key)
+		if !ok {
+			continue
+		}
+		switch value := item.value.(type) {
+		case *SortedSetValue:
+			inputs[i].zset = value
+		case *SetValue:
+			inputs[i].set = value
+		default:
+			return 0, ErrWrongType
+		}
+	}
+
+	result := newSortedSetValue()
+	scores := make(map[string]float64)
+	switch op {
+	case SetOpUnion:
+		for _, in := range inputs {
+			in.each(func(member string, score float64) {
+				if old, ok := scores[member]; ok {
+					score = aggregate.apply(old, score)
+				}
+				scores[member] = score
+			})
+		}
+	case SetOpInter:
+		// Check the members of the smallest input against the others, as
+		// sets do. The order of the others does not change the result.
+		sorted := slices.Clone(inputs)
+		slices.SortStableFunc(sorted, func(a, b zsetInput) int { return a.len() - b.len() })
+		sorted[0].each(func(member string, score float64) {
+			for _, in := range sorted[1:] {
+				other, ok := in.score(member)
+				if !ok {
+					return
+				}
+				score = aggregate.apply(score, other)
+			}
+			scores[member] = score
+		})
+	case SetOpDiff:
+		inputs[0].each(func(member string, score float64) {
+			for _, in := range inputs[1:] {
+				if _, ok := in.score(member); ok {
+					return
+				}
+			}
+			scores[member] = score
+		})
+	}
+	for member, score := range scores {
+		result.set(member, score)
+	}
+	s.storeSortedSet(dst, result)
+	return result.Len(), nil
+}
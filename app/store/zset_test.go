@@ -0,0 +1,288 @@
+package store_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/persistence"
+	"github.com/codecrafters-io/redis-starter-go/app/store"
+)
+
+func zmembers(members []store.ScoreMember) string {
+	list := make([]string, len(members))
+	for i, m := range members {
+		list[i] = fmt.Sprint(m.Member, ":", m.Score)
+	}
+	return strings.Join(list, " ")
+}
+
+func zadd(s *store.InMemoryStore, key string, pairs ...any) store.ZAddResult {
+	var members []store.ScoreMember
+	for i := 0; i < len(pairs); i += 2 {
+		members = append(members, store.ScoreMember{Member: pairs[i+1].(string), Score: float64(pairs[i].(int))})
+	}
+	result, _ := s.ZAdd(key, members, store.ZAddOptions{})
+	return result
+}
+
+func TestZSet_AddOptions(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	add := func(opts store.ZAddOptions, score float64, member string) store.ZAddResult {
+		t.Helper()
+		result, err := IMstore.ZAdd("zset", []store.ScoreMember{{Member: member, Score: score}}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if result := add(store.ZAddOptions{XX: true}, 1, "a"); result.Applied || IMstore.Exists("zset") != 0 {
+		t.Error("Expected XX not to create the key")
+	}
+	if result := add(store.ZAddOptions{}, 5, "a"); result.Added != 1 {
+		t.Errorf("Expected the member to be added, got %+v", result)
+	}
+	if result := add(store.ZAddOptions{NX: true}, 1, "a"); result.Updated != 0 {
+		t.Errorf("Expected NX not to update the score, got %+v", result)
+	}
+	if result := add(store.ZAddOptions{GT: true}, 3, "a"); result.Applied {
+		t.Errorf("Expected GT to reject a lower score, got %+v", result)
+	}
+	if result := add(store.ZAddOptions{LT: true}, 3, "a"); result.Updated != 1 {
+		t.Errorf("Expected LT to accept a lower score, got %+v", result)
+	}
+	if result := add(store.ZAddOptions{Incr: true}, 2.5, "a"); !result.Applied || result.Score != 5.5 {
+		t.Errorf("Expected the score to be incremented to 5.5, got %+v", result)
+	}
+	if result := add(store.ZAddOptions{Incr: true, GT: true}, -1, "a"); result.Applied {
+		t.Errorf("Expected GT to reject a negative increment, got %+v", result)
+	}
+
+	add(store.ZAddOptions{}, math.Inf(1), "inf")
+	if _, err := IMstore.ZAdd("zset", []store.ScoreMember{{Member: "inf", Score: math.Inf(-1)}}, store.ZAddOptions{Incr: true}); err != store.ErrZSetNaN {
+		t.Errorf("Expected ErrZSetNaN, got %v", err)
+	}
+	if scores, found, _ := IMstore.ZScores("zset", fieldArgs("inf", "missing")); !math.IsInf(scores[0], 1) || found[1] {
+		t.Errorf("Expected the failed increment to leave the score unchanged, got %v %v", scores, found)
+	}
+
+	IMstore.Set("string", []byte("value"), 0)
+	if _, err := IMstore.ZAdd("string", []store.ScoreMember{{Member: "a"}}, store.ZAddOptions{}); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+// TestZSet_RanksAndRanges checks ranks, counts and ranges of a sorted set
+// against a sorted slice while members are added, updated and removed.
+func TestZSet_RanksAndRanges(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	rng := rand.New(rand.NewSource(1))
+	model := make(map[string]float64)
+	sorted := func() []store.ScoreMember {
+		var members []store.ScoreMember
+		for member, score := range model {
+			members = append(members, store.ScoreMember{Member: member, Score: score})
+		}
+		slices.SortFunc(members, func(a, b store.ScoreMember) int {
+			if a.Score != b.Score {
+				if a.Score < b.Score {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(a.Member, b.Member)
+		})
+		return members
+	}
+
+	for round := 0; round < 2000; round++ {
+		member := fmt.Sprint("m", rng.Intn(300))
+		if rng.Intn(4) == 0 {
+			IMstore.ZRem("zset", fieldArgs(member))
+			delete(model, member)
+			continue
+		}
+		score := float64(rng.Intn(50))
+		zadd(IMstore, "zset", int(score), member)
+		model[member] = score
+	}
+
+	want := sorted()
+	if card, _ := IMstore.ZCard("zset"); card != len(want) {
+		t.Fatalf("Expected %d members, got %d", len(want), card)
+	}
+	for i, m := range want {
+		rank, score, ok, _ := IMstore.ZRank("zset", []byte(m.Member), false)
+		if !ok || rank != i || score != m.Score {
+			t.Fatalf("Expected %s to have rank %d, got %d", m.Member, i, rank)
+		}
+		rank, _, _, _ = IMstore.ZRank("zset", []byte(m.Member), true)
+		if rank != len(want)-1-i {
+			t.Fatalf("Expected %s to have reverse rank %d, got %d", m.Member, len(want)-1-i, rank)
+		}
+	}
+
+	got, _ := IMstore.ZRange("zset", store.ZRangeQuery{Start: 10, Stop: -10})
+	if zmembers(got) != zmembers(want[10:len(want)-9]) {
+		t.Errorf("Unexpected range by rank %s", zmembers(got))
+	}
+	got, _ = IMstore.ZRange("zset", store.ZRangeQuery{Start: 0, Stop: 4, Rev: true})
+	rev := slices.Clone(want[len(want)-5:])
+	slices.Reverse(rev)
+	if zmembers(got) != zmembers(rev) {
+		t.Errorf("Unexpected reverse range by rank %s", zmembers(got))
+	}
+
+	r := store.ScoreRange{Min: 10, Max: 20, MinExclusive: true}
+	var inRange []store.ScoreMember
+	for _, m := range want {
+		if m.Score > 10 && m.Score <= 20 {
+			inRange = append(inRange, m)
+		}
+	}
+	if count, _ := IMstore.ZCount("zset", r); count != len(inRange) {
+		t.Errorf("Expected %d members in the range, got %d", len(inRange), count)
+	}
+	got, _ = IMstore.ZRange("zset", store.ZRangeQuery{By: store.ZRangeByScore, Score: r, Offset: 3, Count: 5})
+	if zmembers(got) != zmembers(inRange[3:8]) {
+		t.Errorf("Unexpected range by score %s, want %s", zmembers(got), zmembers(inRange[3:8]))
+	}
+	got, _ = IMstore.ZRange("zset", store.ZRangeQuery{By: store.ZRangeByScore, Score: r, Rev: true, Offset: 1, Count: -1})
+	rev = slices.Clone(inRange[:len(inRange)-1])
+	slices.Reverse(rev)
+	if zmembers(got) != zmembers(rev) {
+		t.Errorf("Unexpected reverse range by score %s", zmembers(got))
+	}
+	if count, _ := IMstore.ZCount("zset", store.ScoreRange{Min: 20, Max: 10}); count != 0 {
+		t.Errorf("Expected an empty range, got %d members", count)
+	}
+}
+
+func TestZSet_LexRange(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	zadd(IMstore, "zset", 0, "a", 0, "b", 0, "c", 0, "d", 0, "e")
+
+	tests := []struct {
+		r    store.LexRange
+		want string
+	}{
+		{store.LexRange{Min: store.LexBound{Inf: -1}, Max: store.LexBound{Inf: 1}}, "a b c d e"},
+		{store.LexRange{Min: store.LexBound{Value: "b"}, Max: store.LexBound{Value: "d", Exclusive: true}}, "b c"},
+		{store.LexRange{Min: store.LexBound{Value: "b", Exclusive: true}, Max: store.LexBound{Inf: 1}}, "c d e"},
+		{store.LexRange{Min: store.LexBound{Value: "bb"}, Max: store.LexBound{Value: "cc"}}, "c"},
+		{store.LexRange{Min: store.LexBound{Value: "d"}, Max: store.LexBound{Value: "b"}}, ""},
+		{store.LexRange{Min: store.LexBound{Inf: 1}, Max: store.LexBound{Inf: -1}}, ""},
+	}
+	for _, tt := range tests {
+		members, _ := IMstore.ZRange("zset", store.ZRangeQuery{By: store.ZRangeByLex, Lex: tt.r, Count: -1})
+		var got []string
+		for _, m := range members {
+			got = append(got, m.Member)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("Range %+v = %v, want %s", tt.r, got, tt.want)
+		}
+		if count, _ := IMstore.ZLexCount("zset", tt.r); count != len(got) {
+			t.Errorf("Expected ZLexCount to match the range, got %d", count)
+		}
+	}
+}
+
+func TestZSet_PopAndRangeStore(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	zadd(IMstore, "zset", 1, "a", 2, "b", 3, "c", 4, "d")
+
+	if popped, _ := IMstore.ZPop("zset", 2, false); zmembers(popped) != "a:1 b:2" {
+		t.Errorf("Unexpected minimum members %s", zmembers(popped))
+	}
+	if popped, _ := IMstore.ZPop("zset", 1, true); zmembers(popped) != "d:4" {
+		t.Errorf("Unexpected maximum member %s", zmembers(popped))
+	}
+	if card, _ := IMstore.ZRangeStore("dst", "zset", store.ZRangeQuery{Start: 0, Stop: -1}); card != 1 {
+		t.Errorf("Expected 1 member to be stored, got %d", card)
+	}
+	if popped, _ := IMstore.ZPop("zset", 5, false); zmembers(popped) != "c:3" || IMstore.Exists("zset") != 0 {
+		t.Errorf("Expected the last member to be popped with the key, got %s", zmembers(popped))
+	}
+	if card, _ := IMstore.ZRangeStore("dst", "zset", store.ZRangeQuery{Start: 0, Stop: -1}); card != 0 || IMstore.Exists("dst") != 0 {
+		t.Error("Expected an empty range to delete the destination")
+	}
+}
+
+func TestZSet_Algebra(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	zadd(IMstore, "a", 1, "x", 2, "y", 3, "z")
+	zadd(IMstore, "b", 10, "y", 20, "z", 30, "w")
+	IMstore.SetAdd("set", fieldArgs("z", "w"))
+
+	tests := []struct {
+		op        store.SetOperation
+		keys      []string
+		weights   []float64
+		aggregate store.ZAggregate
+		want      string
+	}{
+		{store.SetOpUnion, []string{"a", "b"}, nil, store.ZAggregateSum, "x:1 y:12 z:23 w:30"},
+		{store.SetOpUnion, []string{"a", "b"}, []float64{2, 1}, store.ZAggregateMax, "x:2 y:10 z:20 w:30"},
+		{store.SetOpInter, []string{"a", "b", "set"}, nil, store.ZAggregateMin, "z:1"},
+		{store.SetOpInter, []string{"a", "missing"}, nil, store.ZAggregateSum, ""},
+		{store.SetOpDiff, []string{"a", "set"}, nil, store.ZAggregateSum, "x:1 y:2"},
+		{store.SetOpUnion, []string{"a"}, []float64{0}, store.ZAggregateSum, "x:0 y:0 z:0"},
+	}
+	for _, tt := range tests {
+		if _, err := IMstore.ZAlgebraStore(tt.op, "dst", tt.keys, tt.weights, tt.aggregate); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := IMstore.ZRange("dst", store.ZRangeQuery{Start: 0, Stop: -1})
+		if zmembers(got) != tt.want {
+			t.Errorf("ZAlgebraStore(%d, %v) = %s, want %s", tt.op, tt.keys, zmembers(got), tt.want)
+		}
+	}
+
+	// 0 times an infinite score is 0 rather than NaN.
+	IMstore.ZAdd("inf", []store.ScoreMember{{Member: "x", Score: math.Inf(1)}}, store.ZAddOptions{})
+	IMstore.ZAlgebraStore(store.SetOpUnion, "dst", []string{"inf"}, []float64{0}, store.ZAggregateSum)
+	if got, _ := IMstore.ZRange("dst", store.ZRangeQuery{Start: 0, Stop: -1}); zmembers(got) != "x:0" {
+		t.Errorf("Expected a weight of 0 to zero an infinite score, got %s", zmembers(got))
+	}
+
+	IMstore.Set("string", []byte("value"), 0)
+	if _, err := IMstore.ZAlgebraStore(store.SetOpUnion, "dst", []string{"a", "string"}, nil, store.ZAggregateSum); err != store.ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestZSet_ExportAndLoad(t *testing.T) {
+	IMstore := store.NewInMemoryStore()
+	IMstore.ZAdd("zset", []store.ScoreMember{
+		{Member: "a", Score: 1.5},
+		{Member: "b", Score: math.Inf(-1)},
+		{Member: "", Score: 0},
+	}, store.ZAddOptions{})
+
+	var buf bytes.Buffer
+	for _, entry := range IMstore.Export() {
+		if err := persistence.WriteKeyValue(&buf, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entry, err := persistence.ReadKeyValue(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := store.NewInMemoryStore()
+	loaded.Load([]persistence.Entry{entry})
+
+	got, _ := loaded.ZRange("zset", store.ZRangeQuery{Start: 0, Stop: -1})
+	if zmembers(got) != "b:-Inf :0 a:1.5" {
+		t.Errorf("Expected the sorted set to survive a round trip, got %s", zmembers(got))
+	}
+	if got := encoding(loaded, "zset"); got != "skiplist" {
+		t.Errorf("Expected the skiplist encoding, got %s", got)
+	}
+}